@@ -0,0 +1,345 @@
+// Package backup exports torrents of a Transmission daemon to a JSON archive
+// and imports them back, e.g. to an empty daemon after reinstall.
+package backup
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/pborzenkov/go-transmission/transmission"
+)
+
+// Version is the version of archives written by Export.
+const Version = 1
+
+const defaultVerifyPollInterval = 5 * time.Second
+
+// Client is a subset of transmission.Client methods used by Archiver.
+type Client interface {
+	GetTorrents(ctx context.Context, ids transmission.Identifier, fields ...transmission.TorrentField) ([]*transmission.Torrent, error)                       //nolint:lll
+	AddTorrentWithSettings(ctx context.Context, req *transmission.AddTorrentReq, settings *transmission.AddTorrentSettings) (*transmission.NewTorrent, error) //nolint:lll
+	VerifyTorrents(ctx context.Context, ids transmission.Identifier) error
+	StartTorrents(ctx context.Context, ids transmission.Identifier) error
+	SetTorrents(ctx context.Context, ids transmission.Identifier, req *transmission.SetTorrentReq) error
+}
+
+var _ Client = (*transmission.Client)(nil)
+
+// Archive is a versioned set of torrent records.
+type Archive struct {
+	// Version of the archive format
+	Version int `json:"version"`
+	// Creation time of the archive
+	CreatedAt time.Time `json:"createdAt"`
+	// Torrents in queue order
+	Torrents []*Record `json:"torrents"`
+}
+
+// ReadArchive decodes an archive from r.
+func ReadArchive(r io.Reader) (*Archive, error) {
+	var a Archive
+	if err := json.NewDecoder(r).Decode(&a); err != nil {
+		return nil, fmt.Errorf("backup: failed to decode archive: %w", err)
+	}
+	if a.Version != Version {
+		return nil, fmt.Errorf("backup: unsupported archive version %d", a.Version)
+	}
+	return &a, nil
+}
+
+// Item is the outcome of exporting or importing a single torrent.
+type Item struct {
+	// Hash of the torrent
+	Hash transmission.Hash
+	// Name of the torrent
+	Name string
+	// ID of the imported torrent. Not set by Export
+	ID transmission.ID
+	// Indicates that the daemon already had the imported torrent
+	Duplicate bool
+	// Failure, if any
+	Err error
+}
+
+// String implements fmt.Stringer.
+func (i *Item) String() string {
+	s := fmt.Sprintf("torrent %s (%s)", i.Hash, i.Name)
+	switch {
+	case i.Err != nil:
+		s += ": " + i.Err.Error()
+	case i.Duplicate:
+		s += " [duplicate]"
+	}
+	return s
+}
+
+// Result is the outcome of Export or Import.
+type Result struct {
+	// Items in processing order
+	Items []*Item
+}
+
+// Failed returns items that failed.
+func (r *Result) Failed() []*Item {
+	var failed []*Item
+	for _, i := range r.Items {
+		if i.Err != nil {
+			failed = append(failed, i)
+		}
+	}
+	return failed
+}
+
+// Err returns all the failures joined together or nil if all the items
+// succeeded.
+func (r *Result) Err() error {
+	var errs []error
+	for _, i := range r.Failed() {
+		errs = append(errs, errors.New(i.String()))
+	}
+	return errors.Join(errs...)
+}
+
+// ImportOpts controls Import.
+type ImportOpts struct {
+	// Add all the torrents paused, regardless of their recorded state
+	Paused bool
+	// What to do with torrents the daemon already has
+	OnDuplicate transmission.DuplicatePolicy
+	// Verify local data of imported torrents. Verified torrents are added
+	// paused and started once the verification is over, so they don't
+	// download over the existing data
+	Verify bool
+	// Interval between checks of verification progress. If 0, 5 seconds is
+	// used
+	VerifyPollInterval time.Duration
+	// Interval between metadata checks of magnet links. If 0, one second is
+	// used
+	MetadataPollInterval time.Duration
+}
+
+// Archiver exports and imports torrents.
+type Archiver struct {
+	// Transmission client
+	Client Client
+	// Reads .torrent files of exported torrents. If nil, os.ReadFile is
+	// used. Use transmission.WithPathMapping if the daemon runs on another
+	// host or in a container
+	ReadFile func(path string) ([]byte, error)
+	// Called after each processed torrent with the number of processed and
+	// total torrents
+	OnProgress func(done, total int, item *Item)
+}
+
+func (a *Archiver) progress(done, total int, item *Item) {
+	if a.OnProgress != nil {
+		a.OnProgress(done, total, item)
+	}
+}
+
+// Records returns records of all the torrents in queue order. Torrents
+// with neither readable .torrent file nor magnet link are reported as
+// failed items.
+func (a *Archiver) Records(ctx context.Context) ([]*Record, *Result, error) {
+	if a.Client == nil {
+		return nil, nil, errors.New("backup: client is not set")
+	}
+	torrents, err := a.Client.GetTorrents(ctx, transmission.All(), Fields...)
+	if err != nil {
+		return nil, nil, err
+	}
+	sort.SliceStable(torrents, func(i, j int) bool {
+		return torrents[i].PositionInQueue < torrents[j].PositionInQueue
+	})
+
+	var records []*Record
+	res := new(Result)
+	for i, t := range torrents {
+		if err := ctx.Err(); err != nil {
+			return records, res, err
+		}
+
+		item := &Item{Hash: t.Hash, Name: t.Name}
+		rec, err := a.Record(t)
+		if err != nil {
+			item.Err = err
+		} else {
+			records = append(records, rec)
+		}
+		res.Items = append(res.Items, item)
+		a.progress(i+1, len(torrents), item)
+	}
+	return records, res, nil
+}
+
+// Record returns a record of t, which must have all the Fields populated.
+// The .torrent file is read with ReadFile. If it can't be read, the magnet
+// link is recorded instead.
+func (a *Archiver) Record(t *transmission.Torrent) (*Record, error) {
+	readFile := a.ReadFile
+	if readFile == nil {
+		readFile = os.ReadFile
+	}
+
+	var meta []byte
+	var err error
+	if t.File != "" {
+		meta, err = readFile(t.File)
+	}
+	switch {
+	case len(meta) > 0 || t.MagnetLink != "":
+		return NewRecord(t, meta), nil
+	case err != nil:
+		return nil, fmt.Errorf("backup: failed to read torrent file: %w", err)
+	default:
+		return nil, errors.New("backup: torrent has neither torrent file nor magnet link")
+	}
+}
+
+// Export writes an archive of all the torrents to w. Torrents that can't be
+// exported are reported in the result and skipped.
+func (a *Archiver) Export(ctx context.Context, w io.Writer) (*Result, error) {
+	records, res, err := a.Records(ctx)
+	if err != nil {
+		return res, err
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "\t")
+	if err := enc.Encode(&Archive{Version: Version, CreatedAt: time.Now().UTC(), Torrents: records}); err != nil {
+		return res, fmt.Errorf("backup: failed to encode archive: %w", err)
+	}
+	return res, nil
+}
+
+// Restore adds the torrent described by rec to the daemon.
+func (a *Archiver) Restore(ctx context.Context, rec *Record, opts *ImportOpts) (*transmission.NewTorrent, error) {
+	return a.restore(ctx, rec, opts, true)
+}
+
+// restore adds the torrent described by rec to the daemon, moving it to its
+// recorded queue position if position is true.
+func (a *Archiver) restore(ctx context.Context, rec *Record, opts *ImportOpts, position bool) (*transmission.NewTorrent, error) { //nolint:lll
+	if a.Client == nil {
+		return nil, errors.New("backup: client is not set")
+	}
+	if opts == nil {
+		opts = new(ImportOpts)
+	}
+
+	req, settings, err := rec.AddRequest()
+	if err != nil {
+		return nil, fmt.Errorf("backup: invalid record: %w", err)
+	}
+	if opts.Paused || opts.Verify {
+		req.Paused = transmission.OptBool(true)
+	}
+	req.OnDuplicate = opts.OnDuplicate
+	if !position {
+		settings.Set.PositionInQueue = nil
+	}
+	settings.MetadataPollInterval = opts.MetadataPollInterval
+
+	t, err := a.Client.AddTorrentWithSettings(ctx, req, settings)
+	if err != nil || t.Duplicate || !opts.Verify {
+		return t, err
+	}
+	if err := a.Client.VerifyTorrents(ctx, transmission.IDs(t.ID)); err != nil {
+		return t, fmt.Errorf("backup: failed to verify torrent: %w", err)
+	}
+	if err := a.waitVerified(ctx, t.ID, opts.VerifyPollInterval); err != nil {
+		return t, fmt.Errorf("backup: failed to verify torrent: %w", err)
+	}
+	if rec.Paused || opts.Paused {
+		return t, nil
+	}
+	if err := a.Client.StartTorrents(ctx, transmission.IDs(t.ID)); err != nil {
+		return t, fmt.Errorf("backup: failed to start torrent: %w", err)
+	}
+	return t, nil
+}
+
+// waitVerified waits for the verification of the torrent to finish.
+// Transmission queues the verification before replying to the request, so a
+// torrent that is not being checked is done verifying.
+func (a *Archiver) waitVerified(ctx context.Context, id transmission.ID, interval time.Duration) error {
+	if interval <= 0 {
+		interval = defaultVerifyPollInterval
+	}
+	for {
+		torrents, err := a.Client.GetTorrents(ctx, transmission.IDs(id), transmission.TorrentFieldStatus)
+		if err != nil {
+			return err
+		}
+		if len(torrents) == 0 {
+			return fmt.Errorf("torrent %d disappeared", id)
+		}
+		if s := torrents[0].Status; s != transmission.StatusCheckWait && s != transmission.StatusCheck {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(interval):
+		}
+	}
+}
+
+// Import reads an archive from r and adds the recorded torrents to the daemon
+// in queue order. Every added torrent shifts the queue, so the recorded queue
+// positions are restored once all the torrents are added. Torrents that can't
+// be imported are reported in the result.
+func (a *Archiver) Import(ctx context.Context, r io.Reader, opts *ImportOpts) (*Result, error) {
+	if a.Client == nil {
+		return nil, errors.New("backup: client is not set")
+	}
+	arch, err := ReadArchive(r)
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(arch.Torrents, func(i, j int) bool {
+		return arch.Torrents[i].PositionInQueue < arch.Torrents[j].PositionInQueue
+	})
+
+	res := new(Result)
+	var added []int
+	for i, rec := range arch.Torrents {
+		if err := ctx.Err(); err != nil {
+			return res, err
+		}
+
+		item := &Item{Hash: rec.Hash, Name: rec.Name}
+		t, err := a.restore(ctx, rec, opts, false)
+		if t != nil {
+			item.ID, item.Duplicate = t.ID, t.Duplicate
+			if !t.Duplicate {
+				added = append(added, i)
+			}
+		}
+		item.Err = err
+		res.Items = append(res.Items, item)
+		a.progress(i+1, len(arch.Torrents), item)
+	}
+
+	// Positions are set in ascending order, so that moving a torrent doesn't
+	// shift the ones that are already in place.
+	for _, i := range added {
+		if err := ctx.Err(); err != nil {
+			return res, err
+		}
+
+		item := res.Items[i]
+		req := &transmission.SetTorrentReq{PositionInQueue: transmission.OptInt(arch.Torrents[i].PositionInQueue)}
+		if err := a.Client.SetTorrents(ctx, transmission.IDs(item.ID), req); err != nil {
+			item.Err = errors.Join(item.Err, fmt.Errorf("backup: failed to restore queue position: %w", err))
+		}
+	}
+	return res, nil
+}
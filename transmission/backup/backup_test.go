@@ -0,0 +1,290 @@
+package backup
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pborzenkov/go-transmission/transmission"
+)
+
+type fakeClient struct {
+	mu       sync.Mutex
+	torrents []*transmission.Torrent
+	added    []*Record
+	calls    []string
+	fail     map[string]bool
+	err      error
+}
+
+func (c *fakeClient) GetTorrents(ctx context.Context, ids transmission.Identifier, fields ...transmission.TorrentField) ([]*transmission.Torrent, error) { //nolint:lll
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.err != nil {
+		return nil, c.err
+	}
+	// Torrents are requested by ID while waiting for their verification.
+	if ids, ok := ids.(transmission.IDList); ok {
+		return []*transmission.Torrent{{ID: ids[0].(transmission.ID), Status: transmission.StatusStopped}}, nil
+	}
+	var torrents []*transmission.Torrent
+	for _, t := range c.torrents {
+		copy := *t
+		torrents = append(torrents, &copy)
+	}
+	return torrents, nil
+}
+
+// AddTorrentWithSettings records the request as a record, so that it can be
+// compared with the exported one.
+func (c *fakeClient) AddTorrentWithSettings(ctx context.Context, req *transmission.AddTorrentReq, settings *transmission.AddTorrentSettings) (*transmission.NewTorrent, error) { //nolint:lll
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	rec := &Record{Labels: req.Labels, Priority: *req.Priority, Paused: *req.Paused}
+	if settings.Set.PositionInQueue != nil {
+		rec.PositionInQueue = *settings.Set.PositionInQueue
+	}
+	if req.Meta != nil {
+		meta, err := io.ReadAll(req.Meta)
+		if err != nil {
+			return nil, err
+		}
+		rec.Meta = meta
+		rec.Name = string(meta)
+	}
+	if req.URL != nil {
+		rec.MagnetLink = *req.URL
+		rec.Name = *req.URL
+	}
+	if req.DownloadDirectory != nil {
+		rec.DownloadDirectory = *req.DownloadDirectory
+	}
+	if req.PeerLimit != nil {
+		rec.PeerLimit = *req.PeerLimit
+	}
+	set := settings.Set
+	rec.DownloadRateLimit, rec.DownloadRateLimitEnabled = *set.DownloadRateLimit, *set.DownloadRateLimitEnabled
+	rec.UploadRateLimit, rec.UploadRateLimitEnabled = *set.UploadRateLimit, *set.UploadRateLimitEnabled
+	rec.HonorSessionLimits = *set.HonorSessionLimits
+	rec.IdleSeedingLimit, rec.IdleSeedingLimitMode = *set.IdleSeedingLimit, *set.IdleSeedingLimitMode
+	rec.UploadRatioLimit, rec.UploadRatioLimitMode = *set.UploadRatioLimit, *set.UploadRatioLimitMode
+	if set.TrackerList != nil {
+		rec.Trackers = set.TrackerList.String()
+	}
+	c.calls = append(c.calls, fmt.Sprintf("add %s wanted=%v unwanted=%v high=%v low=%v",
+		rec.Name, set.WantedFiles, set.UnwantedFiles, set.HighPriorityFiles, set.LowPriorityFiles))
+
+	if c.fail[rec.Name] {
+		return nil, errors.New("daemon said no")
+	}
+	c.added = append(c.added, rec)
+	return &transmission.NewTorrent{ID: transmission.ID(len(c.added)), Name: rec.Name}, nil
+}
+
+func (c *fakeClient) VerifyTorrents(ctx context.Context, ids transmission.Identifier) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.calls = append(c.calls, fmt.Sprint("verify ", ids))
+	return nil
+}
+
+func (c *fakeClient) StartTorrents(ctx context.Context, ids transmission.Identifier) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.calls = append(c.calls, fmt.Sprint("start ", ids))
+	return nil
+}
+
+// SetTorrents only supports queue positions, which Import sets separately.
+func (c *fakeClient) SetTorrents(ctx context.Context, ids transmission.Identifier, req *transmission.SetTorrentReq) error { //nolint:lll
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.calls = append(c.calls, fmt.Sprint("queue ", ids, " ", *req.PositionInQueue))
+	id := ids.(transmission.IDList)[0].(transmission.ID)
+	c.added[id-1].PositionInQueue = *req.PositionInQueue
+	return nil
+}
+
+func mustTrackers(t *testing.T, s string) transmission.TrackerList {
+	t.Helper()
+
+	l, err := transmission.ParseTrackerList(s)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return l
+}
+
+func readFile(path string) ([]byte, error) {
+	if name, ok := strings.CutPrefix(path, "/torrents/"); ok {
+		return []byte(name), nil
+	}
+	return nil, fs.ErrNotExist
+}
+
+func describe(items []*Item) []string {
+	var s []string
+	for _, i := range items {
+		s = append(s, i.String())
+	}
+	return s
+}
+
+func TestArchiver_roundTrip(t *testing.T) {
+	src := &fakeClient{
+		torrents: []*transmission.Torrent{
+			{
+				Hash: "h2", Name: "b", File: "/missing/b.torrent", MagnetLink: "magnet:?xt=urn:btih:h2",
+				PositionInQueue: 1, Status: transmission.StatusSeed, Priority: transmission.PriorityHigh,
+				TrackerList: mustTrackers(t, "https://a.example.org/announce\n\nudp://b.example.org:6969"),
+			},
+			{
+				Hash: "h1", Name: "a", File: "/torrents/a", Labels: []string{"movies"}, DownloadDirectory: "/data",
+				Status: transmission.StatusStopped, PeerLimit: 10, Wanted: []bool{true, false, true},
+				Priorities: []transmission.Priority{
+					transmission.PriorityNormal, transmission.PriorityLow, transmission.PriorityHigh,
+				},
+				DownloadRateLimit: 100, DownloadRateLimitEnabled: true, UploadRateLimit: 50,
+				UploadRatioLimit: 2, UploadRatioLimitMode: transmission.LimitLocal,
+			},
+			{Hash: "h3", Name: "c", File: "/missing/c.torrent"},
+		},
+	}
+
+	var progress []string
+	a := &Archiver{
+		Client:   src,
+		ReadFile: readFile,
+		OnProgress: func(done, total int, item *Item) {
+			progress = append(progress, fmt.Sprintf("%d/%d %s", done, total, item.Hash))
+		},
+	}
+
+	var buf bytes.Buffer
+	res, err := a.Export(context.Background(), &buf)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []string{
+		"torrent h3 (c): backup: failed to read torrent file: " + fs.ErrNotExist.Error(),
+	}
+	if got := describe(res.Failed()); !cmp.Equal(want, got) {
+		t.Errorf("unexpected failures, diff = \n%s", cmp.Diff(want, got))
+	}
+	if res.Err() == nil {
+		t.Errorf("expected result to have an error")
+	}
+
+	dst := &fakeClient{}
+	a.Client = dst
+	if res, err = a.Import(context.Background(), &buf, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := res.Err(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	wantProgress := []string{"1/3 h1", "2/3 h3", "3/3 h2", "1/2 h1", "2/2 h2"}
+	if !cmp.Equal(wantProgress, progress) {
+		t.Errorf("unexpected progress, diff = \n%s", cmp.Diff(wantProgress, progress))
+	}
+
+	wantAdded := []*Record{
+		{
+			Name: "a", Meta: []byte("a"), Labels: []string{"movies"}, DownloadDirectory: "/data", Paused: true,
+			PeerLimit: 10, DownloadRateLimit: 100, DownloadRateLimitEnabled: true, UploadRateLimit: 50,
+			UploadRatioLimit: 2, UploadRatioLimitMode: transmission.LimitLocal,
+		},
+		{
+			Name: "magnet:?xt=urn:btih:h2", MagnetLink: "magnet:?xt=urn:btih:h2", Priority: transmission.PriorityHigh,
+			PositionInQueue: 1, Trackers: "https://a.example.org/announce\n\nudp://b.example.org:6969",
+		},
+	}
+	if !cmp.Equal(wantAdded, dst.added) {
+		t.Errorf("unexpected added torrents, diff = \n%s", cmp.Diff(wantAdded, dst.added))
+	}
+	wantCalls := []string{
+		"add a wanted=[0 2] unwanted=[1] high=[2] low=[1]",
+		"add magnet:?xt=urn:btih:h2 wanted=[] unwanted=[] high=[] low=[]",
+		// Queue positions are restored after all the torrents are added.
+		"queue [1] 0",
+		"queue [2] 1",
+	}
+	if !cmp.Equal(wantCalls, dst.calls) {
+		t.Errorf("unexpected calls, diff = \n%s", cmp.Diff(wantCalls, dst.calls))
+	}
+}
+
+func TestArchiver_Import(t *testing.T) {
+	archive := `{"version":1,"torrents":[
+		{"name":"b","hash":"h2","meta":"Yg==","queuePosition":1},
+		{"name":"a","hash":"h1","meta":"YQ==","queuePosition":0}
+	]}`
+
+	client := &fakeClient{fail: map[string]bool{"a": true}}
+	a := &Archiver{Client: client}
+	opts := &ImportOpts{Verify: true, VerifyPollInterval: time.Millisecond}
+	res, err := a.Import(context.Background(), strings.NewReader(archive), opts)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []string{"torrent h1 (a): daemon said no", "torrent h2 (b)"}
+	if got := describe(res.Items); !cmp.Equal(want, got) {
+		t.Errorf("unexpected results, diff = \n%s", cmp.Diff(want, got))
+	}
+	if want, got := 1, len(res.Failed()); want != got {
+		t.Errorf("unexpected number of failures, want = %d, got = %d", want, got)
+	}
+	if len(client.added) != 1 || !client.added[0].Paused {
+		t.Errorf("expected verified torrent to be added paused")
+	}
+	// The torrent is recorded as running, so it is started after the
+	// verification.
+	wantCalls := []string{"verify [1]", "start [1]", "queue [1] 1"}
+	if got := client.calls[len(client.calls)-3:]; !cmp.Equal(wantCalls, got) {
+		t.Errorf("unexpected calls, diff = \n%s", cmp.Diff(wantCalls, got))
+	}
+}
+
+func TestArchiver_errors(t *testing.T) {
+	var tests = []struct {
+		name    string
+		archive string
+	}{
+		{name: "unsupported version", archive: `{"version":2,"torrents":[]}`},
+		{name: "malformed", archive: `{"version":`},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			a := &Archiver{Client: &fakeClient{}}
+			if _, err := a.Import(context.Background(), strings.NewReader(tc.archive), nil); err == nil {
+				t.Errorf("expected Import to fail")
+			}
+		})
+	}
+
+	if _, err := (&Archiver{}).Export(context.Background(), io.Discard); err == nil {
+		t.Errorf("expected archiver without client to fail")
+	}
+	a := &Archiver{Client: &fakeClient{err: errors.New("daemon is down")}}
+	if _, err := a.Export(context.Background(), io.Discard); err == nil {
+		t.Errorf("expected Export to fail")
+	}
+}
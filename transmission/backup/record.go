@@ -0,0 +1,187 @@
+package backup
+
+import (
+	"bytes"
+	"time"
+
+	"github.com/pborzenkov/go-transmission/transmission"
+)
+
+// Record holds identity and settings of a single torrent.
+type Record struct {
+	// Hash of the torrent
+	Hash transmission.Hash `json:"hash"`
+	// Name of the torrent
+	Name string `json:"name"`
+	// Contents of the .torrent file, if it was readable
+	Meta []byte `json:"meta,omitempty"`
+	// Magnet link of the torrent, used if Meta is empty
+	MagnetLink string `json:"magnetLink,omitempty"`
+	// Labels of the torrent
+	Labels []string `json:"labels,omitempty"`
+	// Download directory of the torrent
+	DownloadDirectory string `json:"downloadDir"`
+	// Indicates whether the torrent is stopped
+	Paused bool `json:"paused"`
+	// Indicates whether the files are wanted. Empty if all files are wanted
+	Wanted []bool `json:"wanted,omitempty"`
+	// Priorities of the files. Empty if all files have normal priority
+	Priorities []transmission.Priority `json:"priorities,omitempty"`
+
+	// Bandwidth priority
+	Priority transmission.Priority `json:"bandwidthPriority"`
+	// Position in queue
+	PositionInQueue int `json:"queuePosition"`
+	// Maximum number of peers
+	PeerLimit int `json:"peerLimit,omitempty"`
+	// Download rate limit (bytes/s)
+	DownloadRateLimit int64 `json:"downloadLimit"`
+	// Indicates if download rate is limited
+	DownloadRateLimitEnabled bool `json:"downloadLimited"`
+	// Upload rate limit (bytes/s)
+	UploadRateLimit int64 `json:"uploadLimit"`
+	// Indicates if upload rate is limited
+	UploadRateLimitEnabled bool `json:"uploadLimited"`
+	// Indicates if session limits are honored
+	HonorSessionLimits bool `json:"honorsSessionLimits"`
+	// Stop seeding after given time of inactivity
+	IdleSeedingLimit time.Duration `json:"seedIdleLimit"`
+	// Which IdleSeedingLimit value to use
+	IdleSeedingLimitMode transmission.Limit `json:"seedIdleMode"`
+	// Stop seeding after reaching the given ratio
+	UploadRatioLimit float64 `json:"seedRatioLimit"`
+	// Which UploadRatioLimit value to use
+	UploadRatioLimitMode transmission.Limit `json:"seedRatioMode"`
+	// Trackers in Transmission format, tiers are separated by empty lines
+	Trackers string `json:"trackers,omitempty"`
+}
+
+// Fields is a list of torrent fields required to create records.
+var Fields = []transmission.TorrentField{
+	transmission.TorrentFieldHash,
+	transmission.TorrentFieldName,
+	transmission.TorrentFieldStatus,
+	transmission.TorrentFieldFile,
+	transmission.TorrentFieldMagnetLink,
+	transmission.TorrentFieldLabels,
+	transmission.TorrentFieldDownloadDirectory,
+	transmission.TorrentFieldWanted,
+	transmission.TorrentFieldPriorities,
+	transmission.TorrentFieldPriority,
+	transmission.TorrentFieldPositionInQueue,
+	transmission.TorrentFieldPeerLimit,
+	transmission.TorrentFieldDownloadRateLimit,
+	transmission.TorrentFieldDownloadRateLimitEnabled,
+	transmission.TorrentFieldUploadRateLimit,
+	transmission.TorrentFieldUploadRateLimited,
+	transmission.TorrentFieldHonorSessionLimits,
+	transmission.TorrentFieldIdleSeedingLimit,
+	transmission.TorrentFieldIdleSeedingLimitMode,
+	transmission.TorrentFieldUploadRatioLimit,
+	transmission.TorrentFieldUploadRatioLimitMode,
+	transmission.TorrentFieldTrackerList,
+}
+
+// NewRecord returns a record of t with the given .torrent file contents. t
+// must have all the Fields populated.
+func NewRecord(t *transmission.Torrent, meta []byte) *Record {
+	r := &Record{
+		Hash:                     t.Hash,
+		Name:                     t.Name,
+		Meta:                     meta,
+		MagnetLink:               t.MagnetLink,
+		Labels:                   t.Labels,
+		DownloadDirectory:        t.DownloadDirectory,
+		Paused:                   t.Status == transmission.StatusStopped,
+		Priority:                 t.Priority,
+		PositionInQueue:          t.PositionInQueue,
+		PeerLimit:                t.PeerLimit,
+		DownloadRateLimit:        t.DownloadRateLimit,
+		DownloadRateLimitEnabled: t.DownloadRateLimitEnabled,
+		UploadRateLimit:          t.UploadRateLimit,
+		UploadRateLimitEnabled:   t.UploadRateLimited,
+		HonorSessionLimits:       t.HonorSessionLimits,
+		IdleSeedingLimit:         t.IdleSeedingLimit,
+		IdleSeedingLimitMode:     t.IdleSeedingLimitMode,
+		UploadRatioLimit:         t.UploadRatioLimit,
+		UploadRatioLimitMode:     t.UploadRatioLimitMode,
+		Trackers:                 t.TrackerList.String(),
+	}
+	for _, w := range t.Wanted {
+		if !w {
+			r.Wanted = t.Wanted
+			break
+		}
+	}
+	for _, p := range t.Priorities {
+		if p != transmission.PriorityNormal {
+			r.Priorities = t.Priorities
+			break
+		}
+	}
+	return r
+}
+
+// AddRequest returns a request and settings that add the torrent described
+// by the record to Transmission.
+func (r *Record) AddRequest() (*transmission.AddTorrentReq, *transmission.AddTorrentSettings, error) {
+	req := &transmission.AddTorrentReq{
+		Paused:   transmission.OptBool(r.Paused),
+		Labels:   r.Labels,
+		Priority: transmission.OptPriority(r.Priority),
+	}
+	if len(r.Meta) > 0 {
+		req.Meta = bytes.NewReader(r.Meta)
+	} else {
+		req.URL = transmission.OptString(r.MagnetLink)
+	}
+	if r.DownloadDirectory != "" {
+		req.DownloadDirectory = transmission.OptString(r.DownloadDirectory)
+	}
+	if r.PeerLimit > 0 {
+		req.PeerLimit = transmission.OptInt(r.PeerLimit)
+	}
+
+	set := &transmission.SetTorrentReq{
+		PositionInQueue:          transmission.OptInt(r.PositionInQueue),
+		DownloadRateLimit:        transmission.OptInt64(r.DownloadRateLimit),
+		DownloadRateLimitEnabled: transmission.OptBool(r.DownloadRateLimitEnabled),
+		UploadRateLimit:          transmission.OptInt64(r.UploadRateLimit),
+		UploadRateLimitEnabled:   transmission.OptBool(r.UploadRateLimitEnabled),
+		HonorSessionLimits:       transmission.OptBool(r.HonorSessionLimits),
+		IdleSeedingLimit:         transmission.OptDuration(r.IdleSeedingLimit),
+		IdleSeedingLimitMode:     transmission.OptLimit(r.IdleSeedingLimitMode),
+		UploadRatioLimit:         transmission.OptFloat64(r.UploadRatioLimit),
+		UploadRatioLimitMode:     transmission.OptLimit(r.UploadRatioLimitMode),
+	}
+	// Empty index lists mean all files, so only non-empty ones are set.
+	var wanted, unwanted []int
+	for i, w := range r.Wanted {
+		if w {
+			wanted = append(wanted, i)
+		} else {
+			unwanted = append(unwanted, i)
+		}
+	}
+	if len(unwanted) > 0 {
+		set.UnwantedFiles = unwanted
+		if len(wanted) > 0 {
+			set.WantedFiles = wanted
+		}
+	}
+	for i, p := range r.Priorities {
+		switch p {
+		case transmission.PriorityHigh:
+			set.HighPriorityFiles = append(set.HighPriorityFiles, i)
+		case transmission.PriorityLow:
+			set.LowPriorityFiles = append(set.LowPriorityFiles, i)
+		}
+	}
+	if r.Trackers != "" {
+		var err error
+		if set.TrackerList, err = transmission.ParseTrackerList(r.Trackers); err != nil {
+			return nil, nil, err
+		}
+	}
+	return req, &transmission.AddTorrentSettings{Set: set}, nil
+}
@@ -0,0 +1,153 @@
+// Package bencode implements encoding and decoding of bencoded data used by
+// BitTorrent metainfo (.torrent) files and Transmission resume files.
+//
+// The mapping between bencode and Go values follows encoding/json: integers
+// map to Go integers and bools, strings map to Go strings, byte slices and
+// byte arrays, lists map to slices and arrays, and dictionaries map to maps
+// with string keys and structs. Struct fields are matched against dictionary
+// keys using the "bencode" struct tag:
+//
+//	// Field is ignored by this package.
+//	Field int `bencode:"-"`
+//
+//	// Field appears in bencode as key "piece length".
+//	Field int `bencode:"piece length"`
+//
+//	// Field is omitted from the output if it's empty.
+//	Field string `bencode:"comment,omitempty"`
+//
+// Dictionary keys are always encoded in sorted order. Nil pointers and
+// interfaces have no bencode representation and are omitted from dictionaries.
+package bencode
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// Marshaler is the interface implemented by types that can marshal themselves
+// into valid bencode.
+type Marshaler interface {
+	MarshalBencode() ([]byte, error)
+}
+
+// Unmarshaler is the interface implemented by types that can unmarshal a
+// bencode description of themselves. The input is a single complete bencode
+// value. UnmarshalBencode must copy the data if it wishes to retain it after
+// returning.
+type Unmarshaler interface {
+	UnmarshalBencode([]byte) error
+}
+
+// RawMessage is a raw encoded bencode value. It can be used to delay decoding
+// or to preserve exact bytes of a value, e.g. to compute info hash of a
+// torrent.
+type RawMessage []byte
+
+var _ Marshaler = RawMessage(nil)
+var _ Unmarshaler = (*RawMessage)(nil)
+
+// MarshalBencode returns m as the bencode encoding of m.
+func (m RawMessage) MarshalBencode() ([]byte, error) {
+	if m == nil {
+		return nil, &MarshalerError{Type: reflect.TypeOf(m), Err: fmt.Errorf("empty RawMessage")}
+	}
+	return m, nil
+}
+
+// UnmarshalBencode sets *m to a copy of data.
+func (m *RawMessage) UnmarshalBencode(data []byte) error {
+	*m = append((*m)[0:0], data...)
+	return nil
+}
+
+// SyntaxError describes malformed bencode data.
+type SyntaxError struct {
+	msg string
+	// Offset of the byte in the input after which the error occurred
+	Offset int64
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("bencode: %s (offset %d)", e.msg, e.Offset)
+}
+
+// UnmarshalTypeError describes a bencode value that was not appropriate for a
+// value of a specific Go type.
+type UnmarshalTypeError struct {
+	// Description of bencode value - "integer", "string", "list", "dictionary"
+	Value string
+	// Type of Go value it could not be assigned to
+	Type reflect.Type
+	// Offset of the value in the input
+	Offset int64
+}
+
+func (e *UnmarshalTypeError) Error() string {
+	return fmt.Sprintf("bencode: cannot unmarshal %s into Go value of type %s (offset %d)", e.Value, e.Type, e.Offset)
+}
+
+// UnknownFieldError is returned by a Decoder configured with
+// DisallowUnknownFields when a dictionary key doesn't match any struct field.
+type UnknownFieldError struct {
+	// Dictionary key
+	Key string
+	// Type of the struct
+	Type reflect.Type
+	// Offset of the key in the input
+	Offset int64
+}
+
+func (e *UnknownFieldError) Error() string {
+	return fmt.Sprintf("bencode: unknown field %q in %s (offset %d)", e.Key, e.Type, e.Offset)
+}
+
+// InvalidUnmarshalError describes an invalid argument passed to Unmarshal.
+type InvalidUnmarshalError struct {
+	Type reflect.Type
+}
+
+func (e *InvalidUnmarshalError) Error() string {
+	if e.Type == nil {
+		return "bencode: Unmarshal(nil)"
+	}
+	if e.Type.Kind() != reflect.Pointer {
+		return "bencode: Unmarshal(non-pointer " + e.Type.String() + ")"
+	}
+	return "bencode: Unmarshal(nil " + e.Type.String() + ")"
+}
+
+// UnsupportedTypeError is returned by Marshal when attempting to encode an
+// unsupported value type.
+type UnsupportedTypeError struct {
+	Type reflect.Type
+}
+
+func (e *UnsupportedTypeError) Error() string {
+	return "bencode: unsupported type: " + e.Type.String()
+}
+
+// UnsupportedValueError is returned by Marshal when attempting to encode a value
+// that has no bencode representation, like a nil pointer.
+type UnsupportedValueError struct {
+	Str string
+}
+
+func (e *UnsupportedValueError) Error() string {
+	return "bencode: unsupported value: " + e.Str
+}
+
+// MarshalerError represents an error from calling a MarshalBencode method.
+type MarshalerError struct {
+	Type reflect.Type
+	Err  error
+}
+
+func (e *MarshalerError) Error() string {
+	return "bencode: error calling MarshalBencode for type " + e.Type.String() + ": " + e.Err.Error()
+}
+
+// Unwrap returns the underlying error.
+func (e *MarshalerError) Unwrap() error {
+	return e.Err
+}
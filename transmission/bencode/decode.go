@@ -0,0 +1,610 @@
+package bencode
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+)
+
+const (
+	defaultMaxDepth = 1000
+
+	// maxIntLength limits the length of integer and string length tokens
+	maxIntLength = 32
+
+	// strings longer than smallString are read incrementally, so malformed
+	// input can't force a huge allocation
+	smallString = 64 << 10
+)
+
+// Unmarshal parses bencoded data and stores the result in the value pointed to
+// by v. Data must hold exactly one bencode value.
+func Unmarshal(data []byte, v interface{}) error {
+	d := NewDecoder(bytes.NewReader(data))
+	if err := d.Decode(v); err != nil {
+		return err
+	}
+	if _, err := d.r.Peek(1); err != io.EOF {
+		return &SyntaxError{msg: "invalid data after top-level value", Offset: d.off}
+	}
+	return nil
+}
+
+// Decoder reads and decodes bencode values from an input stream.
+type Decoder struct {
+	r   *bufio.Reader
+	off int64
+
+	raw      []byte
+	rawDepth int
+
+	depth    int
+	savedErr error
+
+	disallowUnknownFields bool
+	strict                bool
+	maxDepth              int
+}
+
+// NewDecoder returns a new decoder that reads from r. The decoder may read
+// data from r beyond the bencode values requested.
+func NewDecoder(r io.Reader) *Decoder {
+	br, ok := r.(*bufio.Reader)
+	if !ok {
+		br = bufio.NewReader(r)
+	}
+	return &Decoder{r: br, maxDepth: defaultMaxDepth}
+}
+
+// DisallowUnknownFields causes the Decoder to return an error when a
+// dictionary contains a key that doesn't match any field of the destination
+// struct.
+func (d *Decoder) DisallowUnknownFields() {
+	d.disallowUnknownFields = true
+}
+
+// Strict causes the Decoder to accept only canonical bencode: dictionary keys
+// must be unique and sorted, integers and string lengths must not have leading
+// zeros and negative zero is not allowed.
+func (d *Decoder) Strict() {
+	d.strict = true
+}
+
+// SetMaxDepth limits nesting of lists and dictionaries. The default is 1000.
+func (d *Decoder) SetMaxDepth(depth int) {
+	d.maxDepth = depth
+}
+
+// InputOffset returns the input stream offset of the current decoder
+// position.
+func (d *Decoder) InputOffset() int64 {
+	return d.off
+}
+
+// Decode reads the next bencode value from its input and stores it in the
+// value pointed to by v. It returns io.EOF if there are no more values.
+func (d *Decoder) Decode(v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return &InvalidUnmarshalError{Type: reflect.TypeOf(v)}
+	}
+	if _, err := d.r.Peek(1); err != nil {
+		return err
+	}
+
+	d.savedErr = nil
+	if err := d.value(rv); err != nil {
+		return err
+	}
+	return d.savedErr
+}
+
+func (d *Decoder) eof(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+func (d *Decoder) syntaxError(format string, args ...interface{}) error {
+	return &SyntaxError{msg: fmt.Sprintf(format, args...), Offset: d.off}
+}
+
+func (d *Decoder) saveError(err error) {
+	if d.savedErr == nil {
+		d.savedErr = err
+	}
+}
+
+func (d *Decoder) peekByte() (byte, error) {
+	b, err := d.r.Peek(1)
+	if err != nil {
+		return 0, d.eof(err)
+	}
+	return b[0], nil
+}
+
+func (d *Decoder) readByte() (byte, error) {
+	c, err := d.r.ReadByte()
+	if err != nil {
+		return 0, d.eof(err)
+	}
+	d.off++
+	if d.rawDepth > 0 {
+		d.raw = append(d.raw, c)
+	}
+	return c, nil
+}
+
+func (d *Decoder) readBytes(n int64) ([]byte, error) {
+	var data []byte
+	if n <= smallString {
+		data = make([]byte, n)
+		if _, err := io.ReadFull(d.r, data); err != nil {
+			return nil, d.eof(err)
+		}
+	} else {
+		buf := new(bytes.Buffer)
+		if _, err := io.CopyN(buf, d.r, n); err != nil {
+			return nil, d.eof(err)
+		}
+		data = buf.Bytes()
+	}
+	d.off += n
+	if d.rawDepth > 0 {
+		d.raw = append(d.raw, data...)
+	}
+	return data, nil
+}
+
+// readNumber reads a decimal number terminated by term. It returns the number
+// as a string without the terminator.
+func (d *Decoder) readNumber(term byte, signed bool) (string, error) {
+	var buf [maxIntLength]byte
+	n := 0
+	for {
+		c, err := d.readByte()
+		if err != nil {
+			return "", err
+		}
+		if c == term {
+			break
+		}
+		if !(c >= '0' && c <= '9' || signed && n == 0 && c == '-') {
+			return "", d.syntaxError("invalid character %q in number", c)
+		}
+		if n == len(buf) {
+			return "", d.syntaxError("number is too long")
+		}
+		buf[n] = c
+		n++
+	}
+
+	num := string(buf[:n])
+	digits := num
+	if len(digits) > 0 && digits[0] == '-' {
+		digits = digits[1:]
+	}
+	if digits == "" {
+		return "", d.syntaxError("empty number")
+	}
+	if d.strict {
+		if len(digits) > 1 && digits[0] == '0' {
+			return "", d.syntaxError("number %q has leading zeros", num)
+		}
+		if num == "-0" {
+			return "", d.syntaxError("negative zero")
+		}
+	}
+	return num, nil
+}
+
+func (d *Decoder) readString() ([]byte, error) {
+	num, err := d.readNumber(':', false)
+	if err != nil {
+		return nil, err
+	}
+	n, err := strconv.ParseInt(num, 10, 64)
+	if err != nil {
+		return nil, d.syntaxError("invalid string length %q", num)
+	}
+	return d.readBytes(n)
+}
+
+// indirect walks down v allocating pointers as needed, until it gets to a
+// non-pointer. If it encounters an Unmarshaler, indirect stops and returns
+// that.
+func indirect(v reflect.Value) (Unmarshaler, reflect.Value) {
+	if !v.IsValid() {
+		return nil, v
+	}
+	if v.Kind() != reflect.Pointer && v.Type().Name() != "" && v.CanAddr() {
+		v = v.Addr()
+	}
+	for {
+		if v.Kind() == reflect.Interface && !v.IsNil() {
+			e := v.Elem()
+			if e.Kind() == reflect.Pointer && !e.IsNil() {
+				v = e
+				continue
+			}
+		}
+		if v.Kind() != reflect.Pointer {
+			break
+		}
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		if v.Type().NumMethod() > 0 && v.CanInterface() {
+			if u, ok := v.Interface().(Unmarshaler); ok {
+				return u, reflect.Value{}
+			}
+		}
+		v = v.Elem()
+	}
+	return nil, v
+}
+
+func isEmptyInterface(v reflect.Value) bool {
+	return v.Kind() == reflect.Interface && v.NumMethod() == 0
+}
+
+// value decodes the next value into v. If v is invalid, the value is skipped.
+func (d *Decoder) value(v reflect.Value) error {
+	u, v := indirect(v)
+	if u != nil {
+		raw, err := d.rawValue()
+		if err != nil {
+			return err
+		}
+		if err := u.UnmarshalBencode(raw); err != nil {
+			d.saveError(err)
+		}
+		return nil
+	}
+
+	c, err := d.peekByte()
+	if err != nil {
+		return err
+	}
+	switch {
+	case c == 'i':
+		return d.integer(v)
+	case c >= '0' && c <= '9':
+		return d.str(v)
+	case c == 'l':
+		return d.list(v)
+	case c == 'd':
+		return d.dict(v)
+	}
+	return d.syntaxError("invalid character %q looking for beginning of value", c)
+}
+
+// rawValue returns bytes of the next value.
+func (d *Decoder) rawValue() ([]byte, error) {
+	start := len(d.raw)
+	d.rawDepth++
+	err := d.value(reflect.Value{})
+	d.rawDepth--
+
+	raw := make([]byte, len(d.raw)-start)
+	copy(raw, d.raw[start:])
+	if d.rawDepth == 0 {
+		d.raw = d.raw[:0]
+	}
+	return raw, err
+}
+
+func (d *Decoder) typeError(value string, t reflect.Type, off int64) {
+	d.saveError(&UnmarshalTypeError{Value: value, Type: t, Offset: off})
+}
+
+func (d *Decoder) integer(v reflect.Value) error {
+	off := d.off
+	if _, err := d.readByte(); err != nil {
+		return err
+	}
+	num, err := d.readNumber('e', true)
+	if err != nil {
+		return err
+	}
+	if !v.IsValid() {
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(num, 10, 64)
+		if err != nil || v.OverflowInt(n) {
+			d.typeError("integer "+num, v.Type(), off)
+			return nil
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		n, err := strconv.ParseUint(num, 10, 64)
+		if err != nil || v.OverflowUint(n) {
+			d.typeError("integer "+num, v.Type(), off)
+			return nil
+		}
+		v.SetUint(n)
+	case reflect.Bool:
+		v.SetBool(num != "0" && num != "-0")
+	case reflect.Interface:
+		n, err := strconv.ParseInt(num, 10, 64)
+		if err != nil {
+			return d.syntaxError("integer %s is out of range", num)
+		}
+		if !isEmptyInterface(v) {
+			d.typeError("integer", v.Type(), off)
+			return nil
+		}
+		v.Set(reflect.ValueOf(n))
+	default:
+		d.typeError("integer", v.Type(), off)
+	}
+	return nil
+}
+
+func (d *Decoder) str(v reflect.Value) error {
+	off := d.off
+	data, err := d.readString()
+	if err != nil {
+		return err
+	}
+	if !v.IsValid() {
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(string(data))
+	case reflect.Slice:
+		if v.Type().Elem().Kind() != reflect.Uint8 {
+			d.typeError("string", v.Type(), off)
+			return nil
+		}
+		v.SetBytes(data)
+	case reflect.Array:
+		if v.Type().Elem().Kind() != reflect.Uint8 || v.Len() != len(data) {
+			d.typeError("string", v.Type(), off)
+			return nil
+		}
+		reflect.Copy(v, reflect.ValueOf(data))
+	case reflect.Interface:
+		if !isEmptyInterface(v) {
+			d.typeError("string", v.Type(), off)
+			return nil
+		}
+		v.Set(reflect.ValueOf(string(data)))
+	default:
+		d.typeError("string", v.Type(), off)
+	}
+	return nil
+}
+
+func (d *Decoder) enter() error {
+	d.depth++
+	if d.depth > d.maxDepth {
+		return d.syntaxError("exceeded max depth")
+	}
+	return nil
+}
+
+// next reports whether the current list or dictionary has more values,
+// consuming the terminator if it doesn't.
+func (d *Decoder) next() (bool, error) {
+	c, err := d.peekByte()
+	if err != nil {
+		return false, err
+	}
+	if c == 'e' {
+		_, err = d.readByte()
+		return false, err
+	}
+	return true, nil
+}
+
+func (d *Decoder) list(v reflect.Value) error {
+	off := d.off
+	if _, err := d.readByte(); err != nil {
+		return err
+	}
+	if err := d.enter(); err != nil {
+		return err
+	}
+	defer func() { d.depth-- }()
+
+	if v.IsValid() {
+		switch v.Kind() {
+		case reflect.Interface:
+			if isEmptyInterface(v) {
+				l := make([]interface{}, 0)
+				lv := reflect.ValueOf(&l).Elem()
+				if err := d.listElems(lv); err != nil {
+					return err
+				}
+				v.Set(lv)
+				return nil
+			}
+		case reflect.Slice, reflect.Array:
+			return d.listElems(v)
+		}
+		d.typeError("list", v.Type(), off)
+	}
+
+	return d.listElems(reflect.Value{})
+}
+
+func (d *Decoder) listElems(v reflect.Value) error {
+	i := 0
+	for {
+		more, err := d.next()
+		if err != nil {
+			return err
+		}
+		if !more {
+			break
+		}
+
+		var elem reflect.Value
+		switch {
+		case !v.IsValid():
+		case v.Kind() == reflect.Slice:
+			if i >= v.Cap() {
+				v.Set(reflect.Append(v, reflect.Zero(v.Type().Elem())))
+			}
+			v.SetLen(i + 1)
+			elem = v.Index(i)
+			elem.Set(reflect.Zero(elem.Type()))
+		case i < v.Len():
+			elem = v.Index(i)
+		}
+		if err := d.value(elem); err != nil {
+			return err
+		}
+		i++
+	}
+
+	switch {
+	case !v.IsValid():
+	case v.Kind() == reflect.Slice:
+		if v.IsNil() {
+			v.Set(reflect.MakeSlice(v.Type(), 0, 0))
+		}
+		v.SetLen(i)
+	default:
+		for ; i < v.Len(); i++ {
+			v.Index(i).Set(reflect.Zero(v.Type().Elem()))
+		}
+	}
+	return nil
+}
+
+func (d *Decoder) dict(v reflect.Value) error {
+	off := d.off
+	if _, err := d.readByte(); err != nil {
+		return err
+	}
+	if err := d.enter(); err != nil {
+		return err
+	}
+	defer func() { d.depth-- }()
+
+	if v.IsValid() {
+		switch v.Kind() {
+		case reflect.Interface:
+			if isEmptyInterface(v) {
+				m := make(map[string]interface{})
+				mv := reflect.ValueOf(m)
+				if err := d.dictEntries(mv); err != nil {
+					return err
+				}
+				v.Set(mv)
+				return nil
+			}
+		case reflect.Map:
+			if v.Type().Key().Kind() == reflect.String {
+				if v.IsNil() {
+					v.Set(reflect.MakeMap(v.Type()))
+				}
+				return d.dictEntries(v)
+			}
+		case reflect.Struct:
+			return d.dictEntries(v)
+		}
+		d.typeError("dictionary", v.Type(), off)
+	}
+
+	return d.dictEntries(reflect.Value{})
+}
+
+func fieldByIndex(v reflect.Value, index []int) reflect.Value {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				if !v.CanSet() {
+					return reflect.Value{}
+				}
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v
+}
+
+func (d *Decoder) dictEntries(v reflect.Value) error {
+	var fields []field
+	if v.IsValid() && v.Kind() == reflect.Struct {
+		fields = cachedFields(v.Type())
+	}
+
+	var prev []byte
+	first := true
+	for {
+		more, err := d.next()
+		if err != nil {
+			return err
+		}
+		if !more {
+			break
+		}
+
+		keyOff := d.off
+		c, err := d.peekByte()
+		if err != nil {
+			return err
+		}
+		if c < '0' || c > '9' {
+			return d.syntaxError("invalid character %q looking for dictionary key", c)
+		}
+		key, err := d.readString()
+		if err != nil {
+			return err
+		}
+		if d.strict {
+			if !first && bytes.Compare(prev, key) >= 0 {
+				return &SyntaxError{msg: fmt.Sprintf("dictionary key %q is not sorted or duplicated", key), Offset: keyOff}
+			}
+			prev = key
+		}
+		first = false
+
+		switch {
+		case !v.IsValid():
+			err = d.value(reflect.Value{})
+		case v.Kind() == reflect.Map:
+			elem := reflect.New(v.Type().Elem()).Elem()
+			if err = d.value(elem); err == nil {
+				v.SetMapIndex(reflect.ValueOf(string(key)).Convert(v.Type().Key()), elem)
+			}
+		default:
+			var fv reflect.Value
+			if f := fieldByName(fields, string(key)); f != nil {
+				fv = fieldByIndex(v, f.index)
+			} else if d.disallowUnknownFields {
+				return &UnknownFieldError{Key: string(key), Type: v.Type(), Offset: keyOff}
+			}
+			err = d.value(fv)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Valid reports whether data is a single valid bencode value.
+func Valid(data []byte) bool {
+	d := NewDecoder(bytes.NewReader(data))
+	if err := d.value(reflect.Value{}); err != nil {
+		return false
+	}
+	_, err := d.r.Peek(1)
+	return errors.Is(err, io.EOF)
+}
@@ -0,0 +1,353 @@
+package bencode
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+type testInfo struct {
+	Name        string `bencode:"name"`
+	PieceLength int64  `bencode:"piece length"`
+	Pieces      []byte `bencode:"pieces"`
+	Private     bool   `bencode:"private,omitempty"`
+}
+
+type testMeta struct {
+	Announce     string     `bencode:"announce"`
+	AnnounceList [][]string `bencode:"announce-list,omitempty"`
+	Info         testInfo   `bencode:"info"`
+	CreationDate *int64     `bencode:"creation date,omitempty"`
+}
+
+func TestUnmarshal(t *testing.T) {
+	var tests = []struct {
+		name string
+		data string
+		v    func() interface{}
+		want interface{}
+	}{
+		{
+			name: "int",
+			data: "i-42e",
+			v:    func() interface{} { return new(int) },
+			want: -42,
+		},
+		{
+			name: "uint",
+			data: "i42e",
+			v:    func() interface{} { return new(uint8) },
+			want: uint8(42),
+		},
+		{
+			name: "bool",
+			data: "i1e",
+			v:    func() interface{} { return new(bool) },
+			want: true,
+		},
+		{
+			name: "string",
+			data: "5:hello",
+			v:    func() interface{} { return new(string) },
+			want: "hello",
+		},
+		{
+			name: "bytes",
+			data: "3:\x00\x01\x02",
+			v:    func() interface{} { return new([]byte) },
+			want: []byte{0, 1, 2},
+		},
+		{
+			name: "array",
+			data: "4:abcd",
+			v:    func() interface{} { return new([4]byte) },
+			want: [4]byte{'a', 'b', 'c', 'd'},
+		},
+		{
+			name: "list",
+			data: "li1ei2ei3ee",
+			v:    func() interface{} { return new([]int) },
+			want: []int{1, 2, 3},
+		},
+		{
+			name: "empty_list",
+			data: "le",
+			v:    func() interface{} { return new([]string) },
+			want: []string{},
+		},
+		{
+			name: "map",
+			data: "d1:ai1e1:bi2ee",
+			v:    func() interface{} { return new(map[string]int) },
+			want: map[string]int{"a": 1, "b": 2},
+		},
+		{
+			name: "interface",
+			data: "d4:listli1e1:xe3:numi7e3:str1:se",
+			v:    func() interface{} { return new(interface{}) },
+			want: map[string]interface{}{
+				"list": []interface{}{int64(1), "x"},
+				"num":  int64(7),
+				"str":  "s",
+			},
+		},
+		{
+			name: "struct",
+			data: "d8:announce14:http://tracker13:announce-listll1:a1:bel1:cee13:creation datei1700000000e" +
+				"4:infod4:name4:test12:piece lengthi16384e6:pieces3:xyz7:privatei1eee",
+			v: func() interface{} { return new(testMeta) },
+			want: testMeta{
+				Announce:     "http://tracker",
+				AnnounceList: [][]string{{"a", "b"}, {"c"}},
+				Info:         testInfo{Name: "test", PieceLength: 16384, Pieces: []byte("xyz"), Private: true},
+				CreationDate: func() *int64 { v := int64(1700000000); return &v }(),
+			},
+		},
+		{
+			name: "unknown_fields",
+			data: "d1:xli1ed1:y1:zee8:announce1:ae",
+			v:    func() interface{} { return new(testMeta) },
+			want: testMeta{Announce: "a"},
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			v := tc.v()
+			if err := Unmarshal([]byte(tc.data), v); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			got := deref(v)
+			if !cmp.Equal(tc.want, got) {
+				t.Errorf("unexpected value, diff = \n%s", cmp.Diff(tc.want, got))
+			}
+		})
+	}
+}
+
+func deref(v interface{}) interface{} {
+	switch v := v.(type) {
+	case *int:
+		return *v
+	case *uint8:
+		return *v
+	case *bool:
+		return *v
+	case *string:
+		return *v
+	case *[]byte:
+		return *v
+	case *[4]byte:
+		return *v
+	case *[]int:
+		return *v
+	case *[]string:
+		return *v
+	case *map[string]int:
+		return *v
+	case *interface{}:
+		return *v
+	case *testMeta:
+		return *v
+	}
+	panic("unexpected type")
+}
+
+func TestUnmarshal_errors(t *testing.T) {
+	var tests = []struct {
+		name string
+		data string
+		v    interface{}
+	}{
+		{name: "empty", data: "", v: new(interface{})},
+		{name: "truncated_int", data: "i12", v: new(int)},
+		{name: "empty_int", data: "ie", v: new(int)},
+		{name: "bad_int", data: "i1x2e", v: new(int)},
+		{name: "truncated_string", data: "5:abc", v: new(string)},
+		{name: "truncated_list", data: "li1e", v: new([]int)},
+		{name: "bad_key", data: "di1ei2ee", v: new(map[string]int)},
+		{name: "trailing_data", data: "i1ei2e", v: new(int)},
+		{name: "bad_prefix", data: "x", v: new(interface{})},
+		{name: "overflow", data: "i300e", v: new(uint8)},
+		{name: "type_mismatch", data: "3:abc", v: new(int)},
+		{name: "array_length", data: "3:abc", v: new([4]byte)},
+		{name: "huge_string", data: "99999999999:abc", v: new(string)},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			if err := Unmarshal([]byte(tc.data), tc.v); err == nil {
+				t.Errorf("expected unmarshal of %q to fail", tc.data)
+			}
+		})
+	}
+}
+
+func TestUnmarshal_invalidArgument(t *testing.T) {
+	var v int
+	var ie *InvalidUnmarshalError
+	if err := Unmarshal([]byte("i1e"), v); !errors.As(err, &ie) {
+		t.Errorf("expected InvalidUnmarshalError, got %v", err)
+	}
+	if err := Unmarshal([]byte("i1e"), nil); !errors.As(err, &ie) {
+		t.Errorf("expected InvalidUnmarshalError, got %v", err)
+	}
+}
+
+func TestDecoder_strict(t *testing.T) {
+	var tests = []struct {
+		name string
+		data string
+	}{
+		{name: "leading_zero_int", data: "i01e"},
+		{name: "negative_zero", data: "i-0e"},
+		{name: "leading_zero_length", data: "01:a"},
+		{name: "unsorted_keys", data: "d1:bi1e1:ai2ee"},
+		{name: "duplicate_keys", data: "d1:ai1e1:ai2ee"},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			var v interface{}
+			if err := NewDecoder(strings.NewReader(tc.data)).Decode(&v); err != nil {
+				t.Fatalf("expected lenient decoder to accept %q, got %v", tc.data, err)
+			}
+
+			d := NewDecoder(strings.NewReader(tc.data))
+			d.Strict()
+			var se *SyntaxError
+			if err := d.Decode(&v); !errors.As(err, &se) {
+				t.Errorf("expected strict decoder to reject %q with syntax error, got %v", tc.data, err)
+			}
+		})
+	}
+}
+
+func TestDecoder_disallowUnknownFields(t *testing.T) {
+	d := NewDecoder(strings.NewReader("d8:announce1:a5:extrai1ee"))
+	d.DisallowUnknownFields()
+
+	var v testMeta
+	var ue *UnknownFieldError
+	if err := d.Decode(&v); !errors.As(err, &ue) {
+		t.Fatalf("expected UnknownFieldError, got %v", err)
+	}
+	if want, got := "extra", ue.Key; want != got {
+		t.Errorf("unexpected key, want = %q, got = %q", want, got)
+	}
+}
+
+func TestDecoder_maxDepth(t *testing.T) {
+	data := strings.Repeat("l", 20) + strings.Repeat("e", 20)
+
+	d := NewDecoder(strings.NewReader(data))
+	d.SetMaxDepth(10)
+	var v interface{}
+	if err := d.Decode(&v); err == nil {
+		t.Errorf("expected decode to fail")
+	}
+}
+
+func TestDecoder_stream(t *testing.T) {
+	d := NewDecoder(strings.NewReader("i1e3:abcle"))
+
+	var n int
+	var s string
+	var l []int
+	if err := d.Decode(&n); err != nil || n != 1 {
+		t.Fatalf("unexpected first value %d, err = %v", n, err)
+	}
+	if err := d.Decode(&s); err != nil || s != "abc" {
+		t.Fatalf("unexpected second value %q, err = %v", s, err)
+	}
+	if err := d.Decode(&l); err != nil || len(l) != 0 {
+		t.Fatalf("unexpected third value %v, err = %v", l, err)
+	}
+	if want, got := int64(10), d.InputOffset(); want != got {
+		t.Errorf("unexpected offset, want = %d, got = %d", want, got)
+	}
+	if err := d.Decode(&n); err != io.EOF {
+		t.Errorf("expected io.EOF, got %v", err)
+	}
+}
+
+func TestRawMessage(t *testing.T) {
+	const info = "d4:name4:test12:piece lengthi16384e6:pieces0:e"
+	data := "d8:announce1:a4:info" + info + "e"
+
+	var v struct {
+		Announce string     `bencode:"announce"`
+		Info     RawMessage `bencode:"info"`
+	}
+	if err := Unmarshal([]byte(data), &v); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want, got := info, string(v.Info); want != got {
+		t.Errorf("unexpected raw message, want = %q, got = %q", want, got)
+	}
+
+	out, err := Marshal(&v)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !bytes.Equal([]byte(data), out) {
+		t.Errorf("unexpected marshaled data, want = %q, got = %q", data, out)
+	}
+}
+
+func TestValid(t *testing.T) {
+	if !Valid([]byte("d1:ali1ei2eee")) {
+		t.Errorf("expected data to be valid")
+	}
+	if Valid([]byte("d1:ali1ei2ee")) {
+		t.Errorf("expected truncated data to be invalid")
+	}
+	if Valid([]byte("i1ei2e")) {
+		t.Errorf("expected multiple values to be invalid")
+	}
+}
+
+func FuzzUnmarshal(f *testing.F) {
+	for _, seed := range []string{
+		"i42e",
+		"4:spam",
+		"l4:spami42ee",
+		"d3:bar4:spam3:fooi42ee",
+		"d8:announce14:http://tracker4:infod4:name4:test12:piece lengthi16384e6:pieces0:ee",
+	} {
+		f.Add([]byte(seed))
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var v interface{}
+		if err := Unmarshal(data, &v); err != nil {
+			return
+		}
+		out, err := Marshal(v)
+		if err != nil {
+			t.Fatalf("failed to marshal decoded value: %v", err)
+		}
+
+		// Canonical input must round-trip exactly.
+		d := NewDecoder(bytes.NewReader(data))
+		d.Strict()
+		if err := d.Decode(&v); err == nil && !bytes.Equal(data, out) {
+			t.Errorf("canonical input didn't round-trip, in = %q, out = %q", data, out)
+		}
+
+		var raw RawMessage
+		if err := Unmarshal(data, &raw); err != nil {
+			t.Fatalf("failed to decode valid input into RawMessage: %v", err)
+		}
+		if !bytes.Equal(data, raw) {
+			t.Errorf("unexpected raw message, in = %q, out = %q", data, raw)
+		}
+	})
+}
@@ -0,0 +1,227 @@
+package bencode
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"sort"
+	"strconv"
+)
+
+// Marshal returns the bencode encoding of v.
+func Marshal(v interface{}) ([]byte, error) {
+	e := new(encodeState)
+	if err := e.value(reflect.ValueOf(v)); err != nil {
+		return nil, err
+	}
+	return e.Bytes(), nil
+}
+
+// Encoder writes bencode values to an output stream.
+type Encoder struct {
+	w io.Writer
+}
+
+// NewEncoder returns a new encoder that writes to w.
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{w: w}
+}
+
+// Encode writes the bencode encoding of v to the stream.
+func (enc *Encoder) Encode(v interface{}) error {
+	e := new(encodeState)
+	if err := e.value(reflect.ValueOf(v)); err != nil {
+		return err
+	}
+	_, err := enc.w.Write(e.Bytes())
+	return err
+}
+
+type encodeState struct {
+	bytes.Buffer
+	scratch [64]byte
+}
+
+var marshalerType = reflect.TypeOf((*Marshaler)(nil)).Elem()
+
+// isNil reports whether v has no bencode representation.
+func isNil(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		return v.IsNil()
+	case reflect.Slice:
+		return v.IsNil() && v.Type().Implements(marshalerType)
+	}
+	return !v.IsValid()
+}
+
+func isEmpty(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Interface, reflect.Pointer:
+		return v.IsNil()
+	}
+	return false
+}
+
+func (e *encodeState) marshaler(v reflect.Value) (bool, error) {
+	var m Marshaler
+	switch {
+	case v.Type().Implements(marshalerType):
+		if v.Kind() == reflect.Pointer && v.IsNil() {
+			return true, &UnsupportedValueError{Str: "nil " + v.Type().String()}
+		}
+		m = v.Interface().(Marshaler)
+	case v.Kind() != reflect.Pointer && v.CanAddr() && reflect.PointerTo(v.Type()).Implements(marshalerType):
+		m = v.Addr().Interface().(Marshaler)
+	default:
+		return false, nil
+	}
+
+	data, err := m.MarshalBencode()
+	if err != nil {
+		if _, ok := err.(*MarshalerError); ok {
+			return true, err
+		}
+		return true, &MarshalerError{Type: v.Type(), Err: err}
+	}
+	e.Write(data)
+	return true, nil
+}
+
+func (e *encodeState) value(v reflect.Value) error {
+	if !v.IsValid() {
+		return &UnsupportedValueError{Str: "nil"}
+	}
+	if ok, err := e.marshaler(v); ok {
+		return err
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		if v.Bool() {
+			e.WriteString("i1e")
+		} else {
+			e.WriteString("i0e")
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		e.WriteByte('i')
+		e.Write(strconv.AppendInt(e.scratch[:0], v.Int(), 10))
+		e.WriteByte('e')
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		e.WriteByte('i')
+		e.Write(strconv.AppendUint(e.scratch[:0], v.Uint(), 10))
+		e.WriteByte('e')
+	case reflect.String:
+		e.str(v.String())
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			e.bytes(v.Bytes())
+			return nil
+		}
+		return e.list(v)
+	case reflect.Array:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			data := make([]byte, v.Len())
+			reflect.Copy(reflect.ValueOf(data), v)
+			e.bytes(data)
+			return nil
+		}
+		return e.list(v)
+	case reflect.Map:
+		return e.mapDict(v)
+	case reflect.Struct:
+		return e.structDict(v)
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return &UnsupportedValueError{Str: "nil " + v.Type().String()}
+		}
+		return e.value(v.Elem())
+	default:
+		return &UnsupportedTypeError{Type: v.Type()}
+	}
+	return nil
+}
+
+func (e *encodeState) str(s string) {
+	e.Write(strconv.AppendInt(e.scratch[:0], int64(len(s)), 10))
+	e.WriteByte(':')
+	e.WriteString(s)
+}
+
+func (e *encodeState) bytes(b []byte) {
+	e.Write(strconv.AppendInt(e.scratch[:0], int64(len(b)), 10))
+	e.WriteByte(':')
+	e.Write(b)
+}
+
+func (e *encodeState) list(v reflect.Value) error {
+	e.WriteByte('l')
+	for i := 0; i < v.Len(); i++ {
+		elem := v.Index(i)
+		if isNil(elem) {
+			return &UnsupportedValueError{Str: "nil list element of type " + elem.Type().String()}
+		}
+		if err := e.value(elem); err != nil {
+			return err
+		}
+	}
+	e.WriteByte('e')
+	return nil
+}
+
+func (e *encodeState) mapDict(v reflect.Value) error {
+	if v.Type().Key().Kind() != reflect.String {
+		return &UnsupportedTypeError{Type: v.Type()}
+	}
+
+	keys := v.MapKeys()
+	sort.Slice(keys, func(i, j int) bool { return keys[i].String() < keys[j].String() })
+
+	e.WriteByte('d')
+	for _, k := range keys {
+		elem := v.MapIndex(k)
+		if isNil(elem) {
+			continue
+		}
+		e.str(k.String())
+		if err := e.value(elem); err != nil {
+			return err
+		}
+	}
+	e.WriteByte('e')
+	return nil
+}
+
+func (e *encodeState) structDict(v reflect.Value) error {
+	e.WriteByte('d')
+	for _, f := range cachedFields(v.Type()) {
+		fv := v
+		for i, x := range f.index {
+			if i > 0 && fv.Kind() == reflect.Pointer {
+				if fv.IsNil() {
+					fv = reflect.Value{}
+					break
+				}
+				fv = fv.Elem()
+			}
+			fv = fv.Field(x)
+		}
+		if !fv.IsValid() || isNil(fv) || f.omitEmpty && isEmpty(fv) {
+			continue
+		}
+		e.str(f.name)
+		if err := e.value(fv); err != nil {
+			return err
+		}
+	}
+	e.WriteByte('e')
+	return nil
+}
@@ -0,0 +1,127 @@
+package bencode
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+type testEmbedded struct {
+	Shared string `bencode:"shared"`
+	Inner  int    `bencode:"inner"`
+}
+
+type testOuter struct {
+	testEmbedded
+	Shared  string  `bencode:"shared"`
+	Skipped string  `bencode:"-"`
+	Omitted string  `bencode:"omitted,omitempty"`
+	Pointer *string `bencode:"pointer"`
+	Default int
+	private int
+}
+
+type testMarshaler struct{}
+
+func (testMarshaler) MarshalBencode() ([]byte, error) {
+	return []byte("4:mars"), nil
+}
+
+func TestMarshal(t *testing.T) {
+	var tests = []struct {
+		name string
+		v    interface{}
+		want string
+	}{
+		{name: "int", v: -42, want: "i-42e"},
+		{name: "uint", v: uint64(42), want: "i42e"},
+		{name: "bool", v: true, want: "i1e"},
+		{name: "string", v: "hello", want: "5:hello"},
+		{name: "bytes", v: []byte{0, 1}, want: "2:\x00\x01"},
+		{name: "byte_array", v: [2]byte{'a', 'b'}, want: "2:ab"},
+		{name: "list", v: []interface{}{1, "a", []int{}}, want: "li1e1:alee"},
+		{name: "nil_list", v: []int(nil), want: "le"},
+		{name: "map", v: map[string]int{"b": 2, "a": 1, "": 0}, want: "d0:i0e1:ai1e1:bi2ee"},
+		{
+			name: "struct",
+			v: &testMeta{
+				Announce: "http://tracker",
+				Info:     testInfo{Name: "test", PieceLength: 16384, Pieces: []byte("xyz")},
+			},
+			want: "d8:announce14:http://tracker4:infod4:name4:test12:piece lengthi16384e6:pieces3:xyzee",
+		},
+		{
+			name: "embedded",
+			v: testOuter{
+				testEmbedded: testEmbedded{Shared: "inner", Inner: 1},
+				Shared:       "outer",
+				Skipped:      "skipped",
+				Default:      2,
+				private:      3,
+			},
+			want: "d7:Defaulti2e5:inneri1e6:shared5:outere",
+		},
+		{name: "marshaler", v: map[string]testMarshaler{"m": {}}, want: "d1:m4:marse"},
+		{name: "raw_message", v: []RawMessage{RawMessage("i1e"), RawMessage("le")}, want: "li1elee"},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := Marshal(tc.v)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tc.want != string(got) {
+				t.Errorf("unexpected output, want = %q, got = %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestMarshal_errors(t *testing.T) {
+	var ute *UnsupportedTypeError
+	if _, err := Marshal(1.5); !errors.As(err, &ute) {
+		t.Errorf("expected UnsupportedTypeError, got %v", err)
+	}
+	if _, err := Marshal(map[int]int{1: 1}); !errors.As(err, &ute) {
+		t.Errorf("expected UnsupportedTypeError, got %v", err)
+	}
+
+	var uve *UnsupportedValueError
+	if _, err := Marshal(nil); !errors.As(err, &uve) {
+		t.Errorf("expected UnsupportedValueError, got %v", err)
+	}
+	if _, err := Marshal([]*int{nil}); !errors.As(err, &uve) {
+		t.Errorf("expected UnsupportedValueError, got %v", err)
+	}
+
+	var me *MarshalerError
+	if _, err := Marshal(RawMessage(nil)); !errors.As(err, &me) {
+		t.Errorf("expected MarshalerError, got %v", err)
+	}
+}
+
+func TestEncoder(t *testing.T) {
+	buf := new(bytes.Buffer)
+	enc := NewEncoder(buf)
+	if err := enc.Encode(1); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := enc.Encode("a"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want, got := "i1e1:a", buf.String(); want != got {
+		t.Errorf("unexpected output, want = %q, got = %q", want, got)
+	}
+
+	var n int
+	var s string
+	d := NewDecoder(buf)
+	if err := d.Decode(&n); err != nil || n != 1 {
+		t.Errorf("unexpected value %d, err = %v", n, err)
+	}
+	if err := d.Decode(&s); err != nil || s != "a" {
+		t.Errorf("unexpected value %q, err = %v", s, err)
+	}
+}
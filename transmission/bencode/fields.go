@@ -0,0 +1,126 @@
+package bencode
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// field describes a struct field mapped to a dictionary key.
+type field struct {
+	name      string
+	index     []int
+	typ       reflect.Type
+	tagged    bool
+	omitEmpty bool
+}
+
+var fieldCache sync.Map // map[reflect.Type][]field
+
+// cachedFields returns fields of struct type t sorted by dictionary key.
+func cachedFields(t reflect.Type) []field {
+	if f, ok := fieldCache.Load(t); ok {
+		return f.([]field)
+	}
+	f, _ := fieldCache.LoadOrStore(t, typeFields(t))
+	return f.([]field)
+}
+
+func typeFields(t reflect.Type) []field {
+	type candidate struct {
+		field
+		depth int
+	}
+
+	var candidates []candidate
+	visited := make(map[reflect.Type]bool)
+
+	var walk func(t reflect.Type, index []int, depth int)
+	walk = func(t reflect.Type, index []int, depth int) {
+		if visited[t] {
+			return
+		}
+		visited[t] = true
+		defer delete(visited, t)
+
+		for i := 0; i < t.NumField(); i++ {
+			sf := t.Field(i)
+			tag := sf.Tag.Get("bencode")
+			if tag == "-" {
+				continue
+			}
+			name, opts, _ := strings.Cut(tag, ",")
+
+			idx := make([]int, len(index)+1)
+			copy(idx, index)
+			idx[len(index)] = i
+
+			ft := sf.Type
+			if sf.Anonymous && name == "" {
+				if ft.Kind() == reflect.Pointer {
+					ft = ft.Elem()
+				}
+				if ft.Kind() == reflect.Struct {
+					walk(ft, idx, depth+1)
+					continue
+				}
+			}
+			if !sf.IsExported() {
+				continue
+			}
+
+			f := field{name: name, index: idx, typ: sf.Type, tagged: name != ""}
+			if f.name == "" {
+				f.name = sf.Name
+			}
+			for _, opt := range strings.Split(opts, ",") {
+				if opt == "omitempty" {
+					f.omitEmpty = true
+				}
+			}
+			candidates = append(candidates, candidate{field: f, depth: depth})
+		}
+	}
+	walk(t, nil, 0)
+
+	// The shallowest field wins, a tagged field wins over an untagged one
+	// at the same depth. Remaining conflicts hide each other.
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if candidates[i].name != candidates[j].name {
+			return candidates[i].name < candidates[j].name
+		}
+		if candidates[i].depth != candidates[j].depth {
+			return candidates[i].depth < candidates[j].depth
+		}
+		return candidates[i].tagged && !candidates[j].tagged
+	})
+
+	fields := make([]field, 0, len(candidates))
+	for i := 0; i < len(candidates); {
+		j := i + 1
+		for j < len(candidates) && candidates[j].name == candidates[i].name {
+			j++
+		}
+		dominant := true
+		if j-i > 1 {
+			a, b := candidates[i], candidates[i+1]
+			dominant = a.depth < b.depth || (a.tagged && !b.tagged)
+		}
+		if dominant {
+			fields = append(fields, candidates[i].field)
+		}
+		i = j
+	}
+
+	return fields
+}
+
+// fieldByName returns a field with the given dictionary key.
+func fieldByName(fields []field, name string) *field {
+	i := sort.Search(len(fields), func(i int) bool { return fields[i].name >= name })
+	if i < len(fields) && fields[i].name == name {
+		return &fields[i]
+	}
+	return nil
+}
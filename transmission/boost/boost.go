@@ -0,0 +1,262 @@
+// Package boost temporarily runs torrents or the whole daemon at full speed.
+//
+// Booster records the current priority, queue position, rate limits and
+// "turtle" mode, lifts all the limits and restores the saved values once the
+// boost expires or is cancelled. The saved values are persisted in a state
+// file, so boosts interrupted by a process restart can be finished with
+// Resume.
+package boost
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pborzenkov/go-transmission/transmission"
+)
+
+// Client is a subset of *transmission.Client used by Booster.
+type Client interface {
+	GetTorrents(ctx context.Context, ids transmission.Identifier, fields ...transmission.TorrentField) ([]*transmission.Torrent, error) //nolint:lll
+	SetTorrents(ctx context.Context, ids transmission.Identifier, req *transmission.SetTorrentReq) error
+	StartTorrents(ctx context.Context, ids transmission.Identifier) error
+	StartTorrentsNow(ctx context.Context, ids transmission.Identifier) error
+	StopTorrents(ctx context.Context, ids transmission.Identifier) error
+	QueueMoveToTop(ctx context.Context, ids transmission.Identifier) error
+	GetSession(ctx context.Context, fields ...transmission.SessionField) (*transmission.Session, error)
+	SetSession(ctx context.Context, req *transmission.SetSessionReq) error
+}
+
+var _ Client = (*transmission.Client)(nil)
+
+// Booster boosts torrents and the session. Overlapping boosts are supported,
+// the original values are restored when the last boost covering a torrent or
+// the session ends.
+type Booster struct {
+	client Client
+	path   string
+
+	mu    sync.Mutex
+	state *state
+}
+
+// New returns a Booster that persists its state in the file at path. If path
+// is empty, the state is kept in memory only.
+func New(client Client, path string) (*Booster, error) {
+	st, err := loadState(path)
+	if err != nil {
+		return nil, err
+	}
+	return &Booster{client: client, path: path, state: st}, nil
+}
+
+// Boost raises priority of the torrents identified by ids to high, moves them
+// to the top of the queue, starts them regardless of the queue and lifts
+// their rate limits, including the session ones. Boost blocks until d passes
+// or ctx is cancelled and then restores the saved values. The returned error
+// covers failures to apply or to restore the boost.
+func (b *Booster) Boost(ctx context.Context, ids transmission.Identifier, d time.Duration) error {
+	torrents, err := b.client.GetTorrents(ctx, ids, torrentFields...)
+	if err != nil {
+		return err
+	}
+	if len(torrents) == 0 {
+		return nil
+	}
+
+	rec := &record{Until: time.Now().Add(d)}
+	hashes := make([]transmission.SingularIdentifier, 0, len(torrents))
+	b.mu.Lock()
+	for _, t := range torrents {
+		rec.Hashes = append(rec.Hashes, t.Hash)
+		hashes = append(hashes, t.Hash)
+		if b.coveringLocked(rec, func(r *record) bool { return r.covers(t.Hash) }) == nil {
+			rec.Torrents = append(rec.Torrents, saveTorrent(t))
+		}
+	}
+	err = b.addLocked(rec)
+	b.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	return b.wait(ctx, rec, b.boostTorrents(ctx, transmission.IDs(hashes...)))
+}
+
+func (b *Booster) boostTorrents(ctx context.Context, ids transmission.Identifier) error {
+	err := b.client.SetTorrents(ctx, ids, &transmission.SetTorrentReq{
+		Priority:                 transmission.OptPriority(transmission.PriorityHigh),
+		DownloadRateLimitEnabled: transmission.OptBool(false),
+		UploadRateLimitEnabled:   transmission.OptBool(false),
+		HonorSessionLimits:       transmission.OptBool(false),
+	})
+	if err != nil {
+		return err
+	}
+	if err := b.client.QueueMoveToTop(ctx, ids); err != nil {
+		return err
+	}
+	return b.client.StartTorrentsNow(ctx, ids)
+}
+
+// BoostSession disables "turtle" mode and the session rate limits. Like
+// Boost, it blocks until d passes or ctx is cancelled and then restores the
+// saved values.
+func (b *Booster) BoostSession(ctx context.Context, d time.Duration) error {
+	sess, err := b.client.GetSession(ctx, sessionFields...)
+	if err != nil {
+		return err
+	}
+
+	rec := &record{Until: time.Now().Add(d), Session: true}
+	b.mu.Lock()
+	if b.coveringLocked(rec, func(r *record) bool { return r.Session }) == nil {
+		rec.SavedSession = saveSession(sess)
+	}
+	err = b.addLocked(rec)
+	b.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	return b.wait(ctx, rec, b.client.SetSession(ctx, &transmission.SetSessionReq{
+		TurtleEnabled:            transmission.OptBool(false),
+		DownloadRateLimitEnabled: transmission.OptBool(false),
+		UploadRateLimitEnabled:   transmission.OptBool(false),
+	}))
+}
+
+// Resume finishes boosts started by a previous process. It blocks until all
+// of them expire or ctx is cancelled and restores the saved values. Boosts
+// that failed to be restored before are retried as well.
+func (b *Booster) Resume(ctx context.Context) error {
+	b.mu.Lock()
+	var recs []*record
+	for _, r := range b.state.Records {
+		if r.orphan {
+			r.orphan = false
+			recs = append(recs, r)
+		}
+	}
+	b.mu.Unlock()
+	sort.Slice(recs, func(i, j int) bool { return recs[i].Until.Before(recs[j].Until) })
+
+	var errs []error
+	for _, rec := range recs {
+		errs = append(errs, b.wait(ctx, rec, nil))
+	}
+	return errors.Join(errs...)
+}
+
+func (b *Booster) wait(ctx context.Context, rec *record, err error) error {
+	if err == nil && ctx.Err() == nil {
+		timer := time.NewTimer(time.Until(rec.Until))
+		select {
+		case <-ctx.Done():
+		case <-timer.C:
+		}
+		timer.Stop()
+	}
+	return errors.Join(err, b.restore(context.WithoutCancel(ctx), rec))
+}
+
+func (b *Booster) restore(ctx context.Context, rec *record) error {
+	b.mu.Lock()
+	// Hand saved values over to other boosts that still cover them.
+	var torrents []torrentState
+	for _, s := range rec.Torrents {
+		s := s
+		if other := b.coveringLocked(rec, func(r *record) bool { return r.covers(s.Hash) }); other != nil {
+			other.Torrents = append(other.Torrents, s)
+			continue
+		}
+		torrents = append(torrents, s)
+	}
+	rec.Torrents = torrents
+	if rec.SavedSession != nil {
+		if other := b.coveringLocked(rec, func(r *record) bool { return r.Session }); other != nil {
+			other.SavedSession, rec.SavedSession = rec.SavedSession, nil
+		}
+	}
+	session := rec.SavedSession
+	err := b.state.save(b.path)
+	b.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for i := range torrents {
+		errs = append(errs, b.restoreTorrent(ctx, &torrents[i]))
+	}
+	// Queue positions are restored last and in ascending order, so that
+	// neither restarted torrents nor other moves shift the restored ones.
+	queue := slices.Clone(torrents)
+	sort.Slice(queue, func(i, j int) bool { return queue[i].PositionInQueue < queue[j].PositionInQueue })
+	for _, s := range queue {
+		req := &transmission.SetTorrentReq{PositionInQueue: transmission.OptInt(s.PositionInQueue)}
+		errs = append(errs, b.client.SetTorrents(ctx, s.Hash, req))
+	}
+	if session != nil {
+		errs = append(errs, b.client.SetSession(ctx, session.request()))
+	}
+	if err := errors.Join(errs...); err != nil {
+		b.mu.Lock()
+		rec.orphan = true
+		b.mu.Unlock()
+		return err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for i, r := range b.state.Records {
+		if r == rec {
+			b.state.Records = append(b.state.Records[:i], b.state.Records[i+1:]...)
+			break
+		}
+	}
+	return b.state.save(b.path)
+}
+
+func (b *Booster) restoreTorrent(ctx context.Context, s *torrentState) error {
+	if err := b.client.SetTorrents(ctx, s.Hash, s.request()); err != nil {
+		return err
+	}
+	if !s.Forced {
+		return nil
+	}
+	// Starting a running torrent does nothing, so queued torrents are
+	// stopped first to put them back into the queue.
+	if err := b.client.StopTorrents(ctx, s.Hash); err != nil {
+		return err
+	}
+	switch s.Status {
+	case transmission.StatusDownloadWait, transmission.StatusSeedWait:
+		return b.client.StartTorrents(ctx, s.Hash)
+	}
+	return nil
+}
+
+func (b *Booster) coveringLocked(self *record, covers func(*record) bool) *record {
+	for _, r := range b.state.Records {
+		if r != self && covers(r) {
+			return r
+		}
+	}
+	return nil
+}
+
+func (b *Booster) addLocked(rec *record) error {
+	b.state.NextID++
+	rec.ID = b.state.NextID
+	b.state.Records = append(b.state.Records, rec)
+	if err := b.state.save(b.path); err != nil {
+		b.state.Records = b.state.Records[:len(b.state.Records)-1]
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,363 @@
+package boost
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pborzenkov/go-transmission/transmission"
+)
+
+type fakeClient struct {
+	mu       sync.Mutex
+	torrents map[transmission.Hash]*transmission.Torrent
+	session  transmission.Session
+	boosted  chan struct{}
+	moves    []string
+	err      error
+}
+
+func newFakeClient() *fakeClient {
+	return &fakeClient{
+		torrents: map[transmission.Hash]*transmission.Torrent{
+			"h1": {
+				Hash:                     "h1",
+				Status:                   transmission.StatusStopped,
+				Priority:                 transmission.PriorityLow,
+				PositionInQueue:          3,
+				DownloadRateLimit:        1000,
+				DownloadRateLimitEnabled: true,
+				HonorSessionLimits:       true,
+			},
+			"h2": {
+				Hash:               "h2",
+				Status:             transmission.StatusDownloadWait,
+				PositionInQueue:    5,
+				UploadRateLimit:    2000,
+				UploadRateLimited:  true,
+				HonorSessionLimits: true,
+			},
+		},
+		session: transmission.Session{
+			TurtleEnabled:            true,
+			DownloadRateLimit:        5000,
+			DownloadRateLimitEnabled: true,
+		},
+		boosted: make(chan struct{}, 10),
+	}
+}
+
+func (c *fakeClient) each(ids transmission.Identifier, fn func(t *transmission.Torrent)) {
+	var hashes []transmission.Hash
+	switch ids := ids.(type) {
+	case transmission.Hash:
+		hashes = append(hashes, ids)
+	case transmission.IDList:
+		for _, id := range ids {
+			hashes = append(hashes, id.(transmission.Hash))
+		}
+	default:
+		for h := range c.torrents {
+			hashes = append(hashes, h)
+		}
+	}
+	for _, h := range hashes {
+		if t, ok := c.torrents[h]; ok {
+			fn(t)
+		}
+	}
+}
+
+func (c *fakeClient) GetTorrents(ctx context.Context, ids transmission.Identifier, fields ...transmission.TorrentField) ([]*transmission.Torrent, error) { //nolint:lll
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.err != nil {
+		return nil, c.err
+	}
+	var torrents []*transmission.Torrent
+	c.each(ids, func(t *transmission.Torrent) {
+		copy := *t
+		torrents = append(torrents, &copy)
+	})
+	return torrents, nil
+}
+
+func (c *fakeClient) SetTorrents(ctx context.Context, ids transmission.Identifier, req *transmission.SetTorrentReq) error { //nolint:lll
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.each(ids, func(t *transmission.Torrent) {
+		if req.Priority != nil {
+			t.Priority = *req.Priority
+		}
+		if req.PositionInQueue != nil {
+			t.PositionInQueue = *req.PositionInQueue
+			c.moves = append(c.moves, fmt.Sprint(t.Hash, " ", t.PositionInQueue))
+		}
+		if req.DownloadRateLimit != nil {
+			t.DownloadRateLimit = *req.DownloadRateLimit
+		}
+		if req.DownloadRateLimitEnabled != nil {
+			t.DownloadRateLimitEnabled = *req.DownloadRateLimitEnabled
+		}
+		if req.UploadRateLimit != nil {
+			t.UploadRateLimit = *req.UploadRateLimit
+		}
+		if req.UploadRateLimitEnabled != nil {
+			t.UploadRateLimited = *req.UploadRateLimitEnabled
+		}
+		if req.HonorSessionLimits != nil {
+			t.HonorSessionLimits = *req.HonorSessionLimits
+		}
+	})
+	return nil
+}
+
+func (c *fakeClient) setStatus(ids transmission.Identifier, status transmission.Status) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.each(ids, func(t *transmission.Torrent) { t.Status = status })
+	return nil
+}
+
+// StartTorrents queues stopped torrents, running ones are left as is.
+func (c *fakeClient) StartTorrents(ctx context.Context, ids transmission.Identifier) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.each(ids, func(t *transmission.Torrent) {
+		if t.Status == transmission.StatusStopped {
+			t.Status = transmission.StatusDownloadWait
+		}
+	})
+	return nil
+}
+
+func (c *fakeClient) StartTorrentsNow(ctx context.Context, ids transmission.Identifier) error {
+	defer func() { c.boosted <- struct{}{} }()
+	return c.setStatus(ids, transmission.StatusDownload)
+}
+
+func (c *fakeClient) StopTorrents(ctx context.Context, ids transmission.Identifier) error {
+	return c.setStatus(ids, transmission.StatusStopped)
+}
+
+func (c *fakeClient) QueueMoveToTop(ctx context.Context, ids transmission.Identifier) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.each(ids, func(t *transmission.Torrent) { t.PositionInQueue = 0 })
+	return nil
+}
+
+func (c *fakeClient) GetSession(ctx context.Context, fields ...transmission.SessionField) (*transmission.Session, error) { //nolint:lll
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.err != nil {
+		return nil, c.err
+	}
+	sess := c.session
+	return &sess, nil
+}
+
+func (c *fakeClient) SetSession(ctx context.Context, req *transmission.SetSessionReq) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if req.TurtleEnabled != nil {
+		c.session.TurtleEnabled = *req.TurtleEnabled
+	}
+	if req.DownloadRateLimit != nil {
+		c.session.DownloadRateLimit = *req.DownloadRateLimit
+	}
+	if req.DownloadRateLimitEnabled != nil {
+		c.session.DownloadRateLimitEnabled = *req.DownloadRateLimitEnabled
+	}
+	if req.UploadRateLimit != nil {
+		c.session.UploadRateLimit = *req.UploadRateLimit
+	}
+	if req.UploadRateLimitEnabled != nil {
+		c.session.UploadRateLimitEnabled = *req.UploadRateLimitEnabled
+	}
+	c.boosted <- struct{}{}
+	return nil
+}
+
+func (c *fakeClient) snapshot() map[transmission.Hash]transmission.Torrent {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	snap := make(map[transmission.Hash]transmission.Torrent, len(c.torrents))
+	for h, t := range c.torrents {
+		snap[h] = *t
+	}
+	return snap
+}
+
+func TestBoost(t *testing.T) {
+	client := newFakeClient()
+	orig := client.snapshot()
+
+	b, err := New(client, filepath.Join(t.TempDir(), "boost.json"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	done := make(chan error)
+	go func() { done <- b.Boost(context.Background(), transmission.All(), 50*time.Millisecond) }()
+	<-client.boosted
+
+	for h, tt := range client.snapshot() {
+		if tt.Priority != transmission.PriorityHigh || tt.Status != transmission.StatusDownload ||
+			tt.PositionInQueue != 0 || tt.DownloadRateLimitEnabled || tt.UploadRateLimited || tt.HonorSessionLimits {
+			t.Errorf("torrent %s is not boosted: %+v", h, tt)
+		}
+	}
+
+	if err := <-done; err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := client.snapshot(); !cmp.Equal(orig, got) {
+		t.Errorf("unexpected torrents after boost, diff = \n%s", cmp.Diff(orig, got))
+	}
+	// Queue positions are restored in ascending order.
+	if want := []string{"h1 3", "h2 5"}; !cmp.Equal(want, client.moves) {
+		t.Errorf("unexpected queue moves, diff = \n%s", cmp.Diff(want, client.moves))
+	}
+}
+
+func TestBoost_overlap(t *testing.T) {
+	client := newFakeClient()
+	orig := client.snapshot()
+
+	b, err := New(client, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	ctx1, cancel1 := context.WithCancel(context.Background())
+	done1 := make(chan error)
+	go func() { done1 <- b.Boost(ctx1, transmission.Hash("h1"), time.Hour) }()
+	<-client.boosted
+
+	ctx2, cancel2 := context.WithCancel(context.Background())
+	done2 := make(chan error)
+	go func() { done2 <- b.Boost(ctx2, transmission.All(), time.Hour) }()
+	<-client.boosted
+
+	// The first boost ends, but h1 is still boosted by the second one.
+	cancel1()
+	if err := <-done1; err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := client.snapshot()["h1"]; got.Priority != transmission.PriorityHigh {
+		t.Errorf("expected h1 to stay boosted, got = %+v", got)
+	}
+
+	cancel2()
+	if err := <-done2; err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := client.snapshot(); !cmp.Equal(orig, got) {
+		t.Errorf("unexpected torrents after boost, diff = \n%s", cmp.Diff(orig, got))
+	}
+}
+
+func TestBoostSession(t *testing.T) {
+	client := newFakeClient()
+	orig := client.session
+
+	b, err := New(client, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- b.BoostSession(ctx, time.Hour) }()
+	<-client.boosted
+
+	client.mu.Lock()
+	if client.session.TurtleEnabled || client.session.DownloadRateLimitEnabled {
+		t.Errorf("session is not boosted: %+v", client.session)
+	}
+	client.mu.Unlock()
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !cmp.Equal(orig, client.session) {
+		t.Errorf("unexpected session after boost, diff = \n%s", cmp.Diff(orig, client.session))
+	}
+}
+
+func TestResume(t *testing.T) {
+	client := newFakeClient()
+	orig := client.snapshot()
+	path := filepath.Join(t.TempDir(), "boost.json")
+
+	b, err := New(client, path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Simulate a crash: the boost is applied, but never restored.
+	rec := &record{Until: time.Now().Add(10 * time.Millisecond), Hashes: []transmission.Hash{"h1"}}
+	rec.Torrents = append(rec.Torrents, saveTorrent(client.torrents["h1"]))
+	b.mu.Lock()
+	if err := b.addLocked(rec); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	b.mu.Unlock()
+	if err := b.boostTorrents(context.Background(), transmission.Hash("h1")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	b, err = New(client, path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := b.Resume(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := client.snapshot(); !cmp.Equal(orig, got) {
+		t.Errorf("unexpected torrents after resume, diff = \n%s", cmp.Diff(orig, got))
+	}
+
+	b, err = New(client, path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(b.state.Records) != 0 {
+		t.Errorf("expected no boosts left, got = %d", len(b.state.Records))
+	}
+}
+
+func TestBoost_errors(t *testing.T) {
+	client := newFakeClient()
+	client.err = errors.New("daemon is down")
+
+	b, err := New(client, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := b.Boost(context.Background(), transmission.All(), time.Hour); err == nil {
+		t.Errorf("expected Boost to fail")
+	}
+	if err := b.BoostSession(context.Background(), time.Hour); err == nil {
+		t.Errorf("expected BoostSession to fail")
+	}
+
+	if _, err := New(client, filepath.Join(t.TempDir(), "missing", "boost.json")); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
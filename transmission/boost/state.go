@@ -0,0 +1,177 @@
+package boost
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"time"
+
+	"github.com/pborzenkov/go-transmission/transmission"
+	"github.com/pborzenkov/go-transmission/transmission/internal/atomicfile"
+)
+
+// torrentState is the part of a torrent state changed by a boost.
+type torrentState struct {
+	Hash                     transmission.Hash     `json:"hash"`
+	Status                   transmission.Status   `json:"status"`
+	Priority                 transmission.Priority `json:"priority"`
+	PositionInQueue          int                   `json:"queuePosition"`
+	DownloadRateLimit        int64                 `json:"downloadLimit"`
+	DownloadRateLimitEnabled bool                  `json:"downloadLimited"`
+	UploadRateLimit          int64                 `json:"uploadLimit"`
+	UploadRateLimitEnabled   bool                  `json:"uploadLimited"`
+	HonorSessionLimits       bool                  `json:"honorsSessionLimits"`
+	Forced                   bool                  `json:"forced,omitempty"`
+}
+
+var torrentFields = []transmission.TorrentField{
+	transmission.TorrentFieldHash,
+	transmission.TorrentFieldStatus,
+	transmission.TorrentFieldPriority,
+	transmission.TorrentFieldPositionInQueue,
+	transmission.TorrentFieldDownloadRateLimit,
+	transmission.TorrentFieldDownloadRateLimitEnabled,
+	transmission.TorrentFieldUploadRateLimit,
+	transmission.TorrentFieldUploadRateLimited,
+	transmission.TorrentFieldHonorSessionLimits,
+}
+
+// saveTorrent saves the state of t before it is boosted. Torrents that are not
+// running are started bypassing the queue, which keeps them running after the
+// boost unless they are stopped again.
+func saveTorrent(t *transmission.Torrent) torrentState {
+	return torrentState{
+		Hash:                     t.Hash,
+		Status:                   t.Status,
+		Priority:                 t.Priority,
+		PositionInQueue:          t.PositionInQueue,
+		DownloadRateLimit:        t.DownloadRateLimit,
+		DownloadRateLimitEnabled: t.DownloadRateLimitEnabled,
+		UploadRateLimit:          t.UploadRateLimit,
+		UploadRateLimitEnabled:   t.UploadRateLimited,
+		HonorSessionLimits:       t.HonorSessionLimits,
+		Forced:                   t.Status != transmission.StatusDownload && t.Status != transmission.StatusSeed,
+	}
+}
+
+func (s *torrentState) request() *transmission.SetTorrentReq {
+	return &transmission.SetTorrentReq{
+		Priority:                 transmission.OptPriority(s.Priority),
+		DownloadRateLimit:        transmission.OptInt64(s.DownloadRateLimit),
+		DownloadRateLimitEnabled: transmission.OptBool(s.DownloadRateLimitEnabled),
+		UploadRateLimit:          transmission.OptInt64(s.UploadRateLimit),
+		UploadRateLimitEnabled:   transmission.OptBool(s.UploadRateLimitEnabled),
+		HonorSessionLimits:       transmission.OptBool(s.HonorSessionLimits),
+	}
+}
+
+// sessionState is the part of a session state changed by a boost.
+type sessionState struct {
+	TurtleEnabled            bool  `json:"turtleEnabled"`
+	DownloadRateLimit        int64 `json:"downloadLimit"`
+	DownloadRateLimitEnabled bool  `json:"downloadLimited"`
+	UploadRateLimit          int64 `json:"uploadLimit"`
+	UploadRateLimitEnabled   bool  `json:"uploadLimited"`
+}
+
+var sessionFields = []transmission.SessionField{
+	transmission.SessionFieldTurtleEnabled,
+	transmission.SessionFieldDownloadRateLimit,
+	transmission.SessionFieldDownloadRateLimitEnabled,
+	transmission.SessionFieldUploadRateLimit,
+	transmission.SessionFieldUploadRateLimitEnabled,
+}
+
+func saveSession(s *transmission.Session) *sessionState {
+	return &sessionState{
+		TurtleEnabled:            s.TurtleEnabled,
+		DownloadRateLimit:        s.DownloadRateLimit,
+		DownloadRateLimitEnabled: s.DownloadRateLimitEnabled,
+		UploadRateLimit:          s.UploadRateLimit,
+		UploadRateLimitEnabled:   s.UploadRateLimitEnabled,
+	}
+}
+
+func (s *sessionState) request() *transmission.SetSessionReq {
+	return &transmission.SetSessionReq{
+		TurtleEnabled:            transmission.OptBool(s.TurtleEnabled),
+		DownloadRateLimit:        transmission.OptInt64(s.DownloadRateLimit),
+		DownloadRateLimitEnabled: transmission.OptBool(s.DownloadRateLimitEnabled),
+		UploadRateLimit:          transmission.OptInt64(s.UploadRateLimit),
+		UploadRateLimitEnabled:   transmission.OptBool(s.UploadRateLimitEnabled),
+	}
+}
+
+// record is a single active boost. Only the first boost covering a torrent
+// or the session saves its original state. When a boost ends while another
+// one still covers the torrent or the session, the saved state is handed over
+// to that boost instead of being restored.
+type record struct {
+	ID    int64     `json:"id"`
+	Until time.Time `json:"until"`
+	// Boosted torrents
+	Hashes []transmission.Hash `json:"hashes,omitempty"`
+	// Original states of the torrents owned by the boost
+	Torrents []torrentState `json:"torrents,omitempty"`
+	// Indicates whether the session is boosted
+	Session bool `json:"session,omitempty"`
+	// Original state of the session, if owned by the boost
+	SavedSession *sessionState `json:"savedSession,omitempty"`
+
+	// Indicates whether nobody waits for the boost to expire
+	orphan bool
+}
+
+func (r *record) covers(hash transmission.Hash) bool {
+	for _, h := range r.Hashes {
+		if h == hash {
+			return true
+		}
+	}
+	return false
+}
+
+type state struct {
+	Version int       `json:"version"`
+	NextID  int64     `json:"nextID"`
+	Records []*record `json:"records"`
+}
+
+const stateVersion = 1
+
+func loadState(path string) (*state, error) {
+	st := &state{Version: stateVersion}
+	if path == "" {
+		return st, nil
+	}
+
+	data, err := os.ReadFile(path)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return st, nil
+	case err != nil:
+		return nil, err
+	}
+	if err := json.Unmarshal(data, st); err != nil {
+		return nil, err
+	}
+	if st.Version != stateVersion {
+		return nil, errors.New("boost: unsupported state version")
+	}
+	for _, r := range st.Records {
+		r.orphan = true
+	}
+	return st, nil
+}
+
+func (st *state) save(path string) error {
+	if path == "" {
+		return nil
+	}
+	data, err := json.Marshal(st)
+	if err != nil {
+		return err
+	}
+	return atomicfile.Write(path, data, 0o644)
+}
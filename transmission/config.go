@@ -11,6 +11,8 @@ type config struct {
 	UserAgent string
 
 	HTTPClient *http.Client
+
+	PathMapping map[string]string
 }
 
 // Option customizes client behaviour
@@ -45,3 +47,16 @@ func WithUserAgent(ua string) Option {
 		c.UserAgent = ua
 	})
 }
+
+// WithPathMapping translates filesystem paths between the client and the
+// daemon, e.g. when the daemon runs in a container with host directories
+// mounted at different locations. Keys of mapping are client side paths and
+// values are the corresponding daemon side paths. Paths in requests are
+// translated to the daemon side and paths in replies are translated back,
+// the longest matching prefix wins. Paths that don't match any prefix are
+// left intact. AddTorrentReq.URL is never translated.
+func WithPathMapping(mapping map[string]string) Option {
+	return optionFunc(func(c *config) {
+		c.PathMapping = mapping
+	})
+}
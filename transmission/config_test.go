@@ -35,6 +35,13 @@ func TestOption(t *testing.T) {
 				UserAgent: "go-transmission",
 			},
 		},
+		{
+			name: "path_mapping",
+			opt:  WithPathMapping(map[string]string{"/srv/media": "/downloads"}),
+			want: config{
+				PathMapping: map[string]string{"/srv/media": "/downloads"},
+			},
+		},
 	}
 
 	for _, tc := range tests {
@@ -0,0 +1,329 @@
+// Package diskguard pauses downloads before the disk fills up.
+//
+// Guard periodically checks free space in the session download and incomplete
+// directories and in download directories of all torrents, and compares it
+// with the amount of data the active downloads still need. When a directory
+// runs low, the lowest priority downloads stored there are paused. They are
+// resumed once enough space is available again. Paused downloads are marked
+// with MetaKey metadata, so they are resumed even if Guard is restarted in
+// between.
+package diskguard
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pborzenkov/go-transmission/transmission"
+	"github.com/pborzenkov/go-transmission/transmission/internal/periodic"
+)
+
+const defaultInterval = time.Minute
+
+// MetaKey is the metadata key (see transmission.MetaLabel) Guard marks the
+// torrents it paused with.
+const MetaKey = "diskguard"
+
+const metaPaused = "paused"
+
+// Client is a subset of *transmission.Client used by Guard.
+type Client interface {
+	GetSession(ctx context.Context, fields ...transmission.SessionField) (*transmission.Session, error)
+	GetTorrents(ctx context.Context, ids transmission.Identifier, fields ...transmission.TorrentField) ([]*transmission.Torrent, error) //nolint:lll
+	GetFreeSpace(ctx context.Context, path string) (int64, error)
+	StartTorrents(ctx context.Context, ids transmission.Identifier) error
+	StopTorrents(ctx context.Context, ids transmission.Identifier) error
+	SetMeta(ctx context.Context, ids transmission.Identifier, meta map[string]string) error
+	DeleteMeta(ctx context.Context, ids transmission.Identifier, keys ...string) error
+}
+
+var _ Client = (*transmission.Client)(nil)
+
+// EventType is a type of Event.
+type EventType int
+
+const (
+	// EventLowSpace is emitted when free space in a directory is below
+	// Guard.MinFree, taking the active downloads into account
+	EventLowSpace EventType = iota
+	// EventPaused is emitted when a download is paused
+	EventPaused
+	// EventResumed is emitted when a previously paused download is resumed
+	EventResumed
+)
+
+// String implements fmt.Stringer.
+func (t EventType) String() string {
+	switch t {
+	case EventLowSpace:
+		return "low space"
+	case EventPaused:
+		return "paused"
+	case EventResumed:
+		return "resumed"
+	default:
+		return fmt.Sprintf("EventType(%d)", t)
+	}
+}
+
+// Event describes an action taken or a condition detected by Guard.
+type Event struct {
+	// Type of the event
+	Type EventType
+	// Directory the event relates to
+	Path string
+	// Free space in the directory (bytes)
+	Free int64
+	// Data still needed by the active downloads in the directory (bytes)
+	Needed int64
+	// Paused or resumed torrent (EventPaused and EventResumed only)
+	Torrent *transmission.Torrent
+	// An error that prevented the torrent from being paused or resumed
+	Err error
+}
+
+// String returns a human readable description of the event.
+func (e *Event) String() string {
+	s := fmt.Sprintf("%s: %s (free %d, needed %d)", e.Path, e.Type, e.Free, e.Needed)
+	if e.Torrent != nil {
+		s += fmt.Sprintf(": torrent %d (%s)", e.Torrent.ID, e.Torrent.Name)
+	}
+	if e.Err != nil {
+		s += fmt.Sprintf(": %v", e.Err)
+	}
+	return s
+}
+
+var torrentFields = []transmission.TorrentField{
+	transmission.TorrentFieldID,
+	transmission.TorrentFieldHash,
+	transmission.TorrentFieldName,
+	transmission.TorrentFieldStatus,
+	transmission.TorrentFieldPriority,
+	transmission.TorrentFieldPositionInQueue,
+	transmission.TorrentFieldDownloadDirectory,
+	transmission.TorrentFieldWantedLeft,
+	transmission.TorrentFieldLabels,
+}
+
+// Guard monitors free disk space. Directories are checked independently, so
+// directories on the same file system should be avoided or MinFree should
+// account for that.
+type Guard struct {
+	// Transmission client
+	Client Client
+	// Downloads are paused when free space minus data still needed by the
+	// active downloads drops below MinFree (bytes)
+	MinFree int64
+	// A paused download is resumed when free space minus data needed by the
+	// active downloads and the resumed one stays at or above ResumeFree
+	// (bytes). If less than MinFree, 2*MinFree is used
+	ResumeFree int64
+	// Interval between checks in Run. If 0, 1 minute is used
+	Interval time.Duration
+	// Called with every event
+	OnEvent func(Event)
+	// Called when Run fails to query the session, the torrents or free space
+	// of a download directory. Failures to pause or resume a torrent are
+	// reported as events instead
+	OnError func(error)
+
+	mu     sync.Mutex
+	paused map[transmission.Hash]bool
+}
+
+func (g *Guard) resumeFree() int64 {
+	if g.ResumeFree < g.MinFree {
+		return 2 * g.MinFree
+	}
+	return g.ResumeFree
+}
+
+// Paused returns hashes of the torrents paused by Guard as of the last Check.
+func (g *Guard) Paused() []transmission.Hash {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	hashes := make([]transmission.Hash, 0, len(g.paused))
+	for h := range g.paused {
+		hashes = append(hashes, h)
+	}
+	sort.Slice(hashes, func(i, j int) bool { return hashes[i] < hashes[j] })
+	return hashes
+}
+
+type directory struct {
+	path   string
+	free   int64
+	active []*transmission.Torrent
+	paused []*transmission.Torrent
+	needed int64
+}
+
+// Check checks free space once, pauses or resumes downloads as needed and
+// returns the emitted events.
+func (g *Guard) Check(ctx context.Context) ([]Event, error) {
+	sess, err := g.Client.GetSession(ctx,
+		transmission.SessionFieldDownloadDirectory,
+		transmission.SessionFieldIncompleteDirectory,
+		transmission.SessionFieldIncompleteDirectoryEnabled,
+	)
+	if err != nil {
+		return nil, err
+	}
+	torrents, err := g.Client.GetTorrents(ctx, transmission.All(), torrentFields...)
+	if err != nil {
+		return nil, err
+	}
+
+	dirs := make(map[string]*directory)
+	var order []string
+	dir := func(path string) *directory {
+		d, ok := dirs[path]
+		if !ok {
+			d = &directory{path: path}
+			dirs[path] = d
+			order = append(order, path)
+		}
+		return d
+	}
+	dir(sess.DownloadDirectory)
+	if sess.IncompleteDirectoryEnabled {
+		dir(sess.IncompleteDirectory)
+	}
+
+	var (
+		events []Event
+		errs   []error
+		stale  transmission.IDList
+	)
+	paused := make(map[transmission.Hash]bool)
+	for _, t := range torrents {
+		d := dir(t.DownloadDirectory)
+		marked := transmission.MatchMeta(t, MetaKey, metaPaused)
+		if t.WantedLeft == 0 {
+			if marked {
+				stale = append(stale, t.ID)
+			}
+			continue
+		}
+		// Incomplete data is stored in the incomplete directory, if enabled.
+		if sess.IncompleteDirectoryEnabled {
+			d = dir(sess.IncompleteDirectory)
+		}
+		switch {
+		case marked && t.Status == transmission.StatusStopped:
+			paused[t.Hash] = true
+			d.paused = append(d.paused, t)
+		case t.Status != transmission.StatusStopped:
+			// Torrents started by someone else are not managed anymore.
+			if marked {
+				stale = append(stale, t.ID)
+			}
+			d.active = append(d.active, t)
+			d.needed += t.WantedLeft
+		}
+	}
+	if len(stale) > 0 {
+		if err := g.Client.DeleteMeta(ctx, stale, MetaKey); err != nil {
+			errs = append(errs, fmt.Errorf("diskguard: failed to unmark torrents: %w", err))
+		}
+	}
+	g.mu.Lock()
+	g.paused = paused
+	g.mu.Unlock()
+
+	emit := func(e Event) {
+		events = append(events, e)
+		if g.OnEvent != nil {
+			g.OnEvent(e)
+		}
+	}
+	for _, path := range order {
+		d := dirs[path]
+		if path == "" {
+			continue
+		}
+		if d.free, err = g.Client.GetFreeSpace(ctx, path); err != nil {
+			errs = append(errs, fmt.Errorf("diskguard: %s: %w", path, err))
+			continue
+		}
+
+		if d.free-d.needed < g.MinFree {
+			emit(Event{Type: EventLowSpace, Path: path, Free: d.free, Needed: d.needed})
+			g.pause(ctx, d, emit)
+		} else {
+			g.resume(ctx, d, emit)
+		}
+	}
+	return events, errors.Join(errs...)
+}
+
+// less orders torrents from the least to the most important.
+func less(a, b *transmission.Torrent) bool {
+	if a.Priority != b.Priority {
+		return a.Priority < b.Priority
+	}
+	return a.PositionInQueue > b.PositionInQueue
+}
+
+func (g *Guard) pause(ctx context.Context, d *directory, emit func(Event)) {
+	sort.Slice(d.active, func(i, j int) bool { return less(d.active[i], d.active[j]) })
+	for _, t := range d.active {
+		if d.free-d.needed >= g.MinFree {
+			return
+		}
+		// The torrent is marked first, a marked torrent that failed to stop
+		// is unmarked by the next Check.
+		err := g.Client.SetMeta(ctx, t.ID, map[string]string{MetaKey: metaPaused})
+		if err == nil {
+			err = g.Client.StopTorrents(ctx, t.ID)
+		}
+		if err == nil {
+			d.needed -= t.WantedLeft
+			g.mu.Lock()
+			g.paused[t.Hash] = true
+			g.mu.Unlock()
+		}
+		emit(Event{Type: EventPaused, Path: d.path, Free: d.free, Needed: d.needed, Torrent: t, Err: err})
+	}
+}
+
+func (g *Guard) resume(ctx context.Context, d *directory, emit func(Event)) {
+	sort.Slice(d.paused, func(i, j int) bool { return less(d.paused[j], d.paused[i]) })
+	for _, t := range d.paused {
+		if d.free-d.needed-t.WantedLeft < g.resumeFree() {
+			return
+		}
+		err := g.Client.StartTorrents(ctx, t.ID)
+		if err == nil {
+			d.needed += t.WantedLeft
+			g.mu.Lock()
+			delete(g.paused, t.Hash)
+			g.mu.Unlock()
+			// A mark left on the running torrent is removed by the next Check.
+			_ = g.Client.DeleteMeta(ctx, t.ID, MetaKey)
+		}
+		emit(Event{Type: EventResumed, Path: d.path, Free: d.free, Needed: d.needed, Torrent: t, Err: err})
+	}
+}
+
+// Run checks free space right away and then every Interval until ctx is
+// done. It returns ctx.Err(). Downloads paused by an earlier run of the guard
+// are resumed once there is enough space again.
+func (g *Guard) Run(ctx context.Context) error {
+	if g.Client == nil {
+		return errors.New("diskguard: client is not set")
+	}
+	interval := g.Interval
+	if interval <= 0 {
+		interval = defaultInterval
+	}
+	return periodic.Run(ctx, interval, func(ctx context.Context) error {
+		_, err := g.Check(ctx)
+		return err
+	}, g.OnError)
+}
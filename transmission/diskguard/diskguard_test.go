@@ -0,0 +1,244 @@
+package diskguard
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pborzenkov/go-transmission/transmission"
+)
+
+type fakeClient struct {
+	session  transmission.Session
+	torrents []*transmission.Torrent
+	free     map[string]int64
+	calls    []string
+	err      error
+}
+
+func (c *fakeClient) GetSession(ctx context.Context, fields ...transmission.SessionField) (*transmission.Session, error) { //nolint:lll
+	if c.err != nil {
+		return nil, c.err
+	}
+	sess := c.session
+	return &sess, nil
+}
+
+func (c *fakeClient) GetTorrents(ctx context.Context, ids transmission.Identifier, fields ...transmission.TorrentField) ([]*transmission.Torrent, error) { //nolint:lll
+	torrents := make([]*transmission.Torrent, 0, len(c.torrents))
+	for _, t := range c.torrents {
+		copy := *t
+		torrents = append(torrents, &copy)
+	}
+	return torrents, nil
+}
+
+func (c *fakeClient) GetFreeSpace(ctx context.Context, path string) (int64, error) {
+	free, ok := c.free[path]
+	if !ok {
+		return 0, errors.New("no such directory")
+	}
+	return free, nil
+}
+
+func (c *fakeClient) setStatus(ids transmission.Identifier, status transmission.Status) {
+	for _, t := range c.torrents {
+		if t.ID == ids {
+			t.Status = status
+		}
+	}
+}
+
+func (c *fakeClient) updateLabels(ids transmission.Identifier, meta map[string]string, remove ...string) {
+	for _, t := range c.torrents {
+		if t.ID == ids {
+			t.Labels = transmission.LabelsWithMeta(t.Labels, meta, remove...)
+			continue
+		}
+		if list, ok := ids.(transmission.IDList); ok {
+			for _, id := range list {
+				if t.ID == id {
+					t.Labels = transmission.LabelsWithMeta(t.Labels, meta, remove...)
+				}
+			}
+		}
+	}
+}
+
+func (c *fakeClient) SetMeta(ctx context.Context, ids transmission.Identifier, meta map[string]string) error {
+	c.calls = append(c.calls, fmt.Sprint("mark ", ids))
+	c.updateLabels(ids, meta)
+	return nil
+}
+
+func (c *fakeClient) DeleteMeta(ctx context.Context, ids transmission.Identifier, keys ...string) error {
+	c.calls = append(c.calls, fmt.Sprint("unmark ", ids))
+	c.updateLabels(ids, nil, keys...)
+	return nil
+}
+
+func (c *fakeClient) StartTorrents(ctx context.Context, ids transmission.Identifier) error {
+	c.calls = append(c.calls, fmt.Sprint("start ", ids))
+	c.setStatus(ids, transmission.StatusDownload)
+	return nil
+}
+
+func (c *fakeClient) StopTorrents(ctx context.Context, ids transmission.Identifier) error {
+	c.calls = append(c.calls, fmt.Sprint("stop ", ids))
+	c.setStatus(ids, transmission.StatusStopped)
+	return nil
+}
+
+func newFakeClient() *fakeClient {
+	return &fakeClient{
+		session: transmission.Session{DownloadDirectory: "/downloads"},
+		torrents: []*transmission.Torrent{
+			{ID: 1, Hash: "h1", DownloadDirectory: "/downloads", Status: transmission.StatusDownload,
+				WantedLeft: 40, Priority: transmission.PriorityHigh},
+			{ID: 2, Hash: "h2", DownloadDirectory: "/downloads", Status: transmission.StatusDownload,
+				WantedLeft: 30, PositionInQueue: 1},
+			{ID: 3, Hash: "h3", DownloadDirectory: "/downloads", Status: transmission.StatusDownloadWait,
+				WantedLeft: 20, PositionInQueue: 2},
+			{ID: 4, Hash: "h4", DownloadDirectory: "/media", Status: transmission.StatusSeed},
+			{ID: 5, Hash: "h5", DownloadDirectory: "/downloads", Status: transmission.StatusStopped,
+				WantedLeft: 100},
+		},
+		free: map[string]int64{"/downloads": 100, "/media": 1000},
+	}
+}
+
+func eventTypes(events []Event) []string {
+	var types []string
+	for _, e := range events {
+		s := e.Type.String()
+		if e.Torrent != nil {
+			s += fmt.Sprint(" ", e.Torrent.ID)
+		}
+		types = append(types, s)
+	}
+	return types
+}
+
+func TestGuard_Check(t *testing.T) {
+	client := newFakeClient()
+
+	var emitted int
+	guard := &Guard{Client: client, MinFree: 50, ResumeFree: 60, OnEvent: func(Event) { emitted++ }}
+
+	// 100 free - 90 needed < 50, pausing 3 and 2 leaves 100 - 40 >= 50.
+	events, err := guard.Check(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want, got := []string{"low space", "paused 3", "paused 2"}, eventTypes(events); !cmp.Equal(want, got) {
+		t.Errorf("unexpected events, diff = \n%s", cmp.Diff(want, got))
+	}
+	if want, got := len(events), emitted; want != got {
+		t.Errorf("unexpected number of emitted events, want = %d, got = %d", want, got)
+	}
+	if want, got := []transmission.Hash{"h2", "h3"}, guard.Paused(); !cmp.Equal(want, got) {
+		t.Errorf("unexpected paused torrents, diff = \n%s", cmp.Diff(want, got))
+	}
+
+	// Nothing changes while space doesn't recover.
+	if events, _ := guard.Check(context.Background()); len(events) != 0 {
+		t.Errorf("unexpected events: %v", eventTypes(events))
+	}
+
+	// 130 free - 40 needed - 30 for 2 >= 60, but adding 3 would leave 40.
+	client.free["/downloads"] = 130
+	events, err = guard.Check(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want, got := []string{"resumed 2"}, eventTypes(events); !cmp.Equal(want, got) {
+		t.Errorf("unexpected events, diff = \n%s", cmp.Diff(want, got))
+	}
+
+	// Torrents paused before a restart are still resumed.
+	guard = &Guard{Client: client, MinFree: 50, ResumeFree: 60}
+	if events, _ := guard.Check(context.Background()); len(events) != 0 {
+		t.Errorf("unexpected events: %v", eventTypes(events))
+	}
+	if want, got := []transmission.Hash{"h3"}, guard.Paused(); !cmp.Equal(want, got) {
+		t.Errorf("unexpected paused torrents, diff = \n%s", cmp.Diff(want, got))
+	}
+
+	// Torrent 3 is started by the user, so it is not managed anymore.
+	client.setStatus(transmission.ID(3), transmission.StatusDownload)
+	client.free["/downloads"] = 1000
+	if events, _ := guard.Check(context.Background()); len(events) != 0 {
+		t.Errorf("unexpected events: %v", eventTypes(events))
+	}
+	if len(guard.Paused()) != 0 {
+		t.Errorf("unexpected paused torrents: %v", guard.Paused())
+	}
+
+	want := []string{"mark 3", "stop 3", "mark 2", "stop 2", "start 2", "unmark 2", "unmark [3]"}
+	if !cmp.Equal(want, client.calls) {
+		t.Errorf("unexpected calls, diff = \n%s", cmp.Diff(want, client.calls))
+	}
+}
+
+func TestGuard_Check_incomplete(t *testing.T) {
+	client := newFakeClient()
+	client.session.IncompleteDirectory = "/incomplete"
+	client.session.IncompleteDirectoryEnabled = true
+	client.free["/incomplete"] = 60
+
+	guard := &Guard{Client: client, MinFree: 10}
+	events, err := guard.Check(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []string{"low space", "paused 3", "paused 2"}
+	if got := eventTypes(events); !cmp.Equal(want, got) {
+		t.Errorf("unexpected events, diff = \n%s", cmp.Diff(want, got))
+	}
+	if events[0].Path != "/incomplete" {
+		t.Errorf("unexpected path, want = %q, got = %q", "/incomplete", events[0].Path)
+	}
+}
+
+func TestGuard_Check_errors(t *testing.T) {
+	client := newFakeClient()
+	delete(client.free, "/media")
+
+	guard := &Guard{Client: client, MinFree: 10}
+	if _, err := guard.Check(context.Background()); err == nil {
+		t.Errorf("expected missing directory to be reported")
+	}
+
+	client.err = errors.New("daemon is down")
+	if _, err := guard.Check(context.Background()); err == nil {
+		t.Errorf("expected Check to fail")
+	}
+}
+
+func TestGuard_Run(t *testing.T) {
+	client := newFakeClient()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	guard := &Guard{
+		Client:   client,
+		MinFree:  50,
+		Interval: time.Hour,
+		OnEvent:  func(Event) { cancel() },
+	}
+	if err := guard.Run(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("unexpected error, want = %v, got = %v", context.Canceled, err)
+	}
+	if err := (&Guard{}).Run(context.Background()); err == nil {
+		t.Errorf("expected guard without client to fail")
+	}
+}
+
+func TestEvent_String(t *testing.T) {
+	e := Event{Type: EventPaused, Path: "/d", Free: 1, Needed: 2, Torrent: &transmission.Torrent{ID: 3, Name: "t"}}
+	if want, got := "/d: paused (free 1, needed 2): torrent 3 (t)", e.String(); want != got {
+		t.Errorf("unexpected string, want = %q, got = %q", want, got)
+	}
+}
@@ -0,0 +1,107 @@
+package errcause
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/pborzenkov/go-transmission/transmission"
+	"github.com/pborzenkov/go-transmission/transmission/internal/periodic"
+)
+
+const defaultCleanupInterval = time.Hour
+
+// Cleanup removes torrents that are unregistered by their trackers.
+type Cleanup struct {
+	// Transmission client
+	Client Client
+	// Classifier of torrent errors. If nil, DefaultPatterns are used
+	Classifier *Classifier
+	// A torrent is removed only after it was seen unregistered by
+	// consecutive calls to Clean for at least GracePeriod. This protects
+	// against trackers temporarily misreporting torrents. If 0, torrents
+	// are removed immediately
+	GracePeriod time.Duration
+	// Remove downloaded data together with the torrent
+	RemoveData bool
+	// Only report torrents that would be removed, don't remove them
+	DryRun bool
+	// Interval between cleanups in Run. If 0, 1 hour is used
+	Interval time.Duration
+	// Called with every result
+	OnResult func(*Result)
+	// Called when Run fails to list the torrents
+	OnError func(error)
+
+	mu   sync.Mutex
+	seen map[transmission.Hash]time.Time
+}
+
+// Clean removes unregistered torrents, unless DryRun is set. A failure to
+// remove a torrent doesn't stop the process, instead it is reported in the
+// corresponding result.
+func (c *Cleanup) Clean(ctx context.Context) ([]*Result, error) {
+	torrents, err := c.Client.GetTorrents(ctx, transmission.All(), Fields...)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	seen := make(map[transmission.Hash]time.Time)
+	var results []*Result
+	for _, t := range torrents {
+		cause, msg := c.Classifier.ClassifyTorrent(t)
+		if cause != CauseUnregistered {
+			continue
+		}
+		since, ok := c.seen[t.Hash]
+		if !ok {
+			since = now
+		}
+		seen[t.Hash] = since
+		if now.Sub(since) < c.GracePeriod {
+			continue
+		}
+
+		res := &Result{ID: t.ID, Hash: t.Hash, Name: t.Name, Cause: cause, Message: msg, Action: ActionRemove}
+		if !c.DryRun {
+			if res.Err = c.remove(ctx, t); res.Err == nil {
+				res.Applied = true
+				delete(seen, t.Hash)
+			}
+		}
+		results = append(results, res)
+		if c.OnResult != nil {
+			c.OnResult(res)
+		}
+	}
+	c.seen = seen
+	return results, nil
+}
+
+func (c *Cleanup) remove(ctx context.Context, t *transmission.Torrent) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return c.Client.RemoveTorrents(ctx, t.ID, c.RemoveData)
+}
+
+// Run removes torrents that trackers report as unregistered, right away and
+// then every Interval, until ctx is done. It returns ctx.Err().
+func (c *Cleanup) Run(ctx context.Context) error {
+	if c.Client == nil {
+		return errors.New("errcause: client is not set")
+	}
+	interval := c.Interval
+	if interval <= 0 {
+		interval = defaultCleanupInterval
+	}
+	return periodic.Run(ctx, interval, func(ctx context.Context) error {
+		_, err := c.Clean(ctx)
+		return err
+	}, c.OnError)
+}
@@ -0,0 +1,117 @@
+package errcause
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pborzenkov/go-transmission/transmission"
+)
+
+func unregistered(id transmission.ID, hash transmission.Hash) *transmission.Torrent {
+	return &transmission.Torrent{
+		ID:        id,
+		Hash:      hash,
+		ErrorType: transmission.ErrorTypeTrackerError,
+		Error:     "Unregistered torrent",
+	}
+}
+
+func TestCleanup_Clean(t *testing.T) {
+	client := &fakeClient{
+		torrents: []*transmission.Torrent{
+			unregistered(1, "h1"),
+			{ID: 2, Hash: "h2", ErrorType: transmission.ErrorTypeTrackerError, Error: "Connection failed"},
+			{ID: 3, Hash: "h3"},
+			unregistered(4, "h4"),
+		},
+	}
+
+	var reported int
+	c := &Cleanup{Client: client, RemoveData: true, OnResult: func(*Result) { reported++ }}
+	results, err := c.Clean(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want, got := 2, len(results); want != got {
+		t.Fatalf("unexpected number of results, want = %d, got = %d", want, got)
+	}
+	if want, got := len(results), reported; want != got {
+		t.Errorf("unexpected number of reported results, want = %d, got = %d", want, got)
+	}
+	if !results[0].Applied || results[0].Err != nil {
+		t.Errorf("expected torrent 1 to be removed, got = %v", results[0])
+	}
+	if results[1].Applied || results[1].Err == nil {
+		t.Errorf("expected removal of torrent 4 to fail, got = %v", results[1])
+	}
+
+	want := []string{"remove 1 true"}
+	if !cmp.Equal(want, client.calls) {
+		t.Errorf("unexpected calls, diff = \n%s", cmp.Diff(want, client.calls))
+	}
+}
+
+func TestCleanup_Clean_gracePeriod(t *testing.T) {
+	client := &fakeClient{torrents: []*transmission.Torrent{unregistered(1, "h1"), unregistered(2, "h2")}}
+
+	c := &Cleanup{Client: client, GracePeriod: time.Hour}
+	if results, _ := c.Clean(context.Background()); len(results) != 0 {
+		t.Errorf("unexpected results during grace period: %v", results)
+	}
+
+	// Torrent 2 is registered again, so its grace period starts over.
+	client.torrents[1] = &transmission.Torrent{ID: 2, Hash: "h2"}
+	if results, _ := c.Clean(context.Background()); len(results) != 0 {
+		t.Errorf("unexpected results during grace period: %v", results)
+	}
+	client.torrents[1] = unregistered(2, "h2")
+
+	c.seen["h1"] = time.Now().Add(-2 * time.Hour)
+	results, err := c.Clean(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(results) != 1 || results[0].ID != 1 {
+		t.Errorf("expected only torrent 1 to be removed, got = %v", results)
+	}
+	if _, ok := c.seen["h1"]; ok {
+		t.Errorf("expected removed torrent to be forgotten")
+	}
+}
+
+func TestCleanup_Clean_dryRun(t *testing.T) {
+	client := &fakeClient{torrents: []*transmission.Torrent{unregistered(1, "h1")}}
+
+	c := &Cleanup{Client: client, DryRun: true}
+	results, err := c.Clean(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(results) != 1 || results[0].Applied {
+		t.Errorf("unexpected results: %v", results)
+	}
+	if len(client.calls) != 0 {
+		t.Errorf("unexpected calls: %v", client.calls)
+	}
+
+	client.err = errors.New("daemon is down")
+	if _, err := c.Clean(context.Background()); err == nil {
+		t.Errorf("expected Clean to fail")
+	}
+}
+
+func TestCleanup_Run(t *testing.T) {
+	client := &fakeClient{torrents: []*transmission.Torrent{unregistered(1, "h1")}}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	c := &Cleanup{Client: client, Interval: time.Hour, OnResult: func(*Result) { cancel() }}
+	if err := c.Run(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("unexpected error, want = %v, got = %v", context.Canceled, err)
+	}
+	if err := (&Cleanup{}).Run(context.Background()); err == nil {
+		t.Errorf("expected cleanup without client to fail")
+	}
+}
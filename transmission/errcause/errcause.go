@@ -0,0 +1,280 @@
+// Package errcause classifies torrent errors into known causes.
+//
+// Torrent.Error is free text coming from trackers and local I/O. Classifier
+// maps it, together with Torrent.ErrorType and results of the last announces,
+// into a Cause using a table of patterns that can be extended or replaced.
+// Cleanup and Recovery build automation on top of the classification: the
+// former removes torrents unregistered by their trackers, the latter restarts
+// torrents whose data became available again after a drive was reconnected.
+package errcause
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+
+	"github.com/pborzenkov/go-transmission/transmission"
+)
+
+// Cause is a known cause of a torrent error.
+type Cause int
+
+const (
+	// CauseNone means there is no error
+	CauseNone Cause = iota
+	// CauseUnknown means the error doesn't match any pattern
+	CauseUnknown
+	// CauseUnregistered means the tracker doesn't know the torrent
+	CauseUnregistered
+	// CauseTrackerUnreachable means the tracker can't be reached
+	CauseTrackerUnreachable
+	// CauseMissingData means the downloaded data is gone, usually because a
+	// drive was disconnected
+	CauseMissingData
+	// CausePermissionDenied means the data can't be accessed due to
+	// file system permissions
+	CausePermissionDenied
+	// CauseNoSpace means the disk is full
+	CauseNoSpace
+)
+
+// String implements fmt.Stringer.
+func (c Cause) String() string {
+	switch c {
+	case CauseNone:
+		return "none"
+	case CauseUnknown:
+		return "unknown"
+	case CauseUnregistered:
+		return "unregistered"
+	case CauseTrackerUnreachable:
+		return "tracker unreachable"
+	case CauseMissingData:
+		return "missing data"
+	case CausePermissionDenied:
+		return "permission denied"
+	case CauseNoSpace:
+		return "no space"
+	default:
+		return fmt.Sprintf("Cause(%d)", c)
+	}
+}
+
+// Scope selects errors a pattern applies to.
+type Scope int
+
+const (
+	// ScopeAny applies the pattern to all errors
+	ScopeAny Scope = iota
+	// ScopeTracker applies the pattern to tracker warnings and errors and to
+	// announce results
+	ScopeTracker
+	// ScopeLocal applies the pattern to local errors
+	ScopeLocal
+)
+
+// String implements fmt.Stringer.
+func (s Scope) String() string {
+	switch s {
+	case ScopeAny:
+		return "any"
+	case ScopeTracker:
+		return "tracker"
+	case ScopeLocal:
+		return "local"
+	default:
+		return fmt.Sprintf("Scope(%d)", s)
+	}
+}
+
+func (s Scope) includes(typ transmission.ErrorType) bool {
+	switch s {
+	case ScopeTracker:
+		return typ == transmission.ErrorTypeTrackerWarning || typ == transmission.ErrorTypeTrackerError
+	case ScopeLocal:
+		return typ == transmission.ErrorTypeLocalError
+	}
+	return true
+}
+
+// Pattern maps error messages matching a regular expression to a cause.
+type Pattern struct {
+	// Errors the pattern applies to
+	Scope Scope
+	// Regular expression matched against error messages
+	Match *regexp.Regexp
+	// Cause of the matching errors
+	Cause Cause
+}
+
+// DefaultPatterns are patterns used if Classifier.Patterns is nil. They cover
+// messages of Transmission itself and of popular tracker software.
+var DefaultPatterns = []Pattern{
+	{
+		Scope: ScopeTracker,
+		Match: regexp.MustCompile(`(?i)unregistered|not registered|torrent not found|not found on tracker|` +
+			`torrent does not exist|unknown torrent|info_?hash not found|torrent (has been )?(deleted|removed)|trumped`),
+		Cause: CauseUnregistered,
+	},
+	{
+		Scope: ScopeTracker,
+		Match: regexp.MustCompile(`(?i)could not connect|connection (refused|failed|reset|timed out)|timed out|` +
+			`could( not|n't) resolve|host not found|tracker did not respond|bad gateway|service unavailable|` +
+			`gateway time-?out|tracker gave http response code (0|5\d\d)`),
+		Cause: CauseTrackerUnreachable,
+	},
+	{
+		Scope: ScopeLocal,
+		Match: regexp.MustCompile(`(?i)no data found|reconnect your drive|drives are connected|no such file or directory`),
+		Cause: CauseMissingData,
+	},
+	{
+		Scope: ScopeLocal,
+		Match: regexp.MustCompile(`(?i)permission denied|operation not permitted|read-only file system`),
+		Cause: CausePermissionDenied,
+	},
+	{
+		Scope: ScopeLocal,
+		Match: regexp.MustCompile(`(?i)no space left|disk full|not enough (disk )?space|quota exceeded`),
+		Cause: CauseNoSpace,
+	},
+}
+
+// Fields is a list of torrent fields required to classify torrent errors.
+var Fields = []transmission.TorrentField{
+	transmission.TorrentFieldID,
+	transmission.TorrentFieldHash,
+	transmission.TorrentFieldName,
+	transmission.TorrentFieldErrorType,
+	transmission.TorrentFieldError,
+	transmission.TorrentFieldTrackerStats,
+}
+
+// Client is a subset of *transmission.Client used by Cleanup and Recovery.
+type Client interface {
+	GetTorrents(ctx context.Context, ids transmission.Identifier, fields ...transmission.TorrentField) ([]*transmission.Torrent, error) //nolint:lll
+	RemoveTorrents(ctx context.Context, ids transmission.Identifier, removeData bool) error
+	VerifyTorrents(ctx context.Context, ids transmission.Identifier) error
+	StartTorrents(ctx context.Context, ids transmission.Identifier) error
+	GetFreeSpace(ctx context.Context, path string) (int64, error)
+	SetMeta(ctx context.Context, ids transmission.Identifier, meta map[string]string) error
+	DeleteMeta(ctx context.Context, ids transmission.Identifier, keys ...string) error
+}
+
+var _ Client = (*transmission.Client)(nil)
+
+// Classifier classifies torrent errors. The zero value uses DefaultPatterns.
+type Classifier struct {
+	// Patterns to match errors against. The first matching pattern wins. If
+	// nil, DefaultPatterns is used
+	Patterns []Pattern
+}
+
+func (c *Classifier) patterns() []Pattern {
+	if c == nil || c.Patterns == nil {
+		return DefaultPatterns
+	}
+	return c.Patterns
+}
+
+// Classify returns the cause of an error of type typ with message msg.
+func (c *Classifier) Classify(typ transmission.ErrorType, msg string) Cause {
+	if typ == transmission.ErrorTypeOK || msg == "" {
+		return CauseNone
+	}
+	for _, p := range c.patterns() {
+		if p.Scope.includes(typ) && p.Match != nil && p.Match.MatchString(msg) {
+			return p.Cause
+		}
+	}
+	return CauseUnknown
+}
+
+// ClassifyTorrent returns the cause of the torrent error and the message it
+// was derived from. If the torrent error is unknown or absent and announces
+// to all trackers failed, results of the failed announces are classified,
+// starting from the most recent one. t must have all the Fields populated.
+func (c *Classifier) ClassifyTorrent(t *transmission.Torrent) (Cause, string) {
+	cause := c.Classify(t.ErrorType, t.Error)
+	if cause != CauseNone && cause != CauseUnknown {
+		return cause, t.Error
+	}
+
+	var failed []*transmission.TrackerStat
+	for i := range t.TrackerStats {
+		ts := &t.TrackerStats[i]
+		if ts.IsLastAnnounceSucceeded {
+			return cause, t.Error
+		}
+		if ts.HasAnnounced && ts.LastAnnounceResult != "" {
+			failed = append(failed, ts)
+		}
+	}
+	sort.SliceStable(failed, func(i, j int) bool {
+		return failed[i].LastAnnounceTime.After(failed[j].LastAnnounceTime)
+	})
+	for _, ts := range failed {
+		if tc := c.Classify(transmission.ErrorTypeTrackerError, ts.LastAnnounceResult); tc != CauseUnknown {
+			return tc, ts.LastAnnounceResult
+		}
+	}
+	if cause == CauseNone && len(failed) > 0 {
+		return CauseUnknown, failed[0].LastAnnounceResult
+	}
+	return cause, t.Error
+}
+
+// Action is an action taken by Cleanup or Recovery.
+type Action int
+
+const (
+	// ActionRemove removes the torrent
+	ActionRemove Action = iota
+	// ActionRecover verifies and starts the torrent
+	ActionRecover
+)
+
+// String implements fmt.Stringer.
+func (a Action) String() string {
+	switch a {
+	case ActionRemove:
+		return "remove"
+	case ActionRecover:
+		return "recover"
+	default:
+		return fmt.Sprintf("Action(%d)", a)
+	}
+}
+
+// Result describes an action taken, or planned, for a single torrent.
+type Result struct {
+	// ID of the torrent
+	ID transmission.ID
+	// Hash of the torrent
+	Hash transmission.Hash
+	// Name of the torrent
+	Name string
+	// Cause of the torrent error
+	Cause Cause
+	// Error message the cause was derived from
+	Message string
+	// Action to apply
+	Action Action
+	// Indicates whether the action was applied
+	Applied bool
+	// An error that prevented the action from being applied
+	Err error
+}
+
+// String returns a human readable description of the result.
+func (r *Result) String() string {
+	s := fmt.Sprintf("torrent %d (%s): %s (%s): %s", r.ID, r.Name, r.Cause, r.Message, r.Action)
+	switch {
+	case r.Err != nil:
+		s += fmt.Sprintf(": %v", r.Err)
+	case !r.Applied:
+		s += " [dry run]"
+	}
+	return s
+}
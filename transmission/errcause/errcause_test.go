@@ -0,0 +1,257 @@
+package errcause
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pborzenkov/go-transmission/transmission"
+)
+
+type fakeClient struct {
+	mu       sync.Mutex
+	torrents []*transmission.Torrent
+	dirs     map[string]bool
+	valid    map[transmission.ID]int64
+	checks   int
+	calls    []string
+	err      error
+}
+
+func (c *fakeClient) GetTorrents(ctx context.Context, ids transmission.Identifier, fields ...transmission.TorrentField) ([]*transmission.Torrent, error) { //nolint:lll
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.err != nil {
+		return nil, c.err
+	}
+	// A single torrent is requested while waiting for its verification,
+	// which takes one extra request to finish.
+	if id, ok := ids.(transmission.ID); ok {
+		c.checks++
+		status := transmission.StatusStopped
+		if c.checks%2 == 1 {
+			status = transmission.StatusCheck
+		}
+		return []*transmission.Torrent{{ID: id, Status: status, ValidSize: c.valid[id]}}, nil
+	}
+	return c.torrents, nil
+}
+
+func (c *fakeClient) record(call string, args ...any) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.calls = append(c.calls, fmt.Sprint(append([]any{call}, args...)...))
+	return nil
+}
+
+func (c *fakeClient) RemoveTorrents(ctx context.Context, ids transmission.Identifier, removeData bool) error {
+	if ids == transmission.ID(4) {
+		return errors.New("failed")
+	}
+	return c.record("remove ", ids, " ", removeData)
+}
+
+func (c *fakeClient) VerifyTorrents(ctx context.Context, ids transmission.Identifier) error {
+	return c.record("verify ", ids)
+}
+
+func (c *fakeClient) StartTorrents(ctx context.Context, ids transmission.Identifier) error {
+	return c.record("start ", ids)
+}
+
+func (c *fakeClient) SetMeta(ctx context.Context, ids transmission.Identifier, meta map[string]string) error {
+	c.mu.Lock()
+	for _, t := range c.torrents {
+		if t.ID == ids {
+			for k, v := range meta {
+				t.Labels = append(t.Labels, transmission.MetaLabel(k, v))
+			}
+		}
+	}
+	c.mu.Unlock()
+	return c.record("mark ", ids)
+}
+
+func (c *fakeClient) DeleteMeta(ctx context.Context, ids transmission.Identifier, keys ...string) error {
+	c.mu.Lock()
+	list, _ := ids.(transmission.IDList)
+	for _, t := range c.torrents {
+		if t.ID == ids || slices.Contains(list, transmission.SingularIdentifier(t.ID)) {
+			t.Labels = nil
+		}
+	}
+	c.mu.Unlock()
+	return c.record("unmark ", ids)
+}
+
+func (c *fakeClient) GetFreeSpace(ctx context.Context, path string) (int64, error) {
+	if err := c.record("free ", path); err != nil {
+		return 0, err
+	}
+	if !c.dirs[path] {
+		return 0, errors.New("no such file or directory")
+	}
+	return 1024, nil
+}
+
+func TestClassifier_Classify(t *testing.T) {
+	var tests = []struct {
+		typ  transmission.ErrorType
+		msg  string
+		want Cause
+	}{
+		{typ: transmission.ErrorTypeOK, msg: "", want: CauseNone},
+		{typ: transmission.ErrorTypeTrackerError, msg: "Unregistered torrent", want: CauseUnregistered},
+		{typ: transmission.ErrorTypeTrackerError, msg: "torrent not found on tracker", want: CauseUnregistered},
+		{typ: transmission.ErrorTypeTrackerWarning, msg: "Torrent has been deleted.", want: CauseUnregistered},
+		{typ: transmission.ErrorTypeTrackerError, msg: "Could not connect to tracker", want: CauseTrackerUnreachable},
+		{
+			typ:  transmission.ErrorTypeTrackerError,
+			msg:  "Tracker gave HTTP response code 502 (Bad Gateway)",
+			want: CauseTrackerUnreachable,
+		},
+		{
+			typ:  transmission.ErrorTypeTrackerError,
+			msg:  "Tracker gave HTTP response code 404 (Not Found)",
+			want: CauseUnknown,
+		},
+		{typ: transmission.ErrorTypeLocalError, msg: "No data found! Reconnect your drive", want: CauseMissingData},
+		{
+			typ:  transmission.ErrorTypeLocalError,
+			msg:  `No data found! Ensure your drives are connected or use "Set Location".`,
+			want: CauseMissingData,
+		},
+		{typ: transmission.ErrorTypeLocalError, msg: "Permission denied (/data/a.mkv)", want: CausePermissionDenied},
+		{typ: transmission.ErrorTypeLocalError, msg: "No space left on device", want: CauseNoSpace},
+		// Tracker patterns don't apply to local errors and vice versa.
+		{typ: transmission.ErrorTypeLocalError, msg: "Unregistered torrent", want: CauseUnknown},
+		{typ: transmission.ErrorTypeTrackerError, msg: "No space left on device", want: CauseUnknown},
+	}
+
+	for _, tc := range tests {
+		if got := (&Classifier{}).Classify(tc.typ, tc.msg); got != tc.want {
+			t.Errorf("unexpected cause of %s %q, want = %s, got = %s", tc.typ, tc.msg, tc.want, got)
+		}
+	}
+}
+
+func TestClassifier_Classify_patterns(t *testing.T) {
+	c := &Classifier{Patterns: append([]Pattern{{
+		Match: regexp.MustCompile(`(?i)banned client`),
+		Cause: CausePermissionDenied,
+	}}, DefaultPatterns...)}
+
+	if want, got := CausePermissionDenied, c.Classify(transmission.ErrorTypeTrackerError, "Banned client"); want != got {
+		t.Errorf("unexpected cause, want = %s, got = %s", want, got)
+	}
+	if want, got := CauseNoSpace, c.Classify(transmission.ErrorTypeLocalError, "No space left"); want != got {
+		t.Errorf("unexpected cause, want = %s, got = %s", want, got)
+	}
+
+	c = &Classifier{Patterns: []Pattern{}}
+	if want, got := CauseUnknown, c.Classify(transmission.ErrorTypeLocalError, "No space left"); want != got {
+		t.Errorf("unexpected cause, want = %s, got = %s", want, got)
+	}
+}
+
+func TestClassifier_ClassifyTorrent(t *testing.T) {
+	now := time.Now()
+	var tests = []struct {
+		name    string
+		torrent transmission.Torrent
+		cause   Cause
+		msg     string
+	}{
+		{
+			name:    "healthy",
+			torrent: transmission.Torrent{},
+			cause:   CauseNone,
+		},
+		{
+			name:    "torrent error",
+			torrent: transmission.Torrent{ErrorType: transmission.ErrorTypeLocalError, Error: "Permission denied"},
+			cause:   CausePermissionDenied,
+			msg:     "Permission denied",
+		},
+		{
+			name: "failed announces",
+			torrent: transmission.Torrent{
+				ErrorType: transmission.ErrorTypeTrackerWarning,
+				Error:     "something odd",
+				TrackerStats: []transmission.TrackerStat{
+					{HasAnnounced: true, LastAnnounceResult: "Connection failed", LastAnnounceTime: now.Add(-time.Hour)},
+					{HasAnnounced: true, LastAnnounceResult: "unregistered torrent", LastAnnounceTime: now},
+				},
+			},
+			cause: CauseUnregistered,
+			msg:   "unregistered torrent",
+		},
+		{
+			name: "one tracker works",
+			torrent: transmission.Torrent{
+				TrackerStats: []transmission.TrackerStat{
+					{HasAnnounced: true, LastAnnounceResult: "unregistered torrent"},
+					{HasAnnounced: true, IsLastAnnounceSucceeded: true, LastAnnounceResult: "Success"},
+				},
+			},
+			cause: CauseNone,
+		},
+		{
+			name: "unknown announce result",
+			torrent: transmission.Torrent{
+				TrackerStats: []transmission.TrackerStat{{HasAnnounced: true, LastAnnounceResult: "go away"}},
+			},
+			cause: CauseUnknown,
+			msg:   "go away",
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			cause, msg := (*Classifier)(nil).ClassifyTorrent(&tc.torrent)
+			if cause != tc.cause {
+				t.Errorf("unexpected cause, want = %s, got = %s", tc.cause, cause)
+			}
+			if msg != tc.msg {
+				t.Errorf("unexpected message, want = %q, got = %q", tc.msg, msg)
+			}
+		})
+	}
+}
+
+func TestResult_String(t *testing.T) {
+	var tests = []struct {
+		res  Result
+		want string
+	}{
+		{
+			res:  Result{ID: 1, Name: "a", Cause: CauseUnregistered, Message: "m", Action: ActionRemove},
+			want: "torrent 1 (a): unregistered (m): remove [dry run]",
+		},
+		{
+			res:  Result{ID: 1, Name: "a", Cause: CauseMissingData, Message: "m", Action: ActionRecover, Applied: true},
+			want: "torrent 1 (a): missing data (m): recover",
+		},
+		{
+			res: Result{ID: 1, Name: "a", Cause: CauseUnregistered, Message: "m", Action: ActionRemove,
+				Err: errors.New("failed")},
+			want: "torrent 1 (a): unregistered (m): remove: failed",
+		},
+	}
+
+	for _, tc := range tests {
+		if got := tc.res.String(); got != tc.want {
+			t.Errorf("unexpected string, want = %q, got = %q", tc.want, got)
+		}
+	}
+}
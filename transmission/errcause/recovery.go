@@ -0,0 +1,225 @@
+package errcause
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/pborzenkov/go-transmission/transmission"
+	"github.com/pborzenkov/go-transmission/transmission/internal/periodic"
+)
+
+const (
+	defaultRecoveryInterval = 5 * time.Minute
+	defaultPollInterval     = 5 * time.Second
+)
+
+// MetaKey is the metadata key (see transmission.MetaLabel) Recovery marks the
+// torrents it verified with. Verification clears the error of a torrent, so
+// the mark keeps the torrent recovered until its data shows up.
+const MetaKey = "errcause"
+
+const metaRecovering = "recovering"
+
+var (
+	// ErrNoFiles is reported for torrents none of whose files exist in the
+	// download directory, e.g. because it is an empty mount point. Such
+	// torrents are not verified, so they keep their progress.
+	ErrNoFiles = errors.New("errcause: no files found in download directory")
+	// ErrNoData is reported for torrents that have no valid data after the
+	// verification. Such torrents are left stopped, so they don't download
+	// the data again, and are verified again once their files show up.
+	ErrNoData = errors.New("errcause: no data found after verification")
+)
+
+var recoveryFields = append([]transmission.TorrentField{
+	transmission.TorrentFieldStatus,
+	transmission.TorrentFieldLabels,
+	transmission.TorrentFieldDownloadDirectory,
+	transmission.TorrentFieldFiles,
+}, Fields...)
+
+// Recovery restarts torrents that lost their data, usually because a drive
+// was disconnected, once their download directory becomes available again.
+type Recovery struct {
+	// Transmission client
+	Client Client
+	// Classifier of torrent errors. If nil, DefaultPatterns are used
+	Classifier *Classifier
+	// Returns information about a file of a torrent, used to check that the
+	// data is back before verifying it. If nil, os.Stat is used. Use
+	// transmission.WithPathMapping if the daemon runs on another host or in
+	// a container
+	Stat func(path string) (fs.FileInfo, error)
+	// Only report torrents that would be recovered, don't recover them
+	DryRun bool
+	// Interval between recoveries in Run. If 0, 5 minutes is used
+	Interval time.Duration
+	// Interval between checks of verification progress. If 0, 5 seconds is
+	// used
+	PollInterval time.Duration
+	// Called with every result
+	OnResult func(*Result)
+	// Called when Run fails to list the torrents
+	OnError func(error)
+}
+
+// Recover verifies torrents with missing data whose files are back in the
+// download directory, unless DryRun is set, and starts those that have valid
+// data after the verification. Torrents verified without finding any data
+// are marked with MetaKey and recovered again by later calls. Torrents whose
+// download directory is still unavailable or empty are reported with an
+// error. A failure to recover a torrent doesn't stop the process, instead it
+// is reported in the corresponding result.
+func (r *Recovery) Recover(ctx context.Context) ([]*Result, error) {
+	torrents, err := r.Client.GetTorrents(ctx, transmission.All(), recoveryFields...)
+	if err != nil {
+		return nil, err
+	}
+
+	available := make(map[string]error)
+	var (
+		results []*Result
+		stale   transmission.IDList
+	)
+	for _, t := range torrents {
+		cause, msg := r.Classifier.ClassifyTorrent(t)
+		marked := transmission.MatchMeta(t, MetaKey, metaRecovering)
+		switch {
+		case cause == CauseMissingData:
+		case marked && t.Status == transmission.StatusStopped:
+			// Verified by an earlier call without finding any data.
+			cause = CauseMissingData
+		case marked && t.Status != transmission.StatusCheckWait && t.Status != transmission.StatusCheck:
+			// The torrent was started by someone else.
+			stale = append(stale, t.ID)
+			continue
+		default:
+			continue
+		}
+
+		res := &Result{ID: t.ID, Hash: t.Hash, Name: t.Name, Cause: cause, Message: msg, Action: ActionRecover}
+		dirErr, ok := available[t.DownloadDirectory]
+		if !ok {
+			_, dirErr = r.Client.GetFreeSpace(ctx, t.DownloadDirectory)
+			available[t.DownloadDirectory] = dirErr
+		}
+		switch {
+		case dirErr != nil:
+			res.Err = fmt.Errorf("errcause: %s is unavailable: %w", t.DownloadDirectory, dirErr)
+		case !r.hasFiles(t):
+			res.Err = ErrNoFiles
+		case !r.DryRun:
+			if res.Err = r.recover(ctx, t, marked); res.Err == nil {
+				res.Applied = true
+			}
+		}
+		results = append(results, res)
+		if r.OnResult != nil {
+			r.OnResult(res)
+		}
+	}
+	if len(stale) > 0 && !r.DryRun {
+		if err := r.Client.DeleteMeta(ctx, stale, MetaKey); err != nil {
+			return results, fmt.Errorf("errcause: failed to unmark torrents: %w", err)
+		}
+	}
+	return results, nil
+}
+
+// hasFiles reports whether any file of the torrent exists, either complete or
+// with the .part suffix Transmission adds to incomplete files.
+func (r *Recovery) hasFiles(t *transmission.Torrent) bool {
+	stat := r.Stat
+	if stat == nil {
+		stat = os.Stat
+	}
+	for _, f := range t.Files {
+		path := filepath.Join(t.DownloadDirectory, filepath.FromSlash(f.Name))
+		for _, p := range []string{path, path + ".part"} {
+			if fi, err := stat(p); err == nil && !fi.IsDir() {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (r *Recovery) recover(ctx context.Context, t *transmission.Torrent, marked bool) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	// Verification clears the error, so the torrent is marked first to be
+	// found again if no data turns up.
+	if !marked {
+		if err := r.Client.SetMeta(ctx, t.ID, map[string]string{MetaKey: metaRecovering}); err != nil {
+			return err
+		}
+	}
+	if err := r.Client.VerifyTorrents(ctx, t.ID); err != nil {
+		return err
+	}
+	valid, err := r.waitVerified(ctx, t.ID)
+	if err != nil {
+		return err
+	}
+	if valid == 0 {
+		return ErrNoData
+	}
+	if err := r.Client.StartTorrents(ctx, t.ID); err != nil {
+		return err
+	}
+	// A stale mark is removed by the next call.
+	_ = r.Client.DeleteMeta(ctx, t.ID, MetaKey)
+	return nil
+}
+
+// waitVerified waits for the verification of the torrent to finish and
+// returns the size of its valid data. Transmission queues the verification
+// before replying to the request, so a torrent that is not being checked is
+// done verifying.
+func (r *Recovery) waitVerified(ctx context.Context, id transmission.ID) (int64, error) {
+	interval := r.PollInterval
+	if interval <= 0 {
+		interval = defaultPollInterval
+	}
+	for {
+		torrents, err := r.Client.GetTorrents(ctx, id, transmission.TorrentFieldStatus, transmission.TorrentFieldValidSize)
+		if err != nil {
+			return 0, err
+		}
+		if len(torrents) == 0 {
+			return 0, fmt.Errorf("errcause: torrent %d is gone", id)
+		}
+		if s := torrents[0].Status; s != transmission.StatusCheckWait && s != transmission.StatusCheck {
+			return torrents[0].ValidSize, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		case <-time.After(interval):
+		}
+	}
+}
+
+// Run recovers torrents with local errors right away and then every
+// Interval until ctx is done. It returns ctx.Err(). A torrent whose recovery
+// failed is tried again on the next pass.
+func (r *Recovery) Run(ctx context.Context) error {
+	if r.Client == nil {
+		return errors.New("errcause: client is not set")
+	}
+	interval := r.Interval
+	if interval <= 0 {
+		interval = defaultRecoveryInterval
+	}
+	return periodic.Run(ctx, interval, func(ctx context.Context) error {
+		_, err := r.Recover(ctx)
+		return err
+	}, r.OnError)
+}
@@ -0,0 +1,160 @@
+package errcause
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pborzenkov/go-transmission/transmission"
+)
+
+func missing(id transmission.ID, dir string) *transmission.Torrent {
+	return &transmission.Torrent{
+		ID:                id,
+		DownloadDirectory: dir,
+		ErrorType:         transmission.ErrorTypeLocalError,
+		Error:             "No data found! Reconnect your drive",
+		Files:             []transmission.File{{Name: "dir/file"}},
+	}
+}
+
+// mountDirs creates directories a and b, with a holding the data of the
+// torrents and b being an empty mount point.
+func mountDirs(t *testing.T) (a, b string) {
+	t.Helper()
+
+	root := t.TempDir()
+	a, b = filepath.Join(root, "a"), filepath.Join(root, "b")
+	if err := os.MkdirAll(filepath.Join(a, "dir"), 0o755); err != nil {
+		t.Fatalf("failed to create directory: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(a, "dir", "file.part"), []byte("data"), 0o600); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+	if err := os.Mkdir(b, 0o755); err != nil {
+		t.Fatalf("failed to create directory: %v", err)
+	}
+	return a, b
+}
+
+func TestRecovery_Recover(t *testing.T) {
+	a, b := mountDirs(t)
+	client := &fakeClient{
+		torrents: []*transmission.Torrent{
+			missing(1, a),
+			missing(2, "/mnt/gone"),
+			missing(3, a),
+			{ID: 4, DownloadDirectory: a, ErrorType: transmission.ErrorTypeLocalError, Error: "Permission denied"},
+			missing(5, b),
+		},
+		dirs:  map[string]bool{a: true, b: true},
+		valid: map[transmission.ID]int64{1: 1024},
+	}
+
+	var reported int
+	r := &Recovery{Client: client, PollInterval: time.Millisecond, OnResult: func(*Result) { reported++ }}
+	results, err := r.Recover(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want, got := 4, len(results); want != got {
+		t.Fatalf("unexpected number of results, want = %d, got = %d", want, got)
+	}
+	if want, got := len(results), reported; want != got {
+		t.Errorf("unexpected number of reported results, want = %d, got = %d", want, got)
+	}
+	for _, res := range results {
+		if applied := res.ID == 1; applied != (res.Err == nil) || applied != res.Applied {
+			t.Errorf("unexpected outcome for torrent %d: applied = %v, err = %v", res.ID, res.Applied, res.Err)
+		}
+	}
+	// Torrent 3 has no valid data after the verification, so it is left
+	// stopped and marked.
+	if !errors.Is(results[2].Err, ErrNoData) {
+		t.Errorf("unexpected error, want = %v, got = %v", ErrNoData, results[2].Err)
+	}
+	// Torrent 5 is on an empty mount point, so it is not verified.
+	if !errors.Is(results[3].Err, ErrNoFiles) {
+		t.Errorf("unexpected error, want = %v, got = %v", ErrNoFiles, results[3].Err)
+	}
+
+	want := []string{
+		"free " + a, "mark 1", "verify 1", "start 1", "unmark 1",
+		"free /mnt/gone",
+		"mark 3", "verify 3",
+		"free " + b,
+	}
+	if !cmp.Equal(want, client.calls) {
+		t.Errorf("unexpected calls, diff = \n%s", cmp.Diff(want, client.calls))
+	}
+}
+
+func TestRecovery_Recover_marked(t *testing.T) {
+	a, _ := mountDirs(t)
+	mark := []string{transmission.MetaLabel(MetaKey, metaRecovering)}
+	client := &fakeClient{
+		torrents: []*transmission.Torrent{
+			// Verified by an earlier call, the error is cleared.
+			{ID: 1, DownloadDirectory: a, Status: transmission.StatusStopped, Labels: mark, Files: missing(1, a).Files},
+			// Started by the user.
+			{ID: 2, DownloadDirectory: a, Status: transmission.StatusDownload, Labels: mark},
+		},
+		dirs:  map[string]bool{a: true},
+		valid: map[transmission.ID]int64{1: 1024},
+	}
+
+	r := &Recovery{Client: client, PollInterval: time.Millisecond}
+	results, err := r.Recover(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(results) != 1 || results[0].ID != 1 || !results[0].Applied {
+		t.Errorf("unexpected results: %v", results)
+	}
+	want := []string{"free " + a, "verify 1", "start 1", "unmark 1", "unmark [2]"}
+	if !cmp.Equal(want, client.calls) {
+		t.Errorf("unexpected calls, diff = \n%s", cmp.Diff(want, client.calls))
+	}
+}
+
+func TestRecovery_Recover_dryRun(t *testing.T) {
+	a, _ := mountDirs(t)
+	client := &fakeClient{
+		torrents: []*transmission.Torrent{missing(1, a)},
+		dirs:     map[string]bool{a: true},
+	}
+
+	r := &Recovery{Client: client, DryRun: true}
+	results, err := r.Recover(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(results) != 1 || results[0].Applied || results[0].Err != nil {
+		t.Errorf("unexpected results: %v", results)
+	}
+	if want := []string{"free " + a}; !cmp.Equal(want, client.calls) {
+		t.Errorf("unexpected calls, diff = \n%s", cmp.Diff(want, client.calls))
+	}
+
+	client.err = errors.New("daemon is down")
+	if _, err := r.Recover(context.Background()); err == nil {
+		t.Errorf("expected Recover to fail")
+	}
+}
+
+func TestRecovery_Run(t *testing.T) {
+	client := &fakeClient{torrents: []*transmission.Torrent{missing(1, "/mnt/a")}}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	r := &Recovery{Client: client, Interval: time.Hour, OnResult: func(*Result) { cancel() }}
+	if err := r.Run(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("unexpected error, want = %v, got = %v", context.Canceled, err)
+	}
+	if err := (&Recovery{}).Run(context.Background()); err == nil {
+		t.Errorf("expected recovery without client to fail")
+	}
+}
@@ -0,0 +1,203 @@
+// Package feed implements an RSS/Atom feed auto-downloader.
+//
+// Poller periodically fetches feeds, matches their items against rules and
+// adds matching torrents to Transmission. Processed items are remembered in a
+// Store, so every torrent is added only once.
+package feed
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"time"
+
+	"github.com/pborzenkov/go-transmission/transmission"
+	"github.com/pborzenkov/go-transmission/transmission/internal/periodic"
+)
+
+const (
+	defaultInterval = 15 * time.Minute
+	maxFeedSize     = 16 << 20
+)
+
+// Adder adds torrents to Transmission. *transmission.Client implements it.
+type Adder interface {
+	AddTorrent(ctx context.Context, req *transmission.AddTorrentReq) (*transmission.NewTorrent, error)
+}
+
+// Rule selects feed items to download and settings of their torrents.
+type Rule struct {
+	// Name of the rule
+	Name string
+	// Items with matching titles are downloaded. If nil, all items match
+	Include *regexp.Regexp
+	// Items with matching titles are not downloaded, even if they match
+	// Include
+	Exclude *regexp.Regexp
+	// Labels to attach to the torrents
+	Labels []string
+	// Download directory of the torrents, if not empty
+	DownloadDirectory string
+	// Add the torrents paused
+	Paused bool
+}
+
+// Match returns true if item matches the rule.
+func (r *Rule) Match(item *Item) bool {
+	if r.Include != nil && !r.Include.MatchString(item.Title) {
+		return false
+	}
+	return r.Exclude == nil || !r.Exclude.MatchString(item.Title)
+}
+
+func (r *Rule) request(item *Item) *transmission.AddTorrentReq {
+	req := &transmission.AddTorrentReq{
+		URL:    transmission.OptString(item.URL()),
+		Labels: r.Labels,
+	}
+	if r.DownloadDirectory != "" {
+		req.DownloadDirectory = transmission.OptString(r.DownloadDirectory)
+	}
+	if r.Paused {
+		req.Paused = transmission.OptBool(true)
+	}
+	return req
+}
+
+// Result is the outcome of processing of a matched feed item.
+type Result struct {
+	// URL of the feed
+	Feed string
+	// Matched item
+	Item Item
+	// Name of the matched rule
+	Rule string
+	// Added torrent
+	Torrent *transmission.NewTorrent
+	// Error that prevented the torrent from being added
+	Err error
+}
+
+// Poller fetches feeds and adds matching torrents.
+type Poller struct {
+	// URLs of the feeds
+	Feeds []string
+	// Rules are checked in order and the first matching rule is used
+	Rules []Rule
+	// Adds the torrents
+	Adder Adder
+	// Remembers processed items. If nil, Poll fails
+	Store Store
+	// HTTP client to fetch feeds with. If nil, http.DefaultClient is used
+	HTTPClient *http.Client
+	// Interval between polls in Run. If 0, 15 minutes is used
+	Interval time.Duration
+	// Called with the result of every matched item
+	OnResult func(Result)
+	// Called when Run fails to fetch a feed or to access the Store
+	OnError func(error)
+}
+
+func (p *Poller) fetch(ctx context.Context, url string) ([]Item, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	client := p.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		return nil, fmt.Errorf("feed: failed to fetch %s (%s)", url, http.StatusText(resp.StatusCode))
+	}
+	items, err := Parse(io.LimitReader(resp.Body, maxFeedSize))
+	if err != nil {
+		return nil, fmt.Errorf("feed: failed to parse %s: %w", url, err)
+	}
+	return items, nil
+}
+
+func (p *Poller) match(item *Item) *Rule {
+	for i := range p.Rules {
+		if p.Rules[i].Match(item) {
+			return &p.Rules[i]
+		}
+	}
+	return nil
+}
+
+// Poll fetches every feed once and adds torrents of new matching items. Items
+// are marked as seen once their torrents are added, so failed items are
+// retried on the next poll. Errors of individual items are reported in
+// results, the returned error covers feeds that couldn't be fetched.
+func (p *Poller) Poll(ctx context.Context) ([]Result, error) {
+	if p.Store == nil {
+		return nil, errors.New("feed: store is not set")
+	}
+
+	var (
+		results []Result
+		errs    []error
+	)
+	for _, url := range p.Feeds {
+		items, err := p.fetch(ctx, url)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		for i := range items {
+			item := &items[i]
+			rule := p.match(item)
+			if rule == nil {
+				continue
+			}
+			seen, err := p.Store.IsSeen(item.Key())
+			if err != nil {
+				return results, err
+			}
+			if seen {
+				continue
+			}
+
+			res := Result{Feed: url, Item: *item, Rule: rule.Name}
+			if item.URL() == "" {
+				res.Err = errors.New("feed: item has no torrent")
+			} else if res.Torrent, res.Err = p.Adder.AddTorrent(ctx, rule.request(item)); res.Err == nil {
+				if err := p.Store.MarkSeen(item.Key()); err != nil {
+					return results, err
+				}
+			}
+			results = append(results, res)
+			if p.OnResult != nil {
+				p.OnResult(res)
+			}
+		}
+	}
+	return results, errors.Join(errs...)
+}
+
+// Run polls the feeds right away and then every Interval until ctx is done.
+// It returns ctx.Err(). Failed feeds and items are retried on the next poll.
+func (p *Poller) Run(ctx context.Context) error {
+	if p.Store == nil {
+		return errors.New("feed: store is not set")
+	}
+	interval := p.Interval
+	if interval <= 0 {
+		interval = defaultInterval
+	}
+	return periodic.Run(ctx, interval, func(ctx context.Context) error {
+		_, err := p.Poll(ctx)
+		return err
+	}, p.OnError)
+}
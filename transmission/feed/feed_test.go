@@ -0,0 +1,178 @@
+package feed
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pborzenkov/go-transmission/transmission"
+)
+
+type fakeAdder struct {
+	mu   sync.Mutex
+	reqs []*transmission.AddTorrentReq
+	err  error
+}
+
+func (a *fakeAdder) AddTorrent(ctx context.Context, req *transmission.AddTorrentReq) (*transmission.NewTorrent, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.reqs = append(a.reqs, req)
+	if a.err != nil {
+		return nil, a.err
+	}
+	return &transmission.NewTorrent{ID: transmission.ID(len(a.reqs))}, nil
+}
+
+func (a *fakeAdder) urls() []string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	var urls []string
+	for _, r := range a.reqs {
+		urls = append(urls, *r.URL)
+	}
+	return urls
+}
+
+func testServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/rss", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, testRSS)
+	})
+	mux.HandleFunc("/atom", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, testAtom)
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestPoll(t *testing.T) {
+	server := testServer(t)
+
+	adder := new(fakeAdder)
+	p := &Poller{
+		Feeds: []string{server.URL + "/rss", server.URL + "/atom", server.URL + "/missing"},
+		Rules: []Rule{
+			{
+				Name:              "shows",
+				Include:           regexp.MustCompile(`(?i)^show s\d+e\d+`),
+				Exclude:           regexp.MustCompile(`720p`),
+				Labels:            []string{"tv"},
+				DownloadDirectory: "/tv",
+			},
+			{
+				Name:    "movies",
+				Include: regexp.MustCompile(`^Movie`),
+				Paused:  true,
+			},
+		},
+		Adder:      adder,
+		Store:      NewMemoryStore(),
+		HTTPClient: server.Client(),
+	}
+
+	results, err := p.Poll(context.Background())
+	if err == nil {
+		t.Errorf("expected missing feed to be reported")
+	}
+	if want, got := 2, len(results); want != got {
+		t.Fatalf("unexpected number of results, want = %d, got = %d", want, got)
+	}
+
+	want := []string{
+		"magnet:?xt=urn:btih:c12fe1c06bba254a9dc9f519b335aa7c1367a88a",
+		"http://example.com/movie.torrent",
+	}
+	if got := adder.urls(); !cmp.Equal(want, got) {
+		t.Errorf("unexpected URLs, diff = \n%s", cmp.Diff(want, got))
+	}
+
+	show := adder.reqs[0]
+	if want, got := []string{"tv"}, show.Labels; !cmp.Equal(want, got) {
+		t.Errorf("unexpected labels, diff = \n%s", cmp.Diff(want, got))
+	}
+	if want, got := "/tv", *show.DownloadDirectory; want != got {
+		t.Errorf("unexpected download directory, want = %q, got = %q", want, got)
+	}
+	if show.Paused != nil {
+		t.Errorf("expected show not to be paused")
+	}
+	if movie := adder.reqs[1]; movie.Paused == nil || !*movie.Paused {
+		t.Errorf("expected movie to be paused")
+	}
+	if want, got := "movies", results[1].Rule; want != got {
+		t.Errorf("unexpected rule, want = %q, got = %q", want, got)
+	}
+
+	// Seen items are not added again.
+	if _, err := p.Poll(context.Background()); err == nil {
+		t.Errorf("expected missing feed to be reported")
+	}
+	if want, got := 2, len(adder.urls()); want != got {
+		t.Errorf("unexpected number of added torrents, want = %d, got = %d", want, got)
+	}
+}
+
+func TestPoll_retry(t *testing.T) {
+	server := testServer(t)
+
+	adder := &fakeAdder{err: errors.New("daemon is down")}
+	p := &Poller{
+		Feeds:      []string{server.URL + "/atom"},
+		Rules:      []Rule{{Name: "all"}},
+		Adder:      adder,
+		Store:      NewMemoryStore(),
+		HTTPClient: server.Client(),
+	}
+
+	results, err := p.Poll(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(results) != 1 || results[0].Err == nil {
+		t.Fatalf("expected add to fail, got = %+v", results)
+	}
+
+	adder.err = nil
+	results, err = p.Poll(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(results) != 1 || results[0].Err != nil {
+		t.Errorf("expected failed item to be retried, got = %+v", results)
+	}
+}
+
+func TestRun(t *testing.T) {
+	server := testServer(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	p := &Poller{
+		Feeds:      []string{server.URL + "/atom"},
+		Rules:      []Rule{{Name: "all"}},
+		Adder:      new(fakeAdder),
+		Store:      NewMemoryStore(),
+		HTTPClient: server.Client(),
+		Interval:   time.Hour,
+		OnResult:   func(Result) { cancel() },
+	}
+
+	if err := p.Run(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("unexpected error, want = %v, got = %v", context.Canceled, err)
+	}
+	if err := (&Poller{}).Run(context.Background()); err == nil {
+		t.Errorf("expected poller without store to fail")
+	}
+}
@@ -0,0 +1,220 @@
+package feed
+
+import (
+	"encoding/xml"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pborzenkov/go-transmission/transmission"
+)
+
+const torrentMIMEType = "application/x-bittorrent"
+
+// Item is a single feed item.
+type Item struct {
+	// Unique identifier of the item (RSS guid or Atom id)
+	GUID string
+	// Title of the item
+	Title string
+	// Link to the item page
+	Link string
+	// URL of the torrent file
+	TorrentURL string
+	// Magnet link of the torrent
+	MagnetURI string
+	// Info hash of the torrent, if known
+	InfoHash transmission.Hash
+	// Size of the torrent content, if known
+	Size int64
+	// Publication time
+	Published time.Time
+	// Item categories
+	Categories []string
+}
+
+// Key returns the key that identifies the item in a Store: GUID if present,
+// download URL otherwise.
+func (i *Item) Key() string {
+	if i.GUID != "" {
+		return i.GUID
+	}
+	return i.URL()
+}
+
+// URL returns the URL to add the torrent from, preferring magnet links.
+func (i *Item) URL() string {
+	if i.MagnetURI != "" {
+		return i.MagnetURI
+	}
+	return i.TorrentURL
+}
+
+type xmlLink struct {
+	Href   string `xml:"href,attr"`
+	Rel    string `xml:"rel,attr"`
+	Type   string `xml:"type,attr"`
+	Length string `xml:"length,attr"`
+	Text   string `xml:",chardata"`
+}
+
+type xmlEnclosure struct {
+	URL    string `xml:"url,attr"`
+	Type   string `xml:"type,attr"`
+	Length string `xml:"length,attr"`
+}
+
+type xmlCategory struct {
+	Term string `xml:"term,attr"`
+	Text string `xml:",chardata"`
+}
+
+// xmlItem holds both RSS item and Atom entry elements. Torrent namespace
+// elements are matched by their local names as feeds use different
+// namespace URIs.
+type xmlItem struct {
+	Title         string         `xml:"title"`
+	Links         []xmlLink      `xml:"link"`
+	GUID          string         `xml:"guid"`
+	ID            string         `xml:"id"`
+	PubDate       string         `xml:"pubDate"`
+	Published     string         `xml:"published"`
+	Updated       string         `xml:"updated"`
+	Enclosures    []xmlEnclosure `xml:"enclosure"`
+	Categories    []xmlCategory  `xml:"category"`
+	MagnetURI     string         `xml:"magnetURI"`
+	InfoHash      string         `xml:"infoHash"`
+	ContentLength string         `xml:"contentLength"`
+}
+
+type xmlFeed struct {
+	XMLName xml.Name
+	Items   []xmlItem `xml:"channel>item"`
+	Entries []xmlItem `xml:"entry"`
+}
+
+var timeLayouts = []string{
+	time.RFC1123Z,
+	time.RFC1123,
+	time.RFC3339,
+	"Mon, 2 Jan 2006 15:04:05 -0700",
+	"Mon, 2 Jan 2006 15:04:05 MST",
+}
+
+func parseTime(s string) time.Time {
+	s = strings.TrimSpace(s)
+	for _, layout := range timeLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+func isMagnet(s string) bool {
+	return strings.HasPrefix(strings.ToLower(s), "magnet:")
+}
+
+func isTorrentURL(s string) bool {
+	return strings.HasSuffix(strings.ToLower(strings.SplitN(s, "?", 2)[0]), ".torrent")
+}
+
+func (x *xmlItem) item() Item {
+	item := Item{
+		GUID:      strings.TrimSpace(x.GUID),
+		Title:     strings.TrimSpace(x.Title),
+		MagnetURI: strings.TrimSpace(x.MagnetURI),
+	}
+	if item.GUID == "" {
+		item.GUID = strings.TrimSpace(x.ID)
+	}
+	for _, d := range []string{x.PubDate, x.Published, x.Updated} {
+		if d != "" {
+			item.Published = parseTime(d)
+			break
+		}
+	}
+	for _, c := range x.Categories {
+		if c.Term != "" {
+			item.Categories = append(item.Categories, c.Term)
+		} else if text := strings.TrimSpace(c.Text); text != "" {
+			item.Categories = append(item.Categories, text)
+		}
+	}
+	if n, err := strconv.ParseInt(strings.TrimSpace(x.ContentLength), 10, 64); err == nil {
+		item.Size = n
+	}
+
+	// Torrent files are preferably found in enclosures.
+	for _, e := range x.Enclosures {
+		if item.TorrentURL == "" || e.Type == torrentMIMEType {
+			item.TorrentURL = e.URL
+			if n, err := strconv.ParseInt(e.Length, 10, 64); err == nil && item.Size == 0 {
+				item.Size = n
+			}
+		}
+	}
+	for _, l := range x.Links {
+		href := strings.TrimSpace(l.Href)
+		if href == "" {
+			href = strings.TrimSpace(l.Text)
+		}
+		switch {
+		case href == "":
+		case isMagnet(href):
+			if item.MagnetURI == "" {
+				item.MagnetURI = href
+			}
+		case l.Rel == "enclosure" || l.Type == torrentMIMEType:
+			if item.TorrentURL == "" || l.Type == torrentMIMEType {
+				item.TorrentURL = href
+			}
+			if n, err := strconv.ParseInt(l.Length, 10, 64); err == nil && item.Size == 0 {
+				item.Size = n
+			}
+		case l.Rel == "" || l.Rel == "alternate":
+			if item.Link == "" {
+				item.Link = href
+			}
+		}
+	}
+	if item.TorrentURL == "" && isTorrentURL(item.Link) {
+		item.TorrentURL = item.Link
+	}
+
+	if h, err := transmission.ParseHash(strings.TrimSpace(x.InfoHash)); err == nil {
+		item.InfoHash = h
+	} else if m, err := transmission.ParseMagnet(item.MagnetURI); err == nil {
+		item.InfoHash = m.Hash()
+	}
+
+	return item
+}
+
+// Parse parses RSS 2.0 or Atom feed. Torrent namespace extensions (magnetURI,
+// infoHash, contentLength) and enclosures are used to find the torrent of
+// each item.
+func Parse(r io.Reader) ([]Item, error) {
+	var feed xmlFeed
+	if err := xml.NewDecoder(r).Decode(&feed); err != nil {
+		return nil, err
+	}
+
+	var raw []xmlItem
+	switch feed.XMLName.Local {
+	case "rss":
+		raw = feed.Items
+	case "feed":
+		raw = feed.Entries
+	default:
+		return nil, errors.New("feed: unsupported feed type " + feed.XMLName.Local)
+	}
+
+	items := make([]Item, len(raw))
+	for i := range raw {
+		items[i] = raw[i].item()
+	}
+	return items, nil
+}
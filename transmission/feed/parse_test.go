@@ -0,0 +1,105 @@
+package feed
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+const testRSS = `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0" xmlns:torrent="http://xmlns.ezrss.it/0.1/" xmlns:atom="http://www.w3.org/2005/Atom">
+  <channel>
+    <title>Test</title>
+    <atom:link href="http://example.com/rss" rel="self" type="application/rss+xml"/>
+    <item>
+      <title>Show S01E01 1080p</title>
+      <link>http://example.com/item/1</link>
+      <guid isPermaLink="false">item-1</guid>
+      <pubDate>Tue, 10 Oct 2023 10:00:00 +0000</pubDate>
+      <category>TV</category>
+      <enclosure url="http://example.com/1.torrent" length="1000" type="application/x-bittorrent"/>
+      <torrent:contentLength>123456</torrent:contentLength>
+      <torrent:infoHash>C12FE1C06BBA254A9DC9F519B335AA7C1367A88A</torrent:infoHash>
+      <torrent:magnetURI><![CDATA[magnet:?xt=urn:btih:c12fe1c06bba254a9dc9f519b335aa7c1367a88a]]></torrent:magnetURI>
+    </item>
+    <item>
+      <title>Show S01E02 720p</title>
+      <link>http://example.com/2.torrent</link>
+    </item>
+  </channel>
+</rss>`
+
+const testAtom = `<?xml version="1.0" encoding="utf-8"?>
+<feed xmlns="http://www.w3.org/2005/Atom">
+  <title>Test</title>
+  <entry>
+    <id>urn:entry:1</id>
+    <title>Movie 2023</title>
+    <updated>2023-10-10T10:00:00Z</updated>
+    <category term="Movies"/>
+    <link rel="alternate" href="http://example.com/entry/1"/>
+    <link rel="enclosure" type="application/x-bittorrent" length="2000" href="http://example.com/movie.torrent"/>
+  </entry>
+</feed>`
+
+func TestParse_rss(t *testing.T) {
+	items, err := Parse(strings.NewReader(testRSS))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []Item{
+		{
+			GUID:       "item-1",
+			Title:      "Show S01E01 1080p",
+			Link:       "http://example.com/item/1",
+			TorrentURL: "http://example.com/1.torrent",
+			MagnetURI:  "magnet:?xt=urn:btih:c12fe1c06bba254a9dc9f519b335aa7c1367a88a",
+			InfoHash:   "c12fe1c06bba254a9dc9f519b335aa7c1367a88a",
+			Size:       123456,
+			Published:  time.Date(2023, 10, 10, 10, 0, 0, 0, time.UTC),
+			Categories: []string{"TV"},
+		},
+		{
+			Title:      "Show S01E02 720p",
+			Link:       "http://example.com/2.torrent",
+			TorrentURL: "http://example.com/2.torrent",
+		},
+	}
+	if !cmp.Equal(want, items) {
+		t.Errorf("unexpected items, diff = \n%s", cmp.Diff(want, items))
+	}
+	if want, got := "http://example.com/2.torrent", items[1].Key(); want != got {
+		t.Errorf("unexpected key, want = %q, got = %q", want, got)
+	}
+}
+
+func TestParse_atom(t *testing.T) {
+	items, err := Parse(strings.NewReader(testAtom))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []Item{{
+		GUID:       "urn:entry:1",
+		Title:      "Movie 2023",
+		Link:       "http://example.com/entry/1",
+		TorrentURL: "http://example.com/movie.torrent",
+		Size:       2000,
+		Published:  time.Date(2023, 10, 10, 10, 0, 0, 0, time.UTC),
+		Categories: []string{"Movies"},
+	}}
+	if !cmp.Equal(want, items) {
+		t.Errorf("unexpected items, diff = \n%s", cmp.Diff(want, items))
+	}
+}
+
+func TestParse_errors(t *testing.T) {
+	for _, in := range []string{"", "<html></html>", "<rss><channel>"} {
+		if _, err := Parse(strings.NewReader(in)); err == nil {
+			t.Errorf("expected %q to fail", in)
+		}
+	}
+}
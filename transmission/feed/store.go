@@ -0,0 +1,115 @@
+package feed
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/pborzenkov/go-transmission/transmission/internal/atomicfile"
+)
+
+// Store remembers feed items that have already been processed.
+type Store interface {
+	// IsSeen returns true if item identified by key was marked as seen
+	IsSeen(key string) (bool, error)
+	// MarkSeen marks item identified by key as seen
+	MarkSeen(key string) error
+}
+
+// MemoryStore is a Store that keeps seen items in memory.
+type MemoryStore struct {
+	mu   sync.Mutex
+	seen map[string]bool
+}
+
+var _ Store = (*MemoryStore)(nil)
+
+// NewMemoryStore returns a new empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{seen: make(map[string]bool)}
+}
+
+// IsSeen implements Store.
+func (s *MemoryStore) IsSeen(key string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.seen[key], nil
+}
+
+// MarkSeen implements Store.
+func (s *MemoryStore) MarkSeen(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.seen[key] = true
+	return nil
+}
+
+// FileStore is a Store that persists seen items in a JSON file.
+type FileStore struct {
+	path   string
+	maxAge time.Duration
+
+	mu   sync.Mutex
+	seen map[string]time.Time
+}
+
+var _ Store = (*FileStore)(nil)
+
+// NewFileStore returns a FileStore backed by the file at path, loading
+// previously seen items if the file exists. Items seen more than maxAge ago
+// are forgotten when the file is written. Zero maxAge keeps items forever.
+func NewFileStore(path string, maxAge time.Duration) (*FileStore, error) {
+	s := &FileStore{
+		path:   path,
+		maxAge: maxAge,
+		seen:   make(map[string]time.Time),
+	}
+
+	data, err := os.ReadFile(path)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return s, nil
+	case err != nil:
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &s.seen); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// IsSeen implements Store.
+func (s *FileStore) IsSeen(key string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, ok := s.seen[key]
+	return ok, nil
+}
+
+// MarkSeen implements Store. The file is rewritten on every call.
+func (s *FileStore) MarkSeen(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	s.seen[key] = now
+	if s.maxAge > 0 {
+		for k, t := range s.seen {
+			if now.Sub(t) > s.maxAge {
+				delete(s.seen, k)
+			}
+		}
+	}
+
+	data, err := json.Marshal(s.seen)
+	if err != nil {
+		return err
+	}
+	return atomicfile.Write(s.path, data, 0o644)
+}
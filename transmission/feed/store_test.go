@@ -0,0 +1,77 @@
+package feed
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func testStore(t *testing.T, s Store) {
+	t.Helper()
+
+	if seen, err := s.IsSeen("a"); err != nil || seen {
+		t.Errorf("expected a to be unseen, got = %v, %v", seen, err)
+	}
+	if err := s.MarkSeen("a"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if seen, err := s.IsSeen("a"); err != nil || !seen {
+		t.Errorf("expected a to be seen, got = %v, %v", seen, err)
+	}
+}
+
+func TestMemoryStore(t *testing.T) {
+	testStore(t, NewMemoryStore())
+}
+
+func TestFileStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "seen.json")
+
+	s, err := NewFileStore(path, 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	testStore(t, s)
+
+	s, err = NewFileStore(path, 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if seen, _ := s.IsSeen("a"); !seen {
+		t.Errorf("expected a to be loaded from file")
+	}
+}
+
+func TestFileStore_maxAge(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "seen.json")
+	old := time.Now().Add(-2 * time.Hour).Format(time.RFC3339)
+	if err := os.WriteFile(path, []byte(`{"old":"`+old+`"}`), 0o600); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+
+	s, err := NewFileStore(path, time.Hour)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if seen, _ := s.IsSeen("old"); !seen {
+		t.Errorf("expected old to be loaded from file")
+	}
+	if err := s.MarkSeen("new"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if seen, _ := s.IsSeen("old"); seen {
+		t.Errorf("expected old to be forgotten")
+	}
+}
+
+func TestFileStore_corrupted(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "seen.json")
+	if err := os.WriteFile(path, []byte("garbage"), 0o600); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+
+	if _, err := NewFileStore(path, 0); err == nil {
+		t.Errorf("expected corrupted file to fail")
+	}
+}
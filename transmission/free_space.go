@@ -5,11 +5,11 @@ import (
 )
 
 type freeSpaceRequest struct {
-	Path string `json:"path"`
+	Path string `json:"path" path:"true"`
 }
 
 type freeSpaceResponse struct {
-	Path      string `json:"path"`
+	Path      string `json:"path" path:"true"`
 	SizeBytes int64  `json:"size-bytes"`
 }
 
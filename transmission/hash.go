@@ -0,0 +1,102 @@
+package transmission
+
+import (
+	"encoding/base32"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+const (
+	hashV1Len       = 40
+	hashV1Base32Len = 32
+	hashV2Len       = 64
+)
+
+// ParseHash parses torrent info hash. It accepts 40 character hex and 32
+// character base32 encoded v1 hashes and 64 character hex encoded v2 hashes.
+// The result is normalized to lower case hex.
+func ParseHash(s string) (Hash, error) {
+	switch len(s) {
+	case hashV1Len, hashV2Len:
+		if isHex(s) {
+			return Hash(strings.ToLower(s)), nil
+		}
+	case hashV1Base32Len:
+		if b, err := base32.StdEncoding.DecodeString(strings.ToUpper(s)); err == nil {
+			return Hash(hex.EncodeToString(b)), nil
+		}
+	}
+	return "", fmt.Errorf("transmission: invalid hash %q", s)
+}
+
+func isHex(s string) bool {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') && (c < 'A' || c > 'F') {
+			return false
+		}
+	}
+	return true
+}
+
+// Bytes returns raw bytes of the hash or nil if the hash is not valid hex.
+func (h Hash) Bytes() []byte {
+	b, err := hex.DecodeString(string(h))
+	if err != nil {
+		return nil
+	}
+	return b
+}
+
+// Base32 returns base32 encoding of the hash as used in magnet links. v2
+// hashes are encoded without padding.
+func (h Hash) Base32() string {
+	return base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(h.Bytes())
+}
+
+// IsV2 returns true if h is a v2 (SHA-256) info hash.
+func (h Hash) IsV2() bool {
+	return len(h) == hashV2Len
+}
+
+// Short returns abbreviated hash suitable for display.
+func (h Hash) Short() string {
+	if len(h) <= 8 {
+		return string(h)
+	}
+	return string(h[:8])
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (h Hash) MarshalText() ([]byte, error) {
+	return []byte(h), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler. It accepts any format
+// supported by ParseHash.
+func (h *Hash) UnmarshalText(text []byte) error {
+	parsed, err := ParseHash(string(text))
+	if err != nil {
+		return err
+	}
+	*h = parsed
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler.
+func (h Hash) MarshalJSON() ([]byte, error) {
+	return json.Marshal(string(h))
+}
+
+// UnmarshalJSON implements json.Unmarshaler. Hashes reported by Transmission
+// are taken as is.
+func (h *Hash) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	*h = Hash(s)
+	return nil
+}
@@ -0,0 +1,110 @@
+package transmission
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseHash(t *testing.T) {
+	var tests = []struct {
+		name string
+		in   string
+		want Hash
+	}{
+		{
+			name: "hex",
+			in:   "C12FE1C06BBA254A9DC9F519B335AA7C1367A88A",
+			want: "c12fe1c06bba254a9dc9f519b335aa7c1367a88a",
+		},
+		{
+			name: "base32",
+			in:   "yex6dqdlxisuvhoj6um3gnnkpqjwpkek",
+			want: "c12fe1c06bba254a9dc9f519b335aa7c1367a88a",
+		},
+		{
+			name: "v2",
+			in:   "D8DD32AC93357C368556AF3AC1D95C9D76BD0DFF6FA9833ECDAC3D53134EFABB",
+			want: "d8dd32ac93357c368556af3ac1d95c9d76bd0dff6fa9833ecdac3d53134efabb",
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := ParseHash(tc.in)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tc.want != got {
+				t.Errorf("unexpected hash, want = %q, got = %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestParseHash_errors(t *testing.T) {
+	for _, in := range []string{
+		"",
+		"12345",
+		"c12fe1c06bba254a9dc9f519b335aa7c1367a88",
+		"z12fe1c06bba254a9dc9f519b335aa7c1367a88a",
+		"yex6dqdlxisuvhoj6um3gnnkpqjwpke1",
+	} {
+		if _, err := ParseHash(in); err == nil {
+			t.Errorf("expected %q to fail", in)
+		}
+	}
+}
+
+func TestHash_formats(t *testing.T) {
+	h := Hash("c12fe1c06bba254a9dc9f519b335aa7c1367a88a")
+
+	if want, got := 20, len(h.Bytes()); want != got {
+		t.Errorf("unexpected bytes length, want = %d, got = %d", want, got)
+	}
+	if want, got := "YEX6DQDLXISUVHOJ6UM3GNNKPQJWPKEK", h.Base32(); want != got {
+		t.Errorf("unexpected base32, want = %q, got = %q", want, got)
+	}
+	if h.IsV2() {
+		t.Errorf("expected v1 hash")
+	}
+	if want, got := "c12fe1c0", h.Short(); want != got {
+		t.Errorf("unexpected short hash, want = %q, got = %q", want, got)
+	}
+	if Hash("xyz").Bytes() != nil {
+		t.Errorf("expected invalid hash to have no bytes")
+	}
+}
+
+func TestHash_text(t *testing.T) {
+	var cfg struct {
+		Hashes map[Hash]string `json:"hashes"`
+	}
+	in := `{"hashes":{"YEX6DQDLXISUVHOJ6UM3GNNKPQJWPKEK":"a"}}`
+	if err := json.Unmarshal([]byte(in), &cfg); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := map[Hash]string{"c12fe1c06bba254a9dc9f519b335aa7c1367a88a": "a"}
+	if !cmp.Equal(want, cfg.Hashes) {
+		t.Errorf("unexpected hashes, diff = \n%s", cmp.Diff(want, cfg.Hashes))
+	}
+
+	out, err := json.Marshal(cfg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want, got := `{"hashes":{"c12fe1c06bba254a9dc9f519b335aa7c1367a88a":"a"}}`, string(out); want != got {
+		t.Errorf("unexpected JSON, want = %q, got = %q", want, got)
+	}
+
+	if err := json.Unmarshal([]byte(`{"hashes":{"bogus":"a"}}`), &cfg); err == nil {
+		t.Errorf("expected invalid hash to fail")
+	}
+
+	var h Hash
+	if err := h.UnmarshalText([]byte("bogus")); err == nil {
+		t.Errorf("expected invalid hash to fail")
+	}
+}
@@ -0,0 +1,153 @@
+package health
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/pborzenkov/go-transmission/transmission"
+)
+
+// Condition is an unhealthy state of a torrent.
+type Condition int
+
+const (
+	// ConditionStalled indicates that an active download doesn't transfer
+	// any data
+	ConditionStalled Condition = iota
+	// ConditionDead indicates that a download was inactive for a long time
+	// and nobody is known to have the missing data
+	ConditionDead
+	// ConditionMetadataStuck indicates that a magnet link doesn't resolve
+	// into a torrent
+	ConditionMetadataStuck
+	// ConditionTrackerBroken indicates that announces to all trackers fail
+	ConditionTrackerBroken
+)
+
+// String implements fmt.Stringer.
+func (c Condition) String() string {
+	switch c {
+	case ConditionStalled:
+		return "stalled"
+	case ConditionDead:
+		return "dead"
+	case ConditionMetadataStuck:
+		return "metadata stuck"
+	case ConditionTrackerBroken:
+		return "tracker broken"
+	default:
+		return fmt.Sprintf("Condition(%d)", c)
+	}
+}
+
+// Remedy is an action applied to an unhealthy torrent.
+type Remedy int
+
+const (
+	// RemedyNone only reports the condition
+	RemedyNone Remedy = iota
+	// RemedyReannounce asks trackers for more peers
+	RemedyReannounce
+	// RemedyQueueBottom moves the torrent to the bottom of the queue
+	RemedyQueueBottom
+	// RemedyStop stops the torrent
+	RemedyStop
+	// RemedyRemove removes the torrent
+	RemedyRemove
+)
+
+// String implements fmt.Stringer.
+func (r Remedy) String() string {
+	switch r {
+	case RemedyNone:
+		return "none"
+	case RemedyReannounce:
+		return "reannounce"
+	case RemedyQueueBottom:
+		return "move to queue bottom"
+	case RemedyStop:
+		return "stop"
+	case RemedyRemove:
+		return "remove"
+	default:
+		return fmt.Sprintf("Remedy(%d)", r)
+	}
+}
+
+// DefaultRemedies are remedies used if Analyzer.Remedies is nil.
+var DefaultRemedies = map[Condition]Remedy{
+	ConditionStalled:       RemedyReannounce,
+	ConditionDead:          RemedyQueueBottom,
+	ConditionMetadataStuck: RemedyReannounce,
+	ConditionTrackerBroken: RemedyReannounce,
+}
+
+// Fields is a list of torrent fields required to diagnose torrents.
+var Fields = []transmission.TorrentField{
+	transmission.TorrentFieldID,
+	transmission.TorrentFieldHash,
+	transmission.TorrentFieldName,
+	transmission.TorrentFieldStatus,
+	transmission.TorrentFieldAddedAt,
+	transmission.TorrentFieldLastActiveAt,
+	transmission.TorrentFieldIsStalled,
+	transmission.TorrentFieldWantedAvailable,
+	transmission.TorrentFieldWantedLeft,
+	transmission.TorrentFieldMetadataDone,
+	transmission.TorrentFieldPeersSendingToUs,
+	transmission.TorrentFieldPeersFrom,
+	transmission.TorrentFieldTrackerStats,
+}
+
+// idle returns for how long the torrent is inactive. Torrents that were never
+// active are considered idle since they were added.
+func idle(t *transmission.Torrent, now time.Time) time.Duration {
+	last := t.LastActiveAt
+	if t.AddedAt.After(last) {
+		last = t.AddedAt
+	}
+	if last.IsZero() {
+		return 0
+	}
+	return now.Sub(last)
+}
+
+// peers returns the number of peers the torrent is connected to.
+func peers(t *transmission.Torrent) int {
+	p := t.PeersFrom
+	return p.Tracker + p.Incoming + p.Cache + p.DHT + p.LPD + p.PEX + p.LTEP
+}
+
+// seeders returns the largest number of seeders reported by a tracker, or -1
+// if no tracker reported it.
+func seeders(t *transmission.Torrent) int {
+	n := -1
+	for i := range t.TrackerStats {
+		n = max(n, t.TrackerStats[i].Seeders)
+	}
+	return n
+}
+
+// trackerBroken returns the result of the last failed announce if announces
+// to all trackers of the torrent failed.
+func trackerBroken(t *transmission.Torrent) (string, bool) {
+	if len(t.TrackerStats) == 0 {
+		return "", false
+	}
+	for i := range t.TrackerStats {
+		ts := &t.TrackerStats[i]
+		if !ts.HasAnnounced || (ts.IsLastAnnounceSucceeded && !ts.IsLastAnnounceTimedOut) {
+			return "", false
+		}
+	}
+	last := &t.TrackerStats[0]
+	for i := range t.TrackerStats {
+		if t.TrackerStats[i].LastAnnounceTime.After(last.LastAnnounceTime) {
+			last = &t.TrackerStats[i]
+		}
+	}
+	if last.IsLastAnnounceTimedOut {
+		return "timed out", true
+	}
+	return last.LastAnnounceResult, true
+}
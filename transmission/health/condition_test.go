@@ -0,0 +1,178 @@
+package health
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pborzenkov/go-transmission/transmission"
+)
+
+var now = time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+func brokenTracker(result string) transmission.TrackerStat {
+	return transmission.TrackerStat{
+		HasAnnounced:       true,
+		LastAnnounceTime:   now.Add(-time.Minute),
+		LastAnnounceResult: result,
+		Seeders:            -1,
+	}
+}
+
+func workingTracker(seeders int) transmission.TrackerStat {
+	return transmission.TrackerStat{
+		HasAnnounced:            true,
+		IsLastAnnounceSucceeded: true,
+		LastAnnounceResult:      "Success",
+		Seeders:                 seeders,
+	}
+}
+
+func TestAnalyzer_Diagnose(t *testing.T) {
+	var tests = []struct {
+		name    string
+		torrent transmission.Torrent
+		want    Condition
+		reason  string
+		healthy bool
+	}{
+		{
+			name: "healthy download",
+			torrent: transmission.Torrent{
+				Status: transmission.StatusDownload, MetadataDone: 1, WantedLeft: 100, PeersSendingToUs: 2,
+				LastActiveAt: now, TrackerStats: []transmission.TrackerStat{workingTracker(5)},
+			},
+			healthy: true,
+		},
+		{
+			name: "stopped",
+			torrent: transmission.Torrent{
+				Status: transmission.StatusStopped, MetadataDone: 1, WantedLeft: 100, AddedAt: now.Add(-30 * 24 * time.Hour),
+			},
+			healthy: true,
+		},
+		{
+			name: "stalled flag",
+			torrent: transmission.Torrent{
+				Status: transmission.StatusDownload, MetadataDone: 1, WantedLeft: 100, IsStalled: true,
+				LastActiveAt: now.Add(-10 * time.Minute), PeersFrom: transmission.PeersOrigin{DHT: 2, PEX: 1},
+			},
+			want:   ConditionStalled,
+			reason: "inactive for 10m0s, 3 peers",
+		},
+		{
+			name: "stalled inactive",
+			torrent: transmission.Torrent{
+				Status: transmission.StatusDownload, MetadataDone: 1, WantedLeft: 100, WantedAvailable: 100,
+				AddedAt: now.Add(-2 * time.Hour), TrackerStats: []transmission.TrackerStat{workingTracker(0)},
+			},
+			want:   ConditionStalled,
+			reason: "inactive for 2h0m0s, 0 peers",
+		},
+		{
+			name: "recently active",
+			torrent: transmission.Torrent{
+				Status: transmission.StatusDownload, MetadataDone: 1, WantedLeft: 100,
+				AddedAt: now.Add(-2 * time.Hour), LastActiveAt: now.Add(-time.Minute),
+			},
+			healthy: true,
+		},
+		{
+			name: "dead",
+			torrent: transmission.Torrent{
+				Status: transmission.StatusDownload, MetadataDone: 1, WantedLeft: 100, WantedAvailable: 40,
+				LastActiveAt: now.Add(-8 * 24 * time.Hour), TrackerStats: []transmission.TrackerStat{workingTracker(0)},
+			},
+			want:   ConditionDead,
+			reason: "inactive for 192h0m0s, no seeders, 40 of 100 bytes available",
+		},
+		{
+			name: "inactive with seeders",
+			torrent: transmission.Torrent{
+				Status: transmission.StatusDownload, MetadataDone: 1, WantedLeft: 100,
+				LastActiveAt: now.Add(-8 * 24 * time.Hour), TrackerStats: []transmission.TrackerStat{workingTracker(3)},
+			},
+			want:   ConditionStalled,
+			reason: "inactive for 192h0m0s, 0 peers",
+		},
+		{
+			name: "metadata stuck",
+			torrent: transmission.Torrent{
+				Status: transmission.StatusDownload, MetadataDone: 0.25, AddedAt: now.Add(-90 * time.Minute),
+				PeersFrom: transmission.PeersOrigin{DHT: 1},
+			},
+			want:   ConditionMetadataStuck,
+			reason: "25% of metadata after 1h30m0s, 1 peers",
+		},
+		{
+			name: "fresh magnet",
+			torrent: transmission.Torrent{
+				Status: transmission.StatusDownload, AddedAt: now.Add(-time.Minute),
+			},
+			healthy: true,
+		},
+		{
+			name: "tracker broken",
+			torrent: transmission.Torrent{
+				Status: transmission.StatusSeed, MetadataDone: 1,
+				TrackerStats: []transmission.TrackerStat{
+					brokenTracker("Could not connect to tracker"),
+					{HasAnnounced: true, IsLastAnnounceTimedOut: true, LastAnnounceTime: now.Add(-time.Hour)},
+				},
+			},
+			want:   ConditionTrackerBroken,
+			reason: "all announces failed: Could not connect to tracker",
+		},
+		{
+			name: "one tracker works",
+			torrent: transmission.Torrent{
+				Status: transmission.StatusSeed, MetadataDone: 1,
+				TrackerStats: []transmission.TrackerStat{brokenTracker("failure"), workingTracker(1)},
+			},
+			healthy: true,
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			d := (&Analyzer{}).Diagnose(&tc.torrent, now)
+			if tc.healthy {
+				if d != nil {
+					t.Errorf("expected torrent to be healthy, got = %v", d)
+				}
+				return
+			}
+			if d == nil {
+				t.Fatalf("expected torrent to be %s", tc.want)
+			}
+			if d.Condition != tc.want {
+				t.Errorf("unexpected condition, want = %s, got = %s", tc.want, d.Condition)
+			}
+			if d.Reason != tc.reason {
+				t.Errorf("unexpected reason, want = %q, got = %q", tc.reason, d.Reason)
+			}
+		})
+	}
+}
+
+func TestAnalyzer_Diagnose_remedies(t *testing.T) {
+	torrent := &transmission.Torrent{
+		Status: transmission.StatusDownload, MetadataDone: 1, WantedLeft: 100, IsStalled: true, LastActiveAt: now,
+	}
+
+	if want, got := RemedyReannounce, (&Analyzer{}).Diagnose(torrent, now).Remedy; want != got {
+		t.Errorf("unexpected default remedy, want = %s, got = %s", want, got)
+	}
+
+	a := &Analyzer{Remedies: map[Condition]Remedy{ConditionStalled: RemedyRemove}, RemoveData: true}
+	if d := a.Diagnose(torrent, now); d.Remedy != RemedyRemove || !d.RemoveData {
+		t.Errorf("unexpected remedy, want = %s with data, got = %s (data %v)", RemedyRemove, d.Remedy, d.RemoveData)
+	}
+
+	a = &Analyzer{Remedies: map[Condition]Remedy{ConditionDead: RemedyStop}}
+	if want, got := RemedyNone, a.Diagnose(torrent, now).Remedy; want != got {
+		t.Errorf("unexpected remedy, want = %s, got = %s", want, got)
+	}
+}
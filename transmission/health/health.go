@@ -0,0 +1,234 @@
+// Package health detects stalled and dead torrents.
+//
+// Analyzer combines the stall flag, last activity time, availability of the
+// missing data, connected peers, tracker statistics and metadata progress of
+// torrents to classify unhealthy ones as stalled, dead, metadata-stuck or
+// tracker-broken. Every condition can be paired with a remedy: reannounce,
+// move to the bottom of the queue, stop or remove.
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/pborzenkov/go-transmission/transmission"
+	"github.com/pborzenkov/go-transmission/transmission/internal/periodic"
+)
+
+const (
+	defaultStalledFor      = 30 * time.Minute
+	defaultDeadFor         = 7 * 24 * time.Hour
+	defaultMetadataTimeout = time.Hour
+	defaultInterval        = 10 * time.Minute
+)
+
+// Client is a subset of *transmission.Client used by Analyzer.
+type Client interface {
+	GetTorrents(ctx context.Context, ids transmission.Identifier, fields ...transmission.TorrentField) ([]*transmission.Torrent, error) //nolint:lll
+	ReannounceTorrents(ctx context.Context, ids transmission.Identifier) error
+	QueueMoveToBottom(ctx context.Context, ids transmission.Identifier) error
+	StopTorrents(ctx context.Context, ids transmission.Identifier) error
+	RemoveTorrents(ctx context.Context, ids transmission.Identifier, removeData bool) error
+}
+
+var _ Client = (*transmission.Client)(nil)
+
+// Diagnosis describes an unhealthy torrent and the remedy taken, or planned,
+// for it.
+type Diagnosis struct {
+	// ID of the torrent
+	ID transmission.ID
+	// Hash of the torrent
+	Hash transmission.Hash
+	// Name of the torrent
+	Name string
+	// Detected condition
+	Condition Condition
+	// Description of the symptoms
+	Reason string
+	// Remedy to apply
+	Remedy Remedy
+	// Remove downloaded data together with the torrent (RemedyRemove only)
+	RemoveData bool
+	// Indicates whether the remedy was applied
+	Applied bool
+	// An error that prevented the remedy from being applied
+	Err error
+}
+
+// String returns a human readable description of the diagnosis.
+func (d *Diagnosis) String() string {
+	s := fmt.Sprintf("torrent %d (%s): %s (%s)", d.ID, d.Name, d.Condition, d.Reason)
+	if d.Remedy == RemedyNone {
+		return s
+	}
+	s += fmt.Sprintf(": %s", d.Remedy)
+	switch {
+	case d.Err != nil:
+		s += fmt.Sprintf(": %v", d.Err)
+	case !d.Applied:
+		s += " [dry run]"
+	}
+	return s
+}
+
+// Analyzer diagnoses torrents and applies remedies to the unhealthy ones.
+type Analyzer struct {
+	// Transmission client
+	Client Client
+	// An active download that is flagged as stalled or inactive for at
+	// least StalledFor is stalled. If 0, 30 minutes is used
+	StalledFor time.Duration
+	// An active download that is inactive for at least DeadFor, has no
+	// known seeders and not all of its missing data available is dead. If
+	// 0, 7 days is used
+	DeadFor time.Duration
+	// A magnet link without metadata MetadataTimeout after it was added is
+	// metadata-stuck. If 0, 1 hour is used
+	MetadataTimeout time.Duration
+	// Remedy for every condition. Conditions without a remedy are only
+	// reported. If nil, DefaultRemedies is used
+	Remedies map[Condition]Remedy
+	// Remove downloaded data together with the torrent (RemedyRemove only)
+	RemoveData bool
+	// Only report remedies, don't apply them
+	DryRun bool
+	// Interval between checks in Run. If 0, 10 minutes is used
+	Interval time.Duration
+	// Called with every diagnosis
+	OnDiagnosis func(*Diagnosis)
+	// Called when Run fails to get the torrents to diagnose
+	OnError func(error)
+}
+
+func orDefault(d, def time.Duration) time.Duration {
+	if d <= 0 {
+		return def
+	}
+	return d
+}
+
+// Diagnose returns the condition of t at the time now, or nil if t is
+// healthy. t must have all the Fields populated. If t has several
+// conditions, the one that most likely causes the others is returned.
+func (a *Analyzer) Diagnose(t *transmission.Torrent, now time.Time) *Diagnosis {
+	switch t.Status {
+	case transmission.StatusDownload, transmission.StatusSeed:
+	default:
+		// Stopped, queued and verified torrents don't transfer data.
+		return nil
+	}
+
+	diag := func(cond Condition, format string, args ...any) *Diagnosis {
+		remedies := a.Remedies
+		if remedies == nil {
+			remedies = DefaultRemedies
+		}
+		d := &Diagnosis{
+			ID:        t.ID,
+			Hash:      t.Hash,
+			Name:      t.Name,
+			Condition: cond,
+			Reason:    fmt.Sprintf(format, args...),
+			Remedy:    remedies[cond],
+		}
+		if d.Remedy == RemedyRemove {
+			d.RemoveData = a.RemoveData
+		}
+		return d
+	}
+
+	if t.MetadataDone < 1 && !t.AddedAt.IsZero() {
+		if age := now.Sub(t.AddedAt); age >= orDefault(a.MetadataTimeout, defaultMetadataTimeout) {
+			return diag(ConditionMetadataStuck, "%.0f%% of metadata after %s, %d peers",
+				t.MetadataDone*100, age.Truncate(time.Minute), peers(t))
+		}
+	}
+	if result, ok := trackerBroken(t); ok {
+		return diag(ConditionTrackerBroken, "all announces failed: %s", result)
+	}
+	if t.Status != transmission.StatusDownload || t.WantedLeft == 0 || t.PeersSendingToUs > 0 {
+		return nil
+	}
+
+	inactive := idle(t, now)
+	if inactive >= orDefault(a.DeadFor, defaultDeadFor) && seeders(t) <= 0 && t.WantedAvailable < t.WantedLeft {
+		return diag(ConditionDead, "inactive for %s, no seeders, %d of %d bytes available",
+			inactive.Truncate(time.Minute), t.WantedAvailable, t.WantedLeft)
+	}
+	if t.IsStalled || inactive >= orDefault(a.StalledFor, defaultStalledFor) {
+		return diag(ConditionStalled, "inactive for %s, %d peers", inactive.Truncate(time.Minute), peers(t))
+	}
+	return nil
+}
+
+// Analyze diagnoses all torrents and returns the unhealthy ones, without
+// applying any remedies.
+func (a *Analyzer) Analyze(ctx context.Context) ([]*Diagnosis, error) {
+	torrents, err := a.Client.GetTorrents(ctx, transmission.All(), Fields...)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	var diags []*Diagnosis
+	for _, t := range torrents {
+		if d := a.Diagnose(t, now); d != nil {
+			diags = append(diags, d)
+		}
+	}
+	return diags, nil
+}
+
+// Remediate diagnoses all torrents and applies remedies to the unhealthy
+// ones, unless DryRun is set. A failure to apply a remedy doesn't stop the
+// process, instead it is reported in the corresponding diagnosis.
+func (a *Analyzer) Remediate(ctx context.Context) ([]*Diagnosis, error) {
+	diags, err := a.Analyze(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, d := range diags {
+		if d.Remedy != RemedyNone && !a.DryRun {
+			if d.Err = a.apply(ctx, d); d.Err == nil {
+				d.Applied = true
+			}
+		}
+		if a.OnDiagnosis != nil {
+			a.OnDiagnosis(d)
+		}
+	}
+	return diags, nil
+}
+
+func (a *Analyzer) apply(ctx context.Context, d *Diagnosis) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	switch d.Remedy {
+	case RemedyReannounce:
+		return a.Client.ReannounceTorrents(ctx, d.ID)
+	case RemedyQueueBottom:
+		return a.Client.QueueMoveToBottom(ctx, d.ID)
+	case RemedyStop:
+		return a.Client.StopTorrents(ctx, d.ID)
+	case RemedyRemove:
+		return a.Client.RemoveTorrents(ctx, d.ID, d.RemoveData)
+	}
+	return fmt.Errorf("health: unknown remedy %d", d.Remedy)
+}
+
+// Run diagnoses the torrents right away and then every Interval, applying
+// remedies as Remediate does, until ctx is done. It returns ctx.Err().
+func (a *Analyzer) Run(ctx context.Context) error {
+	if a.Client == nil {
+		return errors.New("health: client is not set")
+	}
+	return periodic.Run(ctx, orDefault(a.Interval, defaultInterval), func(ctx context.Context) error {
+		_, err := a.Remediate(ctx)
+		return err
+	}, a.OnError)
+}
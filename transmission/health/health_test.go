@@ -0,0 +1,196 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pborzenkov/go-transmission/transmission"
+)
+
+type fakeClient struct {
+	mu       sync.Mutex
+	torrents []*transmission.Torrent
+	calls    []string
+	err      error
+}
+
+func (c *fakeClient) GetTorrents(ctx context.Context, ids transmission.Identifier, fields ...transmission.TorrentField) ([]*transmission.Torrent, error) { //nolint:lll
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.err != nil {
+		return nil, c.err
+	}
+	return c.torrents, nil
+}
+
+func (c *fakeClient) record(call string, args ...any) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.calls = append(c.calls, fmt.Sprint(append([]any{call}, args...)...))
+	return nil
+}
+
+func (c *fakeClient) ReannounceTorrents(ctx context.Context, ids transmission.Identifier) error {
+	return c.record("reannounce ", ids)
+}
+
+func (c *fakeClient) QueueMoveToBottom(ctx context.Context, ids transmission.Identifier) error {
+	return c.record("bottom ", ids)
+}
+
+func (c *fakeClient) StopTorrents(ctx context.Context, ids transmission.Identifier) error {
+	if ids == transmission.ID(3) {
+		return errors.New("failed")
+	}
+	return c.record("stop ", ids)
+}
+
+func (c *fakeClient) RemoveTorrents(ctx context.Context, ids transmission.Identifier, removeData bool) error {
+	return c.record("remove ", ids, " ", removeData)
+}
+
+func testAnalyzer() (*Analyzer, *fakeClient) {
+	recent := time.Now().Add(-time.Minute)
+	long := time.Now().Add(-30 * 24 * time.Hour)
+	client := &fakeClient{
+		torrents: []*transmission.Torrent{
+			{ID: 1, Name: "healthy", Status: transmission.StatusDownload, MetadataDone: 1, WantedLeft: 10,
+				LastActiveAt: recent},
+			{ID: 2, Name: "stalled", Status: transmission.StatusDownload, MetadataDone: 1, WantedLeft: 10,
+				IsStalled: true, LastActiveAt: recent},
+			{ID: 3, Name: "dead", Status: transmission.StatusDownload, MetadataDone: 1, WantedLeft: 10,
+				LastActiveAt: long},
+			{ID: 4, Name: "magnet", Status: transmission.StatusDownload, AddedAt: long},
+		},
+	}
+	return &Analyzer{Client: client}, client
+}
+
+func TestAnalyzer_Analyze(t *testing.T) {
+	a, client := testAnalyzer()
+
+	diags, err := a.Analyze(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var got []string
+	for _, d := range diags {
+		got = append(got, fmt.Sprint(d.ID, " ", d.Condition, ": ", d.Remedy))
+	}
+	want := []string{"2 stalled: reannounce", "3 dead: move to queue bottom", "4 metadata stuck: reannounce"}
+	if !cmp.Equal(want, got) {
+		t.Errorf("unexpected diagnoses, diff = \n%s", cmp.Diff(want, got))
+	}
+	if len(client.calls) != 0 {
+		t.Errorf("unexpected calls: %v", client.calls)
+	}
+}
+
+func TestAnalyzer_Remediate(t *testing.T) {
+	a, client := testAnalyzer()
+	a.Remedies = map[Condition]Remedy{
+		ConditionStalled:       RemedyReannounce,
+		ConditionDead:          RemedyStop,
+		ConditionMetadataStuck: RemedyRemove,
+	}
+	a.RemoveData = true
+
+	var reported int
+	a.OnDiagnosis = func(*Diagnosis) { reported++ }
+
+	diags, err := a.Remediate(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want, got := len(diags), reported; want != got {
+		t.Errorf("unexpected number of reported diagnoses, want = %d, got = %d", want, got)
+	}
+
+	want := []string{"reannounce 2", "remove 4 true"}
+	if !cmp.Equal(want, client.calls) {
+		t.Errorf("unexpected calls, diff = \n%s", cmp.Diff(want, client.calls))
+	}
+	for _, d := range diags {
+		if failed := d.ID == 3; failed != (d.Err != nil) || failed == d.Applied {
+			t.Errorf("unexpected outcome for torrent %d: applied = %v, err = %v", d.ID, d.Applied, d.Err)
+		}
+	}
+}
+
+func TestAnalyzer_Remediate_dryRun(t *testing.T) {
+	a, client := testAnalyzer()
+	a.DryRun = true
+
+	diags, err := a.Remediate(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(diags) != 3 {
+		t.Errorf("unexpected number of diagnoses, want = 3, got = %d", len(diags))
+	}
+	for _, d := range diags {
+		if d.Applied {
+			t.Errorf("unexpected applied remedy: %v", d)
+		}
+	}
+	if len(client.calls) != 0 {
+		t.Errorf("unexpected calls: %v", client.calls)
+	}
+
+	client.err = errors.New("daemon is down")
+	if _, err := a.Remediate(context.Background()); err == nil {
+		t.Errorf("expected Remediate to fail")
+	}
+}
+
+func TestAnalyzer_Run(t *testing.T) {
+	a, _ := testAnalyzer()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	a.Interval = time.Hour
+	a.OnDiagnosis = func(*Diagnosis) { cancel() }
+	if err := a.Run(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("unexpected error, want = %v, got = %v", context.Canceled, err)
+	}
+	if err := (&Analyzer{}).Run(context.Background()); err == nil {
+		t.Errorf("expected analyzer without client to fail")
+	}
+}
+
+func TestDiagnosis_String(t *testing.T) {
+	var tests = []struct {
+		diag Diagnosis
+		want string
+	}{
+		{
+			diag: Diagnosis{ID: 1, Name: "a", Condition: ConditionDead, Reason: "r"},
+			want: "torrent 1 (a): dead (r)",
+		},
+		{
+			diag: Diagnosis{ID: 1, Name: "a", Condition: ConditionStalled, Reason: "r", Remedy: RemedyReannounce},
+			want: "torrent 1 (a): stalled (r): reannounce [dry run]",
+		},
+		{
+			diag: Diagnosis{ID: 1, Name: "a", Condition: ConditionStalled, Reason: "r", Remedy: RemedyStop, Applied: true},
+			want: "torrent 1 (a): stalled (r): stop",
+		},
+		{
+			diag: Diagnosis{ID: 1, Name: "a", Condition: ConditionStalled, Reason: "r", Remedy: RemedyStop,
+				Err: errors.New("failed")},
+			want: "torrent 1 (a): stalled (r): stop: failed",
+		},
+	}
+
+	for _, tc := range tests {
+		if got := tc.diag.String(); got != tc.want {
+			t.Errorf("unexpected string, want = %q, got = %q", tc.want, got)
+		}
+	}
+}
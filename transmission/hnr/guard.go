@@ -0,0 +1,259 @@
+package hnr
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pborzenkov/go-transmission/transmission"
+	"github.com/pborzenkov/go-transmission/transmission/internal/periodic"
+)
+
+const defaultInterval = 10 * time.Minute
+
+// Client is a subset of *transmission.Client used by Guard.
+type Client interface {
+	GetTorrents(ctx context.Context, ids transmission.Identifier, fields ...transmission.TorrentField) ([]*transmission.Torrent, error) //nolint:lll
+	StopTorrents(ctx context.Context, ids transmission.Identifier) error
+	RemoveTorrents(ctx context.Context, ids transmission.Identifier, removeData bool) error
+}
+
+var _ Client = (*transmission.Client)(nil)
+
+// Mode defines what Guard does with actions on torrents at risk.
+type Mode int
+
+const (
+	// ModeRefuse refuses the action
+	ModeRefuse Mode = iota
+	// ModeDefer remembers the action and applies it once the requirement is
+	// met (see ProcessDeferred)
+	ModeDefer
+)
+
+// Deferred is an action deferred by Guard.
+type Deferred struct {
+	// ID of the torrent
+	ID transmission.ID
+	// Hash of the torrent
+	Hash transmission.Hash
+	// Name of the torrent
+	Name string
+	// Deferred action
+	Action Action
+}
+
+// Guard guards stopping and removal of torrents against hit-and-run. The zero
+// value is not usable, Client must be set. Deferred actions are kept in memory
+// only.
+type Guard struct {
+	// Transmission client
+	Client Client
+	// Requirements are checked in order and the first one that matches a
+	// torrent is used
+	Requirements []Requirement
+	// What to do with actions on torrents at risk
+	Mode Mode
+	// Interval between processing of deferred actions in Run. If 0, 10
+	// minutes is used
+	Interval time.Duration
+	// Called for every deferred action that was applied or failed
+	OnDeferred func(Deferred, error)
+	// Called when Run fails to look up deferred torrents or to apply deferred
+	// actions. Failed actions are retried on the next tick
+	OnError func(error)
+
+	mu       sync.Mutex
+	deferred map[transmission.Hash]*Deferred
+}
+
+// StopTorrents stops torrents identified by ids that are not at risk. For
+// every torrent at risk a *ShortfallError is returned, joined with other
+// errors.
+func (g *Guard) StopTorrents(ctx context.Context, ids transmission.Identifier) error {
+	return g.guard(ctx, ids, ActionStop)
+}
+
+// RemoveTorrents removes torrents identified by ids that are not at risk. For
+// every torrent at risk a *ShortfallError is returned, joined with other
+// errors.
+func (g *Guard) RemoveTorrents(ctx context.Context, ids transmission.Identifier, removeData bool) error {
+	action := ActionRemove
+	if removeData {
+		action = ActionRemoveData
+	}
+	return g.guard(ctx, ids, action)
+}
+
+func (g *Guard) guard(ctx context.Context, ids transmission.Identifier, action Action) error {
+	torrents, err := g.Client.GetTorrents(ctx, ids, Fields...)
+	if err != nil {
+		return err
+	}
+
+	var (
+		safe []transmission.SingularIdentifier
+		errs []error
+	)
+	for _, t := range torrents {
+		s := Check(t, g.Requirements)
+		if s == nil {
+			safe = append(safe, t.ID)
+			continue
+		}
+		err := &ShortfallError{Shortfall: *s, Action: action}
+		if g.Mode == ModeDefer {
+			g.addDeferred(&Deferred{ID: t.ID, Hash: t.Hash, Name: t.Name, Action: action})
+			err.Deferred = true
+		}
+		errs = append(errs, err)
+	}
+	if len(safe) > 0 {
+		if err := g.apply(ctx, transmission.IDs(safe...), action); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (g *Guard) apply(ctx context.Context, ids transmission.Identifier, action Action) error {
+	if action == ActionStop {
+		return g.Client.StopTorrents(ctx, ids)
+	}
+	return g.Client.RemoveTorrents(ctx, ids, action == ActionRemoveData)
+}
+
+func (g *Guard) addDeferred(d *Deferred) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.deferred == nil {
+		g.deferred = make(map[transmission.Hash]*Deferred)
+	}
+	// Removal supersedes stopping and removal with data supersedes removal.
+	if old, ok := g.deferred[d.Hash]; ok && old.Action > d.Action {
+		return
+	}
+	g.deferred[d.Hash] = d
+}
+
+// Pending returns the deferred actions ordered by torrent ID.
+func (g *Guard) Pending() []Deferred {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	pending := make([]Deferred, 0, len(g.deferred))
+	for _, d := range g.deferred {
+		pending = append(pending, *d)
+	}
+	sort.Slice(pending, func(i, j int) bool { return pending[i].ID < pending[j].ID })
+	return pending
+}
+
+// Cancel cancels the deferred action on the torrent with the given hash. It
+// returns false if there is no such action.
+func (g *Guard) Cancel(hash transmission.Hash) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	_, ok := g.deferred[hash]
+	delete(g.deferred, hash)
+	return ok
+}
+
+// ProcessDeferred applies deferred actions on torrents that met their
+// requirements and returns them. Actions on torrents that no longer exist are
+// dropped. Failed actions are retried on the next call and their errors are
+// returned joined.
+func (g *Guard) ProcessDeferred(ctx context.Context) ([]Deferred, error) {
+	pending := g.Pending()
+	if len(pending) == 0 {
+		return nil, nil
+	}
+
+	ids := make([]transmission.SingularIdentifier, 0, len(pending))
+	for _, d := range pending {
+		ids = append(ids, d.Hash)
+	}
+	torrents, err := g.Client.GetTorrents(ctx, transmission.IDs(ids...), Fields...)
+	if err != nil {
+		return nil, err
+	}
+	existing := make(map[transmission.Hash]*transmission.Torrent, len(torrents))
+	for _, t := range torrents {
+		existing[t.Hash] = t
+	}
+
+	var (
+		applied []Deferred
+		errs    []error
+	)
+	for _, d := range pending {
+		t, ok := existing[d.Hash]
+		if !ok {
+			g.dropDeferred(d)
+			continue
+		}
+		if Check(t, g.Requirements) != nil {
+			continue
+		}
+		err := g.apply(ctx, t.ID, d.Action)
+		if g.OnDeferred != nil {
+			g.OnDeferred(d, err)
+		}
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		g.dropDeferred(d)
+		applied = append(applied, d)
+	}
+	return applied, errors.Join(errs...)
+}
+
+// dropDeferred removes d unless it was superseded in the meantime.
+func (g *Guard) dropDeferred(d Deferred) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if cur, ok := g.deferred[d.Hash]; ok && cur.Action == d.Action {
+		delete(g.deferred, d.Hash)
+	}
+}
+
+// AtRisk returns shortfalls of all torrents that would be refused or deferred
+// if stopped or removed now.
+func (g *Guard) AtRisk(ctx context.Context) ([]*Shortfall, error) {
+	torrents, err := g.Client.GetTorrents(ctx, transmission.All(), Fields...)
+	if err != nil {
+		return nil, err
+	}
+
+	var risks []*Shortfall
+	for _, t := range torrents {
+		if s := Check(t, g.Requirements); s != nil {
+			risks = append(risks, s)
+		}
+	}
+	return risks, nil
+}
+
+// Run applies deferred actions of torrents that met their requirements,
+// right away and then every Interval, until ctx is done. It returns
+// ctx.Err(). Actions are only deferred in ModeDefer, so Run is not needed in
+// other modes.
+func (g *Guard) Run(ctx context.Context) error {
+	if g.Client == nil {
+		return errors.New("hnr: client is not set")
+	}
+	interval := g.Interval
+	if interval <= 0 {
+		interval = defaultInterval
+	}
+	return periodic.Run(ctx, interval, func(ctx context.Context) error {
+		_, err := g.ProcessDeferred(ctx)
+		return err
+	}, g.OnError)
+}
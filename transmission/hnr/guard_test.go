@@ -0,0 +1,218 @@
+package hnr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pborzenkov/go-transmission/transmission"
+)
+
+type fakeClient struct {
+	mu       sync.Mutex
+	torrents []*transmission.Torrent
+	calls    []string
+	err      error
+}
+
+func selected(t *transmission.Torrent, ids any) bool {
+	switch ids := ids.(type) {
+	case transmission.ID:
+		return t.ID == ids
+	case transmission.Hash:
+		return t.Hash == ids
+	case transmission.IDList:
+		for _, id := range ids {
+			if selected(t, id) {
+				return true
+			}
+		}
+		return false
+	default:
+		return true
+	}
+}
+
+func (c *fakeClient) GetTorrents(ctx context.Context, ids transmission.Identifier, fields ...transmission.TorrentField) ([]*transmission.Torrent, error) { //nolint:lll
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.err != nil {
+		return nil, c.err
+	}
+	var torrents []*transmission.Torrent
+	for _, t := range c.torrents {
+		if selected(t, ids) {
+			torrents = append(torrents, t)
+		}
+	}
+	return torrents, nil
+}
+
+func (c *fakeClient) StopTorrents(ctx context.Context, ids transmission.Identifier) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.calls = append(c.calls, fmt.Sprint("stop ", ids))
+	return c.err
+}
+
+func (c *fakeClient) RemoveTorrents(ctx context.Context, ids transmission.Identifier, removeData bool) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.calls = append(c.calls, fmt.Sprint("remove ", ids, " ", removeData))
+	return c.err
+}
+
+func testGuard(mode Mode) (*Guard, *fakeClient) {
+	client := &fakeClient{
+		torrents: []*transmission.Torrent{
+			{ID: 1, Hash: "h1", Name: "public", DownloadedTotal: 1},
+			{ID: 2, Hash: "h2", Name: "young", IsPrivate: true, DownloadedTotal: 1, SeedingFor: time.Hour},
+			{ID: 3, Hash: "h3", Name: "old", IsPrivate: true, DownloadedTotal: 1, SeedingFor: 48 * time.Hour},
+		},
+	}
+	return &Guard{Client: client, Requirements: testRequirements, Mode: mode}, client
+}
+
+func TestGuard_refuse(t *testing.T) {
+	guard, client := testGuard(ModeRefuse)
+
+	err := guard.RemoveTorrents(context.Background(), transmission.All(), true)
+	var se *ShortfallError
+	if !errors.As(err, &se) {
+		t.Fatalf("expected *ShortfallError, got = %v", err)
+	}
+	if se.ID != 2 || se.Action != ActionRemoveData || se.Deferred {
+		t.Errorf("unexpected error: %v", se)
+	}
+	if want := []string{"remove [1 3] true"}; !cmp.Equal(want, client.calls) {
+		t.Errorf("unexpected calls, diff = \n%s", cmp.Diff(want, client.calls))
+	}
+	if len(guard.Pending()) != 0 {
+		t.Errorf("expected no deferred actions")
+	}
+
+	if err := guard.StopTorrents(context.Background(), transmission.ID(3)); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestGuard_defer(t *testing.T) {
+	guard, client := testGuard(ModeDefer)
+
+	var reported []Deferred
+	guard.OnDeferred = func(d Deferred, err error) {
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+		reported = append(reported, d)
+	}
+
+	err := guard.StopTorrents(context.Background(), transmission.ID(2))
+	var se *ShortfallError
+	if !errors.As(err, &se) || !se.Deferred {
+		t.Fatalf("expected deferred *ShortfallError, got = %v", err)
+	}
+	// Removal supersedes the deferred stop, but not the other way around.
+	_ = guard.RemoveTorrents(context.Background(), transmission.ID(2), false)
+	_ = guard.StopTorrents(context.Background(), transmission.ID(2))
+
+	want := []Deferred{{ID: 2, Hash: "h2", Name: "young", Action: ActionRemove}}
+	if got := guard.Pending(); !cmp.Equal(want, got) {
+		t.Errorf("unexpected pending actions, diff = \n%s", cmp.Diff(want, got))
+	}
+	if len(client.calls) != 0 {
+		t.Errorf("unexpected calls: %v", client.calls)
+	}
+
+	applied, err := guard.ProcessDeferred(context.Background())
+	if err != nil || len(applied) != 0 {
+		t.Errorf("expected nothing to be applied, got = %v, %v", applied, err)
+	}
+
+	client.torrents[1].SeedingFor = 25 * time.Hour
+	applied, err = guard.ProcessDeferred(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !cmp.Equal(want, applied) || !cmp.Equal(want, reported) {
+		t.Errorf("unexpected applied actions, diff = \n%s", cmp.Diff(want, applied))
+	}
+	if want := []string{"remove 2 false"}; !cmp.Equal(want, client.calls) {
+		t.Errorf("unexpected calls, diff = \n%s", cmp.Diff(want, client.calls))
+	}
+	if len(guard.Pending()) != 0 {
+		t.Errorf("expected no deferred actions")
+	}
+}
+
+func TestGuard_deferGone(t *testing.T) {
+	guard, client := testGuard(ModeDefer)
+
+	_ = guard.StopTorrents(context.Background(), transmission.All())
+	if want, got := 1, len(guard.Pending()); want != got {
+		t.Fatalf("unexpected number of pending actions, want = %d, got = %d", want, got)
+	}
+	client.torrents = client.torrents[:1]
+
+	if _, err := guard.ProcessDeferred(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(guard.Pending()) != 0 {
+		t.Errorf("expected action on removed torrent to be dropped")
+	}
+
+	guard, _ = testGuard(ModeDefer)
+	_ = guard.StopTorrents(context.Background(), transmission.All())
+	if !guard.Cancel("h2") || guard.Cancel("h2") {
+		t.Errorf("expected action to be cancelled once")
+	}
+}
+
+func TestGuard_AtRisk(t *testing.T) {
+	guard, client := testGuard(ModeRefuse)
+
+	risks, err := guard.AtRisk(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []*Shortfall{{ID: 2, Hash: "h2", Name: "young", Requirement: testRequirements[2], SeedingFor: time.Hour}}
+	if !cmp.Equal(want, risks) {
+		t.Errorf("unexpected risks, diff = \n%s", cmp.Diff(want, risks))
+	}
+
+	client.err = errors.New("daemon is down")
+	if _, err := guard.AtRisk(context.Background()); err == nil {
+		t.Errorf("expected AtRisk to fail")
+	}
+	if err := guard.StopTorrents(context.Background(), transmission.All()); err == nil {
+		t.Errorf("expected StopTorrents to fail")
+	}
+}
+
+func TestGuard_Run(t *testing.T) {
+	guard, client := testGuard(ModeDefer)
+	guard.Interval = time.Millisecond
+
+	_ = guard.StopTorrents(context.Background(), transmission.ID(2))
+	client.torrents[1].SeedingFor = 25 * time.Hour
+
+	ctx, cancel := context.WithCancel(context.Background())
+	guard.OnDeferred = func(Deferred, error) { cancel() }
+
+	if err := guard.Run(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("unexpected error, want = %v, got = %v", context.Canceled, err)
+	}
+	if want := []string{"stop 2"}; !cmp.Equal(want, client.calls) {
+		t.Errorf("unexpected calls, diff = \n%s", cmp.Diff(want, client.calls))
+	}
+	if err := (&Guard{}).Run(context.Background()); err == nil {
+		t.Errorf("expected guard without client to fail")
+	}
+}
@@ -0,0 +1,173 @@
+// Package hnr protects private tracker torrents from hit-and-run.
+//
+// Private trackers usually require every downloaded torrent to be seeded for
+// some time or to some ratio. Guard wraps RemoveTorrents and StopTorrents of
+// a Transmission client and refuses, or defers, actions on torrents that
+// haven't met the requirements of their tracker yet.
+package hnr
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pborzenkov/go-transmission/transmission"
+)
+
+// Requirement is a minimum seeding requirement of a private tracker.
+type Requirement struct {
+	// Host of the tracker. Subdomains of the host match as well. If empty,
+	// the requirement applies to all private torrents
+	Tracker string
+	// Minimum seeding time
+	MinSeedingFor time.Duration
+	// Minimum upload ratio
+	MinRatio float64
+	// The requirement is met once either minimum is reached, rather than both
+	Either bool
+}
+
+func (r *Requirement) matches(t *transmission.Torrent) bool {
+	return r.Tracker == "" || transmission.MatchTracker(t, r.Tracker)
+}
+
+// Met reports whether t satisfies the requirement.
+func (r *Requirement) Met(t *transmission.Torrent) bool {
+	seeded := t.SeedingFor >= r.MinSeedingFor
+	ratio := t.UploadRatio >= r.MinRatio
+	if r.Either && r.MinSeedingFor > 0 && r.MinRatio > 0 {
+		return seeded || ratio
+	}
+	return seeded && ratio
+}
+
+// Fields is a list of torrent fields required to check requirements.
+var Fields = []transmission.TorrentField{
+	transmission.TorrentFieldID,
+	transmission.TorrentFieldHash,
+	transmission.TorrentFieldName,
+	transmission.TorrentFieldIsPrivate,
+	transmission.TorrentFieldDownloadedTotal,
+	transmission.TorrentFieldSeedingFor,
+	transmission.TorrentFieldUploadRatio,
+	transmission.TorrentFieldTrackerStats,
+}
+
+// Check returns the shortfall of t against the first requirement that applies
+// to it, or nil if t is not at risk. Only private torrents that downloaded
+// some data are checked.
+func Check(t *transmission.Torrent, reqs []Requirement) *Shortfall {
+	if !t.IsPrivate || t.DownloadedTotal == 0 {
+		return nil
+	}
+	for i := range reqs {
+		r := &reqs[i]
+		if !r.matches(t) {
+			continue
+		}
+		if r.Met(t) {
+			return nil
+		}
+		return &Shortfall{
+			ID:          t.ID,
+			Hash:        t.Hash,
+			Name:        t.Name,
+			Requirement: *r,
+			SeedingFor:  t.SeedingFor,
+			UploadRatio: t.UploadRatio,
+		}
+	}
+	return nil
+}
+
+// Shortfall describes a torrent that hasn't met the requirement of its
+// tracker yet.
+type Shortfall struct {
+	// ID of the torrent
+	ID transmission.ID
+	// Hash of the torrent
+	Hash transmission.Hash
+	// Name of the torrent
+	Name string
+	// Requirement the torrent doesn't meet
+	Requirement Requirement
+	// Current seeding time
+	SeedingFor time.Duration
+	// Current upload ratio
+	UploadRatio float64
+}
+
+// SeedingLeft returns the remaining seeding time.
+func (s *Shortfall) SeedingLeft() time.Duration {
+	return max(s.Requirement.MinSeedingFor-s.SeedingFor, 0)
+}
+
+// RatioLeft returns the upload ratio that remains to be reached.
+func (s *Shortfall) RatioLeft() float64 {
+	return max(s.Requirement.MinRatio-s.UploadRatio, 0)
+}
+
+// String returns a human readable description of the shortfall.
+func (s *Shortfall) String() string {
+	var missing []string
+	if left := s.SeedingLeft(); left > 0 {
+		missing = append(missing, fmt.Sprintf("%s more seeding", left))
+	}
+	if left := s.RatioLeft(); left > 0 {
+		missing = append(missing, fmt.Sprintf("%.2f more ratio", left))
+	}
+	sep := " and "
+	if s.Requirement.Either {
+		sep = " or "
+	}
+	tracker := s.Requirement.Tracker
+	if tracker == "" {
+		tracker = "private tracker"
+	}
+	return fmt.Sprintf("torrent %d (%s) needs %s on %s", s.ID, s.Name, strings.Join(missing, sep), tracker)
+}
+
+// Action is an action guarded by Guard.
+type Action int
+
+const (
+	// ActionStop stops the torrent
+	ActionStop Action = iota
+	// ActionRemove removes the torrent, keeping its data
+	ActionRemove
+	// ActionRemoveData removes the torrent together with its data
+	ActionRemoveData
+)
+
+// String implements fmt.Stringer.
+func (a Action) String() string {
+	switch a {
+	case ActionStop:
+		return "stop"
+	case ActionRemove:
+		return "remove"
+	case ActionRemoveData:
+		return "remove with data"
+	default:
+		return fmt.Sprintf("Action(%d)", a)
+	}
+}
+
+// ShortfallError is returned by Guard for every torrent the action on which
+// was refused or deferred.
+type ShortfallError struct {
+	Shortfall
+	// Refused action
+	Action Action
+	// Indicates whether the action was deferred until the requirement is met
+	Deferred bool
+}
+
+// Error implements error.
+func (e *ShortfallError) Error() string {
+	verb := "refused"
+	if e.Deferred {
+		verb = "deferred"
+	}
+	return fmt.Sprintf("hnr: %s %s: %s", e.Action, verb, e.Shortfall.String())
+}
@@ -0,0 +1,142 @@
+package hnr
+
+import (
+	"errors"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pborzenkov/go-transmission/transmission"
+)
+
+func trackerStats(announce string) []transmission.TrackerStat {
+	u, err := url.Parse(announce)
+	if err != nil {
+		panic(err)
+	}
+	return []transmission.TrackerStat{{AnnounceURL: u}}
+}
+
+var testRequirements = []Requirement{
+	{Tracker: "strict.org", MinSeedingFor: 72 * time.Hour, MinRatio: 1},
+	{Tracker: "lenient.org", MinSeedingFor: 72 * time.Hour, MinRatio: 1, Either: true},
+	{MinSeedingFor: 24 * time.Hour},
+}
+
+func TestCheck(t *testing.T) {
+	var tests = []struct {
+		name    string
+		torrent transmission.Torrent
+		want    *Shortfall
+	}{
+		{
+			name:    "public",
+			torrent: transmission.Torrent{DownloadedTotal: 1},
+		},
+		{
+			name:    "nothing downloaded",
+			torrent: transmission.Torrent{IsPrivate: true},
+		},
+		{
+			name: "strict met",
+			torrent: transmission.Torrent{
+				IsPrivate:       true,
+				DownloadedTotal: 1,
+				SeedingFor:      80 * time.Hour,
+				UploadRatio:     1,
+				TrackerStats:    trackerStats("https://t.strict.org/announce"),
+			},
+		},
+		{
+			name: "strict short",
+			torrent: transmission.Torrent{
+				ID:              1,
+				Name:            "a",
+				IsPrivate:       true,
+				DownloadedTotal: 1,
+				SeedingFor:      80 * time.Hour,
+				UploadRatio:     0.5,
+				TrackerStats:    trackerStats("https://t.strict.org/announce"),
+			},
+			want: &Shortfall{
+				ID:          1,
+				Name:        "a",
+				Requirement: testRequirements[0],
+				SeedingFor:  80 * time.Hour,
+				UploadRatio: 0.5,
+			},
+		},
+		{
+			name: "lenient met",
+			torrent: transmission.Torrent{
+				IsPrivate:       true,
+				DownloadedTotal: 1,
+				UploadRatio:     1.5,
+				TrackerStats:    trackerStats("https://lenient.org/announce"),
+			},
+		},
+		{
+			name: "default short",
+			torrent: transmission.Torrent{
+				ID:              2,
+				IsPrivate:       true,
+				DownloadedTotal: 1,
+				UploadRatio:     10,
+				SeedingFor:      time.Hour,
+				TrackerStats:    trackerStats("https://other.org/announce"),
+			},
+			want: &Shortfall{
+				ID:          2,
+				Requirement: testRequirements[2],
+				SeedingFor:  time.Hour,
+				UploadRatio: 10,
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			got := Check(&tc.torrent, testRequirements)
+			if !cmp.Equal(tc.want, got) {
+				t.Errorf("unexpected shortfall, diff = \n%s", cmp.Diff(tc.want, got))
+			}
+		})
+	}
+}
+
+func TestShortfall_String(t *testing.T) {
+	s := &Shortfall{
+		ID:          1,
+		Name:        "a",
+		Requirement: testRequirements[1],
+		SeedingFor:  70 * time.Hour,
+		UploadRatio: 0.25,
+	}
+	if want, got := "torrent 1 (a) needs 2h0m0s more seeding or 0.75 more ratio on lenient.org", s.String(); want != got {
+		t.Errorf("unexpected string, want = %q, got = %q", want, got)
+	}
+
+	s.Requirement, s.SeedingFor = testRequirements[2], 20*time.Hour
+	if want, got := "torrent 1 (a) needs 4h0m0s more seeding on private tracker", s.String(); want != got {
+		t.Errorf("unexpected string, want = %q, got = %q", want, got)
+	}
+	if want, got := 0.0, s.RatioLeft(); want != got {
+		t.Errorf("unexpected ratio left, want = %v, got = %v", want, got)
+	}
+}
+
+func TestShortfallError(t *testing.T) {
+	s := Shortfall{ID: 1, Name: "a", Requirement: testRequirements[2], SeedingFor: 20 * time.Hour}
+	err := errors.Join(errors.New("other"), &ShortfallError{Shortfall: s, Action: ActionRemoveData, Deferred: true})
+
+	var se *ShortfallError
+	if !errors.As(err, &se) {
+		t.Fatalf("expected *ShortfallError in %v", err)
+	}
+	want := "hnr: remove with data deferred: torrent 1 (a) needs 4h0m0s more seeding on private tracker"
+	if got := se.Error(); want != got {
+		t.Errorf("unexpected error, want = %q, got = %q", want, got)
+	}
+}
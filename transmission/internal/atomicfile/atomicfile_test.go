@@ -0,0 +1,44 @@
+package atomicfile
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWrite(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "state.json")
+
+	for _, data := range []string{"first", "second"} {
+		if err := Write(path, []byte(data), 0o644); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		got, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if string(got) != data {
+			t.Errorf("unexpected contents, want = %q, got = %q", data, got)
+		}
+		fi, err := os.Stat(path)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if want, got := os.FileMode(0o644), fi.Mode().Perm(); want != got {
+			t.Errorf("unexpected mode, want = %v, got = %v", want, got)
+		}
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("expected temporary files to be removed, got %d entries", len(entries))
+	}
+
+	if err := Write(filepath.Join(dir, "missing", "state.json"), nil, 0o644); err == nil {
+		t.Errorf("expected write to a missing directory to fail")
+	}
+}
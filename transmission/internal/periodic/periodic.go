@@ -0,0 +1,27 @@
+// Package periodic runs the background loops of the helper packages.
+package periodic
+
+import (
+	"context"
+	"time"
+)
+
+// Run calls fn right away and then every interval until ctx is done, and
+// returns ctx.Err(). Errors of fn are passed to onError, if it is set, unless
+// they are caused by ctx being done.
+func Run(ctx context.Context, interval time.Duration, fn func(context.Context) error, onError func(error)) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := fn(ctx); err != nil && onError != nil && ctx.Err() == nil {
+			onError(err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
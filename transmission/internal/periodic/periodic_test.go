@@ -0,0 +1,34 @@
+package periodic
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestRun(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var calls int
+	var errs []string
+	fn := func(ctx context.Context) error {
+		calls++
+		if calls == 3 {
+			cancel()
+			return ctx.Err()
+		}
+		return errors.New("failed")
+	}
+	onError := func(err error) { errs = append(errs, err.Error()) }
+
+	if err := Run(ctx, time.Millisecond, fn, onError); !errors.Is(err, context.Canceled) {
+		t.Errorf("unexpected error, want = %v, got = %v", context.Canceled, err)
+	}
+	if want := []string{"failed", "failed"}; !cmp.Equal(want, errs) {
+		t.Errorf("unexpected errors, diff = \n%s", cmp.Diff(want, errs))
+	}
+}
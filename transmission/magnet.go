@@ -0,0 +1,208 @@
+package transmission
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	magnetPrefix = "magnet:?"
+	btihPrefix   = "urn:btih:"
+	btmhPrefix   = "urn:btmh:"
+	// multihash prefix of a 32 byte SHA-256 digest
+	sha256Multihash = "1220"
+	// maximum number of file indices a select-only list may expand to
+	maxSelectOnly = 1 << 16
+)
+
+// Magnet is a parsed magnet link.
+type Magnet struct {
+	// v1 info hash (40 hex characters)
+	HashV1 Hash
+	// v2 info hash (64 hex characters)
+	HashV2 Hash
+	// Display name
+	Name string
+	// Tracker announce URLs
+	Trackers []*url.URL
+	// Web seeds
+	WebSeeds []string
+	// Indices of files to download (BEP 53)
+	SelectOnly []int
+}
+
+// ParseMagnet parses magnet link s. Parameters may be separated by either '&'
+// or ';'. Info hashes are accepted in hex and base32 formats and are
+// normalized to lower case hex.
+func ParseMagnet(s string) (*Magnet, error) {
+	if !strings.HasPrefix(strings.ToLower(s), magnetPrefix) {
+		return nil, errors.New("transmission: not a magnet link")
+	}
+	// url.ParseQuery rejects ';', which some clients still use as a
+	// separator. A literal ';' in a value has to be escaped anyway.
+	values, err := url.ParseQuery(strings.ReplaceAll(s[len(magnetPrefix):], ";", "&"))
+	if err != nil {
+		return nil, fmt.Errorf("transmission: invalid magnet link: %w", err)
+	}
+
+	m := new(Magnet)
+	for _, xt := range values["xt"] {
+		switch {
+		case strings.HasPrefix(strings.ToLower(xt), btihPrefix):
+			h, err := parseBTIH(xt[len(btihPrefix):])
+			if err != nil {
+				return nil, err
+			}
+			m.HashV1 = h
+		case strings.HasPrefix(strings.ToLower(xt), btmhPrefix):
+			mh := strings.ToLower(xt[len(btmhPrefix):])
+			if !strings.HasPrefix(mh, sha256Multihash) || len(mh) != len(sha256Multihash)+hashV2Len || !isHex(mh) {
+				return nil, fmt.Errorf("transmission: unsupported btmh %q", xt)
+			}
+			m.HashV2 = Hash(mh[len(sha256Multihash):])
+		}
+	}
+	if m.HashV1 == "" && m.HashV2 == "" {
+		return nil, errors.New("transmission: magnet link has no info hash")
+	}
+
+	m.Name = values.Get("dn")
+	for _, tr := range values["tr"] {
+		u, err := url.Parse(tr)
+		if err != nil {
+			return nil, fmt.Errorf("transmission: invalid tracker %q: %w", tr, err)
+		}
+		m.Trackers = append(m.Trackers, u)
+	}
+	m.WebSeeds = values["ws"]
+	if so := values.Get("so"); so != "" {
+		if m.SelectOnly, err = parseSelectOnly(so); err != nil {
+			return nil, err
+		}
+	}
+
+	return m, nil
+}
+
+func parseBTIH(s string) (Hash, error) {
+	h, err := ParseHash(s)
+	if err != nil || h.IsV2() {
+		return "", fmt.Errorf("transmission: invalid btih %q", s)
+	}
+	return h, nil
+}
+
+func parseSelectOnly(s string) ([]int, error) {
+	var indices []int
+	for _, r := range strings.Split(s, ",") {
+		from, to, isRange := strings.Cut(r, "-")
+		first, err := strconv.Atoi(from)
+		if err != nil || first < 0 {
+			return nil, fmt.Errorf("transmission: invalid select-only range %q", r)
+		}
+		last := first
+		if isRange {
+			if last, err = strconv.Atoi(to); err != nil || last < first {
+				return nil, fmt.Errorf("transmission: invalid select-only range %q", r)
+			}
+		}
+		if last-first >= maxSelectOnly-len(indices) {
+			return nil, fmt.Errorf("transmission: select-only list exceeds %d files", maxSelectOnly)
+		}
+		for i := first; i <= last; i++ {
+			indices = append(indices, i)
+		}
+	}
+	return indices, nil
+}
+
+func formatSelectOnly(indices []int) string {
+	sorted := append([]int(nil), indices...)
+	sort.Ints(sorted)
+
+	var ranges []string
+	for i := 0; i < len(sorted); {
+		j := i
+		for j+1 < len(sorted) && sorted[j+1] <= sorted[j]+1 {
+			j++
+		}
+		if sorted[i] == sorted[j] {
+			ranges = append(ranges, strconv.Itoa(sorted[i]))
+		} else {
+			ranges = append(ranges, strconv.Itoa(sorted[i])+"-"+strconv.Itoa(sorted[j]))
+		}
+		i = j + 1
+	}
+	return strings.Join(ranges, ",")
+}
+
+// Hash returns the hash that identifies the torrent: v1 info hash if present,
+// v2 info hash otherwise.
+func (m *Magnet) Hash() Hash {
+	if m.HashV1 != "" {
+		return m.HashV1
+	}
+	return m.HashV2
+}
+
+// String returns magnet link.
+func (m *Magnet) String() string {
+	var params []string
+	if m.HashV1 != "" {
+		params = append(params, "xt="+btihPrefix+string(m.HashV1))
+	}
+	if m.HashV2 != "" {
+		params = append(params, "xt="+btmhPrefix+sha256Multihash+string(m.HashV2))
+	}
+	if m.Name != "" {
+		params = append(params, "dn="+url.QueryEscape(m.Name))
+	}
+	for _, tr := range m.Trackers {
+		params = append(params, "tr="+url.QueryEscape(tr.String()))
+	}
+	for _, ws := range m.WebSeeds {
+		params = append(params, "ws="+url.QueryEscape(ws))
+	}
+	if len(m.SelectOnly) > 0 {
+		params = append(params, "so="+formatSelectOnly(m.SelectOnly))
+	}
+	return magnetPrefix + strings.Join(params, "&")
+}
+
+// MagnetFromTorrent returns magnet link of torrent t. The torrent must have
+// hash field populated. Name, trackers and web seeds are included if
+// present.
+func MagnetFromTorrent(t *Torrent) (*Magnet, error) {
+	m := &Magnet{Name: t.Name, WebSeeds: t.WebSeeds}
+	h, err := ParseHash(string(t.Hash))
+	if err != nil {
+		return nil, err
+	}
+	if h.IsV2() {
+		m.HashV2 = h
+	} else {
+		m.HashV1 = h
+	}
+	for _, tier := range t.TrackerList {
+		m.Trackers = append(m.Trackers, tier...)
+	}
+	return m, nil
+}
+
+// ExpectedHash returns hash of the torrent the request adds if it can be
+// determined without contacting Transmission, i.e. if URL is a magnet link.
+// AddTorrent fails if Transmission reports a torrent with another hash.
+func (req *AddTorrentReq) ExpectedHash() (Hash, bool) {
+	if req.URL == nil {
+		return "", false
+	}
+	m, err := ParseMagnet(*req.URL)
+	if err != nil {
+		return "", false
+	}
+	return m.Hash(), true
+}
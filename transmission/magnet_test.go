@@ -0,0 +1,146 @@
+package transmission
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseMagnet(t *testing.T) {
+	var tests = []struct {
+		name string
+		in   string
+		want *Magnet
+	}{
+		{
+			name: "hex",
+			in:   "magnet:?xt=urn:btih:C12FE1C06BBA254A9DC9F519B335AA7C1367A88A&dn=Some+Name&so=0,2,4-6",
+			want: &Magnet{
+				HashV1:     "c12fe1c06bba254a9dc9f519b335aa7c1367a88a",
+				Name:       "Some Name",
+				SelectOnly: []int{0, 2, 4, 5, 6},
+			},
+		},
+		{
+			name: "base32",
+			in:   "magnet:?xt=urn:btih:YEX6DQDLXISUVHOJ6UM3GNNKPQJWPKEK&ws=http%3A%2F%2Fseed%2F",
+			want: &Magnet{
+				HashV1:   "c12fe1c06bba254a9dc9f519b335aa7c1367a88a",
+				WebSeeds: []string{"http://seed/"},
+			},
+		},
+		{
+			name: "hybrid",
+			in: "magnet:?xt=urn:btih:631a31dd0a46257d5078c0dee4e66e26f73e42ac" +
+				"&xt=urn:btmh:1220d8dd32ac93357c368556af3ac1d95c9d76bd0dff6fa9833ecdac3d53134efabb" +
+				"&tr=udp%3A%2F%2Ftracker%3A80",
+			want: &Magnet{
+				HashV1:   "631a31dd0a46257d5078c0dee4e66e26f73e42ac",
+				HashV2:   "d8dd32ac93357c368556af3ac1d95c9d76bd0dff6fa9833ecdac3d53134efabb",
+				Trackers: []*url.URL{parseTestURL(t, "udp://tracker:80")},
+			},
+		},
+		{
+			name: "semicolons",
+			in:   "magnet:?xt=urn:btih:c12fe1c06bba254a9dc9f519b335aa7c1367a88a;dn=Some+Name;tr=udp%3A%2F%2Ftracker%3A80",
+			want: &Magnet{
+				HashV1:   "c12fe1c06bba254a9dc9f519b335aa7c1367a88a",
+				Name:     "Some Name",
+				Trackers: []*url.URL{parseTestURL(t, "udp://tracker:80")},
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := ParseMagnet(tc.in)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !cmp.Equal(tc.want, got) {
+				t.Errorf("unexpected magnet, diff = \n%s", cmp.Diff(tc.want, got))
+			}
+		})
+	}
+}
+
+func TestParseMagnet_errors(t *testing.T) {
+	for _, in := range []string{
+		"http://example.com/file.torrent",
+		"magnet:?dn=name",
+		"magnet:?xt=urn:btih:1234",
+		"magnet:?xt=urn:btmh:1114d8dd32ac93357c368556af3ac1d95c9d76bd",
+		"magnet:?xt=urn:btih:c12fe1c06bba254a9dc9f519b335aa7c1367a88a&so=3-1",
+		"magnet:?xt=urn:btih:c12fe1c06bba254a9dc9f519b335aa7c1367a88a&so=0-2147483647",
+		"magnet:?xt=urn:btih:c12fe1c06bba254a9dc9f519b335aa7c1367a88a&so=0-40000,50000-90000",
+	} {
+		if _, err := ParseMagnet(in); err == nil {
+			t.Errorf("expected %q to fail", in)
+		}
+	}
+}
+
+func TestMagnet_String(t *testing.T) {
+	m := &Magnet{
+		HashV1:     "c12fe1c06bba254a9dc9f519b335aa7c1367a88a",
+		HashV2:     "d8dd32ac93357c368556af3ac1d95c9d76bd0dff6fa9833ecdac3d53134efabb",
+		Name:       "a b&c",
+		Trackers:   []*url.URL{parseTestURL(t, "http://tracker/announce")},
+		WebSeeds:   []string{"http://seed/"},
+		SelectOnly: []int{5, 0, 1, 2, 7},
+	}
+	want := "magnet:?xt=urn:btih:c12fe1c06bba254a9dc9f519b335aa7c1367a88a" +
+		"&xt=urn:btmh:1220d8dd32ac93357c368556af3ac1d95c9d76bd0dff6fa9833ecdac3d53134efabb" +
+		"&dn=a+b%26c&tr=http%3A%2F%2Ftracker%2Fannounce&ws=http%3A%2F%2Fseed%2F&so=0-2,5,7"
+	if got := m.String(); want != got {
+		t.Errorf("unexpected magnet, want = %q, got = %q", want, got)
+	}
+
+	parsed, err := ParseMagnet(m.String())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want, got := []int{0, 1, 2, 5, 7}, parsed.SelectOnly; !cmp.Equal(want, got) {
+		t.Errorf("unexpected select-only, diff = \n%s", cmp.Diff(want, got))
+	}
+	if want, got := m.HashV1, parsed.Hash(); want != got {
+		t.Errorf("unexpected hash, want = %q, got = %q", want, got)
+	}
+}
+
+func TestMagnetFromTorrent(t *testing.T) {
+	m, err := MagnetFromTorrent(&Torrent{
+		Hash: "c12fe1c06bba254a9dc9f519b335aa7c1367a88a",
+		Name: "name",
+		TrackerList: TrackerList{
+			{parseTestURL(t, "http://a/announce")},
+			{parseTestURL(t, "http://b/announce")},
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := "magnet:?xt=urn:btih:c12fe1c06bba254a9dc9f519b335aa7c1367a88a&dn=name" +
+		"&tr=http%3A%2F%2Fa%2Fannounce&tr=http%3A%2F%2Fb%2Fannounce"
+	if got := m.String(); want != got {
+		t.Errorf("unexpected magnet, want = %q, got = %q", want, got)
+	}
+
+	if _, err := MagnetFromTorrent(&Torrent{Hash: "12345"}); err == nil {
+		t.Errorf("expected invalid hash to fail")
+	}
+}
+
+func TestAddTorrentReq_ExpectedHash(t *testing.T) {
+	req := &AddTorrentReq{URL: OptString("magnet:?xt=urn:btih:YEX6DQDLXISUVHOJ6UM3GNNKPQJWPKEK")}
+	if h, ok := req.ExpectedHash(); !ok || h != "c12fe1c06bba254a9dc9f519b335aa7c1367a88a" {
+		t.Errorf("unexpected expected hash, got = %q, %v", h, ok)
+	}
+
+	req = &AddTorrentReq{URL: OptString("http://example.com/file.torrent")}
+	if _, ok := req.ExpectedHash(); ok {
+		t.Errorf("expected hash of a torrent URL to be unknown")
+	}
+}
@@ -0,0 +1,219 @@
+package transmission
+
+import (
+	"context"
+	"sort"
+	"strings"
+)
+
+// MetaLabelPrefix is a reserved label prefix used to store key/value metadata
+// in torrent labels. Labels that start with this prefix are managed by
+// GetMeta, SetMeta and DeleteMeta, other labels are left untouched.
+const MetaLabelPrefix = "meta:"
+
+const hexDigits = "0123456789ABCDEF"
+
+// metaNeedsEscape reports whether c must be percent-encoded within a metadata
+// key or value. Transmission splits labels on commas and trims surrounding
+// whitespace, '=' separates key from value and '%' starts an escape sequence.
+func metaNeedsEscape(c byte) bool {
+	return c == '%' || c == '=' || c == ',' || c <= ' ' || c == 0x7f
+}
+
+func metaEscape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if metaNeedsEscape(c) {
+			b.WriteByte('%')
+			b.WriteByte(hexDigits[c>>4])
+			b.WriteByte(hexDigits[c&0xf])
+			continue
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
+
+func unhex(c byte) (byte, bool) {
+	switch {
+	case '0' <= c && c <= '9':
+		return c - '0', true
+	case 'a' <= c && c <= 'f':
+		return c - 'a' + 10, true
+	case 'A' <= c && c <= 'F':
+		return c - 'A' + 10, true
+	}
+	return 0, false
+}
+
+func metaUnescape(s string) (string, bool) {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c != '%' {
+			if metaNeedsEscape(c) {
+				return "", false
+			}
+			b.WriteByte(c)
+			continue
+		}
+		if i+2 >= len(s) {
+			return "", false
+		}
+		hi, ok1 := unhex(s[i+1])
+		lo, ok2 := unhex(s[i+2])
+		if !ok1 || !ok2 {
+			return "", false
+		}
+		b.WriteByte(hi<<4 | lo)
+		i += 2
+	}
+	return b.String(), true
+}
+
+// MetaLabel returns a label that stores key/value pair.
+func MetaLabel(key, value string) string {
+	return MetaLabelPrefix + metaEscape(key) + "=" + metaEscape(value)
+}
+
+// ParseMetaLabel parses label created with MetaLabel. It returns false if
+// label is a plain label or can't be parsed.
+func ParseMetaLabel(label string) (key, value string, ok bool) {
+	if !strings.HasPrefix(label, MetaLabelPrefix) {
+		return "", "", false
+	}
+	ek, ev, found := strings.Cut(label[len(MetaLabelPrefix):], "=")
+	if !found || ek == "" {
+		return "", "", false
+	}
+	if key, ok = metaUnescape(ek); !ok {
+		return "", "", false
+	}
+	if value, ok = metaUnescape(ev); !ok {
+		return "", "", false
+	}
+	return key, value, true
+}
+
+// MetaFromLabels extracts key/value metadata from labels. If the same key is
+// stored more than once, the last value wins.
+func MetaFromLabels(labels []string) map[string]string {
+	meta := make(map[string]string)
+	for _, l := range labels {
+		if k, v, ok := ParseMetaLabel(l); ok {
+			meta[k] = v
+		}
+	}
+	return meta
+}
+
+// GetMeta returns key/value metadata stored in the labels of the torrent. The
+// torrent must be requested with TorrentFieldLabels.
+func GetMeta(t *Torrent) map[string]string {
+	return MetaFromLabels(t.Labels)
+}
+
+// MatchMeta reports whether the torrent has metadata key set to value.
+func MatchMeta(t *Torrent, key, value string) bool {
+	v, ok := GetMeta(t)[key]
+	return ok && v == value
+}
+
+// LabelsWithMeta returns labels with metadata updated according to meta. Plain
+// labels keep their order, metadata labels are appended sorted by key. Keys
+// listed in remove are deleted. The result can be used for
+// AddTorrentReq.Labels or SetTorrentReq.Labels.
+func LabelsWithMeta(labels []string, meta map[string]string, remove ...string) []string {
+	merged := make(map[string]string)
+	result := make([]string, 0, len(labels)+len(meta))
+	for _, l := range labels {
+		if k, v, ok := ParseMetaLabel(l); ok {
+			merged[k] = v
+			continue
+		}
+		result = append(result, l)
+	}
+	for k, v := range meta {
+		merged[k] = v
+	}
+	for _, k := range remove {
+		delete(merged, k)
+	}
+
+	keys := make([]string, 0, len(merged))
+	for k := range merged {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		result = append(result, MetaLabel(k, merged[k]))
+	}
+
+	return result
+}
+
+func equalLabels(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func (c *Client) updateLabels(ctx context.Context, ids Identifier, update func([]string) []string) error {
+	torrents, err := c.GetTorrents(ctx, ids, TorrentFieldID, TorrentFieldLabels)
+	if err != nil {
+		return err
+	}
+	for _, t := range torrents {
+		labels := update(t.Labels)
+		if equalLabels(labels, t.Labels) {
+			continue
+		}
+		if err := c.SetTorrents(ctx, t.ID, &SetTorrentReq{Labels: labels}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SetMeta stores key/value metadata in the labels of torrents identified by
+// ids. Existing keys not present in meta and plain labels are preserved.
+func (c *Client) SetMeta(ctx context.Context, ids Identifier, meta map[string]string) error {
+	return c.updateLabels(ctx, ids, func(labels []string) []string {
+		return LabelsWithMeta(labels, meta)
+	})
+}
+
+// DeleteMeta removes metadata keys from the labels of torrents identified by
+// ids.
+func (c *Client) DeleteMeta(ctx context.Context, ids Identifier, keys ...string) error {
+	return c.updateLabels(ctx, ids, func(labels []string) []string {
+		return LabelsWithMeta(labels, nil, keys...)
+	})
+}
+
+// GetTorrentsWithMeta returns torrents that have metadata key set to value.
+// TorrentFieldLabels is always requested in addition to fields.
+func (c *Client) GetTorrentsWithMeta(ctx context.Context, key, value string, fields ...TorrentField) ([]*Torrent, error) { //nolint:lll
+	if len(fields) > 0 {
+		fields = append(fields[:len(fields):len(fields)], TorrentFieldLabels)
+	}
+	torrents, err := c.GetTorrents(ctx, All(), fields...)
+	if err != nil {
+		return nil, err
+	}
+
+	matched := make([]*Torrent, 0, len(torrents))
+	for _, t := range torrents {
+		if MatchMeta(t, key, value) {
+			matched = append(matched, t)
+		}
+	}
+	return matched, nil
+}
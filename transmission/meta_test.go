@@ -0,0 +1,122 @@
+package transmission
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestMetaLabel(t *testing.T) {
+	var tests = []struct {
+		name  string
+		key   string
+		value string
+		label string
+	}{
+		{name: "simple", key: "owner", value: "alice", label: "meta:owner=alice"},
+		{name: "empty_value", key: "flag", value: "", label: "meta:flag="},
+		{name: "escaped", key: "a=b", value: "x, y%", label: "meta:a%3Db=x%2C%20y%25"},
+		{name: "unicode", key: "владелец", value: "алиса", label: "meta:владелец=алиса"},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			if want, got := tc.label, MetaLabel(tc.key, tc.value); want != got {
+				t.Errorf("unexpected label, want = %q, got = %q", want, got)
+			}
+			key, value, ok := ParseMetaLabel(tc.label)
+			if !ok {
+				t.Fatalf("failed to parse label %q", tc.label)
+			}
+			if key != tc.key || value != tc.value {
+				t.Errorf("unexpected key/value, want = %q/%q, got = %q/%q", tc.key, tc.value, key, value)
+			}
+		})
+	}
+}
+
+func TestParseMetaLabel_plain(t *testing.T) {
+	labels := []string{"linux", "meta:", "meta:=value", "meta:novalue", "meta:a=%2", "meta:a=%zz", "meta:a=b=c"}
+	for _, label := range labels {
+		if _, _, ok := ParseMetaLabel(label); ok {
+			t.Errorf("expected %q to be a plain label", label)
+		}
+	}
+}
+
+func TestLabelsWithMeta(t *testing.T) {
+	labels := []string{"linux", "meta:source=rss", "iso", "meta:owner=bob"}
+
+	got := LabelsWithMeta(labels, map[string]string{"owner": "alice", "team": "qa"}, "source")
+	want := []string{"linux", "iso", "meta:owner=alice", "meta:team=qa"}
+	if !cmp.Equal(want, got) {
+		t.Errorf("unexpected labels, diff = \n%s", cmp.Diff(want, got))
+	}
+
+	meta := GetMeta(&Torrent{Labels: got})
+	if want := map[string]string{"owner": "alice", "team": "qa"}; !cmp.Equal(want, meta) {
+		t.Errorf("unexpected meta, diff = \n%s", cmp.Diff(want, meta))
+	}
+}
+
+func TestSetMeta(t *testing.T) {
+	client, handle, teardown := setup(t)
+	defer teardown()
+
+	var setCalls int
+	handle(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Method string `json:"method"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Fatalf("failed to decode request: %v", err)
+		}
+
+		switch req.Method {
+		case "torrent-get":
+			fmt.Fprintf(w, `{"result":"success","arguments":{"torrents":[
+				{"id":1,"labels":["linux","meta:owner=bob"]},
+				{"id":2,"labels":["meta:owner=alice"]}
+			]}}`)
+		case "torrent-set":
+			setCalls++
+			fmt.Fprintf(w, `{"result":"success"}`)
+		default:
+			t.Errorf("unexpected method %q", req.Method)
+		}
+	})
+
+	if err := client.SetMeta(context.Background(), All(), map[string]string{"owner": "alice"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want, got := 1, setCalls; want != got {
+		t.Errorf("unexpected number of torrent-set calls, want = %d, got = %d", want, got)
+	}
+}
+
+func TestGetTorrentsWithMeta(t *testing.T) {
+	client, handle, teardown := setup(t)
+	defer teardown()
+
+	handle(func(w http.ResponseWriter, r *http.Request) {
+		testBody(t, r, `{"method":"torrent-get","arguments":{"fields":["id","labels"]}}`)
+
+		fmt.Fprintf(w, `{"result":"success","arguments":{"torrents":[
+			{"id":1,"labels":["linux","meta:owner=bob"]},
+			{"id":2,"labels":["meta:owner=alice"]},
+			{"id":3,"labels":["owner=alice"]}
+		]}}`)
+	})
+
+	torrents, err := client.GetTorrentsWithMeta(context.Background(), "owner", "alice", TorrentFieldID)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(torrents) != 1 || torrents[0].ID != 2 {
+		t.Errorf("unexpected torrents: %+v", torrents)
+	}
+}
@@ -0,0 +1,163 @@
+package metainfo
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"slices"
+
+	"github.com/pborzenkov/go-transmission/transmission"
+)
+
+// Match returns indices of files whose path or base name matches any of the
+// patterns. Patterns use path.Match syntax. It returns an error if a pattern
+// is malformed or matches no files.
+func (m *MetaInfo) Match(patterns ...string) ([]int, error) {
+	matched := make([]bool, len(m.Info.Files))
+	for _, p := range patterns {
+		found := false
+		for i, f := range m.Info.Files {
+			okPath, err := path.Match(p, f.Path)
+			if err != nil {
+				return nil, fmt.Errorf("metainfo: invalid pattern %q: %w", p, err)
+			}
+			okBase, _ := path.Match(p, path.Base(f.Path))
+			if okPath || okBase {
+				matched[i] = true
+				found = true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("metainfo: no files match %q", p)
+		}
+	}
+
+	var indices []int
+	for i, ok := range matched {
+		if ok {
+			indices = append(indices, i)
+		}
+	}
+	return indices, nil
+}
+
+// AddTorrentBuilder builds transmission.AddTorrentReq for a parsed metainfo
+// selecting files by name rather than by index.
+type AddTorrentBuilder struct {
+	m   *MetaInfo
+	req transmission.AddTorrentReq
+
+	wanted   []int
+	unwanted []int
+	want     bool
+	err      error
+}
+
+// NewAddTorrentReq returns a builder of a request that adds torrent m. The
+// settings of base, if not nil, are used as a starting point.
+func NewAddTorrentReq(m *MetaInfo, base *transmission.AddTorrentReq) *AddTorrentBuilder {
+	b := &AddTorrentBuilder{m: m}
+	if base != nil {
+		b.req = *base
+		b.req.HighPriorityFiles = append([]int(nil), base.HighPriorityFiles...)
+		b.req.NormalPriorityFiles = append([]int(nil), base.NormalPriorityFiles...)
+		b.req.LowPriorityFiles = append([]int(nil), base.LowPriorityFiles...)
+		b.req.WantedFiles = append([]int(nil), base.WantedFiles...)
+		b.req.UnwatedFiles = append([]int(nil), base.UnwatedFiles...)
+	}
+	b.req.URL = nil
+	return b
+}
+
+func (b *AddTorrentBuilder) match(patterns []string) []int {
+	if b.err != nil {
+		return nil
+	}
+	indices, err := b.m.Match(patterns...)
+	if err != nil {
+		b.err = err
+	}
+	return indices
+}
+
+// Want marks files matching patterns as wanted. Once Want is called, files
+// that don't match any pattern passed to Want aren't downloaded.
+func (b *AddTorrentBuilder) Want(patterns ...string) *AddTorrentBuilder {
+	b.want = true
+	b.wanted = append(b.wanted, b.match(patterns)...)
+	return b
+}
+
+// Skip marks files matching patterns as unwanted.
+func (b *AddTorrentBuilder) Skip(patterns ...string) *AddTorrentBuilder {
+	b.unwanted = append(b.unwanted, b.match(patterns)...)
+	return b
+}
+
+// Priority sets priority of files matching patterns.
+func (b *AddTorrentBuilder) Priority(p transmission.Priority, patterns ...string) *AddTorrentBuilder {
+	indices := b.match(patterns)
+	switch p {
+	case transmission.PriorityHigh:
+		b.req.HighPriorityFiles = append(b.req.HighPriorityFiles, indices...)
+	case transmission.PriorityLow:
+		b.req.LowPriorityFiles = append(b.req.LowPriorityFiles, indices...)
+	default:
+		b.req.NormalPriorityFiles = append(b.req.NormalPriorityFiles, indices...)
+	}
+	return b
+}
+
+// Build returns the request. It returns the first error encountered while
+// matching files.
+func (b *AddTorrentBuilder) Build() (*transmission.AddTorrentReq, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+
+	// The request must not share slices with the builder, which may be
+	// changed and built again.
+	req := b.req
+	req.Meta = bytes.NewReader(b.m.Bytes())
+	req.Labels = slices.Clone(req.Labels)
+	req.WantedFiles = slices.Clone(req.WantedFiles)
+	req.UnwatedFiles = slices.Clone(req.UnwatedFiles)
+	req.HighPriorityFiles = slices.Clone(req.HighPriorityFiles)
+	req.NormalPriorityFiles = slices.Clone(req.NormalPriorityFiles)
+	req.LowPriorityFiles = slices.Clone(req.LowPriorityFiles)
+
+	state := make([]int, len(b.m.Info.Files))
+	const (
+		unset = iota
+		wanted
+		unwanted
+	)
+	if b.want {
+		for i := range state {
+			state[i] = unwanted
+		}
+	}
+	for _, i := range b.wanted {
+		state[i] = wanted
+	}
+	for _, i := range b.unwanted {
+		state[i] = unwanted
+	}
+	for i, s := range state {
+		switch s {
+		case wanted:
+			req.WantedFiles = append(req.WantedFiles, i)
+		case unwanted:
+			req.UnwatedFiles = append(req.UnwatedFiles, i)
+		}
+	}
+
+	return &req, nil
+}
+
+// AddTorrentReq returns a request that adds torrent m with the settings of
+// base, if not nil.
+func (m *MetaInfo) AddTorrentReq(base *transmission.AddTorrentReq) *transmission.AddTorrentReq {
+	req, _ := NewAddTorrentReq(m, base).Build()
+	return req
+}
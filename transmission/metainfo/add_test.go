@@ -0,0 +1,114 @@
+package metainfo
+
+import (
+	"io"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pborzenkov/go-transmission/transmission"
+)
+
+func testMultiFile(t *testing.T) *MetaInfo {
+	t.Helper()
+
+	content := []byte("0123456789abcdefghij")
+	m, err := ParseBytes(marshal(t, map[string]interface{}{
+		"info": map[string]interface{}{
+			"name":         "show",
+			"piece length": 8,
+			"pieces":       sha1Pieces(content, 8),
+			"files": []interface{}{
+				map[string]interface{}{"length": 8, "path": []string{"e01.mkv"}},
+				map[string]interface{}{"length": 8, "path": []string{"e02.mkv"}},
+				map[string]interface{}{"length": 2, "path": []string{"subs", "e01.srt"}},
+				map[string]interface{}{"length": 2, "path": []string{"info.nfo"}},
+			},
+		},
+	}))
+	if err != nil {
+		t.Fatalf("failed to parse metainfo: %v", err)
+	}
+	return m
+}
+
+func TestMatch(t *testing.T) {
+	m := testMultiFile(t)
+
+	got, err := m.Match("*.mkv", "show/subs/*")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := []int{0, 1, 2}; !cmp.Equal(want, got) {
+		t.Errorf("unexpected indices, diff = \n%s", cmp.Diff(want, got))
+	}
+
+	if _, err := m.Match("*.avi"); err == nil {
+		t.Errorf("expected pattern without matches to fail")
+	}
+	if _, err := m.Match("["); err == nil {
+		t.Errorf("expected malformed pattern to fail")
+	}
+}
+
+func TestAddTorrentBuilder(t *testing.T) {
+	m := testMultiFile(t)
+
+	base := &transmission.AddTorrentReq{
+		URL:               transmission.OptString("magnet:?xt=ignored"),
+		DownloadDirectory: transmission.OptString("/downloads"),
+		LowPriorityFiles:  []int{3},
+	}
+	req, err := NewAddTorrentReq(m, base).
+		Want("*.mkv", "*.srt").
+		Skip("e02.mkv").
+		Priority(transmission.PriorityHigh, "e01.*").
+		Build()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if req.URL != nil {
+		t.Errorf("expected URL to be reset")
+	}
+	if want, got := "/downloads", *req.DownloadDirectory; want != got {
+		t.Errorf("unexpected download directory, want = %q, got = %q", want, got)
+	}
+	if want, got := []int{0, 2}, req.WantedFiles; !cmp.Equal(want, got) {
+		t.Errorf("unexpected wanted files, diff = \n%s", cmp.Diff(want, got))
+	}
+	if want, got := []int{1, 3}, req.UnwatedFiles; !cmp.Equal(want, got) {
+		t.Errorf("unexpected unwanted files, diff = \n%s", cmp.Diff(want, got))
+	}
+	if want, got := []int{0, 2}, req.HighPriorityFiles; !cmp.Equal(want, got) {
+		t.Errorf("unexpected high priority files, diff = \n%s", cmp.Diff(want, got))
+	}
+	if want, got := []int{3}, req.LowPriorityFiles; !cmp.Equal(want, got) {
+		t.Errorf("unexpected low priority files, diff = \n%s", cmp.Diff(want, got))
+	}
+
+	meta, err := io.ReadAll(req.Meta)
+	if err != nil {
+		t.Fatalf("failed to read meta: %v", err)
+	}
+	if !cmp.Equal(m.Bytes(), meta) {
+		t.Errorf("unexpected meta contents")
+	}
+
+	// Requests built again don't share slices with the earlier ones, even
+	// if the copied base slices have spare capacity.
+	b := NewAddTorrentReq(m, &transmission.AddTorrentReq{UnwatedFiles: []int{3, 3, 3, 3, 3}}).Want("*.mkv", "*.srt")
+	first, err := b.Build()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := b.Skip("e02.mkv").Build(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want, got := []int{3, 3, 3, 3, 3, 3}, first.UnwatedFiles; !cmp.Equal(want, got) {
+		t.Errorf("unexpected unwanted files of the first request, diff = \n%s", cmp.Diff(want, got))
+	}
+
+	if _, err := NewAddTorrentReq(m, nil).Want("missing").Build(); err == nil {
+		t.Errorf("expected build to fail")
+	}
+}
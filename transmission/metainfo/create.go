@@ -0,0 +1,596 @@
+package metainfo
+
+import (
+	"context"
+	"crypto/sha1" //nolint:gosec
+	"crypto/sha256"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pborzenkov/go-transmission/transmission"
+	"github.com/pborzenkov/go-transmission/transmission/bencode"
+)
+
+const (
+	minPieceLength = BlockSize
+	maxPieceLength = 16 << 20
+
+	// targetPieceCount is the number of pieces automatic piece length
+	// selection aims for
+	targetPieceCount = 2000
+)
+
+// Builder creates metainfo for local files.
+type Builder struct {
+	// Path to the file or directory to create the torrent for
+	Path string
+	// Piece length. It must be a power of two of at least 16 KiB. If 0,
+	// piece length is selected automatically based on the total size
+	PieceLength int64
+	// Torrent version. If 0, V1 torrent is created
+	Version Version
+	// Mark the torrent as private
+	Private bool
+	// Tiers of tracker announce URLs
+	Trackers [][]string
+	// Web seeds (BEP 19)
+	WebSeeds []string
+	// Optional comment
+	Comment string
+	// Program that created the torrent
+	CreatedBy string
+	// Source tag used by private trackers
+	Source string
+	// Creation time. If zero, the current time is used
+	CreatedAt time.Time
+	// Number of pieces hashed in parallel. If 0, runtime.NumCPU()
+	// is used
+	Workers int
+	// Called periodically with the number of bytes hashed so far and total
+	// number of bytes to hash. Calls are serialized
+	Progress func(hashed, total int64)
+}
+
+// sourceFile is a file on disk that holds torrent data.
+type sourceFile struct {
+	// path on disk, empty for padding files that are read as zeros
+	path string
+	// path components within the torrent
+	components []string
+	size       int64
+	// v1 stream offset
+	offset int64
+	// size of padding that follows the file in hybrid torrents
+	padding int64
+}
+
+// AutoPieceLength returns a piece length suitable for a torrent of the given
+// total size.
+func AutoPieceLength(total int64) int64 {
+	length := int64(minPieceLength)
+	for length < maxPieceLength && total/length > targetPieceCount {
+		length *= 2
+	}
+	return length
+}
+
+// collect returns the torrent name and files to include. Files of a
+// directory are returned in the order of the v2 file tree.
+func (b *Builder) collect() (name string, files []sourceFile, single bool, err error) {
+	root, err := filepath.Abs(b.Path)
+	if err != nil {
+		return "", nil, false, err
+	}
+	name = filepath.Base(root)
+
+	fi, err := os.Stat(root)
+	if err != nil {
+		return "", nil, false, err
+	}
+	if fi.Mode().IsRegular() {
+		return name, []sourceFile{{path: root, components: []string{name}, size: fi.Size()}}, true, nil
+	}
+	if !fi.IsDir() {
+		return "", nil, false, errors.New("metainfo: " + b.Path + " is neither a regular file nor a directory")
+	}
+
+	err = filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		files = append(files, sourceFile{
+			path:       p,
+			components: strings.Split(filepath.ToSlash(rel), "/"),
+			size:       info.Size(),
+		})
+		return nil
+	})
+	if err != nil {
+		return "", nil, false, err
+	}
+	if len(files) == 0 {
+		return "", nil, false, errors.New("metainfo: no files found in " + b.Path)
+	}
+
+	return name, files, false, nil
+}
+
+// hashTask describes a range of data hashed as a single piece.
+type hashTask struct {
+	// index of the v1 piece or -1
+	piece int
+	// index of the file for v2 pieces or -1
+	file int
+	// index of the first v2 block within the file
+	block int
+	// v1 stream or file offset
+	offset int64
+	length int64
+	// number of zeros appended to v1 piece
+	padding int64
+}
+
+type hashResult struct {
+	pieces []byte
+	blocks [][][]byte
+}
+
+// maxOpenFiles is the number of files a fileReader keeps open.
+const maxOpenFiles = 16
+
+// fileReader reads torrent data from files on disk. It keeps the files it
+// read from open, so that pieces of a file don't reopen it. A fileReader must
+// not be used concurrently.
+type fileReader struct {
+	files []sourceFile
+	open  map[int]*os.File
+}
+
+func newFileReader(files []sourceFile) *fileReader {
+	return &fileReader{files: files, open: make(map[int]*os.File)}
+}
+
+// Close closes all the open files.
+func (r *fileReader) Close() {
+	for i, f := range r.open {
+		f.Close()
+		delete(r.open, i)
+	}
+}
+
+// readFileAt reads len(buf) bytes at offset off of file i into buf.
+func (r *fileReader) readFileAt(i int, off int64, buf []byte) error {
+	f, ok := r.open[i]
+	if !ok {
+		if len(r.open) >= maxOpenFiles {
+			r.Close()
+		}
+		var err error
+		if f, err = os.Open(r.files[i].path); err != nil {
+			return err
+		}
+		r.open[i] = f
+	}
+
+	_, err := f.ReadAt(buf, off)
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// readRange reads len(buf) bytes at v1 stream offset off into buf.
+func (r *fileReader) readRange(off int64, buf []byte) error {
+	for i := range r.files {
+		f := &r.files[i]
+		if off >= f.offset+f.size || len(buf) == 0 {
+			continue
+		}
+		if off < f.offset {
+			break
+		}
+		n := f.offset + f.size - off
+		if n > int64(len(buf)) {
+			n = int64(len(buf))
+		}
+		if f.path == "" {
+			clear(buf[:n])
+		} else if err := r.readFileAt(i, off-f.offset, buf[:n]); err != nil {
+			return err
+		}
+		buf = buf[n:]
+		off += n
+	}
+	if len(buf) > 0 {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+
+func (b *Builder) hash(ctx context.Context, version Version, files []sourceFile, tasks []hashTask,
+	pieceCount int) (*hashResult, error) {
+	res := &hashResult{pieces: make([]byte, pieceCount*sha1Size)}
+	// Block hashes are only needed for the v2 merkle trees.
+	if version&V2 != 0 {
+		res.blocks = make([][][]byte, len(files))
+		for i, f := range files {
+			res.blocks[i] = make([][]byte, (f.size+BlockSize-1)/BlockSize)
+		}
+	}
+
+	var total int64
+	for _, t := range tasks {
+		total += t.length
+	}
+
+	var (
+		mu     sync.Mutex
+		hashed int64
+	)
+	err := forEach(ctx, b.Workers, len(tasks), files, func(r *fileReader, i int) error {
+		if err := b.hashTask(r, tasks[i], res); err != nil {
+			return err
+		}
+		if b.Progress != nil {
+			mu.Lock()
+			hashed += tasks[i].length
+			b.Progress(hashed, total)
+			mu.Unlock()
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// forEach calls fn for every index in [0, n) using up to workers goroutines,
+// runtime.NumCPU() if workers is not positive. Every goroutine reads files
+// with its own fileReader. It stops at the first error.
+func forEach(ctx context.Context, workers, n int, files []sourceFile, fn func(r *fileReader, i int) error) error {
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg       sync.WaitGroup
+		once     sync.Once
+		firstErr error
+	)
+	indices := make(chan int)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r := newFileReader(files)
+			defer r.Close()
+			for i := range indices {
+				if err := fn(r, i); err != nil {
+					once.Do(func() {
+						firstErr = err
+						cancel()
+					})
+				}
+			}
+		}()
+	}
+
+loop:
+	for i := 0; i < n; i++ {
+		select {
+		case indices <- i:
+		case <-ctx.Done():
+			break loop
+		}
+	}
+	close(indices)
+	wg.Wait()
+
+	if firstErr != nil {
+		return firstErr
+	}
+	return ctx.Err()
+}
+
+func (b *Builder) hashTask(r *fileReader, t hashTask, res *hashResult) error {
+	buf := make([]byte, t.length+t.padding)
+	var err error
+	if t.file >= 0 {
+		err = r.readFileAt(t.file, t.offset, buf[:t.length])
+	} else {
+		err = r.readRange(t.offset, buf[:t.length])
+	}
+	if err != nil {
+		return err
+	}
+
+	if t.piece >= 0 {
+		sum := sha1.Sum(buf) //nolint:gosec
+		copy(res.pieces[t.piece*sha1Size:], sum[:])
+	}
+	if t.file >= 0 && res.blocks != nil {
+		data := buf[:t.length]
+		for i := t.block; len(data) > 0; i++ {
+			n := len(data)
+			if n > BlockSize {
+				n = BlockSize
+			}
+			sum := sha256.Sum256(data[:n])
+			res.blocks[t.file][i] = sum[:]
+			data = data[n:]
+		}
+	}
+	return nil
+}
+
+// merkleRoot computes the root of a merkle tree with the given leaves padded
+// with zero hashes to width leaves.
+func merkleRoot(leaves [][]byte, width int) []byte {
+	layer := make([][]byte, width)
+	zero := make([]byte, sha256Size)
+	for i := range layer {
+		if i < len(leaves) {
+			layer[i] = leaves[i]
+		} else {
+			layer[i] = zero
+		}
+	}
+	for len(layer) > 1 {
+		next := make([][]byte, len(layer)/2)
+		for i := range next {
+			h := sha256.New()
+			h.Write(layer[2*i])
+			h.Write(layer[2*i+1])
+			next[i] = h.Sum(nil)
+		}
+		layer = next
+	}
+	return layer[0]
+}
+
+func nextPowerOfTwo(n int) int {
+	p := 1
+	for p < n {
+		p *= 2
+	}
+	return p
+}
+
+// fileTree computes pieces root and piece layer of a file from its block
+// hashes.
+func fileTree(blocks [][]byte, pieceLength int64) (root, layer []byte) {
+	blocksPerPiece := int(pieceLength / BlockSize)
+	if len(blocks) <= blocksPerPiece {
+		return merkleRoot(blocks, nextPowerOfTwo(len(blocks))), nil
+	}
+
+	var pieces [][]byte
+	for i := 0; i < len(blocks); i += blocksPerPiece {
+		end := i + blocksPerPiece
+		if end > len(blocks) {
+			end = len(blocks)
+		}
+		h := merkleRoot(blocks[i:end], blocksPerPiece)
+		pieces = append(pieces, h)
+		layer = append(layer, h...)
+	}
+
+	// Padding pieces are roots of subtrees made of zero leaves.
+	padding := merkleRoot(nil, blocksPerPiece)
+	width := nextPowerOfTwo(len(pieces))
+	for len(pieces) < width {
+		pieces = append(pieces, padding)
+	}
+	return merkleRoot(pieces, width), layer
+}
+
+// Build hashes the content and returns the resulting metainfo. The encoded
+// torrent is available via MetaInfo.Bytes.
+func (b *Builder) Build(ctx context.Context) (*MetaInfo, error) {
+	version := b.Version
+	if version == 0 {
+		version = V1
+	}
+	if version&^Hybrid != 0 {
+		return nil, errors.New("metainfo: invalid version " + version.String())
+	}
+
+	name, files, single, err := b.collect()
+	if err != nil {
+		return nil, err
+	}
+	var total int64
+	for _, f := range files {
+		total += f.size
+	}
+
+	pieceLength := b.PieceLength
+	if pieceLength == 0 {
+		pieceLength = AutoPieceLength(total)
+	}
+	if pieceLength < minPieceLength || pieceLength&(pieceLength-1) != 0 {
+		return nil, errors.New("metainfo: piece length must be a power of two of at least 16 KiB")
+	}
+
+	var tasks []hashTask
+	pieceCount := 0
+	if version == V1 {
+		var offset int64
+		for i := range files {
+			files[i].offset = offset
+			offset += files[i].size
+		}
+		for off := int64(0); off < total; off += pieceLength {
+			tasks = append(tasks, hashTask{piece: pieceCount, file: -1, offset: off, length: min(pieceLength, total-off)})
+			pieceCount++
+		}
+	} else {
+		var offset int64
+		for i := range files {
+			files[i].offset = offset
+			offset += files[i].size
+			if version == Hybrid && i < len(files)-1 && files[i].size%pieceLength != 0 {
+				files[i].padding = pieceLength - files[i].size%pieceLength
+				offset += files[i].padding
+			}
+			for off := int64(0); off < files[i].size; off += pieceLength {
+				t := hashTask{piece: -1, file: i, block: int(off / BlockSize), offset: off,
+					length: min(pieceLength, files[i].size-off)}
+				if version == Hybrid {
+					t.piece = pieceCount
+					pieceCount++
+					if off+t.length == files[i].size {
+						t.padding = files[i].padding
+					}
+				}
+				tasks = append(tasks, t)
+			}
+		}
+	}
+
+	res, err := b.hash(ctx, version, files, tasks, pieceCount)
+	if err != nil {
+		return nil, err
+	}
+
+	info := map[string]interface{}{
+		"name":         name,
+		"piece length": pieceLength,
+	}
+	if b.Private {
+		info["private"] = 1
+	}
+	if b.Source != "" {
+		info["source"] = b.Source
+	}
+
+	if version&V1 != 0 {
+		info["pieces"] = res.pieces
+		if single {
+			info["length"] = files[0].size
+		} else {
+			var list []interface{}
+			for _, f := range files {
+				list = append(list, map[string]interface{}{"length": f.size, "path": f.components})
+				if f.padding > 0 {
+					list = append(list, map[string]interface{}{
+						"length": f.padding,
+						"path":   []string{".pad", strconv.FormatInt(f.padding, 10)},
+						"attr":   "p",
+					})
+				}
+			}
+			info["files"] = list
+		}
+	}
+
+	var layers map[string][]byte
+	if version&V2 != 0 {
+		info["meta version"] = 2
+		layers = make(map[string][]byte)
+		tree := make(map[string]interface{})
+		for i, f := range files {
+			leaf := map[string]interface{}{"length": f.size}
+			if f.size > 0 {
+				root, layer := fileTree(res.blocks[i], pieceLength)
+				leaf["pieces root"] = root
+				if layer != nil {
+					layers[string(root)] = layer
+				}
+			}
+
+			node := tree
+			for _, c := range f.components {
+				next, ok := node[c].(map[string]interface{})
+				if !ok {
+					next = make(map[string]interface{})
+					node[c] = next
+				}
+				node = next
+			}
+			node[""] = leaf
+		}
+		info["file tree"] = tree
+	}
+
+	meta := map[string]interface{}{"info": info}
+	if len(layers) > 0 {
+		meta["piece layers"] = layers
+	}
+	if tiers := b.trackers(); len(tiers) > 0 {
+		meta["announce"] = tiers[0][0]
+		if len(tiers) > 1 || len(tiers[0]) > 1 {
+			meta["announce-list"] = tiers
+		}
+	}
+	if len(b.WebSeeds) > 0 {
+		meta["url-list"] = b.WebSeeds
+	}
+	if b.Comment != "" {
+		meta["comment"] = b.Comment
+	}
+	if b.CreatedBy != "" {
+		meta["created by"] = b.CreatedBy
+	}
+	createdAt := b.CreatedAt
+	if createdAt.IsZero() {
+		createdAt = time.Now()
+	}
+	meta["creation date"] = createdAt.Unix()
+
+	data, err := bencode.Marshal(meta)
+	if err != nil {
+		return nil, err
+	}
+	return ParseBytes(data)
+}
+
+func (b *Builder) trackers() [][]string {
+	var tiers [][]string
+	for _, tier := range b.Trackers {
+		if len(tier) > 0 {
+			tiers = append(tiers, tier)
+		}
+	}
+	return tiers
+}
+
+// AddTorrentReq returns a request that adds torrent m created by the builder
+// with the settings of base, if not nil. Unless base sets it, download
+// directory points at the parent directory of the content, so Transmission
+// starts seeding without downloading the data again. The directory must be
+// accessible by Transmission under the same path.
+func (b *Builder) AddTorrentReq(m *MetaInfo, base *transmission.AddTorrentReq) (*transmission.AddTorrentReq, error) {
+	req := m.AddTorrentReq(base)
+	if req.DownloadDirectory == nil {
+		abs, err := filepath.Abs(b.Path)
+		if err != nil {
+			return nil, err
+		}
+		req.DownloadDirectory = transmission.OptString(filepath.Dir(abs))
+	}
+	return req, nil
+}
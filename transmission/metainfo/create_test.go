@@ -0,0 +1,269 @@
+package metainfo
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func writeFiles(t *testing.T, files map[string][]byte) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	for name, data := range files {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatalf("failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(p, data, 0o600); err != nil {
+			t.Fatalf("failed to write file: %v", err)
+		}
+	}
+	return dir
+}
+
+func content(n int, seed byte) []byte {
+	data := make([]byte, n)
+	for i := range data {
+		data[i] = seed + byte(i*7)
+	}
+	return data
+}
+
+// naiveRoot computes pieces root by building the whole merkle tree.
+func naiveRoot(data []byte) []byte {
+	var layer [][]byte
+	for len(data) > 0 {
+		n := min(len(data), BlockSize)
+		sum := sha256.Sum256(data[:n])
+		layer = append(layer, sum[:])
+		data = data[n:]
+	}
+	for len(layer)&(len(layer)-1) != 0 {
+		layer = append(layer, make([]byte, 32))
+	}
+	for len(layer) > 1 {
+		var next [][]byte
+		for i := 0; i < len(layer); i += 2 {
+			sum := sha256.Sum256(append(append([]byte(nil), layer[i]...), layer[i+1]...))
+			next = append(next, sum[:])
+		}
+		layer = next
+	}
+	return layer[0]
+}
+
+func TestBuilder_v1Single(t *testing.T) {
+	data := content(40000, 1)
+	dir := writeFiles(t, map[string][]byte{"file.bin": data})
+
+	b := &Builder{
+		Path:        filepath.Join(dir, "file.bin"),
+		PieceLength: 16384,
+		Private:     true,
+		Trackers:    [][]string{{"http://a/announce"}, {"http://b/announce"}},
+		WebSeeds:    []string{"http://seed/"},
+		Comment:     "comment",
+		CreatedBy:   "test",
+		Source:      "SRC",
+		CreatedAt:   time.Unix(1700000000, 0),
+		Workers:     2,
+	}
+	m, err := b.Build(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := Info{
+		Name:        "file.bin",
+		PieceLength: 16384,
+		Pieces:      sha1Pieces(data, 16384),
+		Private:     true,
+		Source:      "SRC",
+		Files:       []File{{Path: "file.bin", Size: 40000}},
+	}
+	if !cmp.Equal(want, m.Info) {
+		t.Errorf("unexpected info, diff = \n%s", cmp.Diff(want, m.Info))
+	}
+	if want, got := "http://a/announce\n\nhttp://b/announce", m.Trackers().String(); want != got {
+		t.Errorf("unexpected trackers, want = %q, got = %q", want, got)
+	}
+	if want, got := []string{"http://seed/"}, m.WebSeeds; !cmp.Equal(want, got) {
+		t.Errorf("unexpected web seeds, diff = \n%s", cmp.Diff(want, got))
+	}
+	if want, got := "comment", m.Comment; want != got {
+		t.Errorf("unexpected comment, want = %q, got = %q", want, got)
+	}
+	if want, got := "test", m.CreatedBy; want != got {
+		t.Errorf("unexpected created by, want = %q, got = %q", want, got)
+	}
+	if want, got := b.CreatedAt, m.CreatedAt; !want.Equal(got) {
+		t.Errorf("unexpected creation date, want = %v, got = %v", want, got)
+	}
+}
+
+func TestBuilder_v1Multi(t *testing.T) {
+	a, c := content(20000, 1), content(100, 3)
+	dir := writeFiles(t, map[string][]byte{"a.bin": a, "b/c.bin": c, "b/empty": nil})
+
+	m, err := (&Builder{Path: dir, PieceLength: 16384}).Build(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	name := filepath.Base(dir)
+	want := Info{
+		Name:        name,
+		PieceLength: 16384,
+		Pieces:      sha1Pieces(append(append([]byte(nil), a...), c...), 16384),
+		Files: []File{
+			{Path: name + "/a.bin", Size: 20000},
+			{Path: name + "/b/c.bin", Size: 100, Offset: 20000},
+			{Path: name + "/b/empty", Offset: 20100},
+		},
+	}
+	if !cmp.Equal(want, m.Info) {
+		t.Errorf("unexpected info, diff = \n%s", cmp.Diff(want, m.Info))
+	}
+}
+
+func TestBuilder_v2(t *testing.T) {
+	for _, version := range []Version{V2, Hybrid} {
+		version := version
+		t.Run(version.String(), func(t *testing.T) {
+			a, c := content(5*BlockSize+100, 1), content(100, 3)
+			dir := writeFiles(t, map[string][]byte{"a.bin": a, "c.bin": c})
+
+			m, err := (&Builder{Path: dir, PieceLength: 2 * BlockSize, Version: version}).Build(context.Background())
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if want, got := version, m.Version(); want != got {
+				t.Errorf("unexpected version, want = %v, got = %v", want, got)
+			}
+
+			var files []File
+			for _, f := range m.Info.Files {
+				if !f.Padding {
+					files = append(files, f)
+				}
+			}
+			if want, got := 2, len(files); want != got {
+				t.Fatalf("unexpected number of files, want = %d, got = %d", want, got)
+			}
+			if want, got := naiveRoot(a), files[0].PiecesRoot; !bytes.Equal(want, got) {
+				t.Errorf("unexpected pieces root of a.bin, want = %x, got = %x", want, got)
+			}
+			if want, got := naiveRoot(c), files[1].PiecesRoot; !bytes.Equal(want, got) {
+				t.Errorf("unexpected pieces root of c.bin, want = %x, got = %x", want, got)
+			}
+			if want, got := 1, len(m.PieceLayers); want != got {
+				t.Fatalf("unexpected number of piece layers, want = %d, got = %d", want, got)
+			}
+			if want, got := 3*32, len(m.PieceLayers[string(files[0].PiecesRoot)]); want != got {
+				t.Errorf("unexpected piece layer size, want = %d, got = %d", want, got)
+			}
+
+			if version == Hybrid {
+				padded := append(append([]byte(nil), a...), make([]byte, 2*BlockSize-len(a)%(2*BlockSize))...)
+				padded = append(padded, c...)
+				if want, got := sha1Pieces(padded, 2*BlockSize), m.Info.Pieces; !bytes.Equal(want, got) {
+					t.Errorf("unexpected v1 pieces")
+				}
+			}
+		})
+	}
+}
+
+func TestBuilder_errors(t *testing.T) {
+	dir := writeFiles(t, map[string][]byte{"a.bin": content(10, 0)})
+	empty := t.TempDir()
+
+	var tests = []struct {
+		name    string
+		builder Builder
+	}{
+		{name: "missing", builder: Builder{Path: filepath.Join(dir, "missing")}},
+		{name: "empty_dir", builder: Builder{Path: empty}},
+		{name: "piece_length", builder: Builder{Path: dir, PieceLength: 1000}},
+		{name: "version", builder: Builder{Path: dir, Version: 4}},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := tc.builder.Build(context.Background()); err == nil {
+				t.Errorf("expected build to fail")
+			}
+		})
+	}
+}
+
+func TestBuilder_canceled(t *testing.T) {
+	dir := writeFiles(t, map[string][]byte{"a.bin": content(10*BlockSize, 0)})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := (&Builder{Path: dir}).Build(ctx); err != context.Canceled {
+		t.Errorf("unexpected error, want = %v, got = %v", context.Canceled, err)
+	}
+}
+
+func TestBuilder_progress(t *testing.T) {
+	dir := writeFiles(t, map[string][]byte{"a.bin": content(5*BlockSize, 0)})
+
+	var last, total int64
+	b := &Builder{Path: dir, PieceLength: BlockSize, Progress: func(hashed, t int64) {
+		last, total = hashed, t
+	}}
+	if _, err := b.Build(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if last != 5*BlockSize || total != 5*BlockSize {
+		t.Errorf("unexpected progress, want = %d/%d, got = %d/%d", 5*BlockSize, 5*BlockSize, last, total)
+	}
+}
+
+func TestAutoPieceLength(t *testing.T) {
+	var tests = []struct {
+		total int64
+		want  int64
+	}{
+		{total: 0, want: 16 << 10},
+		{total: 100 << 20, want: 64 << 10},
+		{total: 4 << 30, want: 4 << 20},
+		{total: 1 << 50, want: 16 << 20},
+	}
+
+	for _, tc := range tests {
+		if got := AutoPieceLength(tc.total); got != tc.want {
+			t.Errorf("unexpected piece length for %d, want = %d, got = %d", tc.total, tc.want, got)
+		}
+	}
+}
+
+func TestBuilder_AddTorrentReq(t *testing.T) {
+	dir := writeFiles(t, map[string][]byte{"data/a.bin": content(10, 0)})
+
+	b := &Builder{Path: filepath.Join(dir, "data")}
+	m, err := b.Build(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	req, err := b.AddTorrentReq(m, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want, got := dir, *req.DownloadDirectory; want != got {
+		t.Errorf("unexpected download directory, want = %q, got = %q", want, got)
+	}
+	if req.Meta == nil {
+		t.Errorf("expected meta to be set")
+	}
+}
@@ -0,0 +1,492 @@
+// Package metainfo parses BitTorrent metainfo (.torrent) files. It supports v1
+// (BEP 3), v2 (BEP 52) and hybrid torrents.
+package metainfo
+
+import (
+	"crypto/sha1" //nolint:gosec
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pborzenkov/go-transmission/transmission"
+	"github.com/pborzenkov/go-transmission/transmission/bencode"
+)
+
+// Version is a version of the BitTorrent protocol a torrent is created for.
+type Version int
+
+const (
+	// V1 is a BEP 3 torrent
+	V1 Version = 1
+	// V2 is a BEP 52 torrent
+	V2 Version = 2
+	// Hybrid is a torrent that has both v1 and v2 metadata
+	Hybrid Version = 3
+)
+
+func (v Version) String() string {
+	switch v {
+	case V1:
+		return "v1"
+	case V2:
+		return "v2"
+	case Hybrid:
+		return "hybrid"
+	default:
+		return fmt.Sprintf("Version(%d)", int(v))
+	}
+}
+
+const (
+	sha1Size   = sha1.Size
+	sha256Size = sha256.Size
+
+	// BlockSize is the size of the leaf blocks of v2 merkle trees.
+	BlockSize = 16 << 10
+)
+
+// File describes a single file within a torrent.
+type File struct {
+	// Path of the file within the torrent using '/' as a separator. For
+	// multi-file torrents it starts with the torrent name, so it matches
+	// transmission.File.Name
+	Path string
+	// Size of the file
+	Size int64
+	// Offset of the file within the concatenated torrent data (v1)
+	Offset int64
+	// Indicates whether the file is a BEP 47 padding file
+	Padding bool
+	// Indicates whether the file is executable
+	Executable bool
+	// Root of the v2 merkle tree of the file
+	PiecesRoot []byte
+}
+
+// Info is a parsed info dictionary.
+type Info struct {
+	// Suggested name of the file or directory
+	Name string
+	// Number of bytes in each piece
+	PieceLength int64
+	// Concatenated SHA-1 hashes of v1 pieces
+	Pieces []byte
+	// Indicates whether the torrent is private
+	Private bool
+	// Source tag used by private trackers to make info hash unique
+	Source string
+	// Meta version, 2 for v2 and hybrid torrents
+	MetaVersion int
+	// Files of the torrent in Transmission order
+	Files []File
+}
+
+// MetaInfo is a parsed metainfo file.
+type MetaInfo struct {
+	// Primary tracker announce URL
+	Announce string
+	// Tiers of tracker announce URLs
+	AnnounceList [][]string
+	// Web seeds (BEP 19)
+	WebSeeds []string
+	// Optional comment
+	Comment string
+	// Program that created the torrent
+	CreatedBy string
+	// Creation time
+	CreatedAt time.Time
+	// Info dictionary
+	Info Info
+	// Exact bytes of the info dictionary
+	InfoBytes bencode.RawMessage
+	// Piece layers of v2 files keyed by the pieces root
+	PieceLayers map[string][]byte
+
+	raw     []byte
+	version Version
+}
+
+type fileJSON struct {
+	Length int64    `bencode:"length"`
+	Path   []string `bencode:"path"`
+	Attr   string   `bencode:"attr,omitempty"`
+}
+
+type infoJSON struct {
+	Name        string             `bencode:"name"`
+	PieceLength int64              `bencode:"piece length"`
+	Pieces      []byte             `bencode:"pieces"`
+	Private     int                `bencode:"private"`
+	Source      string             `bencode:"source"`
+	Length      int64              `bencode:"length"`
+	Attr        string             `bencode:"attr"`
+	Files       []fileJSON         `bencode:"files"`
+	MetaVersion int                `bencode:"meta version"`
+	FileTree    bencode.RawMessage `bencode:"file tree"`
+}
+
+type metaInfoJSON struct {
+	Announce     string             `bencode:"announce"`
+	AnnounceList [][]string         `bencode:"announce-list"`
+	URLList      bencode.RawMessage `bencode:"url-list"`
+	Comment      string             `bencode:"comment"`
+	CreatedBy    string             `bencode:"created by"`
+	CreationDate int64              `bencode:"creation date"`
+	Info         bencode.RawMessage `bencode:"info"`
+	PieceLayers  map[string][]byte  `bencode:"piece layers"`
+}
+
+// Parse reads and parses metainfo from r.
+func Parse(r io.Reader) (*MetaInfo, error) {
+	raw, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return ParseBytes(raw)
+}
+
+// Load reads and parses metainfo file at path.
+func Load(path string) (*MetaInfo, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseBytes(raw)
+}
+
+// ParseBytes parses metainfo contained in data. MetaInfo retains data.
+func ParseBytes(data []byte) (*MetaInfo, error) {
+	var mj metaInfoJSON
+	if err := bencode.Unmarshal(data, &mj); err != nil {
+		return nil, err
+	}
+	if len(mj.Info) == 0 {
+		return nil, errors.New("metainfo: missing info dictionary")
+	}
+
+	m := &MetaInfo{
+		Announce:     mj.Announce,
+		AnnounceList: mj.AnnounceList,
+		Comment:      mj.Comment,
+		CreatedBy:    mj.CreatedBy,
+		InfoBytes:    mj.Info,
+		PieceLayers:  mj.PieceLayers,
+		raw:          data,
+	}
+	if mj.CreationDate > 0 {
+		m.CreatedAt = time.Unix(mj.CreationDate, 0)
+	}
+	if len(mj.URLList) > 0 {
+		var seed string
+		if err := bencode.Unmarshal(mj.URLList, &seed); err == nil {
+			if seed != "" {
+				m.WebSeeds = []string{seed}
+			}
+		} else if err := bencode.Unmarshal(mj.URLList, &m.WebSeeds); err != nil {
+			return nil, fmt.Errorf("metainfo: invalid url-list: %w", err)
+		}
+	}
+
+	var err error
+	if m.Info, m.version, err = parseInfo(mj.Info); err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+func parseInfo(data []byte) (Info, Version, error) {
+	var ij infoJSON
+	if err := bencode.Unmarshal(data, &ij); err != nil {
+		return Info{}, 0, err
+	}
+
+	info := Info{
+		Name:        ij.Name,
+		PieceLength: ij.PieceLength,
+		Pieces:      ij.Pieces,
+		Private:     ij.Private != 0,
+		Source:      ij.Source,
+		MetaVersion: ij.MetaVersion,
+	}
+	if !validPath([]string{info.Name}) {
+		return Info{}, 0, fmt.Errorf("metainfo: invalid name %q", info.Name)
+	}
+	if info.PieceLength <= 0 {
+		return Info{}, 0, errors.New("metainfo: invalid piece length")
+	}
+
+	hasV1 := len(ij.Pieces) > 0 || ij.Length > 0 || len(ij.Files) > 0
+	hasV2 := ij.MetaVersion == 2
+	if !hasV1 && !hasV2 {
+		return Info{}, 0, errors.New("metainfo: neither v1 nor v2 metadata found")
+	}
+
+	if hasV1 {
+		if len(ij.Pieces)%sha1Size != 0 {
+			return Info{}, 0, errors.New("metainfo: invalid pieces length")
+		}
+		if len(ij.Files) == 0 {
+			info.Files = []File{{Path: info.Name, Size: ij.Length, Executable: isExecutable(ij.Attr)}}
+		} else {
+			for _, f := range ij.Files {
+				if !validPath(f.Path) {
+					return Info{}, 0, fmt.Errorf("metainfo: invalid file path %q", f.Path)
+				}
+				info.Files = append(info.Files, File{
+					Path:       path.Join(append([]string{info.Name}, f.Path...)...),
+					Size:       f.Length,
+					Padding:    isPadding(f.Attr),
+					Executable: isExecutable(f.Attr),
+				})
+			}
+		}
+		var offset, total int64
+		for i := range info.Files {
+			if info.Files[i].Size < 0 {
+				return Info{}, 0, errors.New("metainfo: negative file length")
+			}
+			info.Files[i].Offset = offset
+			offset += info.Files[i].Size
+			total += info.Files[i].Size
+		}
+		if want := (total + info.PieceLength - 1) / info.PieceLength; int64(len(ij.Pieces)/sha1Size) != want {
+			return Info{}, 0, fmt.Errorf("metainfo: expected %d pieces, got %d", want, len(ij.Pieces)/sha1Size)
+		}
+	}
+
+	if hasV2 {
+		if len(ij.FileTree) == 0 {
+			return Info{}, 0, errors.New("metainfo: missing file tree")
+		}
+		if info.PieceLength < BlockSize || info.PieceLength&(info.PieceLength-1) != 0 {
+			return Info{}, 0, errors.New("metainfo: v2 piece length must be a power of two of at least 16 KiB")
+		}
+		files, err := parseFileTree(ij.FileTree, nil)
+		if err != nil {
+			return Info{}, 0, err
+		}
+		single := len(files) == 1 && len(files[0].path) == 1 && files[0].path[0] == info.Name
+		v2files := make([]File, 0, len(files))
+		for _, f := range files {
+			p := path.Join(f.path...)
+			if !single {
+				p = path.Join(info.Name, p)
+			}
+			v2files = append(v2files, File{
+				Path:       p,
+				Size:       f.Length,
+				Executable: isExecutable(f.Attr),
+				PiecesRoot: f.PiecesRoot,
+			})
+		}
+
+		if !hasV1 {
+			info.Files = v2files
+		} else if err := mergeHybridFiles(info.Files, v2files); err != nil {
+			return Info{}, 0, err
+		}
+	}
+
+	var version Version
+	if hasV1 {
+		version |= V1
+	}
+	if hasV2 {
+		version |= V2
+	}
+	return info, version, nil
+}
+
+// validPath reports whether path components are safe to join into a file
+// system path.
+func validPath(components []string) bool {
+	if len(components) == 0 {
+		return false
+	}
+	for _, c := range components {
+		if c == "" || c == "." || c == ".." || strings.ContainsAny(c, "/\x00") {
+			return false
+		}
+	}
+	return true
+}
+
+func isPadding(attr string) bool {
+	return strings.ContainsRune(attr, 'p')
+}
+
+func isExecutable(attr string) bool {
+	return strings.ContainsRune(attr, 'x')
+}
+
+// mergeHybridFiles verifies that v1 and v2 files of a hybrid torrent describe
+// the same content and copies v2 pieces roots to v1 files.
+func mergeHybridFiles(v1, v2 []File) error {
+	i := 0
+	for _, f := range v1 {
+		if f.Padding {
+			continue
+		}
+		if i >= len(v2) || v2[i].Path != f.Path || v2[i].Size != f.Size {
+			return errors.New("metainfo: v1 and v2 file lists don't match")
+		}
+		i++
+	}
+	if i != len(v2) {
+		return errors.New("metainfo: v1 and v2 file lists don't match")
+	}
+
+	i = 0
+	for j := range v1 {
+		if v1[j].Padding {
+			continue
+		}
+		v1[j].PiecesRoot = v2[i].PiecesRoot
+		i++
+	}
+	return nil
+}
+
+type treeFile struct {
+	path       []string
+	Length     int64  `bencode:"length"`
+	PiecesRoot []byte `bencode:"pieces root"`
+	Attr       string `bencode:"attr"`
+}
+
+func parseFileTree(data []byte, prefix []string) ([]treeFile, error) {
+	var node map[string]bencode.RawMessage
+	if err := bencode.Unmarshal(data, &node); err != nil {
+		return nil, err
+	}
+
+	if leaf, ok := node[""]; ok {
+		if len(node) != 1 || len(prefix) == 0 {
+			return nil, errors.New("metainfo: invalid file tree")
+		}
+		var f treeFile
+		if err := bencode.Unmarshal(leaf, &f); err != nil {
+			return nil, err
+		}
+		if f.Length > 0 && len(f.PiecesRoot) != sha256Size {
+			return nil, errors.New("metainfo: invalid pieces root")
+		}
+		f.path = prefix
+		return []treeFile{f}, nil
+	}
+
+	names := make([]string, 0, len(node))
+	for name := range node {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var files []treeFile
+	for _, name := range names {
+		if !validPath([]string{name}) {
+			return nil, fmt.Errorf("metainfo: invalid file name %q", name)
+		}
+		p := make([]string, len(prefix)+1)
+		copy(p, prefix)
+		p[len(prefix)] = name
+		sub, err := parseFileTree(node[name], p)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, sub...)
+	}
+	return files, nil
+}
+
+// Bytes returns raw metainfo file contents.
+func (m *MetaInfo) Bytes() []byte {
+	return m.raw
+}
+
+// Version returns torrent version.
+func (m *MetaInfo) Version() Version {
+	return m.version
+}
+
+// HashV1 returns v1 info hash (SHA-1 of the info dictionary). It returns false
+// for v2-only torrents.
+func (m *MetaInfo) HashV1() (transmission.Hash, bool) {
+	if m.Version()&V1 == 0 {
+		return "", false
+	}
+	sum := sha1.Sum(m.InfoBytes) //nolint:gosec
+	return transmission.Hash(hex.EncodeToString(sum[:])), true
+}
+
+// HashV2 returns v2 info hash (SHA-256 of the info dictionary). It returns false
+// for v1-only torrents.
+func (m *MetaInfo) HashV2() (transmission.Hash, bool) {
+	if m.Version()&V2 == 0 {
+		return "", false
+	}
+	sum := sha256.Sum256(m.InfoBytes)
+	return transmission.Hash(hex.EncodeToString(sum[:])), true
+}
+
+// Hash returns the hash Transmission uses to identify the torrent: v1 info hash
+// for v1 and hybrid torrents, v2 info hash for v2-only torrents.
+func (m *MetaInfo) Hash() transmission.Hash {
+	if h, ok := m.HashV1(); ok {
+		return h
+	}
+	h, _ := m.HashV2()
+	return h
+}
+
+// TotalSize returns total size of all files including padding.
+func (m *MetaInfo) TotalSize() int64 {
+	var total int64
+	for _, f := range m.Info.Files {
+		total += f.Size
+	}
+	return total
+}
+
+// PieceCount returns the number of pieces.
+func (m *MetaInfo) PieceCount() int64 {
+	if m.Version()&V1 != 0 {
+		return int64(len(m.Info.Pieces) / sha1Size)
+	}
+	var count int64
+	for _, f := range m.Info.Files {
+		count += (f.Size + m.Info.PieceLength - 1) / m.Info.PieceLength
+	}
+	return count
+}
+
+// Trackers returns tracker tiers. If announce-list is present, announce is
+// ignored as required by BEP 12. Invalid URLs are skipped.
+func (m *MetaInfo) Trackers() transmission.TrackerList {
+	tiers := m.AnnounceList
+	if len(tiers) == 0 && m.Announce != "" {
+		tiers = [][]string{{m.Announce}}
+	}
+
+	list := transmission.TrackerList{}
+	for _, tier := range tiers {
+		var urls []*url.URL
+		for _, s := range tier {
+			if u, err := url.Parse(s); err == nil && u.Scheme != "" {
+				urls = append(urls, u)
+			}
+		}
+		if len(urls) > 0 {
+			list = append(list, urls)
+		}
+	}
+	return list
+}
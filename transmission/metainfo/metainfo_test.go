@@ -0,0 +1,260 @@
+package metainfo
+
+import (
+	"bytes"
+	"crypto/sha1" //nolint:gosec
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pborzenkov/go-transmission/transmission"
+	"github.com/pborzenkov/go-transmission/transmission/bencode"
+)
+
+func marshal(t *testing.T, v interface{}) []byte {
+	t.Helper()
+
+	data, err := bencode.Marshal(v)
+	if err != nil {
+		t.Fatalf("failed to marshal: %v", err)
+	}
+	return data
+}
+
+func sha1Pieces(data []byte, pieceLength int) []byte {
+	var pieces []byte
+	for len(data) > 0 {
+		n := pieceLength
+		if n > len(data) {
+			n = len(data)
+		}
+		sum := sha1.Sum(data[:n]) //nolint:gosec
+		pieces = append(pieces, sum[:]...)
+		data = data[n:]
+	}
+	return pieces
+}
+
+func root(b byte) []byte {
+	return bytes.Repeat([]byte{b}, 32)
+}
+
+func TestParse_v1Single(t *testing.T) {
+	data := "d8:announce23:http://tracker/announce7:comment4:test10:created by4:test13:creation datei1700000000e" +
+		"4:infod6:lengthi5e4:name8:test.txt12:piece lengthi16384e6:pieces20:" +
+		string(sha1Pieces([]byte("hello"), 16384)) + "ee"
+
+	m, err := Parse(strings.NewReader(data))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if want, got := transmission.Hash("f08663d568c05858f594abb0251bf3b7b27baeb4"), m.Hash(); want != got {
+		t.Errorf("unexpected hash, want = %q, got = %q", want, got)
+	}
+	if want, got := V1, m.Version(); want != got {
+		t.Errorf("unexpected version, want = %v, got = %v", want, got)
+	}
+	if _, ok := m.HashV2(); ok {
+		t.Errorf("expected v1 torrent to have no v2 hash")
+	}
+	if want, got := time.Unix(1700000000, 0), m.CreatedAt; !want.Equal(got) {
+		t.Errorf("unexpected creation date, want = %v, got = %v", want, got)
+	}
+	if want, got := []File{{Path: "test.txt", Size: 5}}, m.Info.Files; !cmp.Equal(want, got) {
+		t.Errorf("unexpected files, diff = \n%s", cmp.Diff(want, got))
+	}
+	if want, got := int64(5), m.TotalSize(); want != got {
+		t.Errorf("unexpected total size, want = %d, got = %d", want, got)
+	}
+	if want, got := int64(1), m.PieceCount(); want != got {
+		t.Errorf("unexpected piece count, want = %d, got = %d", want, got)
+	}
+	if want, got := "http://tracker/announce", m.Trackers().String(); want != got {
+		t.Errorf("unexpected trackers, want = %q, got = %q", want, got)
+	}
+	if !bytes.Equal([]byte(data), m.Bytes()) {
+		t.Errorf("expected raw bytes to be retained")
+	}
+}
+
+func TestParse_v1Multi(t *testing.T) {
+	content := []byte("aaaaabbbbbbbbbbc")
+	data := marshal(t, map[string]interface{}{
+		"announce":      "http://ignored/announce",
+		"announce-list": [][]string{{"http://a/announce", "http://b/announce"}, {"udp://c:80"}},
+		"url-list":      "http://seed/",
+		"info": map[string]interface{}{
+			"name":         "dir",
+			"piece length": 8,
+			"pieces":       sha1Pieces(content, 8),
+			"private":      1,
+			"source":       "SRC",
+			"files": []interface{}{
+				map[string]interface{}{"length": 5, "path": []string{"a.txt"}},
+				map[string]interface{}{"length": 10, "path": []string{"sub", "b.mkv"}, "attr": "x"},
+				map[string]interface{}{"length": 1, "path": []string{"c.nfo"}},
+			},
+		},
+	})
+
+	m, err := ParseBytes(data)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := Info{
+		Name:        "dir",
+		PieceLength: 8,
+		Pieces:      sha1Pieces(content, 8),
+		Private:     true,
+		Source:      "SRC",
+		Files: []File{
+			{Path: "dir/a.txt", Size: 5},
+			{Path: "dir/sub/b.mkv", Size: 10, Offset: 5, Executable: true},
+			{Path: "dir/c.nfo", Size: 1, Offset: 15},
+		},
+	}
+	if !cmp.Equal(want, m.Info) {
+		t.Errorf("unexpected info, diff = \n%s", cmp.Diff(want, m.Info))
+	}
+	if want, got := "http://a/announce\nhttp://b/announce\n\nudp://c:80", m.Trackers().String(); want != got {
+		t.Errorf("unexpected trackers, want = %q, got = %q", want, got)
+	}
+	if want, got := []string{"http://seed/"}, m.WebSeeds; !cmp.Equal(want, got) {
+		t.Errorf("unexpected web seeds, diff = \n%s", cmp.Diff(want, got))
+	}
+}
+
+func v2FileTree() map[string]interface{} {
+	return map[string]interface{}{
+		"b.txt": map[string]interface{}{
+			"": map[string]interface{}{"length": 20000, "pieces root": root(2)},
+		},
+		"a": map[string]interface{}{
+			"x.bin": map[string]interface{}{
+				"": map[string]interface{}{"length": 100, "pieces root": root(1)},
+			},
+		},
+	}
+}
+
+func TestParse_v2(t *testing.T) {
+	data := marshal(t, map[string]interface{}{
+		"info": map[string]interface{}{
+			"name":         "dir",
+			"piece length": 16384,
+			"meta version": 2,
+			"file tree":    v2FileTree(),
+		},
+		"piece layers": map[string][]byte{string(root(2)): bytes.Repeat([]byte{9}, 64)},
+	})
+
+	m, err := ParseBytes(data)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want, got := V2, m.Version(); want != got {
+		t.Errorf("unexpected version, want = %v, got = %v", want, got)
+	}
+	if _, ok := m.HashV1(); ok {
+		t.Errorf("expected v2 torrent to have no v1 hash")
+	}
+	h2, _ := m.HashV2()
+	if want, got := h2, m.Hash(); want != got || len(got) != 64 {
+		t.Errorf("unexpected hash, want = %q, got = %q", want, got)
+	}
+	want := []File{
+		{Path: "dir/a/x.bin", Size: 100, PiecesRoot: root(1)},
+		{Path: "dir/b.txt", Size: 20000, PiecesRoot: root(2)},
+	}
+	if !cmp.Equal(want, m.Info.Files) {
+		t.Errorf("unexpected files, diff = \n%s", cmp.Diff(want, m.Info.Files))
+	}
+	if want, got := int64(3), m.PieceCount(); want != got {
+		t.Errorf("unexpected piece count, want = %d, got = %d", want, got)
+	}
+}
+
+func TestParse_hybrid(t *testing.T) {
+	data := marshal(t, map[string]interface{}{
+		"info": map[string]interface{}{
+			"name":         "dir",
+			"piece length": 16384,
+			"meta version": 2,
+			"file tree":    v2FileTree(),
+			"pieces":       bytes.Repeat([]byte{1}, 3*20),
+			"files": []interface{}{
+				map[string]interface{}{"length": 100, "path": []string{"a", "x.bin"}},
+				map[string]interface{}{"length": 16284, "path": []string{".pad", "16284"}, "attr": "p"},
+				map[string]interface{}{"length": 20000, "path": []string{"b.txt"}},
+			},
+		},
+	})
+
+	m, err := ParseBytes(data)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want, got := Hybrid, m.Version(); want != got {
+		t.Errorf("unexpected version, want = %v, got = %v", want, got)
+	}
+	h1, _ := m.HashV1()
+	if want, got := h1, m.Hash(); want != got || len(got) != 40 {
+		t.Errorf("unexpected hash, want = %q, got = %q", want, got)
+	}
+	want := []File{
+		{Path: "dir/a/x.bin", Size: 100, PiecesRoot: root(1)},
+		{Path: "dir/.pad/16284", Size: 16284, Offset: 100, Padding: true},
+		{Path: "dir/b.txt", Size: 20000, Offset: 16384, PiecesRoot: root(2)},
+	}
+	if !cmp.Equal(want, m.Info.Files) {
+		t.Errorf("unexpected files, diff = \n%s", cmp.Diff(want, m.Info.Files))
+	}
+}
+
+func TestParse_errors(t *testing.T) {
+	info := func(kv ...interface{}) []byte {
+		d := map[string]interface{}{
+			"name":         "test",
+			"piece length": 16384,
+			"length":       5,
+			"pieces":       bytes.Repeat([]byte{1}, 20),
+		}
+		for i := 0; i < len(kv); i += 2 {
+			if kv[i+1] == nil {
+				delete(d, kv[i].(string))
+				continue
+			}
+			d[kv[i].(string)] = kv[i+1]
+		}
+		return marshal(t, map[string]interface{}{"info": d})
+	}
+
+	var tests = []struct {
+		name string
+		data []byte
+	}{
+		{name: "not_bencode", data: []byte("garbage")},
+		{name: "no_info", data: []byte("d8:announce1:ae")},
+		{name: "no_name", data: info("name", nil)},
+		{name: "bad_name", data: info("name", "..")},
+		{name: "bad_piece_length", data: info("piece length", 0)},
+		{name: "bad_pieces", data: info("pieces", []byte("short"))},
+		{name: "piece_count", data: info("length", 20000)},
+		{name: "bad_path", data: info("length", nil, "files", []interface{}{
+			map[string]interface{}{"length": 5, "path": []string{"..", "etc"}},
+		})},
+		{name: "v2_no_tree", data: info("meta version", 2)},
+		{name: "v2_piece_length", data: info("meta version", 2, "file tree", v2FileTree(), "piece length", 1000)},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := ParseBytes(tc.data); err == nil {
+				t.Errorf("expected parse to fail")
+			}
+		})
+	}
+}
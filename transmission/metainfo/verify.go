@@ -0,0 +1,286 @@
+package metainfo
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1" //nolint:gosec
+	"crypto/sha256"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/pborzenkov/go-transmission/transmission"
+)
+
+// FileStatus describes completeness of a single file on disk.
+type FileStatus struct {
+	// Path of the file within the torrent
+	Path string
+	// Size of the file
+	Size int64
+	// Number of bytes of the file that belong to verified pieces
+	Verified int64
+	// Indicates whether the file doesn't exist on disk
+	Missing bool
+}
+
+// Complete returns true if all the pieces of the file are verified.
+func (s *FileStatus) Complete() bool {
+	return !s.Missing && s.Verified == s.Size
+}
+
+// VerifyResult is the result of local data verification.
+type VerifyResult struct {
+	// Verified pieces in the format of transmission.Torrent.Pieces
+	Pieces transmission.Pieces
+	// Number of pieces in the torrent
+	PieceCount int
+	// Number of verified pieces
+	VerifiedCount int
+	// Status of each file of the torrent, except for padding files
+	Files []FileStatus
+}
+
+// Complete returns true if all the pieces are verified.
+func (r *VerifyResult) Complete() bool {
+	return r.VerifiedCount == r.PieceCount
+}
+
+// Mismatch returns indices of pieces whose state in pieces, as reported by
+// Transmission, differs from the local verification result.
+func (r *VerifyResult) Mismatch(pieces transmission.Pieces) []int {
+	var indices []int
+	for i := 0; i < r.PieceCount; i++ {
+		if r.Pieces.IsDownloaded(i) != pieces.IsDownloaded(i) {
+			indices = append(indices, i)
+		}
+	}
+	return indices
+}
+
+// Verifier checks torrent data on disk against metainfo.
+type Verifier struct {
+	// Number of pieces verified in parallel. If 0, runtime.NumCPU() is used
+	Workers int
+	// Called periodically with the number of bytes checked so far and total
+	// number of bytes. Calls are serialized
+	Progress func(checked, total int64)
+}
+
+// Verify checks torrent data in dir, the download directory of the torrent,
+// using the default verifier.
+func Verify(ctx context.Context, m *MetaInfo, dir string) (*VerifyResult, error) {
+	return new(Verifier).Verify(ctx, m, dir)
+}
+
+// pieceRange describes data of a single piece.
+type pieceRange struct {
+	// index of the file for v2 pieces or -1
+	file   int
+	offset int64
+	length int64
+	// expected hash
+	hash []byte
+	// merkle tree width of v2 pieces
+	width int
+}
+
+// Verify checks torrent data in dir, the download directory of the torrent.
+// v1 and hybrid torrents are verified using v1 piece hashes, v2 torrents
+// using piece layers. Missing or truncated files make the corresponding
+// pieces unverified rather than fail the verification.
+func (v *Verifier) Verify(ctx context.Context, m *MetaInfo, dir string) (*VerifyResult, error) {
+	files := make([]sourceFile, len(m.Info.Files))
+	missing := make([]bool, len(m.Info.Files))
+	for i, f := range m.Info.Files {
+		files[i] = sourceFile{offset: f.Offset, size: f.Size}
+		if f.Padding {
+			continue
+		}
+		files[i].path = filepath.Join(dir, filepath.FromSlash(f.Path))
+		if _, err := os.Stat(files[i].path); errors.Is(err, fs.ErrNotExist) {
+			missing[i] = true
+		} else if err != nil {
+			return nil, err
+		}
+	}
+
+	var pieces []pieceRange
+	if m.Version()&V1 != 0 {
+		pieces = v1Pieces(m)
+	} else {
+		pieces = v2Pieces(m)
+	}
+
+	var total int64
+	for _, p := range pieces {
+		total += p.length
+	}
+
+	res := &VerifyResult{
+		Pieces:     make(transmission.Pieces, (len(pieces)+7)/8),
+		PieceCount: len(pieces),
+	}
+	var (
+		mu      sync.Mutex
+		checked int64
+	)
+	err := forEach(ctx, v.Workers, len(pieces), files, func(r *fileReader, i int) error {
+		ok := verifyPiece(r, pieces[i])
+
+		mu.Lock()
+		defer mu.Unlock()
+		if ok {
+			res.Pieces[i/8] |= 0x80 >> (i % 8)
+			res.VerifiedCount++
+		}
+		checked += pieces[i].length
+		if v.Progress != nil {
+			v.Progress(checked, total)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ranges := filePieces(m, pieces)
+	for i, f := range m.Info.Files {
+		if f.Padding {
+			continue
+		}
+		status := FileStatus{Path: f.Path, Size: f.Size, Missing: missing[i]}
+		for j := ranges[i][0]; j < ranges[i][1]; j++ {
+			p := pieces[j]
+			if !res.Pieces.IsDownloaded(j) {
+				continue
+			}
+			start, end := p.offset, p.offset+p.length
+			fstart, fend := f.Offset, f.Offset+f.Size
+			if p.file >= 0 {
+				if p.file != i {
+					continue
+				}
+				fstart, fend = 0, f.Size
+			}
+			if overlap := min(end, fend) - max(start, fstart); overlap > 0 {
+				status.Verified += overlap
+			}
+		}
+		res.Files = append(res.Files, status)
+	}
+
+	return res, nil
+}
+
+// filePieces returns the range [first, last) of pieces overlapping every file.
+func filePieces(m *MetaInfo, pieces []pieceRange) [][2]int {
+	ranges := make([][2]int, len(m.Info.Files))
+	if len(pieces) > 0 && pieces[0].file >= 0 {
+		// v2 pieces of a file are adjacent.
+		for j, p := range pieces {
+			r := &ranges[p.file]
+			if r[1] == 0 {
+				r[0] = j
+			}
+			r[1] = j + 1
+		}
+		return ranges
+	}
+
+	for i, f := range m.Info.Files {
+		if f.Size == 0 {
+			continue
+		}
+		first := int(f.Offset / m.Info.PieceLength)
+		last := int((f.Offset+f.Size-1)/m.Info.PieceLength) + 1
+		ranges[i] = [2]int{min(first, len(pieces)), min(last, len(pieces))}
+	}
+	return ranges
+}
+
+func v1Pieces(m *MetaInfo) []pieceRange {
+	total := m.TotalSize()
+	pieces := make([]pieceRange, len(m.Info.Pieces)/sha1Size)
+	for i := range pieces {
+		off := int64(i) * m.Info.PieceLength
+		pieces[i] = pieceRange{
+			file:   -1,
+			offset: off,
+			length: min(m.Info.PieceLength, total-off),
+			hash:   m.Info.Pieces[i*sha1Size : (i+1)*sha1Size],
+		}
+	}
+	return pieces
+}
+
+func v2Pieces(m *MetaInfo) []pieceRange {
+	pieceLength := m.Info.PieceLength
+	blocksPerPiece := int(pieceLength / BlockSize)
+
+	var pieces []pieceRange
+	for i, f := range m.Info.Files {
+		if f.Size == 0 {
+			continue
+		}
+		if f.Size <= pieceLength {
+			blocks := int((f.Size + BlockSize - 1) / BlockSize)
+			pieces = append(pieces, pieceRange{
+				file:   i,
+				length: f.Size,
+				hash:   f.PiecesRoot,
+				width:  nextPowerOfTwo(blocks),
+			})
+			continue
+		}
+
+		layer := m.PieceLayers[string(f.PiecesRoot)]
+		for k, off := 0, int64(0); off < f.Size; k, off = k+1, off+pieceLength {
+			var hash []byte
+			if len(layer) >= (k+1)*sha256Size {
+				hash = layer[k*sha256Size : (k+1)*sha256Size]
+			}
+			pieces = append(pieces, pieceRange{
+				file:   i,
+				offset: off,
+				length: min(pieceLength, f.Size-off),
+				hash:   hash,
+				width:  blocksPerPiece,
+			})
+		}
+	}
+	return pieces
+}
+
+func verifyPiece(r *fileReader, p pieceRange) bool {
+	if p.hash == nil {
+		return false
+	}
+
+	buf := make([]byte, p.length)
+	var err error
+	if p.file >= 0 {
+		err = r.readFileAt(p.file, p.offset, buf)
+	} else {
+		err = r.readRange(p.offset, buf)
+	}
+	if err != nil {
+		return false
+	}
+
+	if p.file < 0 {
+		sum := sha1.Sum(buf) //nolint:gosec
+		return bytes.Equal(sum[:], p.hash)
+	}
+
+	var blocks [][]byte
+	for data := buf; len(data) > 0; {
+		n := min(len(data), BlockSize)
+		sum := sha256.Sum256(data[:n])
+		blocks = append(blocks, sum[:])
+		data = data[n:]
+	}
+	return bytes.Equal(merkleRoot(blocks, p.width), p.hash)
+}
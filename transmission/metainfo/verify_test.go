@@ -0,0 +1,94 @@
+package metainfo
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pborzenkov/go-transmission/transmission"
+)
+
+func TestVerify(t *testing.T) {
+	for _, version := range []Version{V1, V2, Hybrid} {
+		version := version
+		t.Run(version.String(), func(t *testing.T) {
+			dir := writeFiles(t, map[string][]byte{
+				"data/a.bin":   content(3*BlockSize+10, 1),
+				"data/b.bin":   content(BlockSize, 2),
+				"data/c/d.bin": content(2*BlockSize, 3),
+			})
+			m, err := (&Builder{Path: filepath.Join(dir, "data"), PieceLength: BlockSize, Version: version}).
+				Build(context.Background())
+			if err != nil {
+				t.Fatalf("failed to build torrent: %v", err)
+			}
+
+			res, err := Verify(context.Background(), m, dir)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !res.Complete() {
+				t.Errorf("expected intact data to be complete, verified %d of %d", res.VerifiedCount, res.PieceCount)
+			}
+
+			// Corrupt the second piece of a.bin and remove d.bin.
+			f, err := os.OpenFile(filepath.Join(dir, "data", "a.bin"), os.O_WRONLY, 0)
+			if err != nil {
+				t.Fatalf("failed to open file: %v", err)
+			}
+			if _, err := f.WriteAt([]byte("garbage"), BlockSize+1); err != nil {
+				t.Fatalf("failed to write file: %v", err)
+			}
+			f.Close()
+			if err := os.Remove(filepath.Join(dir, "data", "c", "d.bin")); err != nil {
+				t.Fatalf("failed to remove file: %v", err)
+			}
+
+			res, err = (&Verifier{Workers: 2}).Verify(context.Background(), m, dir)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if res.Complete() {
+				t.Errorf("expected damaged data to be incomplete")
+			}
+
+			var got []int
+			for i := 0; i < res.PieceCount; i++ {
+				if !res.Pieces.IsDownloaded(i) {
+					got = append(got, i)
+				}
+			}
+			want := []int{1, 5, 6}
+			if version == V1 {
+				// Without padding piece 4 holds the tail of b.bin and the
+				// head of d.bin.
+				want = []int{1, 4, 5, 6}
+			}
+			if !cmp.Equal(want, got) {
+				t.Errorf("unexpected missing pieces, diff = \n%s", cmp.Diff(want, got))
+			}
+
+			wantFiles := []FileStatus{
+				{Path: "data/a.bin", Size: 3*BlockSize + 10, Verified: 2*BlockSize + 10},
+				{Path: "data/b.bin", Size: BlockSize, Verified: BlockSize},
+				{Path: "data/c/d.bin", Size: 2 * BlockSize, Missing: true},
+			}
+			if version == V1 {
+				wantFiles[1].Verified = BlockSize - 10
+			}
+			if !cmp.Equal(wantFiles, res.Files) {
+				t.Errorf("unexpected files, diff = \n%s", cmp.Diff(wantFiles, res.Files))
+			}
+		})
+	}
+}
+
+func TestVerifyResult_Mismatch(t *testing.T) {
+	res := &VerifyResult{Pieces: transmission.Pieces{0xf0, 0x80}, PieceCount: 10}
+
+	if want, got := []int{2, 8}, res.Mismatch(transmission.Pieces{0xd0, 0x00}); !cmp.Equal(want, got) {
+		t.Errorf("unexpected mismatch, diff = \n%s", cmp.Diff(want, got))
+	}
+}
@@ -0,0 +1,387 @@
+// Package migrate moves torrents between two Transmission daemons.
+//
+// The data of the torrents is expected to be already copied to the target
+// host. Migrator adds each torrent to the target daemon paused, verifies the
+// data there and, if it is complete, starts the torrent on the target and
+// removes it from the source without deleting the data. The state of a
+// migration is derived from both daemons, so an interrupted migration is
+// resumed by running it again, and a torrent is removed from the source only
+// after it is running on the target with all of its data verified. Torrents
+// whose .torrent file can't be read on the source are not migrated, since
+// their data can't be verified before the metadata is fetched.
+package migrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/pborzenkov/go-transmission/transmission"
+	"github.com/pborzenkov/go-transmission/transmission/backup"
+)
+
+const defaultPollInterval = 5 * time.Second
+
+// maxUnverifiedPolls is the number of polls a migration waits for a sign of
+// the requested verification before it trusts a stopped torrent to be
+// verified.
+const maxUnverifiedPolls = 3
+
+var (
+	// ErrMissing is reported for torrents that neither daemon has.
+	ErrMissing = errors.New("migrate: torrent is on neither daemon")
+	// ErrIncomplete is reported for torrents that are not complete on the
+	// target after verification. Such torrents are left on both daemons.
+	ErrIncomplete = errors.New("migrate: data is incomplete on target")
+	// ErrNoMetainfo is reported for torrents whose .torrent file can't be
+	// read on the source. Without metainfo the target can't verify the data,
+	// so such torrents are not migrated.
+	ErrNoMetainfo = errors.New("migrate: torrent file is unavailable")
+)
+
+// Source is a subset of *transmission.Client used to access the source
+// daemon.
+type Source interface {
+	GetTorrents(ctx context.Context, ids transmission.Identifier, fields ...transmission.TorrentField) ([]*transmission.Torrent, error) //nolint:lll
+	RemoveTorrents(ctx context.Context, ids transmission.Identifier, removeData bool) error
+}
+
+// Target is a subset of *transmission.Client used to access the target
+// daemon.
+type Target interface {
+	backup.Client
+	StartTorrents(ctx context.Context, ids transmission.Identifier) error
+}
+
+var (
+	_ Source = (*transmission.Client)(nil)
+	_ Target = (*transmission.Client)(nil)
+)
+
+// Step is a step of a torrent migration.
+type Step int
+
+const (
+	// StepAdd adds the torrent to the target paused and starts verification
+	StepAdd Step = iota
+	// StepVerify waits for the verification to finish
+	StepVerify
+	// StepStart starts the torrent on the target
+	StepStart
+	// StepRemove removes the torrent from the source, keeping the data
+	StepRemove
+	// StepDone means that the torrent is migrated
+	StepDone
+)
+
+// String implements fmt.Stringer.
+func (s Step) String() string {
+	switch s {
+	case StepAdd:
+		return "add"
+	case StepVerify:
+		return "verify"
+	case StepStart:
+		return "start"
+	case StepRemove:
+		return "remove"
+	case StepDone:
+		return "done"
+	default:
+		return fmt.Sprintf("Step(%d)", s)
+	}
+}
+
+// TargetFields is a list of torrent fields requested from the target.
+var TargetFields = []transmission.TorrentField{
+	transmission.TorrentFieldHash,
+	transmission.TorrentFieldName,
+	transmission.TorrentFieldStatus,
+	transmission.TorrentFieldWantedLeft,
+	transmission.TorrentFieldWantedSize,
+	transmission.TorrentFieldValidSize,
+	transmission.TorrentFieldMetadataDone,
+}
+
+// Result describes a step of a torrent migration.
+type Result struct {
+	// Hash of the torrent
+	Hash transmission.Hash
+	// Name of the torrent
+	Name string
+	// Step the result is about
+	Step Step
+	// Failure of the step, if any
+	Err error
+}
+
+// String implements fmt.Stringer.
+func (r *Result) String() string {
+	s := fmt.Sprintf("torrent %s (%s): %s", r.Hash, r.Name, r.Step)
+	if r.Err != nil {
+		s += ": " + r.Err.Error()
+	}
+	return s
+}
+
+// Migrator migrates torrents from Source to Target.
+type Migrator struct {
+	// Daemon to migrate torrents from
+	Source Source
+	// Daemon to migrate torrents to
+	Target Target
+	// Reads .torrent files of the source daemon. If nil, os.ReadFile is used
+	ReadFile func(path string) ([]byte, error)
+	// Maps download directories of the source to the target. If nil,
+	// directories are kept
+	Relocate func(dir string) string
+	// Interval between checks of verification progress. If 0, 5 seconds is
+	// used
+	PollInterval time.Duration
+
+	// Called with every finished or failed step
+	OnResult func(*Result)
+}
+
+// migration is the state of a single torrent migration.
+type migration struct {
+	hash transmission.Hash
+	name string
+	// Verification was requested by this migration
+	verifying bool
+	// The torrent was seen being checked since the verification was requested
+	checked bool
+	// Valid size of the torrent when the verification was requested
+	validSize int64
+	// Number of polls that found the torrent stopped with no sign of the
+	// requested verification
+	unverified int
+	res        *Result
+}
+
+// Migrate migrates the torrents identified by hashes and waits until every
+// migration is either done or failed. It returns the last result of every
+// torrent. A failed migration leaves the torrent on at least one daemon and
+// is resumed by calling Migrate again.
+func (m *Migrator) Migrate(ctx context.Context, hashes ...transmission.Hash) ([]*Result, error) {
+	if m.Source == nil || m.Target == nil {
+		return nil, errors.New("migrate: source or target is not set")
+	}
+
+	interval := m.PollInterval
+	if interval == 0 {
+		interval = defaultPollInterval
+	}
+
+	migrations := make([]*migration, 0, len(hashes))
+	for _, h := range hashes {
+		migrations = append(migrations, &migration{hash: h})
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		pending, err := m.advance(ctx, migrations)
+		if err != nil {
+			return results(migrations), err
+		}
+		if pending == 0 {
+			return results(migrations), nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return results(migrations), ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+func results(migrations []*migration) []*Result {
+	res := make([]*Result, 0, len(migrations))
+	for _, mig := range migrations {
+		if mig.res != nil {
+			res = append(res, mig.res)
+		}
+	}
+	return res
+}
+
+// advance moves every unfinished migration as far as possible and returns the
+// number of migrations that are still in progress.
+func (m *Migrator) advance(ctx context.Context, migrations []*migration) (int, error) {
+	var ids transmission.IDList
+	for _, mig := range migrations {
+		if !mig.finished() {
+			ids = append(ids, mig.hash)
+		}
+	}
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	src, err := torrentsByHash(ctx, m.Source, ids, backup.Fields)
+	if err != nil {
+		return 0, fmt.Errorf("migrate: failed to get source torrents: %w", err)
+	}
+	dst, err := torrentsByHash(ctx, m.Target, ids, TargetFields)
+	if err != nil {
+		return 0, fmt.Errorf("migrate: failed to get target torrents: %w", err)
+	}
+
+	var pending int
+	for _, mig := range migrations {
+		if mig.finished() {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return 0, err
+		}
+		m.step(ctx, mig, src[mig.hash], dst[mig.hash])
+		if !mig.finished() {
+			pending++
+		}
+	}
+	return pending, nil
+}
+
+func (mig *migration) finished() bool {
+	return mig.res != nil && (mig.res.Err != nil || mig.res.Step == StepDone)
+}
+
+func (m *Migrator) report(mig *migration, step Step, err error) {
+	mig.res = &Result{Hash: mig.hash, Name: mig.name, Step: step, Err: err}
+	if m.OnResult != nil {
+		m.OnResult(mig.res)
+	}
+}
+
+// step advances a single migration based on the current state of the torrent
+// on both daemons. src and dst are nil if the daemon doesn't have the torrent.
+func (m *Migrator) step(ctx context.Context, mig *migration, src, dst *transmission.Torrent) {
+	switch {
+	case src != nil:
+		mig.name = src.Name
+	case dst != nil:
+		mig.name = dst.Name
+	}
+
+	switch {
+	case dst == nil && src == nil:
+		m.report(mig, StepAdd, ErrMissing)
+	case dst == nil:
+		if err := m.add(ctx, mig, src); err != nil {
+			m.report(mig, StepAdd, err)
+			return
+		}
+		m.report(mig, StepAdd, nil)
+	case src == nil:
+		// The torrent is removed from the source only after it is started
+		// on the target.
+		m.report(mig, StepDone, nil)
+	case dst.Status == transmission.StatusCheckWait || dst.Status == transmission.StatusCheck:
+		mig.verifying, mig.checked = true, true
+	case dst.Status == transmission.StatusStopped && !mig.verifying:
+		// The torrent was added by an earlier migration, but there is no
+		// telling whether the data was verified since then.
+		if err := m.verify(ctx, mig, dst.Hash, dst.ValidSize); err != nil {
+			m.report(mig, StepVerify, err)
+		}
+	case dst.Status == transmission.StatusStopped && !mig.checked && dst.ValidSize == mig.validSize &&
+		mig.unverified < maxUnverifiedPolls:
+		// The verification may be not queued yet, or it may have finished
+		// between two polls without finding any new data.
+		mig.unverified++
+	case dst.Status == transmission.StatusStopped:
+		if !complete(dst) {
+			m.report(mig, StepVerify, ErrIncomplete)
+			return
+		}
+		m.report(mig, StepVerify, nil)
+		if err := m.Target.StartTorrents(ctx, transmission.IDs(dst.Hash)); err != nil {
+			m.report(mig, StepStart, err)
+			return
+		}
+		m.report(mig, StepStart, nil)
+		m.remove(ctx, mig, src)
+	case complete(dst):
+		// The torrent was started by an earlier migration and all of its
+		// data passed the hash check.
+		m.remove(ctx, mig, src)
+	default:
+		m.report(mig, StepVerify, ErrIncomplete)
+	}
+}
+
+// complete reports whether the torrent has metadata and all the wanted data
+// is verified.
+func complete(t *transmission.Torrent) bool {
+	return t.MetadataDone == 1 && t.WantedLeft == 0 && t.ValidSize == t.WantedSize
+}
+
+// add adds the torrent to the target paused and requests its verification.
+func (m *Migrator) add(ctx context.Context, mig *migration, src *transmission.Torrent) error {
+	a := &backup.Archiver{Client: m.Target, ReadFile: m.ReadFile}
+	rec, err := a.Record(src)
+	if err != nil {
+		return err
+	}
+	if len(rec.Meta) == 0 {
+		return ErrNoMetainfo
+	}
+	if m.Relocate != nil {
+		rec.DownloadDirectory = m.Relocate(rec.DownloadDirectory)
+	}
+	// The verification is awaited by polling, so that torrents are verified
+	// in parallel.
+	t, err := a.Restore(ctx, rec, &backup.ImportOpts{
+		Paused:      true,
+		OnDuplicate: transmission.DuplicateIgnore,
+	})
+	if err != nil {
+		return err
+	}
+	return m.verify(ctx, mig, t.ID, 0)
+}
+
+// verify requests verification of the torrent, whose valid size is
+// validSize, on the target.
+func (m *Migrator) verify(ctx context.Context, mig *migration, id transmission.SingularIdentifier,
+	validSize int64) error {
+	if err := m.Target.VerifyTorrents(ctx, transmission.IDs(id)); err != nil {
+		return err
+	}
+	mig.verifying, mig.checked, mig.validSize, mig.unverified = true, false, validSize, 0
+	return nil
+}
+
+// remove removes the torrent, which is already running on the target, from
+// the source.
+func (m *Migrator) remove(ctx context.Context, mig *migration, src *transmission.Torrent) {
+	if src != nil {
+		if err := m.Source.RemoveTorrents(ctx, transmission.IDs(src.Hash), false); err != nil {
+			m.report(mig, StepRemove, err)
+			return
+		}
+		m.report(mig, StepRemove, nil)
+	}
+	m.report(mig, StepDone, nil)
+}
+
+type torrentGetter interface {
+	GetTorrents(ctx context.Context, ids transmission.Identifier, fields ...transmission.TorrentField) ([]*transmission.Torrent, error) //nolint:lll
+}
+
+func torrentsByHash(ctx context.Context, c torrentGetter, ids transmission.IDList, fields []transmission.TorrentField) (map[transmission.Hash]*transmission.Torrent, error) { //nolint:lll
+	torrents, err := c.GetTorrents(ctx, ids, fields...)
+	if err != nil {
+		return nil, err
+	}
+	byHash := make(map[transmission.Hash]*transmission.Torrent, len(torrents))
+	for _, t := range torrents {
+		byHash[t.Hash] = t
+	}
+	return byHash, nil
+}
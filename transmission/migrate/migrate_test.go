@@ -0,0 +1,325 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pborzenkov/go-transmission/transmission"
+)
+
+type fakeClient struct {
+	mu         sync.Mutex
+	torrents   []*transmission.Torrent
+	incomplete map[transmission.Hash]bool
+	// Number of polls before a verification is queued
+	lag     int
+	pending map[transmission.Hash]int
+	calls   []string
+	err     error
+}
+
+func (c *fakeClient) find(id any) *transmission.Torrent {
+	for _, t := range c.torrents {
+		if t.Hash == id || t.ID == id {
+			return t
+		}
+	}
+	return nil
+}
+
+// GetTorrents also advances verification of the torrents by one step.
+func (c *fakeClient) GetTorrents(ctx context.Context, ids transmission.Identifier, fields ...transmission.TorrentField) ([]*transmission.Torrent, error) { //nolint:lll
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.err != nil {
+		return nil, c.err
+	}
+	var torrents []*transmission.Torrent
+	for _, id := range ids.(transmission.IDList) {
+		t := c.find(id)
+		if t == nil {
+			continue
+		}
+		copy := *t
+		torrents = append(torrents, &copy)
+
+		if n, ok := c.pending[t.Hash]; ok {
+			if c.pending[t.Hash] = n - 1; n == 1 {
+				delete(c.pending, t.Hash)
+				t.Status = transmission.StatusCheckWait
+			}
+			continue
+		}
+		switch t.Status {
+		case transmission.StatusCheckWait:
+			t.Status = transmission.StatusCheck
+		case transmission.StatusCheck:
+			t.Status = transmission.StatusStopped
+			if !c.incomplete[t.Hash] {
+				t.WantedLeft, t.ValidSize = 0, t.WantedSize
+			}
+		}
+	}
+	return torrents, nil
+}
+
+func (c *fakeClient) AddTorrentWithSettings(ctx context.Context, req *transmission.AddTorrentReq, settings *transmission.AddTorrentSettings) (*transmission.NewTorrent, error) { //nolint:lll
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	meta, err := io.ReadAll(req.Meta)
+	if err != nil {
+		return nil, err
+	}
+	c.calls = append(c.calls, fmt.Sprint("add ", string(meta), " ", *req.DownloadDirectory, " ", *req.Paused))
+	h := transmission.Hash(meta)
+	c.torrents = append(c.torrents, &transmission.Torrent{
+		ID:           transmission.ID(len(c.torrents) + 1),
+		Hash:         h,
+		Name:         "name of " + string(h),
+		Status:       transmission.StatusStopped,
+		WantedLeft:   1,
+		WantedSize:   1,
+		MetadataDone: 1,
+	})
+	return &transmission.NewTorrent{ID: transmission.ID(len(c.torrents)), Hash: h}, nil
+}
+
+func (c *fakeClient) VerifyTorrents(ctx context.Context, ids transmission.Identifier) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.calls = append(c.calls, fmt.Sprint("verify ", ids))
+	for _, id := range ids.(transmission.IDList) {
+		for _, t := range c.torrents {
+			switch {
+			case t.ID != id && t.Hash != id:
+			case c.lag > 0:
+				if c.pending == nil {
+					c.pending = make(map[transmission.Hash]int)
+				}
+				c.pending[t.Hash] = c.lag
+			default:
+				t.Status = transmission.StatusCheckWait
+			}
+		}
+	}
+	return nil
+}
+
+func (c *fakeClient) StartTorrents(ctx context.Context, ids transmission.Identifier) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.calls = append(c.calls, fmt.Sprint("start ", ids))
+	for _, id := range ids.(transmission.IDList) {
+		if t := c.find(id); t != nil {
+			t.Status = transmission.StatusSeed
+		}
+	}
+	return nil
+}
+
+// SetTorrents is only used by backup.Archiver.Import, which Migrator doesn't
+// use.
+func (c *fakeClient) SetTorrents(ctx context.Context, ids transmission.Identifier, req *transmission.SetTorrentReq) error { //nolint:lll
+	return errors.New("not implemented")
+}
+
+func (c *fakeClient) RemoveTorrents(ctx context.Context, ids transmission.Identifier, removeData bool) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.calls = append(c.calls, fmt.Sprint("remove ", ids, " ", removeData))
+	for _, id := range ids.(transmission.IDList) {
+		for i, t := range c.torrents {
+			if t.Hash == id {
+				c.torrents = append(c.torrents[:i], c.torrents[i+1:]...)
+				break
+			}
+		}
+	}
+	return nil
+}
+
+func describe(results []*Result) []string {
+	var s []string
+	for _, r := range results {
+		s = append(s, r.String())
+	}
+	return s
+}
+
+func newMigrator(source, target *fakeClient) *Migrator {
+	return &Migrator{
+		Source:       source,
+		Target:       target,
+		ReadFile:     func(path string) ([]byte, error) { return []byte(path), nil },
+		Relocate:     func(dir string) string { return "/new" + dir },
+		PollInterval: time.Millisecond,
+	}
+}
+
+func TestMigrator_Migrate(t *testing.T) {
+	source := &fakeClient{
+		torrents: []*transmission.Torrent{
+			{Hash: "h1", Name: "a", File: "h1", DownloadDirectory: "/data", Status: transmission.StatusSeed},
+			{Hash: "h2", Name: "b", File: "h2", DownloadDirectory: "/data", Status: transmission.StatusSeed},
+		},
+	}
+	target := &fakeClient{incomplete: map[transmission.Hash]bool{"h2": true}}
+
+	m := newMigrator(source, target)
+	var steps []string
+	m.OnResult = func(r *Result) { steps = append(steps, r.String()) }
+
+	results, err := m.Migrate(context.Background(), "h1", "h2", "h3")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []string{
+		"torrent h1 (a): done",
+		"torrent h2 (b): verify: " + ErrIncomplete.Error(),
+		"torrent h3 (): add: " + ErrMissing.Error(),
+	}
+	if got := describe(results); !cmp.Equal(want, got) {
+		t.Errorf("unexpected results, diff = \n%s", cmp.Diff(want, got))
+	}
+	wantSteps := []string{
+		"torrent h1 (a): add",
+		"torrent h2 (b): add",
+		"torrent h3 (): add: " + ErrMissing.Error(),
+		"torrent h1 (a): verify",
+		"torrent h1 (a): start",
+		"torrent h1 (a): remove",
+		"torrent h1 (a): done",
+		"torrent h2 (b): verify: " + ErrIncomplete.Error(),
+	}
+	if !cmp.Equal(wantSteps, steps) {
+		t.Errorf("unexpected steps, diff = \n%s", cmp.Diff(wantSteps, steps))
+	}
+
+	wantTarget := []string{
+		"add h1 /new/data true", "verify [1]",
+		"add h2 /new/data true", "verify [2]",
+		"start [h1]",
+	}
+	if !cmp.Equal(wantTarget, target.calls) {
+		t.Errorf("unexpected target calls, diff = \n%s", cmp.Diff(wantTarget, target.calls))
+	}
+	if want := []string{"remove [h1] false"}; !cmp.Equal(want, source.calls) {
+		t.Errorf("unexpected source calls, diff = \n%s", cmp.Diff(want, source.calls))
+	}
+	if source.find(transmission.Hash("h2")) == nil || target.find(transmission.Hash("h2")) == nil {
+		t.Errorf("expected incomplete torrent to stay on both daemons")
+	}
+}
+
+func TestMigrator_Migrate_resume(t *testing.T) {
+	source := &fakeClient{
+		torrents: []*transmission.Torrent{
+			{Hash: "h1", Name: "a", Status: transmission.StatusSeed},
+			{Hash: "h2", Name: "b", Status: transmission.StatusSeed},
+		},
+	}
+	target := &fakeClient{
+		torrents: []*transmission.Torrent{
+			// Added, but not verified yet.
+			{Hash: "h1", Name: "a", Status: transmission.StatusStopped, WantedLeft: 1, WantedSize: 1, MetadataDone: 1},
+			// Started, but not removed from the source.
+			{Hash: "h2", Name: "b", Status: transmission.StatusSeed, WantedSize: 1, ValidSize: 1, MetadataDone: 1},
+			// Already migrated.
+			{Hash: "h3", Name: "c", Status: transmission.StatusSeed},
+		},
+	}
+
+	results, err := newMigrator(source, target).Migrate(context.Background(), "h1", "h2", "h3")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []string{"torrent h1 (a): done", "torrent h2 (b): done", "torrent h3 (c): done"}
+	if got := describe(results); !cmp.Equal(want, got) {
+		t.Errorf("unexpected results, diff = \n%s", cmp.Diff(want, got))
+	}
+	if want := []string{"verify [h1]", "start [h1]"}; !cmp.Equal(want, target.calls) {
+		t.Errorf("unexpected target calls, diff = \n%s", cmp.Diff(want, target.calls))
+	}
+	if want := []string{"remove [h2] false", "remove [h1] false"}; !cmp.Equal(want, source.calls) {
+		t.Errorf("unexpected source calls, diff = \n%s", cmp.Diff(want, source.calls))
+	}
+}
+
+func TestMigrator_Migrate_verifyLag(t *testing.T) {
+	source := &fakeClient{
+		torrents: []*transmission.Torrent{
+			{Hash: "h1", Name: "a", File: "h1", DownloadDirectory: "/data", Status: transmission.StatusSeed},
+		},
+	}
+	// The verification is queued two polls after it is requested.
+	target := &fakeClient{lag: 2}
+
+	results, err := newMigrator(source, target).Migrate(context.Background(), "h1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want, got := []string{"torrent h1 (a): done"}, describe(results); !cmp.Equal(want, got) {
+		t.Errorf("unexpected results, diff = \n%s", cmp.Diff(want, got))
+	}
+}
+
+func TestMigrator_Migrate_unverified(t *testing.T) {
+	source := &fakeClient{
+		torrents: []*transmission.Torrent{
+			{Hash: "h1", Name: "a", MagnetLink: "magnet:?xt=urn:btih:h1", Status: transmission.StatusSeed},
+			{Hash: "h2", Name: "b", Status: transmission.StatusSeed},
+		},
+	}
+	target := &fakeClient{
+		torrents: []*transmission.Torrent{
+			// Running, but fetching metadata.
+			{Hash: "h2", Name: "b", Status: transmission.StatusDownload},
+		},
+	}
+
+	m := newMigrator(source, target)
+	m.ReadFile = func(string) ([]byte, error) { return nil, errors.New("no such file") }
+	results, err := m.Migrate(context.Background(), "h1", "h2")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []string{
+		"torrent h1 (a): add: " + ErrNoMetainfo.Error(),
+		"torrent h2 (b): verify: " + ErrIncomplete.Error(),
+	}
+	if got := describe(results); !cmp.Equal(want, got) {
+		t.Errorf("unexpected results, diff = \n%s", cmp.Diff(want, got))
+	}
+	if len(target.calls) != 0 || len(source.calls) != 0 {
+		t.Errorf("unexpected calls: %v, %v", target.calls, source.calls)
+	}
+}
+
+func TestMigrator_Migrate_errors(t *testing.T) {
+	if _, err := (&Migrator{}).Migrate(context.Background(), "h1"); err == nil {
+		t.Errorf("expected migrator without daemons to fail")
+	}
+
+	source := &fakeClient{err: errors.New("daemon is down")}
+	if _, err := newMigrator(source, &fakeClient{}).Migrate(context.Background(), "h1"); err == nil {
+		t.Errorf("expected Migrate to fail")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	source = &fakeClient{torrents: []*transmission.Torrent{{Hash: "h1", File: "h1"}}}
+	if _, err := newMigrator(source, &fakeClient{}).Migrate(ctx, "h1"); !errors.Is(err, context.Canceled) {
+		t.Errorf("unexpected error, want = %v, got = %v", context.Canceled, err)
+	}
+}
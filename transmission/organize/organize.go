@@ -0,0 +1,426 @@
+// Package organize moves completed torrents into category directories.
+//
+// Organizer watches for torrents that finished downloading, or seeding,
+// computes their target directory from a Template using the label, tracker
+// host, name and completion date of a torrent and asks Transmission to move
+// the data there. A move is considered done once the daemon reports the new
+// download directory. Moves that don't take effect are retried, and
+// collisions with data already present in the target directory are either
+// skipped or resolved by renaming the torrent.
+package organize
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pborzenkov/go-transmission/transmission"
+	"github.com/pborzenkov/go-transmission/transmission/internal/periodic"
+)
+
+const (
+	defaultMaxAttempts = 3
+	defaultRetryDelay  = 10 * time.Minute
+	defaultInterval    = time.Minute
+)
+
+// ErrCollision is reported when the target directory already contains a file
+// or directory with the name of the torrent.
+var ErrCollision = errors.New("organize: target already exists")
+
+// Client is a subset of *transmission.Client used by Organizer.
+type Client interface {
+	GetTorrents(ctx context.Context, ids transmission.Identifier, fields ...transmission.TorrentField) ([]*transmission.Torrent, error) //nolint:lll
+	SetTorrentsLocation(ctx context.Context, ids transmission.Identifier, location string, move bool) error
+	RenameTorrentPath(ctx context.Context, id transmission.SingularIdentifier, path, name string) error
+}
+
+var _ Client = (*transmission.Client)(nil)
+
+// Collision defines how collisions in the target directory are handled.
+type Collision int
+
+const (
+	// CollisionSkip leaves the torrent where it is and reports ErrCollision
+	CollisionSkip Collision = iota
+	// CollisionRename renames the torrent to "name (N)" before moving it.
+	// The suffix goes before the extension of single file torrents
+	CollisionRename
+	// CollisionIgnore moves the torrent anyway
+	CollisionIgnore
+)
+
+// String implements fmt.Stringer.
+func (c Collision) String() string {
+	switch c {
+	case CollisionSkip:
+		return "skip"
+	case CollisionRename:
+		return "rename"
+	case CollisionIgnore:
+		return "ignore"
+	default:
+		return fmt.Sprintf("Collision(%d)", c)
+	}
+}
+
+// Fields is a list of torrent fields required to organize torrents.
+var Fields = []transmission.TorrentField{
+	transmission.TorrentFieldID,
+	transmission.TorrentFieldHash,
+	transmission.TorrentFieldName,
+	transmission.TorrentFieldStatus,
+	transmission.TorrentFieldLabels,
+	transmission.TorrentFieldTrackers,
+	transmission.TorrentFieldDataDone,
+	transmission.TorrentFieldIsFinished,
+	transmission.TorrentFieldAddedAt,
+	transmission.TorrentFieldDoneAt,
+	transmission.TorrentFieldDownloadDirectory,
+}
+
+// Result describes a move of a single torrent.
+type Result struct {
+	// ID of the torrent
+	ID transmission.ID
+	// Hash of the torrent
+	Hash transmission.Hash
+	// Name of the torrent
+	Name string
+	// Original download directory
+	From string
+	// Target download directory
+	To string
+	// New name of the torrent if it was renamed due to a collision
+	RenamedTo string
+	// Number of attempts to move the torrent
+	Attempts int
+	// Indicates whether the torrent was moved
+	Applied bool
+	// An error that prevented the torrent from being moved
+	Err error
+}
+
+// String returns a human readable description of the result.
+func (r *Result) String() string {
+	s := fmt.Sprintf("torrent %d (%s): move from %s to %s", r.ID, r.Name, r.From, r.To)
+	if r.RenamedTo != "" {
+		s += fmt.Sprintf(" as %q", r.RenamedTo)
+	}
+	switch {
+	case r.Err != nil:
+		s += fmt.Sprintf(": %v", r.Err)
+	case !r.Applied:
+		s += " [dry run]"
+	}
+	return s
+}
+
+type move struct {
+	from, to  string
+	renamedTo string
+	renamed   bool
+	attempts  int
+	issuedAt  time.Time
+	failed    bool
+}
+
+func (m *move) result(t *transmission.Torrent) *Result {
+	return &Result{
+		ID:        t.ID,
+		Hash:      t.Hash,
+		Name:      t.Name,
+		From:      m.from,
+		To:        m.to,
+		RenamedTo: m.renamedTo,
+		Attempts:  m.attempts,
+	}
+}
+
+// Organizer moves completed torrents to directories computed by Template.
+type Organizer struct {
+	// Transmission client
+	Client Client
+	// Template of target directories
+	Template *Template
+	// Only torrents in these download directories are organized. If empty,
+	// all torrents are organized
+	Sources []string
+	// Wait for torrents to finish seeding, not just downloading
+	AfterSeeding bool
+	// How to handle collisions in the target directory
+	Collision Collision
+	// Reports whether a file or directory exists. If nil, only data of
+	// torrents known to Transmission is considered for collisions
+	Exists func(path string) bool
+	// Number of attempts to move a torrent before giving up. If 0, 3 is used
+	MaxAttempts int
+	// Delay before retrying a move that didn't take effect. If 0, 10 minutes
+	// is used
+	RetryDelay time.Duration
+	// Only report moves, don't apply them
+	DryRun bool
+	// Interval between runs in Run. If 0, 1 minute is used
+	Interval time.Duration
+	// Called with every result
+	OnResult func(*Result)
+	// Called when Run fails to list the torrents or to verify moves issued
+	// by an earlier pass
+	OnError func(error)
+
+	mu    sync.Mutex
+	moves map[transmission.Hash]*move
+}
+
+func (o *Organizer) ready(t *transmission.Torrent) bool {
+	if len(o.Sources) > 0 {
+		var found bool
+		for _, s := range o.Sources {
+			found = found || path.Clean(s) == path.Clean(t.DownloadDirectory)
+		}
+		if !found {
+			return false
+		}
+	}
+	switch t.Status {
+	case transmission.StatusCheckWait, transmission.StatusCheck:
+		return false
+	}
+	if o.AfterSeeding {
+		return t.IsFinished
+	}
+	return t.DataDone >= 1
+}
+
+// Organize moves torrents that are ready, unless DryRun is set, and verifies
+// moves issued before. A move that fails or doesn't take effect within
+// RetryDelay is retried up to MaxAttempts times. Results are reported for
+// verified, failed and, in dry-run mode, planned moves.
+func (o *Organizer) Organize(ctx context.Context) ([]*Result, error) {
+	if o.Template == nil {
+		return nil, errors.New("organize: template is not set")
+	}
+	torrents, err := o.Client.GetTorrents(ctx, transmission.All(), Fields...)
+	if err != nil {
+		return nil, err
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.moves == nil {
+		o.moves = make(map[transmission.Hash]*move)
+	}
+
+	occupied := make(map[string]transmission.Hash, len(torrents))
+	present := make(map[transmission.Hash]bool, len(torrents))
+	for _, t := range torrents {
+		occupied[path.Join(t.DownloadDirectory, t.Name)] = t.Hash
+		present[t.Hash] = true
+	}
+	for h := range o.moves {
+		if !present[h] {
+			delete(o.moves, h)
+		}
+	}
+
+	var (
+		results []*Result
+		issued  []transmission.SingularIdentifier
+		now     = time.Now()
+	)
+	report := func(res *Result) {
+		results = append(results, res)
+		if o.OnResult != nil {
+			o.OnResult(res)
+		}
+	}
+	for _, t := range torrents {
+		dir := path.Clean(t.DownloadDirectory)
+		m := o.moves[t.Hash]
+		switch {
+		case m == nil:
+		case m.failed:
+			// Forget the failure once the torrent is moved by someone else.
+			if dir != m.from {
+				delete(o.moves, t.Hash)
+			}
+			continue
+		case dir == m.to:
+			res := m.result(t)
+			res.Applied = true
+			report(res)
+			delete(o.moves, t.Hash)
+			continue
+		case now.Sub(m.issuedAt) < o.retryDelay():
+			continue
+		default:
+			if res := o.issue(ctx, t, m, now); res != nil {
+				report(res)
+			} else {
+				issued = append(issued, t.Hash)
+			}
+			continue
+		}
+
+		if !o.ready(t) {
+			continue
+		}
+		to, err := o.Template.Execute(t)
+		if err != nil {
+			o.moves[t.Hash] = &move{from: dir, failed: true}
+			report(&Result{ID: t.ID, Hash: t.Hash, Name: t.Name, From: dir, Err: err})
+			continue
+		}
+		if dir == to {
+			continue
+		}
+
+		m = &move{from: dir, to: to}
+		if o.collides(occupied, t.Hash, path.Join(to, t.Name)) {
+			switch o.Collision {
+			case CollisionSkip:
+				m.failed = true
+				o.moves[t.Hash] = m
+				res := m.result(t)
+				res.Err = ErrCollision
+				report(res)
+				continue
+			case CollisionRename:
+				if m.renamedTo, err = o.newName(ctx, t, occupied, to); err != nil {
+					m.failed = true
+					o.moves[t.Hash] = m
+					res := m.result(t)
+					res.Err = err
+					report(res)
+					continue
+				}
+			}
+		}
+		name := t.Name
+		if m.renamedTo != "" {
+			name = m.renamedTo
+		}
+		occupied[path.Join(to, name)] = t.Hash
+
+		if o.DryRun {
+			report(m.result(t))
+			continue
+		}
+		o.moves[t.Hash] = m
+		if res := o.issue(ctx, t, m, now); res != nil {
+			report(res)
+		} else {
+			issued = append(issued, t.Hash)
+		}
+	}
+
+	// Moves are usually done by the time the daemon replies, verify them
+	// right away.
+	if len(issued) > 0 {
+		moved, err := o.Client.GetTorrents(ctx, transmission.IDs(issued...),
+			transmission.TorrentFieldID, transmission.TorrentFieldHash, transmission.TorrentFieldName,
+			transmission.TorrentFieldDownloadDirectory)
+		if err != nil {
+			return results, fmt.Errorf("organize: failed to verify moves: %w", err)
+		}
+		for _, t := range moved {
+			if m := o.moves[t.Hash]; m != nil && !m.failed && path.Clean(t.DownloadDirectory) == m.to {
+				res := m.result(t)
+				res.Applied = true
+				report(res)
+				delete(o.moves, t.Hash)
+			}
+		}
+	}
+	return results, nil
+}
+
+func (o *Organizer) retryDelay() time.Duration {
+	if o.RetryDelay <= 0 {
+		return defaultRetryDelay
+	}
+	return o.RetryDelay
+}
+
+func (o *Organizer) collides(occupied map[string]transmission.Hash, hash transmission.Hash, p string) bool {
+	if h, ok := occupied[p]; ok && h != hash {
+		return true
+	}
+	return o.Exists != nil && o.Exists(p)
+}
+
+// newName returns a name of the torrent that doesn't collide with anything in
+// dir.
+func (o *Organizer) newName(ctx context.Context, t *transmission.Torrent,
+	occupied map[string]transmission.Hash, dir string) (string, error) {
+	base, ext := t.Name, ""
+	// Keep extensions of single file torrents.
+	files, err := o.Client.GetTorrents(ctx, t.Hash, transmission.TorrentFieldFiles)
+	if err != nil {
+		return "", err
+	}
+	if len(files) == 1 && len(files[0].Files) == 1 && files[0].Files[0].Name == t.Name {
+		ext = path.Ext(t.Name)
+		base = strings.TrimSuffix(t.Name, ext)
+	}
+	for n := 2; ; n++ {
+		name := fmt.Sprintf("%s (%d)%s", base, n, ext)
+		if !o.collides(occupied, t.Hash, path.Join(dir, name)) {
+			return name, nil
+		}
+	}
+}
+
+// issue asks Transmission to move the torrent. It returns a result if the
+// move failed or if no attempts are left.
+func (o *Organizer) issue(ctx context.Context, t *transmission.Torrent, m *move, now time.Time) *Result {
+	maxAttempts := o.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = defaultMaxAttempts
+	}
+	if m.attempts >= maxAttempts {
+		m.failed = true
+		res := m.result(t)
+		res.Err = fmt.Errorf("organize: torrent is still in %s after %d attempts", t.DownloadDirectory, m.attempts)
+		return res
+	}
+
+	m.attempts++
+	m.issuedAt = now
+	err := ctx.Err()
+	if err == nil && m.renamedTo != "" && !m.renamed {
+		if err = o.Client.RenameTorrentPath(ctx, t.Hash, t.Name, m.renamedTo); err == nil {
+			m.renamed = true
+		}
+	}
+	if err == nil {
+		err = o.Client.SetTorrentsLocation(ctx, t.Hash, m.to, true)
+	}
+	if err != nil {
+		res := m.result(t)
+		res.Err = err
+		return res
+	}
+	return nil
+}
+
+// Run organizes torrents right away and then every Interval until ctx is
+// done, so moves left pending by one pass are verified by the next one. It
+// returns ctx.Err().
+func (o *Organizer) Run(ctx context.Context) error {
+	if o.Client == nil {
+		return errors.New("organize: client is not set")
+	}
+	interval := o.Interval
+	if interval <= 0 {
+		interval = defaultInterval
+	}
+	return periodic.Run(ctx, interval, func(ctx context.Context) error {
+		_, err := o.Organize(ctx)
+		return err
+	}, o.OnError)
+}
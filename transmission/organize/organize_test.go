@@ -0,0 +1,334 @@
+package organize
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pborzenkov/go-transmission/transmission"
+)
+
+type fakeClient struct {
+	mu       sync.Mutex
+	torrents []*transmission.Torrent
+	stuck    map[transmission.Hash]bool
+	calls    []string
+	err      error
+	// Fail requests of specific torrents
+	errByID error
+}
+
+func (c *fakeClient) find(id any) *transmission.Torrent {
+	for _, t := range c.torrents {
+		if t.Hash == id {
+			return t
+		}
+	}
+	return nil
+}
+
+func (c *fakeClient) GetTorrents(ctx context.Context, ids transmission.Identifier, fields ...transmission.TorrentField) ([]*transmission.Torrent, error) { //nolint:lll
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.err != nil {
+		return nil, c.err
+	}
+	if _, ok := ids.(transmission.IDList); ok && c.errByID != nil {
+		return nil, c.errByID
+	}
+	var torrents []*transmission.Torrent
+	for _, t := range c.torrents {
+		switch ids := ids.(type) {
+		case transmission.Hash:
+			if t.Hash != ids {
+				continue
+			}
+		case transmission.IDList:
+			var found bool
+			for _, id := range ids {
+				found = found || id == t.Hash
+			}
+			if !found {
+				continue
+			}
+		}
+		copy := *t
+		torrents = append(torrents, &copy)
+	}
+	return torrents, nil
+}
+
+func (c *fakeClient) SetTorrentsLocation(ctx context.Context, ids transmission.Identifier, location string, move bool) error { //nolint:lll
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.calls = append(c.calls, fmt.Sprint("move ", ids, " ", location, " ", move))
+	if c.stuck[ids.(transmission.Hash)] {
+		return nil
+	}
+	if t := c.find(ids); t != nil {
+		t.DownloadDirectory = location
+	}
+	return nil
+}
+
+func (c *fakeClient) RenameTorrentPath(ctx context.Context, id transmission.SingularIdentifier, path, name string) error { //nolint:lll
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.calls = append(c.calls, fmt.Sprintf("rename %v %s %s", id, path, name))
+	if t := c.find(id); t != nil {
+		t.Name = name
+		if len(t.Files) == 1 {
+			t.Files[0].Name = name
+		}
+	}
+	return nil
+}
+
+func newOrganizer(t *testing.T, client *fakeClient) *Organizer {
+	t.Helper()
+
+	tmpl, err := ParseTemplate(`/data/{{.Label | default "misc"}}`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return &Organizer{Client: client, Template: tmpl, Sources: []string{"/downloads/"}}
+}
+
+func describe(results []*Result) []string {
+	var s []string
+	for _, r := range results {
+		s = append(s, r.String())
+	}
+	return s
+}
+
+func TestOrganizer_Organize(t *testing.T) {
+	client := &fakeClient{
+		torrents: []*transmission.Torrent{
+			{ID: 1, Hash: "h1", Name: "a", Labels: []string{"movies"}, DataDone: 1, DownloadDirectory: "/downloads"},
+			{ID: 2, Hash: "h2", Name: "b", DataDone: 0.5, DownloadDirectory: "/downloads"},
+			{ID: 3, Hash: "h3", Name: "c", DataDone: 1, DownloadDirectory: "/elsewhere"},
+			{ID: 4, Hash: "h4", Name: "d", DataDone: 1, DownloadDirectory: "/downloads", Status: transmission.StatusCheck},
+			{ID: 5, Hash: "h5", Name: "e", DataDone: 1, DownloadDirectory: "/downloads"},
+		},
+	}
+
+	o := newOrganizer(t, client)
+	var reported int
+	o.OnResult = func(*Result) { reported++ }
+
+	results, err := o.Organize(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []string{
+		"torrent 1 (a): move from /downloads to /data/movies",
+		"torrent 5 (e): move from /downloads to /data/misc",
+	}
+	if got := describe(results); !cmp.Equal(want, got) {
+		t.Errorf("unexpected results, diff = \n%s", cmp.Diff(want, got))
+	}
+	if want, got := len(results), reported; want != got {
+		t.Errorf("unexpected number of reported results, want = %d, got = %d", want, got)
+	}
+
+	// Moved torrents are not in the source directory anymore.
+	if results, _ := o.Organize(context.Background()); len(results) != 0 {
+		t.Errorf("unexpected results: %v", describe(results))
+	}
+	if want := []string{"move h1 /data/movies true", "move h5 /data/misc true"}; !cmp.Equal(want, client.calls) {
+		t.Errorf("unexpected calls, diff = \n%s", cmp.Diff(want, client.calls))
+	}
+}
+
+func TestOrganizer_Organize_afterSeeding(t *testing.T) {
+	client := &fakeClient{
+		torrents: []*transmission.Torrent{
+			{ID: 1, Hash: "h1", Name: "a", DataDone: 1, DownloadDirectory: "/downloads"},
+			{ID: 2, Hash: "h2", Name: "b", DataDone: 1, IsFinished: true, DownloadDirectory: "/downloads"},
+		},
+	}
+
+	o := newOrganizer(t, client)
+	o.AfterSeeding = true
+	o.DryRun = true
+
+	results, err := o.Organize(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []string{"torrent 2 (b): move from /downloads to /data/misc [dry run]"}
+	if got := describe(results); !cmp.Equal(want, got) {
+		t.Errorf("unexpected results, diff = \n%s", cmp.Diff(want, got))
+	}
+	if len(client.calls) != 0 {
+		t.Errorf("unexpected calls: %v", client.calls)
+	}
+}
+
+func TestOrganizer_Organize_collision(t *testing.T) {
+	newClient := func() *fakeClient {
+		return &fakeClient{
+			torrents: []*transmission.Torrent{
+				{ID: 1, Hash: "h1", Name: "a.mkv", DataDone: 1, DownloadDirectory: "/downloads",
+					Files: []transmission.File{{Name: "a.mkv"}}},
+				{ID: 2, Hash: "h2", Name: "a.mkv", DataDone: 1, DownloadDirectory: "/data/misc"},
+			},
+		}
+	}
+	exists := func(p string) bool { return p == "/data/misc/a (2).mkv" }
+
+	var tests = []struct {
+		collision Collision
+		want      []string
+		calls     []string
+	}{
+		{
+			collision: CollisionSkip,
+			want:      []string{"torrent 1 (a.mkv): move from /downloads to /data/misc: " + ErrCollision.Error()},
+		},
+		{
+			collision: CollisionRename,
+			want:      []string{`torrent 1 (a (3).mkv): move from /downloads to /data/misc as "a (3).mkv"`},
+			calls:     []string{"rename h1 a.mkv a (3).mkv", "move h1 /data/misc true"},
+		},
+		{
+			collision: CollisionIgnore,
+			want:      []string{"torrent 1 (a.mkv): move from /downloads to /data/misc"},
+			calls:     []string{"move h1 /data/misc true"},
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.collision.String(), func(t *testing.T) {
+			t.Parallel()
+
+			client := newClient()
+			o := newOrganizer(t, client)
+			o.Collision = tc.collision
+			o.Exists = exists
+
+			results, err := o.Organize(context.Background())
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := describe(results); !cmp.Equal(tc.want, got) {
+				t.Errorf("unexpected results, diff = \n%s", cmp.Diff(tc.want, got))
+			}
+			if !cmp.Equal(tc.calls, client.calls) {
+				t.Errorf("unexpected calls, diff = \n%s", cmp.Diff(tc.calls, client.calls))
+			}
+
+			// Skipped torrents are not retried.
+			if results, _ := o.Organize(context.Background()); len(results) != 0 {
+				t.Errorf("unexpected results: %v", describe(results))
+			}
+		})
+	}
+}
+
+func TestOrganizer_Organize_retry(t *testing.T) {
+	client := &fakeClient{
+		torrents: []*transmission.Torrent{
+			{ID: 1, Hash: "h1", Name: "a", DataDone: 1, DownloadDirectory: "/downloads"},
+		},
+		stuck: map[transmission.Hash]bool{"h1": true},
+	}
+
+	o := newOrganizer(t, client)
+	o.MaxAttempts = 2
+	o.RetryDelay = time.Nanosecond
+
+	// The move doesn't take effect, so it is retried once and then given up.
+	for i := 0; i < 2; i++ {
+		if results, _ := o.Organize(context.Background()); len(results) != 0 {
+			t.Errorf("unexpected results: %v", describe(results))
+		}
+	}
+	results, err := o.Organize(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []string{"torrent 1 (a): move from /downloads to /data/misc: " +
+		"organize: torrent is still in /downloads after 2 attempts"}
+	if got := describe(results); !cmp.Equal(want, got) {
+		t.Errorf("unexpected results, diff = \n%s", cmp.Diff(want, got))
+	}
+	if results, _ := o.Organize(context.Background()); len(results) != 0 {
+		t.Errorf("unexpected results: %v", describe(results))
+	}
+
+	// A move that takes effect later is verified by the next run.
+	o = newOrganizer(t, client)
+	o.RetryDelay = time.Hour
+	if results, _ := o.Organize(context.Background()); len(results) != 0 {
+		t.Errorf("unexpected results: %v", describe(results))
+	}
+	client.torrents[0].DownloadDirectory = "/data/misc"
+	results, err = o.Organize(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(results) != 1 || !results[0].Applied || results[0].Attempts != 1 {
+		t.Errorf("unexpected results: %v", describe(results))
+	}
+}
+
+func TestOrganizer_Organize_verifyError(t *testing.T) {
+	client := &fakeClient{
+		torrents: []*transmission.Torrent{
+			{ID: 1, Hash: "h1", Name: "a", DataDone: 1, DownloadDirectory: "/downloads"},
+		},
+		errByID: errors.New("daemon is down"),
+	}
+
+	o := newOrganizer(t, client)
+	if _, err := o.Organize(context.Background()); !errors.Is(err, client.errByID) {
+		t.Errorf("unexpected error, want = %v, got = %v", client.errByID, err)
+	}
+
+	// The move is verified by the next run.
+	client.errByID = nil
+	results, err := o.Organize(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(results) != 1 || !results[0].Applied {
+		t.Errorf("unexpected results: %v", describe(results))
+	}
+}
+
+func TestOrganizer_Run(t *testing.T) {
+	client := &fakeClient{
+		torrents: []*transmission.Torrent{
+			{ID: 1, Hash: "h1", Name: "a", DataDone: 1, DownloadDirectory: "/downloads"},
+		},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	o := newOrganizer(t, client)
+	o.Interval = time.Hour
+	o.OnResult = func(*Result) { cancel() }
+	if err := o.Run(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("unexpected error, want = %v, got = %v", context.Canceled, err)
+	}
+	if err := (&Organizer{}).Run(context.Background()); err == nil {
+		t.Errorf("expected organizer without client to fail")
+	}
+
+	client.err = errors.New("daemon is down")
+	if _, err := o.Organize(context.Background()); err == nil {
+		t.Errorf("expected Organize to fail")
+	}
+	if _, err := (&Organizer{Client: client}).Organize(context.Background()); err == nil {
+		t.Errorf("expected organizer without template to fail")
+	}
+}
@@ -0,0 +1,111 @@
+package organize
+
+import (
+	"fmt"
+	"path"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/pborzenkov/go-transmission/transmission"
+)
+
+// Vars are variables available in a Template. Name, Label, Labels and Tracker
+// never contain slashes and are never "." or "..", so they can't escape the
+// directory they are used in. Directory is used as reported by Transmission
+// and has no such guarantee.
+type Vars struct {
+	// Name of the torrent
+	Name string
+	// The first label of the torrent, or an empty string
+	Label string
+	// All labels of the torrent
+	Labels []string
+	// Host of the first tracker of the torrent, or an empty string
+	Tracker string
+	// Date when the torrent was completed, or added if unknown
+	Done time.Time
+	// Date when the torrent was added
+	Added time.Time
+	// Current download directory of the torrent, not sanitized
+	Directory string
+}
+
+func sanitize(s string) string {
+	s = strings.ReplaceAll(s, "/", "_")
+	if s == "." || s == ".." {
+		s = strings.Repeat("_", len(s))
+	}
+	return s
+}
+
+// VarsOf returns template variables of t.
+func VarsOf(t *transmission.Torrent) *Vars {
+	v := &Vars{
+		Name:      sanitize(t.Name),
+		Done:      t.DoneAt,
+		Added:     t.AddedAt,
+		Directory: t.DownloadDirectory,
+	}
+	for _, l := range t.Labels {
+		v.Labels = append(v.Labels, sanitize(l))
+	}
+	if len(v.Labels) > 0 {
+		v.Label = v.Labels[0]
+	}
+	var first *transmission.Tracker
+	for i := range t.Trackers {
+		if tr := &t.Trackers[i]; tr.AnnounceURL != nil && (first == nil || tr.Tier < first.Tier) {
+			first = tr
+		}
+	}
+	if first != nil {
+		v.Tracker = sanitize(first.AnnounceURL.Hostname())
+	}
+	if v.Done.IsZero() {
+		v.Done = v.Added
+	}
+	return v
+}
+
+var funcs = template.FuncMap{
+	// default returns def if v is empty: {{.Label | default "misc"}}
+	"default": func(def, v string) string {
+		if v == "" {
+			return def
+		}
+		return v
+	},
+	"lower": strings.ToLower,
+}
+
+// Template computes target directories of torrents. It is a text/template
+// executed with Vars that must produce an absolute path. Besides the
+// standard functions, "default" and "lower" are available, e.g.:
+//
+//	/data/{{.Label | default "misc"}}/{{.Tracker}}/{{.Done.Format "2006-01"}}
+type Template struct {
+	tmpl *template.Template
+}
+
+// ParseTemplate parses text into a Template.
+func ParseTemplate(text string) (*Template, error) {
+	tmpl, err := template.New("organize").Funcs(funcs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("organize: %w", err)
+	}
+	return &Template{tmpl: tmpl}, nil
+}
+
+// Execute returns the target directory of t.
+func (tt *Template) Execute(t *transmission.Torrent) (string, error) {
+	var sb strings.Builder
+	if err := tt.tmpl.Execute(&sb, VarsOf(t)); err != nil {
+		return "", fmt.Errorf("organize: %w", err)
+	}
+	dir := path.Clean(strings.TrimSpace(sb.String()))
+	if !path.IsAbs(dir) {
+		return "", fmt.Errorf("organize: target directory %q is not absolute", dir)
+	}
+	return dir, nil
+}
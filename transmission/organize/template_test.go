@@ -0,0 +1,99 @@
+package organize
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/pborzenkov/go-transmission/transmission"
+)
+
+func TestTemplate_Execute(t *testing.T) {
+	done := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	torrent := &transmission.Torrent{
+		Name:   "Some/Album",
+		Labels: []string{"Music", "flac"},
+		Trackers: []transmission.Tracker{
+			{Tier: 1, AnnounceURL: &url.URL{Scheme: "udp", Host: "backup.example.org:6969"}},
+			{Tier: 0, AnnounceURL: &url.URL{Scheme: "https", Host: "tracker.example.org", Path: "/announce"}},
+		},
+		DoneAt:            done,
+		DownloadDirectory: "/downloads",
+	}
+
+	var tests = []struct {
+		name    string
+		text    string
+		torrent *transmission.Torrent
+		want    string
+		wantErr bool
+	}{
+		{
+			name:    "label and tracker",
+			text:    "/data/{{.Label | lower}}/{{.Tracker}}",
+			torrent: torrent,
+			want:    "/data/music/tracker.example.org",
+		},
+		{
+			name:    "date and name",
+			text:    `/data/{{.Done.Format "2006/01"}}/{{.Name}}/`,
+			torrent: torrent,
+			want:    "/data/2024/03/Some_Album",
+		},
+		{
+			name:    "default",
+			text:    `/data/{{.Label | default "misc"}}{{.Tracker}}`,
+			torrent: &transmission.Torrent{},
+			want:    "/data/misc",
+		},
+		{
+			name:    "added date",
+			text:    `/data/{{.Done.Year}}`,
+			torrent: &transmission.Torrent{AddedAt: done},
+			want:    "/data/2024",
+		},
+		{
+			name:    "dot dot",
+			text:    "/data/{{.Label}}/{{.Name}}",
+			torrent: &transmission.Torrent{Name: "..", Labels: []string{"."}},
+			want:    "/data/_/__",
+		},
+		{
+			name:    "relative",
+			text:    "{{.Label}}",
+			torrent: torrent,
+			wantErr: true,
+		},
+		{
+			name:    "unknown variable",
+			text:    "/data/{{.Category}}",
+			torrent: torrent,
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			tmpl, err := ParseTemplate(tc.text)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			got, err := tmpl.Execute(tc.torrent)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tc.want {
+				t.Errorf("unexpected directory, want = %q, got = %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestParseTemplate_error(t *testing.T) {
+	if _, err := ParseTemplate("/data/{{.Label"); err == nil {
+		t.Errorf("expected ParseTemplate to fail")
+	}
+}
@@ -0,0 +1,176 @@
+package transmission
+
+import (
+	"path"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// pathTag marks struct fields holding filesystem paths on the daemon side.
+// Such fields are translated by the path mapping set with WithPathMapping.
+// Fields of type string, *string and []string are supported. Every new RPC
+// field holding a daemon path must have the tag, TestPathFields fails for
+// path-like fields without it.
+const pathTag = "path"
+
+type pathPrefix struct {
+	from, to string
+}
+
+func (p *pathPrefix) apply(s string) (string, bool) {
+	if s == p.from {
+		return p.to, true
+	}
+	prefix := p.from
+	if !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	if rest, ok := strings.CutPrefix(s, prefix); ok {
+		return strings.TrimSuffix(p.to, "/") + "/" + rest, true
+	}
+	return s, false
+}
+
+// pathMapper translates paths between the client and the daemon.
+type pathMapper struct {
+	toDaemon   []pathPrefix
+	fromDaemon []pathPrefix
+}
+
+func newPathMapper(mapping map[string]string) *pathMapper {
+	if len(mapping) == 0 {
+		return nil
+	}
+
+	m := new(pathMapper)
+	for local, remote := range mapping {
+		local, remote = path.Clean(local), path.Clean(remote)
+		m.toDaemon = append(m.toDaemon, pathPrefix{from: local, to: remote})
+		m.fromDaemon = append(m.fromDaemon, pathPrefix{from: remote, to: local})
+	}
+	// The longest matching prefix wins.
+	for _, prefixes := range [][]pathPrefix{m.toDaemon, m.fromDaemon} {
+		sort.Slice(prefixes, func(i, j int) bool {
+			if len(prefixes[i].from) != len(prefixes[j].from) {
+				return len(prefixes[i].from) > len(prefixes[j].from)
+			}
+			return prefixes[i].from < prefixes[j].from
+		})
+	}
+	return m
+}
+
+func mapPath(prefixes []pathPrefix, s string) string {
+	for i := range prefixes {
+		if mapped, ok := prefixes[i].apply(s); ok {
+			return mapped
+		}
+	}
+	return s
+}
+
+// args returns a copy of RPC arguments with paths translated to the daemon
+// side. The original arguments are left intact.
+func (m *pathMapper) args(args interface{}) interface{} {
+	if m == nil || args == nil {
+		return args
+	}
+	return rewritePaths(reflect.ValueOf(args), m.toDaemon, false).Interface()
+}
+
+// reply translates paths in a decoded RPC reply to the client side.
+func (m *pathMapper) reply(reply interface{}) {
+	if m == nil || reply == nil {
+		return
+	}
+	v := reflect.ValueOf(reply)
+	if v.Kind() != reflect.Pointer || v.IsNil() {
+		return
+	}
+	v.Elem().Set(rewritePaths(v.Elem(), m.fromDaemon, false))
+}
+
+// rewritePaths returns a copy of v with all paths translated. Values that
+// don't contain paths are returned as is.
+func rewritePaths(v reflect.Value, prefixes []pathPrefix, isPath bool) reflect.Value {
+	if !isPath && !hasPaths(v.Type()) {
+		return v
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		if isPath {
+			return reflect.ValueOf(mapPath(prefixes, v.String())).Convert(v.Type())
+		}
+	case reflect.Pointer:
+		if !v.IsNil() {
+			n := reflect.New(v.Type().Elem())
+			n.Elem().Set(rewritePaths(v.Elem(), prefixes, isPath))
+			return n
+		}
+	case reflect.Slice:
+		if !v.IsNil() {
+			n := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+			for i := 0; i < v.Len(); i++ {
+				n.Index(i).Set(rewritePaths(v.Index(i), prefixes, isPath))
+			}
+			return n
+		}
+	case reflect.Array:
+		n := reflect.New(v.Type()).Elem()
+		for i := 0; i < v.Len(); i++ {
+			n.Index(i).Set(rewritePaths(v.Index(i), prefixes, isPath))
+		}
+		return n
+	case reflect.Struct:
+		n := reflect.New(v.Type()).Elem()
+		n.Set(v)
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			if f := t.Field(i); f.IsExported() {
+				n.Field(i).Set(rewritePaths(v.Field(i), prefixes, isPathField(&f)))
+			}
+		}
+		return n
+	}
+	return v
+}
+
+func isPathField(f *reflect.StructField) bool {
+	_, ok := f.Tag.Lookup(pathTag)
+	return ok
+}
+
+var pathTypes sync.Map // reflect.Type -> bool
+
+// hasPaths reports whether values of type t may contain paths.
+func hasPaths(t reflect.Type) bool {
+	if has, ok := pathTypes.Load(t); ok {
+		return has.(bool)
+	}
+	has := typeHasPaths(t, make(map[reflect.Type]bool))
+	pathTypes.Store(t, has)
+	return has
+}
+
+func typeHasPaths(t reflect.Type, visiting map[reflect.Type]bool) bool {
+	if visiting[t] {
+		return false
+	}
+	visiting[t] = true
+
+	switch t.Kind() {
+	case reflect.Pointer, reflect.Slice, reflect.Array:
+		return typeHasPaths(t.Elem(), visiting)
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if f.IsExported() && (isPathField(&f) || typeHasPaths(f.Type, visiting)) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,189 @@
+package transmission
+
+import (
+	"context"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io"
+	"io/fs"
+	"net/http"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestPathMapper(t *testing.T) {
+	m := newPathMapper(map[string]string{
+		"/srv/media":        "/downloads",
+		"/srv/media/movies": "/movies/",
+		"/home/user":        "/",
+	})
+
+	var tests = []struct {
+		path     string
+		toDaemon string
+	}{
+		{path: "/srv/media", toDaemon: "/downloads"},
+		{path: "/srv/media/tv/show", toDaemon: "/downloads/tv/show"},
+		{path: "/srv/media/movies/film", toDaemon: "/movies/film"},
+		{path: "/srv/mediax", toDaemon: "/srv/mediax"},
+		{path: "/home/user/a", toDaemon: "/a"},
+		{path: "relative", toDaemon: "relative"},
+		{path: "https://example.org/a.torrent", toDaemon: "https://example.org/a.torrent"},
+	}
+
+	for _, tc := range tests {
+		if got := mapPath(m.toDaemon, tc.path); got != tc.toDaemon {
+			t.Errorf("unexpected daemon path of %q, want = %q, got = %q", tc.path, tc.toDaemon, got)
+		}
+		if tc.path != tc.toDaemon {
+			if got := mapPath(m.fromDaemon, tc.toDaemon); got != tc.path {
+				t.Errorf("unexpected client path of %q, want = %q, got = %q", tc.toDaemon, tc.path, got)
+			}
+		}
+	}
+
+	if newPathMapper(nil) != nil {
+		t.Errorf("expected empty mapping to be disabled")
+	}
+}
+
+func TestPathMapping(t *testing.T) {
+	client, handle, teardown := setup(t, WithPathMapping(map[string]string{"/srv/media": "/downloads"}))
+	defer teardown()
+
+	var calls []string
+	handle(func(w http.ResponseWriter, r *http.Request) {
+		data, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Errorf("failed to read request body: %v", err)
+			return
+		}
+		body := strings.TrimSpace(string(data))
+		calls = append(calls, body)
+
+		switch {
+		case strings.Contains(body, `"torrent-get"`):
+			fmt.Fprintf(w, `{"result":"success","arguments":{"torrents":[{"id":1,"downloadDir":"/downloads/tv"}]}}`)
+		case strings.Contains(body, `"session-get"`):
+			fmt.Fprintf(w, `{"result":"success","arguments":{"download-dir":"/downloads","incomplete-dir":"/tmp"}}`)
+		default:
+			fmt.Fprintf(w, `{"result":"success","arguments":{}}`)
+		}
+	})
+
+	ctx := context.Background()
+	if err := client.SetTorrentsLocation(ctx, ID(1), "/srv/media/movies", true); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := client.GetFreeSpace(ctx, "/srv/media"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	req := &SetSessionReq{DownloadDirectory: OptString("/srv/media/new")}
+	if err := client.SetSession(ctx, req); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want, got := "/srv/media/new", *req.DownloadDirectory; want != got {
+		t.Errorf("request is modified, want = %q, got = %q", want, got)
+	}
+
+	want := []string{
+		`{"method":"torrent-set-location","arguments":{"ids":1,"location":"/downloads/movies","move":true}}`,
+		`{"method":"free-space","arguments":{"path":"/downloads"}}`,
+		`{"method":"session-set","arguments":{"download-dir":"/downloads/new"}}`,
+	}
+	if !cmp.Equal(want, calls) {
+		t.Errorf("unexpected requests, diff = \n%s", cmp.Diff(want, calls))
+	}
+
+	torrents, err := client.GetTorrents(ctx, ID(1), TorrentFieldID, TorrentFieldDownloadDirectory)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want, got := "/srv/media/tv", torrents[0].DownloadDirectory; want != got {
+		t.Errorf("unexpected torrent download directory, want = %q, got = %q", want, got)
+	}
+
+	sess, err := client.GetSession(ctx, SessionFieldDownloadDirectory, SessionFieldIncompleteDirectory)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want, got := "/srv/media", sess.DownloadDirectory; want != got {
+		t.Errorf("unexpected session download directory, want = %q, got = %q", want, got)
+	}
+	if want, got := "/tmp", sess.IncompleteDirectory; want != got {
+		t.Errorf("unexpected session incomplete directory, want = %q, got = %q", want, got)
+	}
+}
+
+// notPaths lists path-like fields that must not be translated, keyed by file
+// and field name.
+var notPaths = map[string]bool{
+	// AddTorrentReq.URL is usually a URL or a magnet link
+	"torrent_add.go URL": true,
+	// RenameTorrentPath path is relative to the torrent
+	"torrent_actions.go Path": true,
+}
+
+// TestPathFields makes sure that fields of all RPC types that look like
+// paths are translated by the path mapping.
+func TestPathFields(t *testing.T) {
+	fset := token.NewFileSet()
+	pkgs, err := parser.ParseDir(fset, ".", func(fi fs.FileInfo) bool {
+		return !strings.HasSuffix(fi.Name(), "_test.go")
+	}, 0)
+	if err != nil {
+		t.Fatalf("failed to parse package: %v", err)
+	}
+
+	var checked int
+	ast.Inspect(pkgs["transmission"], func(n ast.Node) bool {
+		st, ok := n.(*ast.StructType)
+		if !ok {
+			return true
+		}
+		for _, f := range st.Fields.List {
+			if f.Tag == nil || len(f.Names) == 0 {
+				continue
+			}
+			tag := reflect.StructTag(strings.Trim(f.Tag.Value, "`"))
+			name, _, _ := strings.Cut(tag.Get("json"), ",")
+			if !pathLike(f.Names[0].Name, name) {
+				continue
+			}
+			checked++
+			pos := fset.Position(f.Pos())
+			_, tagged := tag.Lookup(pathTag)
+			switch skip := notPaths[filepath.Base(pos.Filename)+" "+f.Names[0].Name]; {
+			case skip && tagged:
+				t.Errorf("%s: %s must not have %q tag", pos, f.Names[0].Name, pathTag)
+			case !skip && !tagged:
+				t.Errorf("%s: %s looks like a path, but has no %q tag", pos, f.Names[0].Name, pathTag)
+			}
+		}
+		return true
+	})
+	if checked == 0 {
+		t.Errorf("no path fields found")
+	}
+}
+
+func pathLike(field, json string) bool {
+	for _, suffix := range []string{"Directory", "Path", "Location", "File"} {
+		if strings.HasSuffix(field, suffix) {
+			return true
+		}
+	}
+	json = strings.ToLower(json)
+	for _, s := range []string{"dir", "path", "location", "filename", "torrentfile"} {
+		if strings.HasSuffix(json, s) {
+			return true
+		}
+	}
+	return false
+}
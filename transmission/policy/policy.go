@@ -0,0 +1,190 @@
+// Package policy implements a rule-based seeding policy engine.
+//
+// Transmission supports a single ratio and idle limit per torrent. Engine
+// evaluates a list of declarative rules against torrents on a schedule and
+// stops, removes or relabels torrents that reached the goals of a rule.
+// Engine can also run in dry-run mode, reporting the actions it would take
+// without applying them.
+package policy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/pborzenkov/go-transmission/transmission"
+	"github.com/pborzenkov/go-transmission/transmission/internal/periodic"
+)
+
+const defaultInterval = 10 * time.Minute
+
+// Client is a subset of *transmission.Client used by Engine.
+type Client interface {
+	GetTorrents(ctx context.Context, ids transmission.Identifier, fields ...transmission.TorrentField) ([]*transmission.Torrent, error) //nolint:lll
+	StopTorrents(ctx context.Context, ids transmission.Identifier) error
+	RemoveTorrents(ctx context.Context, ids transmission.Identifier, removeData bool) error
+	SetTorrents(ctx context.Context, ids transmission.Identifier, req *transmission.SetTorrentReq) error
+}
+
+var _ Client = (*transmission.Client)(nil)
+
+// Result describes an action taken, or planned, for a single torrent.
+type Result struct {
+	// ID of the torrent
+	ID transmission.ID
+	// Hash of the torrent
+	Hash transmission.Hash
+	// Name of the torrent
+	Name string
+	// Name of the rule that made the torrent due
+	Rule string
+	// Action to apply
+	Action Action
+	// Description of the goals the torrent reached
+	Reason string
+	// Remove downloaded data together with the torrent (ActionRemove only)
+	RemoveData bool
+	// New labels of the torrent (ActionRelabel only)
+	Labels []string
+	// Indicates whether the action was applied
+	Applied bool
+	// An error that prevented the action from being applied
+	Err error
+}
+
+// String returns a human readable description of the result.
+func (r *Result) String() string {
+	action := r.Action.String()
+	if r.Action == ActionRelabel {
+		action = fmt.Sprintf("relabel to %q", r.Labels)
+	}
+	s := fmt.Sprintf("torrent %d (%s): %s by rule %q (%s)", r.ID, r.Name, action, r.Rule, r.Reason)
+	switch {
+	case r.Err != nil:
+		s += fmt.Sprintf(": %v", r.Err)
+	case !r.Applied:
+		s += " [dry run]"
+	}
+	return s
+}
+
+// Engine evaluates rules against torrents and applies their actions.
+type Engine struct {
+	// Transmission client
+	Client Client
+	// Rules to evaluate. For every torrent, the first rule that selects it,
+	// considers it due and would change it is used
+	Rules []Rule
+	// Only report actions, don't apply them
+	DryRun bool
+	// Interval between evaluations in Run. If 0, 10 minutes is used
+	Interval time.Duration
+	// Called with every result
+	OnResult func(*Result)
+	// Called with errors that prevented Run from evaluating the rules
+	OnError func(error)
+}
+
+// Plan evaluates the rules against all torrents and returns the actions that
+// are due, without applying them.
+func (e *Engine) Plan(ctx context.Context) ([]*Result, error) {
+	torrents, err := e.Client.GetTorrents(ctx, transmission.All(), Fields...)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []*Result
+	for _, t := range torrents {
+		if res := e.evaluate(t); res != nil {
+			results = append(results, res)
+		}
+	}
+	return results, nil
+}
+
+func (e *Engine) evaluate(t *transmission.Torrent) *Result {
+	for i := range e.Rules {
+		r := &e.Rules[i]
+		if !r.Selects(t) {
+			continue
+		}
+		due, reason := r.Due(t)
+		if !due {
+			continue
+		}
+
+		res := &Result{ID: t.ID, Hash: t.Hash, Name: t.Name, Rule: r.Name, Action: r.Action, Reason: reason}
+		switch r.Action {
+		case ActionStop:
+			if t.Status == transmission.StatusStopped {
+				continue
+			}
+		case ActionRelabel:
+			var changed bool
+			if res.Labels, changed = r.relabel(t); !changed {
+				continue
+			}
+		case ActionRemove:
+			res.RemoveData = r.RemoveData
+		default:
+			res.Err = fmt.Errorf("policy: unknown action %d in rule %q", r.Action, r.Name)
+		}
+		return res
+	}
+	return nil
+}
+
+// Apply evaluates the rules and applies the due actions, unless DryRun is
+// set. A failure to apply an action to a torrent doesn't stop the process,
+// instead it is reported in the corresponding result.
+func (e *Engine) Apply(ctx context.Context) ([]*Result, error) {
+	results, err := e.Plan(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, res := range results {
+		if res.Err == nil && !e.DryRun {
+			if res.Err = e.apply(ctx, res); res.Err == nil {
+				res.Applied = true
+			}
+		}
+		if e.OnResult != nil {
+			e.OnResult(res)
+		}
+	}
+	return results, nil
+}
+
+func (e *Engine) apply(ctx context.Context, res *Result) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	switch res.Action {
+	case ActionStop:
+		return e.Client.StopTorrents(ctx, res.ID)
+	case ActionRemove:
+		return e.Client.RemoveTorrents(ctx, res.ID, res.RemoveData)
+	case ActionRelabel:
+		return e.Client.SetTorrents(ctx, res.ID, &transmission.SetTorrentReq{Labels: res.Labels})
+	}
+	return nil
+}
+
+// Run applies the rules right away and then every Interval until ctx is done.
+// It returns ctx.Err(). Per-torrent failures go to OnResult, and errors of a
+// whole pass go to OnError.
+func (e *Engine) Run(ctx context.Context) error {
+	if e.Client == nil {
+		return errors.New("policy: client is not set")
+	}
+	interval := e.Interval
+	if interval <= 0 {
+		interval = defaultInterval
+	}
+	return periodic.Run(ctx, interval, func(ctx context.Context) error {
+		_, err := e.Apply(ctx)
+		return err
+	}, e.OnError)
+}
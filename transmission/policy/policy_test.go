@@ -0,0 +1,233 @@
+package policy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pborzenkov/go-transmission/transmission"
+)
+
+type fakeClient struct {
+	mu       sync.Mutex
+	torrents []*transmission.Torrent
+	calls    []string
+	err      error
+}
+
+func (c *fakeClient) GetTorrents(ctx context.Context, ids transmission.Identifier, fields ...transmission.TorrentField) ([]*transmission.Torrent, error) { //nolint:lll
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.err != nil {
+		return nil, c.err
+	}
+	return c.torrents, nil
+}
+
+func (c *fakeClient) record(call string, args ...any) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.calls = append(c.calls, fmt.Sprint(append([]any{call}, args...)...))
+	return nil
+}
+
+func (c *fakeClient) StopTorrents(ctx context.Context, ids transmission.Identifier) error {
+	return c.record("stop ", ids)
+}
+
+func (c *fakeClient) RemoveTorrents(ctx context.Context, ids transmission.Identifier, removeData bool) error {
+	if ids == transmission.ID(4) {
+		return errors.New("failed")
+	}
+	return c.record("remove ", ids, " ", removeData)
+}
+
+func (c *fakeClient) SetTorrents(ctx context.Context, ids transmission.Identifier, req *transmission.SetTorrentReq) error { //nolint:lll
+	return c.record("set ", ids, " ", req.Labels)
+}
+
+func testEngine() (*Engine, *fakeClient) {
+	client := &fakeClient{
+		torrents: []*transmission.Torrent{
+			{ID: 1, Name: "private", IsPrivate: true, DataDone: 1, SeedingFor: 80 * time.Hour, UploadRatio: 1.2},
+			{ID: 2, Name: "private young", IsPrivate: true, DataDone: 1, SeedingFor: time.Hour, UploadRatio: 3},
+			{ID: 3, Name: "public", DataDone: 1, SeedingFor: time.Hour, UploadRatio: 2.5},
+			{ID: 4, Name: "public old", DataDone: 1, SeedingFor: 15 * 24 * time.Hour, Labels: []string{"keep"},
+				Status: transmission.StatusSeed},
+			{ID: 5, Name: "public stopped", DataDone: 1, UploadRatio: 3, Labels: []string{"keep"}},
+		},
+	}
+	engine := &Engine{
+		Client: client,
+		Rules: []Rule{
+			{
+				Name:          "private",
+				Privacy:       PrivacyPrivate,
+				MinSeedingFor: 72 * time.Hour,
+				MinRatio:      1,
+				Action:        ActionRemove,
+				RemoveData:    true,
+			},
+			{
+				Name:          "keep",
+				Labels:        []string{"keep"},
+				MaxSeedingFor: 14 * 24 * time.Hour,
+				MaxRatio:      2,
+				Action:        ActionStop,
+			},
+			{
+				Name:          "public",
+				Privacy:       PrivacyPublic,
+				MaxSeedingFor: 14 * 24 * time.Hour,
+				MaxRatio:      2,
+				Action:        ActionRelabel,
+				AddLabels:     []string{"seeded"},
+			},
+		},
+	}
+	return engine, client
+}
+
+func TestEngine_Apply(t *testing.T) {
+	engine, client := testEngine()
+
+	var reported int
+	engine.OnResult = func(*Result) { reported++ }
+
+	results, err := engine.Apply(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []*Result{
+		{
+			ID:         1,
+			Name:       "private",
+			Rule:       "private",
+			Action:     ActionRemove,
+			Reason:     "seeding for 80h0m0s >= 72h0m0s, ratio 1.20 >= 1.00",
+			RemoveData: true,
+			Applied:    true,
+		},
+		{
+			ID:      3,
+			Name:    "public",
+			Rule:    "public",
+			Action:  ActionRelabel,
+			Reason:  "ratio 2.50 >= 2.00",
+			Labels:  []string{"seeded"},
+			Applied: true,
+		},
+		{
+			ID:      4,
+			Name:    "public old",
+			Rule:    "keep",
+			Action:  ActionStop,
+			Reason:  "seeding for 360h0m0s >= 336h0m0s",
+			Applied: true,
+		},
+		{
+			ID:      5,
+			Name:    "public stopped",
+			Rule:    "public",
+			Action:  ActionRelabel,
+			Reason:  "ratio 3.00 >= 2.00",
+			Labels:  []string{"keep", "seeded"},
+			Applied: true,
+		},
+	}
+	if !cmp.Equal(want, results) {
+		t.Errorf("unexpected results, diff = \n%s", cmp.Diff(want, results))
+	}
+	if want, got := len(results), reported; want != got {
+		t.Errorf("unexpected number of reported results, want = %d, got = %d", want, got)
+	}
+
+	wantCalls := []string{
+		"remove 1 true",
+		"set 3 [seeded]",
+		"stop 4",
+		"set 5 [keep seeded]",
+	}
+	if !cmp.Equal(wantCalls, client.calls) {
+		t.Errorf("unexpected calls, diff = \n%s", cmp.Diff(wantCalls, client.calls))
+	}
+}
+
+func TestEngine_Apply_dryRun(t *testing.T) {
+	engine, client := testEngine()
+	engine.DryRun = true
+
+	results, err := engine.Apply(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want, got := 4, len(results); want != got {
+		t.Fatalf("unexpected number of results, want = %d, got = %d", want, got)
+	}
+	for _, res := range results {
+		if res.Applied {
+			t.Errorf("expected %v not to be applied", res)
+		}
+	}
+	if len(client.calls) != 0 {
+		t.Errorf("expected no calls in dry run, got = %v", client.calls)
+	}
+
+	want := `torrent 1 (private): remove by rule "private" (seeding for 80h0m0s >= 72h0m0s, ratio 1.20 >= 1.00) [dry run]`
+	if got := results[0].String(); want != got {
+		t.Errorf("unexpected string, want = %q, got = %q", want, got)
+	}
+}
+
+func TestEngine_Apply_errors(t *testing.T) {
+	engine, client := testEngine()
+	engine.Rules = []Rule{{Name: "all", Action: ActionRemove}}
+
+	results, err := engine.Apply(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want, got := 5, len(results); want != got {
+		t.Fatalf("unexpected number of results, want = %d, got = %d", want, got)
+	}
+	if res := results[3]; res.Applied || res.Err == nil {
+		t.Errorf("expected removal of torrent 4 to fail, got = %v", res)
+	}
+	if want, got := 4, len(client.calls); want != got {
+		t.Errorf("unexpected number of calls, want = %d, got = %d", want, got)
+	}
+
+	engine.Rules = []Rule{{Name: "bad", Action: Action(10)}}
+	results, _ = engine.Apply(context.Background())
+	if len(results) == 0 || results[0].Err == nil {
+		t.Errorf("expected unknown action to fail")
+	}
+
+	client.err = errors.New("daemon is down")
+	if _, err := engine.Apply(context.Background()); err == nil {
+		t.Errorf("expected Apply to fail")
+	}
+}
+
+func TestEngine_Run(t *testing.T) {
+	engine, _ := testEngine()
+	engine.DryRun = true
+	engine.Interval = time.Hour
+
+	ctx, cancel := context.WithCancel(context.Background())
+	engine.OnResult = func(*Result) { cancel() }
+
+	if err := engine.Run(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("unexpected error, want = %v, got = %v", context.Canceled, err)
+	}
+	if err := (&Engine{}).Run(context.Background()); err == nil {
+		t.Errorf("expected engine without client to fail")
+	}
+}
@@ -0,0 +1,208 @@
+package policy
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/pborzenkov/go-transmission/transmission"
+)
+
+// Privacy selects torrents by their private flag.
+type Privacy int
+
+const (
+	// PrivacyAny selects both private and public torrents
+	PrivacyAny Privacy = iota
+	// PrivacyPrivate selects private torrents only
+	PrivacyPrivate
+	// PrivacyPublic selects public torrents only
+	PrivacyPublic
+)
+
+// String implements fmt.Stringer.
+func (p Privacy) String() string {
+	switch p {
+	case PrivacyAny:
+		return "any"
+	case PrivacyPrivate:
+		return "private"
+	case PrivacyPublic:
+		return "public"
+	default:
+		return fmt.Sprintf("Privacy(%d)", p)
+	}
+}
+
+// Action is an action applied to torrents that are due according to a rule.
+type Action int
+
+const (
+	// ActionStop stops the torrent
+	ActionStop Action = iota
+	// ActionRemove removes the torrent
+	ActionRemove
+	// ActionRelabel changes labels of the torrent
+	ActionRelabel
+)
+
+// String implements fmt.Stringer.
+func (a Action) String() string {
+	switch a {
+	case ActionStop:
+		return "stop"
+	case ActionRemove:
+		return "remove"
+	case ActionRelabel:
+		return "relabel"
+	default:
+		return fmt.Sprintf("Action(%d)", a)
+	}
+}
+
+// Rule is a declarative seeding policy. The selector fields choose torrents
+// the rule applies to, the goal fields decide when a selected torrent is due.
+// A torrent is due once all of the Min goals are reached and, if any of the
+// Max goals is set, at least one of them is reached. A rule without goals
+// makes every selected torrent due. Only torrents that finished downloading
+// are ever due.
+//
+// For example, "seed private torrents for at least 72h and to ratio 1.0, then
+// remove them" is
+//
+//	Rule{Privacy: PrivacyPrivate, MinSeedingFor: 72 * time.Hour, MinRatio: 1, Action: ActionRemove}
+//
+// and "remove public torrents at ratio 2.0 or after 14 days" is
+//
+//	Rule{Privacy: PrivacyPublic, MaxRatio: 2, MaxSeedingFor: 14 * 24 * time.Hour, Action: ActionRemove}
+type Rule struct {
+	// Name of the rule
+	Name string
+
+	// Select torrents by their private flag
+	Privacy Privacy
+	// Select torrents that have all of these labels
+	Labels []string
+	// Select torrents that have none of these labels
+	ExcludeLabels []string
+	// Select torrents that have a tracker on one of these hosts or their
+	// subdomains. If empty, trackers are not checked
+	Trackers []string
+
+	// The torrent must seed at least that long
+	MinSeedingFor time.Duration
+	// The torrent must reach at least that upload ratio
+	MinRatio float64
+	// The torrent is due after seeding that long
+	MaxSeedingFor time.Duration
+	// The torrent is due after reaching that upload ratio
+	MaxRatio float64
+
+	// Action to apply to due torrents
+	Action Action
+	// Remove downloaded data together with the torrent (ActionRemove only)
+	RemoveData bool
+	// Labels to add (ActionRelabel only)
+	AddLabels []string
+	// Labels to remove (ActionRelabel only)
+	RemoveLabels []string
+}
+
+// Fields is a list of torrent fields required to evaluate rules.
+var Fields = []transmission.TorrentField{
+	transmission.TorrentFieldID,
+	transmission.TorrentFieldHash,
+	transmission.TorrentFieldName,
+	transmission.TorrentFieldStatus,
+	transmission.TorrentFieldLabels,
+	transmission.TorrentFieldDataDone,
+	transmission.TorrentFieldUploadRatio,
+	transmission.TorrentFieldSeedingFor,
+	transmission.TorrentFieldIsPrivate,
+	transmission.TorrentFieldTrackerStats,
+}
+
+// Selects returns true if the rule applies to t.
+func (r *Rule) Selects(t *transmission.Torrent) bool {
+	switch {
+	case r.Privacy == PrivacyPrivate && !t.IsPrivate,
+		r.Privacy == PrivacyPublic && t.IsPrivate:
+		return false
+	}
+	for _, l := range r.Labels {
+		if !slices.Contains(t.Labels, l) {
+			return false
+		}
+	}
+	for _, l := range r.ExcludeLabels {
+		if slices.Contains(t.Labels, l) {
+			return false
+		}
+	}
+	return len(r.Trackers) == 0 || transmission.MatchTracker(t, r.Trackers...)
+}
+
+// Due reports whether t, that the rule selects, reached the goals of the rule.
+// The reason describes the reached goals.
+func (r *Rule) Due(t *transmission.Torrent) (due bool, reason string) {
+	if t.DataDone < 1 {
+		return false, ""
+	}
+
+	var reasons []string
+	if r.MinSeedingFor > 0 {
+		if t.SeedingFor < r.MinSeedingFor {
+			return false, ""
+		}
+		reasons = append(reasons, fmt.Sprintf("seeding for %s >= %s", t.SeedingFor, r.MinSeedingFor))
+	}
+	if r.MinRatio > 0 {
+		if t.UploadRatio < r.MinRatio {
+			return false, ""
+		}
+		reasons = append(reasons, fmt.Sprintf("ratio %.2f >= %.2f", t.UploadRatio, r.MinRatio))
+	}
+
+	if r.MaxSeedingFor > 0 || r.MaxRatio > 0 {
+		switch {
+		case r.MaxSeedingFor > 0 && t.SeedingFor >= r.MaxSeedingFor:
+			reasons = append(reasons, fmt.Sprintf("seeding for %s >= %s", t.SeedingFor, r.MaxSeedingFor))
+		case r.MaxRatio > 0 && t.UploadRatio >= r.MaxRatio:
+			reasons = append(reasons, fmt.Sprintf("ratio %.2f >= %.2f", t.UploadRatio, r.MaxRatio))
+		default:
+			return false, ""
+		}
+	}
+
+	if len(reasons) == 0 {
+		return true, "no goals"
+	}
+	return true, strings.Join(reasons, ", ")
+}
+
+// relabel returns the new labels of t and true if they differ from the
+// current ones.
+func (r *Rule) relabel(t *transmission.Torrent) ([]string, bool) {
+	labels := make([]string, 0, len(t.Labels)+len(r.AddLabels))
+	for _, l := range t.Labels {
+		if !slices.Contains(r.RemoveLabels, l) && !slices.Contains(labels, l) {
+			labels = append(labels, l)
+		}
+	}
+	for _, l := range r.AddLabels {
+		if !slices.Contains(labels, l) {
+			labels = append(labels, l)
+		}
+	}
+
+	if len(labels) != len(t.Labels) {
+		return labels, true
+	}
+	for i := range labels {
+		if labels[i] != t.Labels[i] {
+			return labels, true
+		}
+	}
+	return labels, false
+}
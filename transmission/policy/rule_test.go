@@ -0,0 +1,159 @@
+package policy
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pborzenkov/go-transmission/transmission"
+)
+
+func trackerStats(t *testing.T, announce ...string) []transmission.TrackerStat {
+	t.Helper()
+
+	stats := make([]transmission.TrackerStat, 0, len(announce))
+	for _, a := range announce {
+		u, err := url.Parse(a)
+		if err != nil {
+			t.Fatalf("failed to parse %q: %v", a, err)
+		}
+		stats = append(stats, transmission.TrackerStat{AnnounceURL: u})
+	}
+	return stats
+}
+
+func TestRule_Selects(t *testing.T) {
+	torrent := &transmission.Torrent{
+		Labels:       []string{"tv", "hd"},
+		IsPrivate:    true,
+		TrackerStats: trackerStats(t, "https://tracker.Example.org:443/announce"),
+	}
+
+	var tests = []struct {
+		name string
+		rule Rule
+		want bool
+	}{
+		{name: "empty", rule: Rule{}, want: true},
+		{name: "private", rule: Rule{Privacy: PrivacyPrivate}, want: true},
+		{name: "public", rule: Rule{Privacy: PrivacyPublic}, want: false},
+		{name: "labels", rule: Rule{Labels: []string{"hd", "tv"}}, want: true},
+		{name: "missing label", rule: Rule{Labels: []string{"tv", "movies"}}, want: false},
+		{name: "excluded label", rule: Rule{ExcludeLabels: []string{"hd"}}, want: false},
+		{name: "tracker", rule: Rule{Trackers: []string{"tracker.example.org"}}, want: true},
+		{name: "tracker domain", rule: Rule{Trackers: []string{"other.net", "example.org"}}, want: true},
+		{name: "tracker suffix", rule: Rule{Trackers: []string{"ample.org"}}, want: false},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.rule.Selects(torrent); got != tc.want {
+				t.Errorf("unexpected result, want = %v, got = %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestRule_Due(t *testing.T) {
+	private := Rule{MinSeedingFor: 72 * time.Hour, MinRatio: 1}
+	public := Rule{MaxSeedingFor: 14 * 24 * time.Hour, MaxRatio: 2}
+
+	var tests = []struct {
+		name    string
+		rule    Rule
+		torrent transmission.Torrent
+		want    bool
+		reason  string
+	}{
+		{
+			name:    "no goals",
+			torrent: transmission.Torrent{DataDone: 1},
+			want:    true,
+			reason:  "no goals",
+		},
+		{
+			name:    "incomplete",
+			torrent: transmission.Torrent{DataDone: 0.5, UploadRatio: 3},
+			want:    false,
+		},
+		{
+			name:    "min reached",
+			rule:    private,
+			torrent: transmission.Torrent{DataDone: 1, SeedingFor: 80 * time.Hour, UploadRatio: 1.5},
+			want:    true,
+			reason:  "seeding for 80h0m0s >= 72h0m0s, ratio 1.50 >= 1.00",
+		},
+		{
+			name:    "min partially reached",
+			rule:    private,
+			torrent: transmission.Torrent{DataDone: 1, SeedingFor: 80 * time.Hour, UploadRatio: 0.5},
+			want:    false,
+		},
+		{
+			name:    "max ratio reached",
+			rule:    public,
+			torrent: transmission.Torrent{DataDone: 1, SeedingFor: time.Hour, UploadRatio: 2},
+			want:    true,
+			reason:  "ratio 2.00 >= 2.00",
+		},
+		{
+			name:    "max time reached",
+			rule:    public,
+			torrent: transmission.Torrent{DataDone: 1, SeedingFor: 15 * 24 * time.Hour},
+			want:    true,
+			reason:  "seeding for 360h0m0s >= 336h0m0s",
+		},
+		{
+			name:    "max not reached",
+			rule:    public,
+			torrent: transmission.Torrent{DataDone: 1, SeedingFor: time.Hour, UploadRatio: 1},
+			want:    false,
+		},
+		{
+			name:    "min and max",
+			rule:    Rule{MinSeedingFor: time.Hour, MaxRatio: 2},
+			torrent: transmission.Torrent{DataDone: 1, SeedingFor: 30 * time.Minute, UploadRatio: 5},
+			want:    false,
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			due, reason := tc.rule.Due(&tc.torrent)
+			if due != tc.want {
+				t.Errorf("unexpected due, want = %v, got = %v", tc.want, due)
+			}
+			if reason != tc.reason {
+				t.Errorf("unexpected reason, want = %q, got = %q", tc.reason, reason)
+			}
+		})
+	}
+}
+
+func TestRule_relabel(t *testing.T) {
+	r := Rule{AddLabels: []string{"seeded", "tv"}, RemoveLabels: []string{"new"}}
+
+	labels, changed := r.relabel(&transmission.Torrent{Labels: []string{"tv", "new"}})
+	if want := []string{"tv", "seeded"}; !changed || !cmp.Equal(want, labels) {
+		t.Errorf("unexpected labels (changed = %v), diff = \n%s", changed, cmp.Diff(want, labels))
+	}
+
+	if _, changed := r.relabel(&transmission.Torrent{Labels: []string{"tv", "seeded"}}); changed {
+		t.Errorf("expected labels to be unchanged")
+	}
+}
+
+func TestStrings(t *testing.T) {
+	if want, got := "relabel", ActionRelabel.String(); want != got {
+		t.Errorf("unexpected action, want = %q, got = %q", want, got)
+	}
+	if want, got := "Action(10)", Action(10).String(); want != got {
+		t.Errorf("unexpected action, want = %q, got = %q", want, got)
+	}
+	if want, got := "private", PrivacyPrivate.String(); want != got {
+		t.Errorf("unexpected privacy, want = %q, got = %q", want, got)
+	}
+}
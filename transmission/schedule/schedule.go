@@ -0,0 +1,166 @@
+// Package schedule implements a weekly bandwidth scheduler.
+//
+// Transmission's own "turtle" schedule supports a single alternate speed
+// window. Schedule describes a weekly calendar of rate limit profiles with
+// any number of windows per day and per-date overrides for holidays.
+// Scheduler applies the profiles to a Transmission session at transitions,
+// reconciles the session on startup and backs off when the user changes the
+// limits manually.
+package schedule
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/pborzenkov/go-transmission/transmission"
+)
+
+const day = 24 * time.Hour
+
+// Profile is a set of rate limits.
+type Profile struct {
+	// Download rate limit (bytes/s). If 0, download rate is unlimited
+	DownloadRateLimit int64
+	// Upload rate limit (bytes/s). If 0, upload rate is unlimited
+	UploadRateLimit int64
+}
+
+func (p *Profile) request() *transmission.SetSessionReq {
+	req := &transmission.SetSessionReq{
+		DownloadRateLimitEnabled: transmission.OptBool(p.DownloadRateLimit > 0),
+		UploadRateLimitEnabled:   transmission.OptBool(p.UploadRateLimit > 0),
+	}
+	if p.DownloadRateLimit > 0 {
+		req.DownloadRateLimit = transmission.OptInt64(p.DownloadRateLimit)
+	}
+	if p.UploadRateLimit > 0 {
+		req.UploadRateLimit = transmission.OptInt64(p.UploadRateLimit)
+	}
+	return req
+}
+
+// Window is a part of a day with its own profile.
+type Window struct {
+	// Start of the window as an offset from midnight
+	Start time.Duration
+	// End of the window as an offset from midnight, not included in the
+	// window. Windows can't span midnight, use 24h to end a window at
+	// midnight
+	End time.Duration
+	// Name of the profile
+	Profile string
+}
+
+// Date is a calendar date.
+type Date struct {
+	Year  int
+	Month time.Month
+	Day   int
+}
+
+// DateOf returns the date of t in t's location.
+func DateOf(t time.Time) Date {
+	y, m, d := t.Date()
+	return Date{Year: y, Month: m, Day: d}
+}
+
+// String returns the date in YYYY-MM-DD format.
+func (d Date) String() string {
+	return fmt.Sprintf("%04d-%02d-%02d", d.Year, d.Month, d.Day)
+}
+
+// Schedule is a weekly calendar of profiles.
+type Schedule struct {
+	// Profiles by name
+	Profiles map[string]Profile
+	// Name of the profile used outside of windows
+	Default string
+	// Windows of every day of the week, indexed by time.Weekday
+	Week [7][]Window
+	// Windows that replace the windows of the week on specific dates
+	Holidays map[Date][]Window
+	// Time zone of the schedule. If nil, time.Local is used
+	Location *time.Location
+}
+
+// Validate checks that all referenced profiles exist and that windows of
+// every day are well formed and don't overlap.
+func (s *Schedule) Validate() error {
+	if _, ok := s.Profiles[s.Default]; !ok {
+		return fmt.Errorf("schedule: unknown default profile %q", s.Default)
+	}
+	for wd, windows := range s.Week {
+		if err := s.validateDay(windows); err != nil {
+			return fmt.Errorf("schedule: %s: %w", time.Weekday(wd), err)
+		}
+	}
+	for d, windows := range s.Holidays {
+		if err := s.validateDay(windows); err != nil {
+			return fmt.Errorf("schedule: %s: %w", d, err)
+		}
+	}
+	return nil
+}
+
+func (s *Schedule) validateDay(windows []Window) error {
+	sorted := make([]Window, len(windows))
+	copy(sorted, windows)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Start < sorted[j].Start })
+
+	for i, w := range sorted {
+		if _, ok := s.Profiles[w.Profile]; !ok {
+			return fmt.Errorf("unknown profile %q", w.Profile)
+		}
+		if w.Start < 0 || w.End > day || w.Start >= w.End {
+			return fmt.Errorf("invalid window %s-%s", w.Start, w.End)
+		}
+		if i > 0 && sorted[i-1].End > w.Start {
+			return errors.New("overlapping windows")
+		}
+	}
+	return nil
+}
+
+func (s *Schedule) location() *time.Location {
+	if s.Location != nil {
+		return s.Location
+	}
+	return time.Local
+}
+
+// At returns the name of the profile active at t and the time of the next
+// window boundary after t. The profile doesn't necessarily change at that
+// time.
+func (s *Schedule) At(t time.Time) (profile string, next time.Time) {
+	t = t.In(s.location())
+	y, m, d := t.Date()
+	midnight := time.Date(y, m, d, 0, 0, 0, 0, t.Location())
+	// Wall clock offset, which differs from t.Sub(midnight) on DST changes.
+	offset := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute +
+		time.Duration(t.Second())*time.Second + time.Duration(t.Nanosecond())
+	// time.Date normalizes the overflowing nanoseconds on the wall clock.
+	clock := func(off time.Duration) time.Time { return time.Date(y, m, d, 0, 0, 0, int(off), t.Location()) }
+
+	windows, ok := s.Holidays[DateOf(t)]
+	if !ok {
+		windows = s.Week[t.Weekday()]
+	}
+
+	profile, next = s.Default, midnight.AddDate(0, 0, 1)
+	for _, w := range windows {
+		switch {
+		case w.Start <= offset && offset < w.End:
+			profile = w.Profile
+			if end := clock(w.End); end.Before(next) {
+				next = end
+			}
+		case w.Start > offset:
+			if start := clock(w.Start); start.Before(next) {
+				next = start
+			}
+		}
+	}
+	return profile, next
+}
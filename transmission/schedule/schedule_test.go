@@ -0,0 +1,170 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+)
+
+func testSchedule(t *testing.T) *Schedule {
+	t.Helper()
+
+	loc, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skipf("time zone database is not available: %v", err)
+	}
+
+	workday := []Window{
+		{Start: 8 * time.Hour, End: 12 * time.Hour, Profile: "slow"},
+		{Start: 13 * time.Hour, End: 18 * time.Hour, Profile: "slow"},
+		{Start: 22 * time.Hour, End: 24 * time.Hour, Profile: "night"},
+	}
+	return &Schedule{
+		Profiles: map[string]Profile{
+			"fast":  {},
+			"slow":  {DownloadRateLimit: 100_000, UploadRateLimit: 10_000},
+			"night": {UploadRateLimit: 1_000_000},
+		},
+		Default: "fast",
+		Week: [7][]Window{
+			time.Monday:    workday,
+			time.Tuesday:   workday,
+			time.Wednesday: workday,
+			time.Thursday:  workday,
+			time.Friday:    workday,
+			time.Saturday:  {{Start: 0, End: 2 * time.Hour, Profile: "night"}},
+		},
+		Holidays: map[Date][]Window{
+			{Year: 2023, Month: time.December, Day: 25}: nil,
+			// Clocks are turned back from 3:00 to 2:00 on that day.
+			{Year: 2023, Month: time.October, Day: 29}: {{Start: 4 * time.Hour, End: 5 * time.Hour, Profile: "slow"}},
+		},
+		Location: loc,
+	}
+}
+
+func TestSchedule_At(t *testing.T) {
+	s := testSchedule(t)
+	at := func(month time.Month, day, hour, minute int) time.Time {
+		return time.Date(2023, month, day, hour, minute, 0, 0, s.Location)
+	}
+
+	var tests = []struct {
+		name    string
+		at      time.Time
+		profile string
+		next    time.Time
+	}{
+		{
+			name:    "before first window",
+			at:      at(time.October, 16, 7, 0),
+			profile: "fast",
+			next:    at(time.October, 16, 8, 0),
+		},
+		{
+			name:    "window start",
+			at:      at(time.October, 16, 8, 0),
+			profile: "slow",
+			next:    at(time.October, 16, 12, 0),
+		},
+		{
+			name:    "between windows",
+			at:      at(time.October, 16, 12, 30),
+			profile: "fast",
+			next:    at(time.October, 16, 13, 0),
+		},
+		{
+			name:    "until midnight",
+			at:      at(time.October, 16, 23, 0),
+			profile: "night",
+			next:    at(time.October, 17, 0, 0),
+		},
+		{
+			name:    "other time zone",
+			at:      time.Date(2023, time.October, 16, 10, 30, 0, 0, time.UTC),
+			profile: "fast",
+			next:    at(time.October, 16, 13, 0),
+		},
+		{
+			name:    "empty day",
+			at:      at(time.October, 22, 10, 0),
+			profile: "fast",
+			next:    at(time.October, 23, 0, 0),
+		},
+		{
+			name:    "holiday",
+			at:      at(time.December, 25, 10, 0),
+			profile: "fast",
+			next:    at(time.December, 26, 0, 0),
+		},
+		{
+			name:    "DST change",
+			at:      at(time.October, 29, 4, 30),
+			profile: "slow",
+			next:    at(time.October, 29, 5, 0),
+		},
+		{
+			name:    "after DST change",
+			at:      at(time.October, 30, 7, 0),
+			profile: "fast",
+			next:    at(time.October, 30, 8, 0),
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			profile, next := s.At(tc.at)
+			if profile != tc.profile {
+				t.Errorf("unexpected profile, want = %q, got = %q", tc.profile, profile)
+			}
+			if !next.Equal(tc.next) {
+				t.Errorf("unexpected next, want = %v, got = %v", tc.next, next)
+			}
+		})
+	}
+}
+
+func TestSchedule_Validate(t *testing.T) {
+	if err := testSchedule(t).Validate(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var tests = []struct {
+		name   string
+		modify func(s *Schedule)
+	}{
+		{name: "default", modify: func(s *Schedule) { s.Default = "none" }},
+		{name: "profile", modify: func(s *Schedule) {
+			s.Week[time.Sunday] = []Window{{Start: 0, End: time.Hour, Profile: "none"}}
+		}},
+		{name: "empty window", modify: func(s *Schedule) {
+			s.Week[time.Sunday] = []Window{{Start: time.Hour, End: time.Hour, Profile: "slow"}}
+		}},
+		{name: "past midnight", modify: func(s *Schedule) {
+			s.Week[time.Sunday] = []Window{{Start: 23 * time.Hour, End: 25 * time.Hour, Profile: "slow"}}
+		}},
+		{name: "overlap", modify: func(s *Schedule) {
+			s.Holidays[Date{Year: 2024, Month: time.January, Day: 1}] = []Window{
+				{Start: 2 * time.Hour, End: 4 * time.Hour, Profile: "slow"},
+				{Start: time.Hour, End: 3 * time.Hour, Profile: "fast"},
+			}
+		}},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			s := testSchedule(t)
+			tc.modify(s)
+			if err := s.Validate(); err == nil {
+				t.Errorf("expected validation to fail")
+			}
+		})
+	}
+}
+
+func TestDate_String(t *testing.T) {
+	if want, got := "2023-01-02", DateOf(time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC)).String(); want != got {
+		t.Errorf("unexpected date, want = %q, got = %q", want, got)
+	}
+}
@@ -0,0 +1,213 @@
+package schedule
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/pborzenkov/go-transmission/transmission"
+)
+
+const defaultCheckInterval = time.Minute
+
+// Client is a subset of *transmission.Client used by Scheduler.
+type Client interface {
+	GetSession(ctx context.Context, fields ...transmission.SessionField) (*transmission.Session, error)
+	SetSession(ctx context.Context, req *transmission.SetSessionReq) error
+}
+
+var _ Client = (*transmission.Client)(nil)
+
+// Clock tells the time and waits for it to pass.
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time                         { return time.Now() }
+func (realClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// limits is the part of the session state controlled by Scheduler.
+type limits struct {
+	download, upload int64
+	turtle           bool
+}
+
+var sessionFields = []transmission.SessionField{
+	transmission.SessionFieldDownloadRateLimit,
+	transmission.SessionFieldDownloadRateLimitEnabled,
+	transmission.SessionFieldUploadRateLimit,
+	transmission.SessionFieldUploadRateLimitEnabled,
+	transmission.SessionFieldTurtleEnabled,
+}
+
+func sessionLimits(s *transmission.Session) limits {
+	l := limits{turtle: s.TurtleEnabled}
+	if s.DownloadRateLimitEnabled {
+		l.download = s.DownloadRateLimit
+	}
+	if s.UploadRateLimitEnabled {
+		l.upload = s.UploadRateLimit
+	}
+	return l
+}
+
+func (p *Profile) limits() limits {
+	return limits{download: p.DownloadRateLimit, upload: p.UploadRateLimit}
+}
+
+// Scheduler applies profiles of a schedule to a Transmission session.
+//
+// A profile is applied when it becomes active and on the first check, so
+// the session is reconciled with the schedule on startup. If the limits are
+// changed by someone else while a profile is active, Scheduler considers it a
+// manual override and leaves the session alone until the next window
+// boundary. "Turtle" mode counts as an override too, even if it was enabled
+// before Scheduler started, because Transmission ignores the regular limits
+// while it is on.
+type Scheduler struct {
+	// Transmission client
+	Client Client
+	// Schedule to apply. It must be valid
+	Schedule *Schedule
+	// Source of time. If nil, the system clock is used
+	Clock Clock
+	// Interval between checks for manual overrides in Run. If 0, 1 minute is
+	// used
+	CheckInterval time.Duration
+	// Called when a profile is applied
+	OnApply func(profile string)
+	// Called when a manual override is detected. The override is respected
+	// until the given time
+	OnOverride func(until time.Time)
+	// Called when Run fails to apply the active profile. The profile is
+	// applied again on the next check
+	OnError func(error)
+
+	applied       limits
+	overrideUntil time.Time
+
+	// mu guards active, which Active reads while Run updates it
+	mu     sync.Mutex
+	active string
+}
+
+func (s *Scheduler) clock() Clock {
+	if s.Clock != nil {
+		return s.Clock
+	}
+	return realClock{}
+}
+
+// Active returns the name of the last applied profile, or an empty string if
+// none was applied yet or a manual override is in effect.
+func (s *Scheduler) Active() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.active
+}
+
+func (s *Scheduler) setActive(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.active = name
+}
+
+// Check applies the currently active profile if needed and returns the time
+// of the next window boundary. It must not be called concurrently with Run or
+// another Check.
+func (s *Scheduler) Check(ctx context.Context) (time.Time, error) {
+	now := s.clock().Now()
+	name, next := s.Schedule.At(now)
+	profile, ok := s.Schedule.Profiles[name]
+	if !ok {
+		return next, fmt.Errorf("schedule: unknown profile %q", name)
+	}
+
+	sess, err := s.Client.GetSession(ctx, sessionFields...)
+	if err != nil {
+		return next, err
+	}
+	cur := sessionLimits(sess)
+
+	switch {
+	case !s.overrideUntil.IsZero():
+		if now.Before(s.overrideUntil) {
+			return next, nil
+		}
+		s.overrideUntil = time.Time{}
+	case s.active != "" && cur != s.applied:
+		s.override(next)
+		return next, nil
+	case s.active == name:
+		return next, nil
+	}
+	if cur.turtle {
+		s.override(next)
+		return next, nil
+	}
+
+	if cur != profile.limits() {
+		// Until the new limits are confirmed, any session state is expected.
+		s.setActive("")
+		if err := s.Client.SetSession(ctx, profile.request()); err != nil {
+			return next, err
+		}
+		// Remember the limits as reported by the daemon, which may round
+		// them to its speed units.
+		if sess, err = s.Client.GetSession(ctx, sessionFields...); err != nil {
+			return next, err
+		}
+		cur = sessionLimits(sess)
+	}
+	s.setActive(name)
+	s.applied = cur
+	if s.OnApply != nil {
+		s.OnApply(name)
+	}
+	return next, nil
+}
+
+func (s *Scheduler) override(until time.Time) {
+	s.setActive("")
+	s.overrideUntil = until
+	if s.OnOverride != nil {
+		s.OnOverride(until)
+	}
+}
+
+// Run checks the schedule at every window boundary and every CheckInterval
+// until ctx is done and returns ctx.Err().
+func (s *Scheduler) Run(ctx context.Context) error {
+	if s.Client == nil || s.Schedule == nil {
+		return errors.New("schedule: client or schedule is not set")
+	}
+	if err := s.Schedule.Validate(); err != nil {
+		return err
+	}
+	interval := s.CheckInterval
+	if interval <= 0 {
+		interval = defaultCheckInterval
+	}
+
+	clock := s.clock()
+	for {
+		next, err := s.Check(ctx)
+		if err != nil && s.OnError != nil && ctx.Err() == nil {
+			s.OnError(err)
+		}
+
+		wait := min(next.Sub(clock.Now()), interval)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-clock.After(max(wait, 0)):
+		}
+	}
+}
@@ -0,0 +1,242 @@
+package schedule
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pborzenkov/go-transmission/transmission"
+)
+
+type fakeClient struct {
+	session transmission.Session
+	sets    int
+	err     error
+}
+
+func (c *fakeClient) GetSession(ctx context.Context, fields ...transmission.SessionField) (*transmission.Session, error) { //nolint:lll
+	if c.err != nil {
+		return nil, c.err
+	}
+	sess := c.session
+	return &sess, nil
+}
+
+func (c *fakeClient) SetSession(ctx context.Context, req *transmission.SetSessionReq) error {
+	c.sets++
+	if req.DownloadRateLimitEnabled != nil {
+		c.session.DownloadRateLimitEnabled = *req.DownloadRateLimitEnabled
+	}
+	if req.DownloadRateLimit != nil {
+		c.session.DownloadRateLimit = *req.DownloadRateLimit
+	}
+	if req.UploadRateLimitEnabled != nil {
+		c.session.UploadRateLimitEnabled = *req.UploadRateLimitEnabled
+	}
+	if req.UploadRateLimit != nil {
+		c.session.UploadRateLimit = *req.UploadRateLimit
+	}
+	return nil
+}
+
+type fakeClock struct {
+	now     time.Time
+	stopped bool
+}
+
+func (c *fakeClock) Now() time.Time { return c.now }
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	if c.stopped {
+		return nil
+	}
+	c.now = c.now.Add(d)
+	ch := make(chan time.Time, 1)
+	ch <- c.now
+	return ch
+}
+
+func TestScheduler_Check(t *testing.T) {
+	s := testSchedule(t)
+	at := func(hour, minute int) time.Time {
+		return time.Date(2023, time.October, 16, hour, minute, 0, 0, s.Location)
+	}
+
+	client := &fakeClient{session: transmission.Session{UploadRateLimit: 5000, UploadRateLimitEnabled: true}}
+	clock := &fakeClock{now: at(9, 0)}
+	var (
+		applied   []string
+		overrides []time.Time
+	)
+	sched := &Scheduler{
+		Client:     client,
+		Schedule:   s,
+		Clock:      clock,
+		OnApply:    func(p string) { applied = append(applied, p) },
+		OnOverride: func(until time.Time) { overrides = append(overrides, until) },
+	}
+	check := func() {
+		t.Helper()
+		if _, err := sched.Check(context.Background()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	// Startup reconciliation.
+	check()
+	want := transmission.Session{
+		DownloadRateLimit:        100_000,
+		DownloadRateLimitEnabled: true,
+		UploadRateLimit:          10_000,
+		UploadRateLimitEnabled:   true,
+	}
+	if !cmp.Equal(want, client.session) {
+		t.Errorf("unexpected session, diff = \n%s", cmp.Diff(want, client.session))
+	}
+	if sched.Active() != "slow" || client.sets != 1 {
+		t.Errorf("expected slow profile to be applied once, got = %q, %d", sched.Active(), client.sets)
+	}
+
+	// Nothing changes within a window.
+	clock.now = at(10, 0)
+	check()
+	if client.sets != 1 {
+		t.Errorf("expected no changes within a window")
+	}
+
+	// Manual override is respected until the next boundary.
+	client.session.TurtleEnabled = true
+	check()
+	if len(overrides) != 1 || !overrides[0].Equal(at(12, 0)) {
+		t.Errorf("unexpected overrides: %v", overrides)
+	}
+	clock.now = at(11, 59)
+	check()
+	if client.sets != 1 || sched.Active() != "" {
+		t.Errorf("expected override to be respected")
+	}
+
+	client.session.TurtleEnabled = false
+	clock.now = at(12, 0)
+	check()
+	if want, got := "fast", sched.Active(); want != got {
+		t.Errorf("unexpected active profile, want = %q, got = %q", want, got)
+	}
+	if client.session.DownloadRateLimitEnabled || client.session.UploadRateLimitEnabled {
+		t.Errorf("expected limits to be disabled, got = %+v", client.session)
+	}
+
+	clock.now = at(13, 0)
+	check()
+	if want := []string{"slow", "fast", "slow"}; !cmp.Equal(want, applied) {
+		t.Errorf("unexpected applied profiles, diff = \n%s", cmp.Diff(want, applied))
+	}
+
+	client.err = errors.New("daemon is down")
+	if _, err := sched.Check(context.Background()); err == nil {
+		t.Errorf("expected Check to fail")
+	}
+}
+
+func TestScheduler_Check_turtle(t *testing.T) {
+	s := testSchedule(t)
+	at := func(hour, minute int) time.Time {
+		return time.Date(2023, time.October, 16, hour, minute, 0, 0, s.Location)
+	}
+
+	client := &fakeClient{session: transmission.Session{TurtleEnabled: true}}
+	clock := &fakeClock{now: at(9, 0)}
+	var overrides []time.Time
+	sched := &Scheduler{
+		Client:     client,
+		Schedule:   s,
+		Clock:      clock,
+		OnOverride: func(until time.Time) { overrides = append(overrides, until) },
+	}
+	check := func() {
+		t.Helper()
+		if _, err := sched.Check(context.Background()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	// Turtle mode enabled before startup is an override.
+	check()
+	if client.sets != 0 || sched.Active() != "" {
+		t.Errorf("expected turtle mode to be respected, got = %q, %d", sched.Active(), client.sets)
+	}
+
+	// It is still respected after the boundary if it stays enabled.
+	clock.now = at(12, 0)
+	check()
+	if want := []time.Time{at(12, 0), at(13, 0)}; !cmp.Equal(want, overrides) {
+		t.Errorf("unexpected overrides, diff = \n%s", cmp.Diff(want, overrides))
+	}
+	if client.sets != 0 {
+		t.Errorf("expected turtle mode to be respected")
+	}
+
+	client.session.TurtleEnabled = false
+	clock.now = at(13, 0)
+	check()
+	if want, got := "slow", sched.Active(); want != got || client.sets != 1 {
+		t.Errorf("unexpected active profile, want = %q, got = %q, %d", want, got, client.sets)
+	}
+}
+
+func TestScheduler_Run(t *testing.T) {
+	s := testSchedule(t)
+
+	client := new(fakeClient)
+	clock := &fakeClock{now: time.Date(2023, time.October, 16, 7, 0, 0, 0, s.Location)}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var applied []string
+	sched := &Scheduler{
+		Client:        client,
+		Schedule:      s,
+		Clock:         clock,
+		CheckInterval: time.Hour,
+		OnApply: func(p string) {
+			applied = append(applied, p)
+			if len(applied) == 3 {
+				clock.stopped = true
+				cancel()
+			}
+		},
+	}
+
+	if err := sched.Run(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("unexpected error, want = %v, got = %v", context.Canceled, err)
+	}
+	if want := []string{"fast", "slow", "fast"}; !cmp.Equal(want, applied) {
+		t.Errorf("unexpected applied profiles, diff = \n%s", cmp.Diff(want, applied))
+	}
+	if want := time.Date(2023, time.October, 16, 12, 0, 0, 0, s.Location); !clock.now.Equal(want) {
+		t.Errorf("unexpected time, want = %v, got = %v", want, clock.now)
+	}
+
+	s.Default = "none"
+	if err := (&Scheduler{Client: client, Schedule: s}).Run(context.Background()); err == nil {
+		t.Errorf("expected invalid schedule to fail")
+	}
+}
+
+func TestScheduler_Active(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	sched := &Scheduler{Client: new(fakeClient), Schedule: testSchedule(t), CheckInterval: time.Hour}
+
+	done := make(chan error, 1)
+	go func() { done <- sched.Run(ctx) }()
+
+	// Active is safe to call while Run applies profiles.
+	for sched.Active() == "" {
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Errorf("unexpected error, want = %v, got = %v", context.Canceled, err)
+	}
+}
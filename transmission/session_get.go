@@ -46,11 +46,11 @@ type Session struct {
 	CacheSize int64 `json:"cache-size-mb"`
 
 	// Location of Transmission config directory
-	ConfigDirectory string `json:"config-dir"`
+	ConfigDirectory string `json:"config-dir" path:"true"`
 	// Default path to download torrents
-	DownloadDirectory string `json:"download-dir"`
+	DownloadDirectory string `json:"download-dir" path:"true"`
 	// Path for incomplete torrents (if enabled)
-	IncompleteDirectory string `json:"incomplete-dir"`
+	IncompleteDirectory string `json:"incomplete-dir" path:"true"`
 	// Indicates whether to keep torrents in incomplete directory until done
 	IncompleteDirectoryEnabled bool `json:"incomplete-dir-enabled"`
 	// Indicates whether Transmission will append '.part' suffix to
@@ -106,7 +106,7 @@ type Session struct {
 	PortForwardingEnabled bool `json:"port-forwarding-enabled"`
 
 	// Path to the script to run when torrent is done downloading
-	ScriptPath string `json:"script-torrent-done-filename"`
+	ScriptPath string `json:"script-torrent-done-filename" path:"true"`
 	// Indicates whether to run script when torrent is done downloading or
 	// not
 	ScriptEnabled bool `json:"script-torrent-done-enabled"`
@@ -175,6 +175,9 @@ func (c *Client) GetSession(ctx context.Context, fields ...SessionField) (*Sessi
 	resp.IdleSeedingLimit *= time.Minute
 
 	c.setUnitConversion(uc)
+	if resp.RPCVersion > 0 {
+		c.rpcVersion.Store(resp.RPCVersion)
+	}
 
 	return resp, nil
 }
@@ -42,9 +42,9 @@ type SetSessionReq struct {
 	CacheSize *int64 `json:"-"`
 
 	// Default path to download torrents
-	DownloadDirectory *string `json:"download-dir,omitempty"`
+	DownloadDirectory *string `json:"download-dir,omitempty" path:"true"`
 	// Path for incomplete torrents (if enabled)
-	IncompleteDirectory *string `json:"incomplete-dir,omitempty"`
+	IncompleteDirectory *string `json:"incomplete-dir,omitempty" path:"true"`
 	// Indicates whether to keep torrents in incomplete directory until done
 	IncompleteDirectoryEnabled *bool `json:"incomplete-dir-enabled,omitempty"`
 	// Indicates whether Transmission will append '.part' suffix to
@@ -100,7 +100,7 @@ type SetSessionReq struct {
 	PortForwardingEnabled *bool `json:"port-forwarding-enabled,omitempty"`
 
 	// Path to the script to run when torrent is done downloading
-	ScriptPath *string `json:"script-torrent-done-filename,omitempty"`
+	ScriptPath *string `json:"script-torrent-done-filename,omitempty" path:"true"`
 	// Indicates whether to run script when torrent is done downloading or
 	// not
 	ScriptEnabled *bool `json:"script-torrent-done-enabled,omitempty"`
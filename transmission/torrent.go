@@ -95,11 +95,11 @@ type Torrent struct {
 	Error string `json:"errorString"`
 
 	// Path to torrent file
-	File string `json:"torrentFile"`
+	File string `json:"torrentFile" path:"true"`
 	// Torrent magnet link
 	MagnetLink string `json:"magnetLink"`
 	// Torrent download directory
-	DownloadDirectory string `json:"downloadDir"`
+	DownloadDirectory string `json:"downloadDir" path:"true"`
 
 	// Torrent creation date
 	CreatedAt time.Time `json:"-" field:"dateCreated"`
@@ -225,6 +225,9 @@ type Torrent struct {
 	Trackers []Tracker `json:"-" field:"trackers"`
 	// TrackerStats holds statistics about trackers
 	TrackerStats []TrackerStat `json:"-" field:"trackerStats"`
+	// TrackerList holds torrent trackers grouped by tiers. On daemons that
+	// don't report it, it is built from Trackers
+	TrackerList TrackerList `json:"-" field:"trackerList"`
 }
 
 //go:generate go run ../tools/gen-fields.go -type Torrent
@@ -453,6 +456,7 @@ type torrentJSON struct {
 	Wanted                []int             `json:"wanted"`
 	Trackers              []trackerJSON     `json:"trackers"`
 	TrackerStats          []trackerStatJSON `json:"trackerStats"`
+	TrackerList           *string           `json:"trackerList"`
 }
 
 func (tj *torrentJSON) torrent(uc unitConversion) (*Torrent, error) {
@@ -487,6 +491,13 @@ func (tj *torrentJSON) torrent(uc unitConversion) (*Torrent, error) {
 			}
 		}
 	}
+	if tj.TrackerList != nil {
+		if t.TrackerList, err = ParseTrackerList(*tj.TrackerList); err != nil {
+			return nil, err
+		}
+	} else if len(t.Trackers) > 0 {
+		t.TrackerList = TrackerListFromTrackers(t.Trackers)
+	}
 	if len(tj.TrackerStats) > 0 {
 		t.TrackerStats = make([]TrackerStat, len(tj.TrackerStats))
 		for i := range tj.TrackerStats {
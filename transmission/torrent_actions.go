@@ -72,7 +72,7 @@ func (c *Client) RenameTorrentPath(ctx context.Context, id SingularIdentifier, p
 func (c *Client) SetTorrentsLocation(ctx context.Context, ids Identifier, location string, move bool) error {
 	var setTorrentsLocationReq = struct {
 		IDs      Identifier `json:"ids,omitempty"`
-		Location string     `json:"location"`
+		Location string     `json:"location" path:"true"`
 		Move     bool       `json:"move"`
 	}{ids, location, move}
 
@@ -1,22 +1,61 @@
 package transmission
 
 import (
+	"bytes"
 	"context"
 	"encoding/base64"
 	"errors"
+	"fmt"
 	"io"
+	"net/url"
+	"slices"
 	"strings"
+
+	"github.com/pborzenkov/go-transmission/transmission/bencode"
+)
+
+// ErrDuplicateTorrent is returned by AddTorrent if Transmission already has
+// the torrent and DuplicateError policy is requested.
+var ErrDuplicateTorrent = errors.New("transmission: duplicate torrent")
+
+// DuplicatePolicy defines how AddTorrent handles torrents Transmission
+// already has.
+type DuplicatePolicy int
+
+const (
+	// DuplicateIgnore returns the existing torrent leaving it intact
+	DuplicateIgnore DuplicatePolicy = iota
+	// DuplicateError returns the existing torrent along with
+	// ErrDuplicateTorrent
+	DuplicateError
+	// DuplicateMerge adds requested labels and trackers to the existing
+	// torrent
+	DuplicateMerge
 )
 
+func (p DuplicatePolicy) String() string {
+	switch p {
+	case DuplicateIgnore:
+		return "ignore"
+	case DuplicateError:
+		return "error"
+	case DuplicateMerge:
+		return "merge"
+	default:
+		return fmt.Sprintf("DuplicatePolicy(%d)", p)
+	}
+}
+
 // AddTorrentReq holds information needed to add new torrent to transission.
 // Either URL or Meta must be set.
 type AddTorrentReq struct {
-	// Either a path/URL to torrent or magnet link
+	// Either a path/URL to torrent or magnet link. It is not translated by
+	// the path mapping
 	URL *string `json:"filename,omitempty"`
 	// Contents of the torrent file
 	Meta io.Reader `json:"-"`
 	// Custom download directory for the torrent
-	DownloadDirectory *string `json:"download-dir,omitempty"`
+	DownloadDirectory *string `json:"download-dir,omitempty" path:"true"`
 	// Cookies to attach to HTTP request when downloading torrent file over
 	// the network
 	Cookies []Cookie `json:"-"`
@@ -42,6 +81,9 @@ type AddTorrentReq struct {
 	WantedFiles []int `json:"files-wanted,omitempty"`
 	// List of files indicies to not download
 	UnwatedFiles []int `json:"files-unwanted,omitempty"`
+
+	// What to do if Transmission already has the torrent
+	OnDuplicate DuplicatePolicy `json:"-"`
 }
 
 // NewTorrent describes newly added torrent.
@@ -49,9 +91,16 @@ type NewTorrent struct {
 	ID   ID     `json:"id"`
 	Hash Hash   `json:"hashString"`
 	Name string `json:"name"`
+	// Indicates that Transmission already had the torrent, so the settings
+	// of the request were not applied
+	Duplicate bool `json:"-"`
 }
 
-// AddTorrent adds new torrent to Transmission.
+// AddTorrent adds new torrent to Transmission. If Transmission already has
+// the torrent, the result is marked as duplicate and req.OnDuplicate policy
+// is applied. If the hash of the torrent is known in advance (see
+// AddTorrentReq.ExpectedHash) and Transmission reports another one, an error
+// is returned and a newly added torrent is removed.
 func (c *Client) AddTorrent(ctx context.Context, req *AddTorrentReq) (*NewTorrent, error) {
 	if req.URL != nil && req.Meta != nil {
 		return nil, errors.New("transmission: can't have both URL and Meta set")
@@ -64,16 +113,13 @@ func (c *Client) AddTorrent(ctx context.Context, req *AddTorrentReq) (*NewTorren
 	}{
 		AddTorrentReq: req,
 	}
+	var meta []byte
 	if req.Meta != nil {
-		meta := new(strings.Builder)
-		w := base64.NewEncoder(base64.StdEncoding, meta)
-		if _, err := io.Copy(w, req.Meta); err != nil {
-			return nil, err
-		}
-		if err := w.Close(); err != nil {
+		var err error
+		if meta, err = io.ReadAll(req.Meta); err != nil {
 			return nil, err
 		}
-		addTorrentJSON.Meta = OptString(meta.String())
+		addTorrentJSON.Meta = OptString(base64.StdEncoding.EncodeToString(meta))
 	}
 	if len(req.Cookies) > 0 {
 		c := make([]string, len(req.Cookies))
@@ -91,10 +137,111 @@ func (c *Client) AddTorrent(ctx context.Context, req *AddTorrentReq) (*NewTorren
 		return nil, err
 	}
 
-	t := addTorrentResp.Added
+	t := addTorrentResp.Duplicate
 	if t == nil {
-		t = addTorrentResp.Duplicate
+		t = addTorrentResp.Added
+		if err := checkHash(req, t); err != nil {
+			// Roll back even if ctx is the reason of the failure.
+			if rerr := c.RemoveTorrents(context.WithoutCancel(ctx), IDs(t.ID), false); rerr != nil {
+				return nil, errors.Join(err, fmt.Errorf("transmission: failed to remove torrent %d: %w", t.ID, rerr))
+			}
+			return nil, err
+		}
+		return t, nil
+	}
+	t.Duplicate = true
+	if err := checkHash(req, t); err != nil {
+		return t, err
 	}
 
+	switch req.OnDuplicate {
+	case DuplicateError:
+		return t, ErrDuplicateTorrent
+	case DuplicateMerge:
+		if err := c.mergeTorrent(ctx, t.ID, req, meta); err != nil {
+			return t, err
+		}
+	}
 	return t, nil
 }
+
+// checkHash makes sure that Transmission added the torrent the request asked
+// for, if it is known in advance.
+func checkHash(req *AddTorrentReq, t *NewTorrent) error {
+	want, ok := req.ExpectedHash()
+	if !ok || t == nil || t.Hash == "" || strings.EqualFold(string(want), string(t.Hash)) {
+		return nil
+	}
+	return fmt.Errorf("transmission: torrent %d has hash %s, want %s", t.ID, t.Hash, want)
+}
+
+// requestedTrackers returns trackers of the torrent being added if they are
+// known without downloading the torrent.
+func requestedTrackers(req *AddTorrentReq, meta []byte) []*url.URL {
+	if req.URL != nil {
+		if m, err := ParseMagnet(*req.URL); err == nil {
+			return m.Trackers
+		}
+		return nil
+	}
+
+	var mi struct {
+		Announce     string     `bencode:"announce"`
+		AnnounceList [][]string `bencode:"announce-list"`
+	}
+	if err := bencode.NewDecoder(bytes.NewReader(meta)).Decode(&mi); err != nil {
+		return nil
+	}
+	tiers := mi.AnnounceList
+	if len(tiers) == 0 && mi.Announce != "" {
+		tiers = [][]string{{mi.Announce}}
+	}
+	var trackers []*url.URL
+	for _, tier := range tiers {
+		for _, tr := range tier {
+			if u, err := url.Parse(tr); err == nil {
+				trackers = append(trackers, u)
+			}
+		}
+	}
+	return trackers
+}
+
+// mergeTorrent adds labels and trackers of req missing from torrent id.
+func (c *Client) mergeTorrent(ctx context.Context, id ID, req *AddTorrentReq, meta []byte) error {
+	torrents, err := c.GetTorrents(ctx, IDs(id), TorrentFieldLabels, TorrentFieldTrackers)
+	if err != nil {
+		return err
+	}
+	if len(torrents) == 0 {
+		return fmt.Errorf("transmission: torrent %d disappeared", id)
+	}
+	t := torrents[0]
+
+	set := new(SetTorrentReq)
+	labels := append([]string(nil), t.Labels...)
+	for _, l := range req.Labels {
+		if !slices.Contains(labels, l) {
+			labels = append(labels, l)
+		}
+	}
+	if len(labels) != len(t.Labels) {
+		set.Labels = labels
+	}
+
+	known := make(map[string]bool)
+	for _, tr := range t.Trackers {
+		known[tr.AnnounceURL.String()] = true
+	}
+	for _, tr := range requestedTrackers(req, meta) {
+		if !known[tr.String()] {
+			known[tr.String()] = true
+			set.TrackersToAdd = append(set.TrackersToAdd, tr)
+		}
+	}
+
+	if set.Labels == nil && set.TrackersToAdd == nil {
+		return nil
+	}
+	return c.SetTorrents(ctx, IDs(id), set)
+}
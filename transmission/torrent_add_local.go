@@ -0,0 +1,163 @@
+package transmission
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// DefaultMaxTorrentSize is the default limit on the size of torrent files
+// read by AddTorrentFromFile and AddTorrentFromURL.
+const DefaultMaxTorrentSize = 10 << 20
+
+// AddTorrentOpts customizes AddTorrentFromFile and AddTorrentFromURL.
+type AddTorrentOpts struct {
+	// Settings of the torrent to add. URL and Meta must not be set. Cookies
+	// are sent with the HTTP request that fetches the torrent file
+	Settings *AddTorrentReq
+	// HTTP client to fetch torrent files with. If nil, http.DefaultClient is
+	// used
+	HTTPClient *http.Client
+	// Additional headers of the HTTP request that fetches the torrent file
+	Header http.Header
+	// Maximum size of the torrent file. If 0, DefaultMaxTorrentSize is used
+	MaxSize int64
+}
+
+func (o *AddTorrentOpts) maxSize() int64 {
+	if o == nil || o.MaxSize <= 0 {
+		return DefaultMaxTorrentSize
+	}
+	return o.MaxSize
+}
+
+func (o *AddTorrentOpts) request() (*AddTorrentReq, error) {
+	req := new(AddTorrentReq)
+	if o != nil && o.Settings != nil {
+		if o.Settings.URL != nil || o.Settings.Meta != nil {
+			return nil, errors.New("transmission: settings can't have URL or Meta set")
+		}
+		*req = *o.Settings
+	}
+	req.Cookies = nil
+	return req, nil
+}
+
+func isMagnet(s string) bool {
+	return strings.HasPrefix(strings.ToLower(s), "magnet:")
+}
+
+func readLimited(r io.Reader, limit int64) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(r, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > limit {
+		return nil, fmt.Errorf("transmission: torrent file exceeds %d bytes", limit)
+	}
+	return data, nil
+}
+
+// addTorrentData adds torrent with contents data, which is either a torrent
+// file or a magnet link.
+func (c *Client) addTorrentData(ctx context.Context, data []byte, opts *AddTorrentOpts) (*NewTorrent, error) {
+	req, err := opts.request()
+	if err != nil {
+		return nil, err
+	}
+	if link := strings.TrimSpace(string(data)); isMagnet(link) {
+		req.URL = OptString(link)
+	} else {
+		req.Meta = bytes.NewReader(data)
+	}
+	return c.AddTorrent(ctx, req)
+}
+
+// AddTorrentFromFile adds torrent from a file local to the client rather
+// than to Transmission. The file holds either torrent metainfo or a magnet
+// link.
+func (c *Client) AddTorrentFromFile(ctx context.Context, path string, opts *AddTorrentOpts) (*NewTorrent, error) { //nolint:lll
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	data, err := readLimited(f, opts.maxSize())
+	if err != nil {
+		return nil, err
+	}
+	return c.addTorrentData(ctx, data, opts)
+}
+
+// AddTorrentFromURL fetches torrent file on the client side and adds it to
+// Transmission. This is useful when Transmission can't reach the URL itself.
+// Magnet links, as well as redirects to magnet links, are passed to
+// Transmission as is.
+func (c *Client) AddTorrentFromURL(ctx context.Context, url string, opts *AddTorrentOpts) (*NewTorrent, error) { //nolint:lll
+	if isMagnet(url) {
+		return c.addTorrentData(ctx, []byte(url), opts)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if opts != nil {
+		for k, v := range opts.Header {
+			httpReq.Header[k] = append([]string(nil), v...)
+		}
+		if opts.Settings != nil {
+			for _, cookie := range opts.Settings.Cookies {
+				httpReq.AddCookie(&http.Cookie{Name: cookie.Name, Value: cookie.Value})
+			}
+		}
+	}
+
+	client := http.DefaultClient
+	if opts != nil && opts.HTTPClient != nil {
+		client = opts.HTTPClient
+	}
+	var magnet string
+	redirectClient := *client
+	redirectClient.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		if isMagnet(req.URL.String()) {
+			magnet = req.URL.String()
+			return http.ErrUseLastResponse
+		}
+		if client.CheckRedirect != nil {
+			return client.CheckRedirect(req, via)
+		}
+		if len(via) >= 10 {
+			return errors.New("stopped after 10 redirects")
+		}
+		return nil
+	}
+
+	resp, err := redirectClient.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if magnet != "" {
+		return c.addTorrentData(ctx, []byte(magnet), opts)
+	}
+	if resp.StatusCode/100 != 2 {
+		return nil, fmt.Errorf("transmission: failed to fetch torrent (%s)", http.StatusText(resp.StatusCode))
+	}
+	if resp.ContentLength > opts.maxSize() {
+		return nil, fmt.Errorf("transmission: torrent file exceeds %d bytes", opts.maxSize())
+	}
+
+	data, err := readLimited(resp.Body, opts.maxSize())
+	if err != nil {
+		return nil, err
+	}
+	return c.addTorrentData(ctx, data, opts)
+}
@@ -0,0 +1,175 @@
+package transmission
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func handleAdd(t *testing.T, body string) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		testBody(t, r, body)
+
+		fmt.Fprintf(w, `{
+			"result": "success",
+			"arguments": {
+			  "torrent-added": {
+			    "id": 1,
+			    "hashString": "12345",
+			    "name": "some-torrent"
+			  }
+		        }
+		  }`)
+	}
+}
+
+func TestAddTorrentFromFile(t *testing.T) {
+	client, handle, teardown := setup(t)
+	defer teardown()
+
+	handle(handleAdd(t, `{
+		"method": "torrent-add",
+		"arguments": {
+		  "paused": true,
+		  "metainfo": "dG9ycmVudC1jb250ZW50cw=="
+		}
+	}`))
+
+	path := filepath.Join(t.TempDir(), "test.torrent")
+	if err := os.WriteFile(path, []byte("torrent-contents"), 0o600); err != nil {
+		t.Fatalf("failed to write torrent: %v", err)
+	}
+
+	got, err := client.AddTorrentFromFile(context.Background(), path, &AddTorrentOpts{
+		Settings: &AddTorrentReq{Paused: OptBool(true)},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := (&NewTorrent{ID: 1, Hash: "12345", Name: "some-torrent"}); !cmp.Equal(want, got) {
+		t.Errorf("unexpected response, diff = \n%s", cmp.Diff(want, got))
+	}
+
+	if _, err := client.AddTorrentFromFile(context.Background(), path, &AddTorrentOpts{MaxSize: 4}); err == nil {
+		t.Errorf("expected too large file to fail")
+	}
+}
+
+func TestAddTorrentFromFile_magnet(t *testing.T) {
+	client, handle, teardown := setup(t)
+	defer teardown()
+
+	handle(handleAdd(t, `{
+		"method": "torrent-add",
+		"arguments": {
+		  "filename": "magnet:?xt=urn:btih:somelink"
+		}
+	}`))
+
+	path := filepath.Join(t.TempDir(), "test.magnet")
+	if err := os.WriteFile(path, []byte("magnet:?xt=urn:btih:somelink\n"), 0o600); err != nil {
+		t.Fatalf("failed to write magnet: %v", err)
+	}
+
+	if _, err := client.AddTorrentFromFile(context.Background(), path, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestAddTorrentFromURL(t *testing.T) {
+	client, handle, teardown := setup(t)
+	defer teardown()
+
+	handle(handleAdd(t, `{
+		"method": "torrent-add",
+		"arguments": {
+		  "labels": ["tv"],
+		  "metainfo": "dG9ycmVudC1jb250ZW50cw=="
+		}
+	}`))
+
+	indexer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		testHeader(t, r, "X-Api-Key", "secret")
+		testHeader(t, r, "Cookie", "a=b")
+		if r.URL.Path == "/redirect" {
+			http.Redirect(w, r, "/file.torrent", http.StatusFound)
+			return
+		}
+		fmt.Fprint(w, "torrent-contents")
+	}))
+	defer indexer.Close()
+
+	_, err := client.AddTorrentFromURL(context.Background(), indexer.URL+"/redirect", &AddTorrentOpts{
+		Settings: &AddTorrentReq{
+			Labels:  []string{"tv"},
+			Cookies: []Cookie{{Name: "a", Value: "b"}},
+		},
+		Header: http.Header{"X-Api-Key": {"secret"}},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestAddTorrentFromURL_magnetRedirect(t *testing.T) {
+	client, handle, teardown := setup(t)
+	defer teardown()
+
+	handle(handleAdd(t, `{
+		"method": "torrent-add",
+		"arguments": {
+		  "filename": "magnet:?xt=urn:btih:somelink"
+		}
+	}`))
+
+	indexer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "magnet:?xt=urn:btih:somelink", http.StatusFound)
+	}))
+	defer indexer.Close()
+
+	if _, err := client.AddTorrentFromURL(context.Background(), indexer.URL, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestAddTorrentFromURL_errors(t *testing.T) {
+	client, _, teardown := setup(t)
+	defer teardown()
+
+	indexer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, strings.Repeat("x", 100))
+	}))
+	defer indexer.Close()
+
+	var tests = []struct {
+		name string
+		url  string
+		opts *AddTorrentOpts
+	}{
+		{name: "not_found", url: indexer.URL + "/missing"},
+		{name: "too_large", url: indexer.URL, opts: &AddTorrentOpts{MaxSize: 10}},
+		{name: "settings_url", url: indexer.URL, opts: &AddTorrentOpts{
+			Settings: &AddTorrentReq{URL: OptString("http://other/")},
+		}},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := client.AddTorrentFromURL(context.Background(), tc.url, tc.opts); err == nil {
+				t.Errorf("expected add to fail")
+			}
+		})
+	}
+}
@@ -0,0 +1,188 @@
+package transmission
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
+)
+
+const defaultMetadataPollInterval = time.Second
+
+// QueuePlacement defines where a newly added torrent is placed in the queue.
+type QueuePlacement int
+
+const (
+	// QueueDefault leaves the torrent where Transmission placed it
+	QueueDefault QueuePlacement = iota
+	// QueueTop moves the torrent to the top of the queue
+	QueueTop
+	// QueueBottom moves the torrent to the bottom of the queue
+	QueueBottom
+)
+
+func (q QueuePlacement) String() string {
+	switch q {
+	case QueueDefault:
+		return "default"
+	case QueueTop:
+		return "top"
+	case QueueBottom:
+		return "bottom"
+	default:
+		return fmt.Sprintf("QueuePlacement(%d)", q)
+	}
+}
+
+// AddTorrentSettings holds settings AddTorrentWithSettings applies to a newly
+// added torrent before starting it.
+type AddTorrentSettings struct {
+	// Torrent settings to apply, if not nil
+	Set *SetTorrentReq
+	// Queue placement of the torrent
+	Queue QueuePlacement
+	// Start the torrent even if the queue is full
+	StartNow bool
+	// Interval between metadata checks of magnet links. If 0, one second is
+	// used
+	MetadataPollInterval time.Duration
+}
+
+// splitFileSettings splits the request into file settings, which need
+// metadata of the torrent, and the rest. Either part is nil if empty.
+func (s *SetTorrentReq) splitFileSettings() (rest, files *SetTorrentReq) {
+	rest = new(SetTorrentReq)
+	*rest = *s
+	rest.HighPriorityFiles, rest.NormalPriorityFiles, rest.LowPriorityFiles = nil, nil, nil
+	rest.WantedFiles, rest.UnwantedFiles = nil, nil
+	if reflect.ValueOf(*rest).IsZero() {
+		rest = nil
+	}
+
+	if s.HighPriorityFiles != nil || s.NormalPriorityFiles != nil || s.LowPriorityFiles != nil ||
+		s.WantedFiles != nil || s.UnwantedFiles != nil {
+		files = &SetTorrentReq{
+			HighPriorityFiles:   s.HighPriorityFiles,
+			NormalPriorityFiles: s.NormalPriorityFiles,
+			LowPriorityFiles:    s.LowPriorityFiles,
+			WantedFiles:         s.WantedFiles,
+			UnwantedFiles:       s.UnwantedFiles,
+		}
+	}
+	return rest, files
+}
+
+// AddTorrentWithSettings adds a torrent and configures it before it starts
+// downloading. The torrent is added paused, then settings.Set and queue
+// placement are applied and the torrent is started unless req asks for it to
+// be paused. File settings of magnet links are applied once Transmission
+// receives the metadata, the rest of settings.Set is applied before the
+// torrent is started to fetch it. The torrent is stopped as soon as the
+// metadata is seen and started again only after the file settings are
+// applied. Transmission may still request pieces of excluded files in between,
+// which is at most MetadataPollInterval.
+//
+// If any step fails, the torrent is removed (keeping the data) and the error
+// is returned. Torrents Transmission already had are handled according to
+// req.OnDuplicate without applying settings.
+func (c *Client) AddTorrentWithSettings(ctx context.Context, req *AddTorrentReq, settings *AddTorrentSettings) (*NewTorrent, error) { //nolint:lll
+	addReq := *req
+	addReq.Paused = OptBool(true)
+
+	t, err := c.AddTorrent(ctx, &addReq)
+	if err != nil || t.Duplicate {
+		return t, err
+	}
+
+	if err := c.configureTorrent(ctx, t.ID, req, settings); err != nil {
+		// Roll back even if ctx is the reason of the failure.
+		if rerr := c.RemoveTorrents(context.WithoutCancel(ctx), IDs(t.ID), false); rerr != nil {
+			return nil, errors.Join(err, fmt.Errorf("transmission: failed to remove torrent %d: %w", t.ID, rerr))
+		}
+		return nil, err
+	}
+	return t, nil
+}
+
+func (c *Client) configureTorrent(ctx context.Context, id ID, req *AddTorrentReq, settings *AddTorrentSettings) error {
+	if settings == nil {
+		settings = new(AddTorrentSettings)
+	}
+
+	if settings.Set != nil {
+		// Limits and trackers must be in effect before the torrent is started
+		// to fetch the metadata.
+		rest, files := settings.Set.splitFileSettings()
+		if rest != nil {
+			if err := c.SetTorrents(ctx, IDs(id), rest); err != nil {
+				return err
+			}
+		}
+		if files != nil {
+			if err := c.waitForMetadata(ctx, id, settings.MetadataPollInterval); err != nil {
+				return err
+			}
+			if err := c.SetTorrents(ctx, IDs(id), files); err != nil {
+				return err
+			}
+		}
+	}
+
+	var err error
+	switch settings.Queue {
+	case QueueTop:
+		err = c.QueueMoveToTop(ctx, IDs(id))
+	case QueueBottom:
+		err = c.QueueMoveToBottom(ctx, IDs(id))
+	}
+	if err != nil {
+		return err
+	}
+
+	if req.Paused != nil && *req.Paused {
+		return nil
+	}
+	if settings.StartNow {
+		return c.StartTorrentsNow(ctx, IDs(id))
+	}
+	return c.StartTorrents(ctx, IDs(id))
+}
+
+// waitForMetadata waits until Transmission has metadata of the paused
+// torrent. Transmission doesn't fetch metadata of paused torrents, so the
+// torrent is started if needed and stopped again once the metadata arrives.
+func (c *Client) waitForMetadata(ctx context.Context, id ID, interval time.Duration) error {
+	if interval <= 0 {
+		interval = defaultMetadataPollInterval
+	}
+
+	started := false
+	for {
+		torrents, err := c.GetTorrents(ctx, IDs(id), TorrentFieldMetadataDone)
+		if err != nil {
+			return err
+		}
+		if len(torrents) == 0 {
+			return fmt.Errorf("transmission: torrent %d disappeared", id)
+		}
+		if torrents[0].MetadataDone >= 1 {
+			if started {
+				return c.StopTorrents(ctx, IDs(id))
+			}
+			return nil
+		}
+		if !started {
+			if err := c.StartTorrents(ctx, IDs(id)); err != nil {
+				return err
+			}
+			started = true
+		}
+
+		select {
+		case <-time.After(interval):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package transmission
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// recordMethods handles RPC calls with handler recording called methods.
+func recordMethods(t *testing.T, handle func(func(http.ResponseWriter, *http.Request)), handler func(w http.ResponseWriter, method string, args json.RawMessage)) *[]string { //nolint:lll
+	t.Helper()
+
+	methods := new([]string)
+	handle(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Method    string          `json:"method"`
+			Arguments json.RawMessage `json:"arguments"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Fatalf("failed to decode request: %v", err)
+		}
+		*methods = append(*methods, req.Method)
+		handler(w, req.Method, req.Arguments)
+	})
+	return methods
+}
+
+func TestAddTorrentWithSettings(t *testing.T) {
+	client, handle, teardown := setup(t)
+	defer teardown()
+
+	metadataChecks := 0
+	var sets []string
+	methods := recordMethods(t, handle, func(w http.ResponseWriter, method string, args json.RawMessage) {
+		switch method {
+		case "torrent-add":
+			if want, got := `{"filename":"magnet:?xt=urn:btih:somelink","paused":true}`, string(args); want != got {
+				t.Errorf("unexpected torrent-add arguments, want = %q, got = %q", want, got)
+			}
+			fmt.Fprintf(w, `{"result":"success","arguments":{"torrent-added":{"id":1,"hashString":"12345","name":"t"}}}`)
+		case "torrent-set":
+			sets = append(sets, string(args))
+			fmt.Fprintf(w, `{"result":"success"}`)
+		case "torrent-get":
+			metadataChecks++
+			done := 0
+			if metadataChecks > 1 {
+				done = 1
+			}
+			fmt.Fprintf(w, `{"result":"success","arguments":{"torrents":[{"metadataPercentComplete":%d}]}}`, done)
+		default:
+			fmt.Fprintf(w, `{"result":"success"}`)
+		}
+	})
+
+	got, err := client.AddTorrentWithSettings(context.Background(), &AddTorrentReq{
+		URL: OptString("magnet:?xt=urn:btih:somelink"),
+	}, &AddTorrentSettings{
+		Set:                  &SetTorrentReq{WantedFiles: []int{0}, UploadRatioLimit: OptFloat64(2)},
+		Queue:                QueueTop,
+		MetadataPollInterval: time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := (&NewTorrent{ID: 1, Hash: "12345", Name: "t"}); !cmp.Equal(want, got) {
+		t.Errorf("unexpected response, diff = \n%s", cmp.Diff(want, got))
+	}
+
+	// The torrent is stopped as soon as the metadata arrives and started
+	// again once the files are configured.
+	want := []string{"torrent-add", "torrent-set", "torrent-get", "torrent-start", "torrent-get", "torrent-stop",
+		"torrent-set", "queue-move-top", "torrent-start"}
+	if !cmp.Equal(want, *methods) {
+		t.Errorf("unexpected methods, diff = \n%s", cmp.Diff(want, *methods))
+	}
+	// File settings are applied once the metadata is received.
+	if len(sets) != 2 ||
+		!strings.Contains(sets[0], `"seedRatioLimit":2`) || !strings.Contains(sets[0], `"files-wanted":null`) ||
+		!strings.Contains(sets[1], `"files-wanted":[0]`) || strings.Contains(sets[1], "seedRatioLimit") {
+		t.Errorf("unexpected torrent-set arguments: %q", sets)
+	}
+}
+
+func TestAddTorrentWithSettings_paused(t *testing.T) {
+	client, handle, teardown := setup(t)
+	defer teardown()
+
+	methods := recordMethods(t, handle, func(w http.ResponseWriter, method string, args json.RawMessage) {
+		switch method {
+		case "torrent-add":
+			fmt.Fprintf(w, `{"result":"success","arguments":{"torrent-added":{"id":1,"hashString":"12345","name":"t"}}}`)
+		default:
+			fmt.Fprintf(w, `{"result":"success"}`)
+		}
+	})
+
+	_, err := client.AddTorrentWithSettings(context.Background(), &AddTorrentReq{
+		URL:    OptString("http://example.com/file.torrent"),
+		Paused: OptBool(true),
+	}, &AddTorrentSettings{
+		Set: &SetTorrentReq{UploadRatioLimit: OptFloat64(2)},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := []string{"torrent-add", "torrent-set"}; !cmp.Equal(want, *methods) {
+		t.Errorf("unexpected methods, diff = \n%s", cmp.Diff(want, *methods))
+	}
+}
+
+func TestAddTorrentWithSettings_rollback(t *testing.T) {
+	client, handle, teardown := setup(t)
+	defer teardown()
+
+	methods := recordMethods(t, handle, func(w http.ResponseWriter, method string, args json.RawMessage) {
+		switch method {
+		case "torrent-add":
+			fmt.Fprintf(w, `{"result":"success","arguments":{"torrent-added":{"id":1,"hashString":"12345","name":"t"}}}`)
+		case "torrent-set":
+			fmt.Fprintf(w, `{"result":"invalid argument"}`)
+		case "torrent-remove":
+			if want, got := `{"ids":[1],"delete-local-data":false}`, string(args); want != got {
+				t.Errorf("unexpected torrent-remove arguments, want = %q, got = %q", want, got)
+			}
+			fmt.Fprintf(w, `{"result":"success"}`)
+		default:
+			t.Errorf("unexpected method %q", method)
+		}
+	})
+
+	_, err := client.AddTorrentWithSettings(context.Background(), &AddTorrentReq{
+		URL: OptString("http://example.com/file.torrent"),
+	}, &AddTorrentSettings{
+		Set: &SetTorrentReq{UploadRatioLimit: OptFloat64(2)},
+	})
+	if err == nil {
+		t.Fatalf("expected add to fail")
+	}
+	if want := []string{"torrent-add", "torrent-set", "torrent-remove"}; !cmp.Equal(want, *methods) {
+		t.Errorf("unexpected methods, diff = \n%s", cmp.Diff(want, *methods))
+	}
+}
+
+func TestAddTorrentWithSettings_duplicate(t *testing.T) {
+	client, handle, teardown := setup(t)
+	defer teardown()
+
+	methods := recordMethods(t, handle, func(w http.ResponseWriter, method string, args json.RawMessage) {
+		fmt.Fprintf(w, `{"result":"success","arguments":{"torrent-duplicate":{"id":1,"hashString":"12345","name":"t"}}}`)
+	})
+
+	_, err := client.AddTorrentWithSettings(context.Background(), &AddTorrentReq{
+		URL: OptString("http://example.com/file.torrent"),
+	}, &AddTorrentSettings{
+		Set: &SetTorrentReq{UploadRatioLimit: OptFloat64(2)},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := []string{"torrent-add"}; !cmp.Equal(want, *methods) {
+		t.Errorf("unexpected methods, diff = \n%s", cmp.Diff(want, *methods))
+	}
+}
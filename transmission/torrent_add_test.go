@@ -2,6 +2,8 @@ package transmission
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"strings"
@@ -143,11 +145,115 @@ func TestAddTorrent_duplicate(t *testing.T) {
 		t.Errorf("unexpected error: %v", err)
 	}
 	want := &NewTorrent{
-		ID:   1,
-		Hash: "12345",
-		Name: "some-torrent",
+		ID:        1,
+		Hash:      "12345",
+		Name:      "some-torrent",
+		Duplicate: true,
 	}
 	if !cmp.Equal(want, got) {
 		t.Fatalf("unexpected response, diff = \n%s", cmp.Diff(want, got))
 	}
 }
+
+func TestAddTorrent_duplicateError(t *testing.T) {
+	client, handle, teardown := setup(t)
+	defer teardown()
+
+	handle(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"result":"success","arguments":{"torrent-duplicate":{"id":1,"hashString":"12345","name":"t"}}}`)
+	})
+
+	got, err := client.AddTorrent(context.Background(), &AddTorrentReq{
+		URL:         OptString("http://example.com/file.torrent"),
+		OnDuplicate: DuplicateError,
+	})
+	if !errors.Is(err, ErrDuplicateTorrent) {
+		t.Errorf("unexpected error, want = %v, got = %v", ErrDuplicateTorrent, err)
+	}
+	if got == nil || got.ID != 1 {
+		t.Errorf("expected existing torrent to be returned, got = %v", got)
+	}
+}
+
+func TestAddTorrent_duplicateMerge(t *testing.T) {
+	client, handle, teardown := setup(t)
+	defer teardown()
+
+	methods := recordMethods(t, handle, func(w http.ResponseWriter, method string, args json.RawMessage) {
+		switch method {
+		case "torrent-add":
+			fmt.Fprintf(w, `{"result":"success","arguments":{"torrent-duplicate":`+
+				`{"id":1,"hashString":"c12fe1c06bba254a9dc9f519b335aa7c1367a88a","name":"t"}}}`)
+		case "torrent-get":
+			fmt.Fprintf(w, `{"result":"success","arguments":{"torrents":[{
+				"labels":["tv"],
+				"trackers":[{"id":0,"tier":0,"announce":"http://a/announce","scrape":"http://a/scrape"}]
+			}]}}`)
+		case "torrent-set":
+			want := `{"priority-high":null,"priority-normal":null,"priority-low":null,"files-wanted":null,` +
+				`"files-unwanted":null,"labels":["tv","hd"],"ids":[1],"trackerAdd":["http://b/announce"]}`
+			if got := string(args); want != got {
+				t.Errorf("unexpected torrent-set arguments, want = %q, got = %q", want, got)
+			}
+			fmt.Fprintf(w, `{"result":"success"}`)
+		}
+	})
+
+	got, err := client.AddTorrent(context.Background(), &AddTorrentReq{
+		URL: OptString("magnet:?xt=urn:btih:c12fe1c06bba254a9dc9f519b335aa7c1367a88a" +
+			"&tr=http%3A%2F%2Fa%2Fannounce&tr=http%3A%2F%2Fb%2Fannounce"),
+		Labels:      []string{"hd", "tv"},
+		OnDuplicate: DuplicateMerge,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !got.Duplicate {
+		t.Errorf("expected torrent to be marked as duplicate")
+	}
+	if want := []string{"torrent-add", "torrent-get", "torrent-set"}; !cmp.Equal(want, *methods) {
+		t.Errorf("unexpected methods, diff = \n%s", cmp.Diff(want, *methods))
+	}
+}
+
+func TestAddTorrent_hashMismatch(t *testing.T) {
+	client, handle, teardown := setup(t)
+	defer teardown()
+
+	methods := recordMethods(t, handle, func(w http.ResponseWriter, method string, args json.RawMessage) {
+		switch method {
+		case "torrent-add":
+			fmt.Fprintf(w, `{"result":"success","arguments":{"torrent-added":{"id":1,"hashString":"12345","name":"t"}}}`)
+		case "torrent-remove":
+			if want, got := `{"ids":[1],"delete-local-data":false}`, string(args); want != got {
+				t.Errorf("unexpected torrent-remove arguments, want = %q, got = %q", want, got)
+			}
+			fmt.Fprintf(w, `{"result":"success"}`)
+		}
+	})
+
+	got, err := client.AddTorrent(context.Background(), &AddTorrentReq{
+		URL: OptString("magnet:?xt=urn:btih:c12fe1c06bba254a9dc9f519b335aa7c1367a88a"),
+	})
+	if err == nil {
+		t.Errorf("expected hash mismatch to fail")
+	}
+	if got != nil {
+		t.Errorf("expected no torrent to be returned, got = %v", got)
+	}
+	if want := []string{"torrent-add", "torrent-remove"}; !cmp.Equal(want, *methods) {
+		t.Errorf("unexpected methods, diff = \n%s", cmp.Diff(want, *methods))
+	}
+}
+
+func TestRequestedTrackers_meta(t *testing.T) {
+	meta := []byte("d13:announce-listll17:http://a/announceel17:http://b/announceee4:infod4:name1:xee")
+
+	var got []string
+	for _, u := range requestedTrackers(&AddTorrentReq{}, meta) {
+		got = append(got, u.String())
+	}
+	if want := []string{"http://a/announce", "http://b/announce"}; !cmp.Equal(want, got) {
+		t.Errorf("unexpected trackers, diff = \n%s", cmp.Diff(want, got))
+	}
+}
@@ -75,6 +75,7 @@ const (
 	TorrentFieldPieces                   TorrentField = "pieces"
 	TorrentFieldTrackers                 TorrentField = "trackers"
 	TorrentFieldTrackerStats             TorrentField = "trackerStats"
+	TorrentFieldTrackerList              TorrentField = "trackerList"
 )
 
 var allTorrentFields = []TorrentField{
@@ -147,4 +148,5 @@ var allTorrentFields = []TorrentField{
 	TorrentFieldPieces,
 	TorrentFieldTrackers,
 	TorrentFieldTrackerStats,
+	TorrentFieldTrackerList,
 }
@@ -89,7 +89,8 @@ func TestGetTorrents(t *testing.T) {
 			    "pieceSize",
 			    "pieces",
 			    "trackers",
-			    "trackerStats"
+			    "trackerStats",
+			    "trackerList"
 			  ]
 		        }
 		}`)
@@ -313,7 +314,8 @@ func TestGetTorrents(t *testing.T) {
 				"seederCount": -1,
 				"tier": 1
 			      }
-			    ]
+			    ],
+			    "trackerList": "http://tracker.trackerfix.com:80/announce\n\nudp://9.rarbg.to:2740"
 		          }]
 		        }
 		  }`)
@@ -568,6 +570,10 @@ func TestGetTorrents(t *testing.T) {
 					NextScrapeTime:        time.Date(2020, 04, 28, 9, 11, 0, 0, time.UTC),
 				},
 			},
+			TrackerList: TrackerList{
+				{parseTestURL(t, "http://tracker.trackerfix.com:80/announce")},
+				{parseTestURL(t, "udp://9.rarbg.to:2740")},
+			},
 		},
 	}
 	if !cmp.Equal(want, got) {
@@ -47,7 +47,7 @@ type SetTorrentReq struct {
 	PeerLimit *int `json:"peer-limit,omitempty"`
 
 	// New location of the torrent contents
-	Location *string `json:"location,omitempty"`
+	Location *string `json:"location,omitempty" path:"true"`
 
 	// Torrent labels
 	Labels []string `json:"labels"`
@@ -67,12 +67,31 @@ type SetTorrentReq struct {
 	TrackerToRemove []int `json:"trackerRemove,omitempty"`
 	// List of trackers with updated announcement URIs
 	TrackersToReplace []TrackerReplacement `json:"-"`
+	// Full list of trackers grouped by tiers. An empty list removes all
+	// trackers. Transmission prior to 4.0 doesn't support it, so it is
+	// emulated by adding and removing trackers without preserving tiers
+	TrackerList TrackerList `json:"-"`
 }
 
 // SetTorrents modifies parameters for the torrents identified by ids.
 //
 // https://github.com/transmission/transmission/blob/46b3e6c8dae02531b1eb8907b51611fb9229b54a/extras/rpc-spec.txt#L105
 func (c *Client) SetTorrents(ctx context.Context, ids Identifier, req *SetTorrentReq) error {
+	if req.TrackerList != nil {
+		version, err := c.getRPCVersion(ctx)
+		if err != nil {
+			return err
+		}
+		if version < rpcVersionTrackerList {
+			r := *req
+			r.TrackerList = nil
+			if err := c.SetTorrents(ctx, ids, &r); err != nil {
+				return err
+			}
+			return c.setTrackerListCompat(ctx, ids, req.TrackerList)
+		}
+	}
+
 	uc := c.getUnitConversion()
 
 	var setTorrentsJSON = struct {
@@ -83,6 +102,7 @@ func (c *Client) SetTorrents(ctx context.Context, ids Identifier, req *SetTorren
 		IdleSeedingLimit  *time.Duration `json:"seedIdleLimit,omitempty"`
 		TrackersToAdd     []string       `json:"trackerAdd,omitempty"`
 		TrackersToReplace []interface{}  `json:"trackerReplace,omitempty"`
+		TrackerList       *string        `json:"trackerList,omitempty"`
 	}{
 		SetTorrentReq: req,
 		IDs:           ids,
@@ -110,5 +130,9 @@ func (c *Client) SetTorrents(ctx context.Context, ids Identifier, req *SetTorren
 		}
 	}
 
+	if req.TrackerList != nil {
+		setTorrentsJSON.TrackerList = OptString(req.TrackerList.String())
+	}
+
 	return c.callRPC(ctx, "torrent-set", &setTorrentsJSON, nil)
 }
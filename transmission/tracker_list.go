@@ -0,0 +1,153 @@
+package transmission
+
+import (
+	"context"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// rpcVersionTrackerList is the first RPC version that supports trackerList
+// torrent field.
+const rpcVersionTrackerList = 17
+
+// TrackerList is an ordered list of tracker tiers. Each tier holds announce
+// URLs of the trackers within the tier.
+type TrackerList [][]*url.URL
+
+// ParseTrackerList parses tracker list in Transmission format: one announce URL
+// per line with tiers separated by blank lines.
+func ParseTrackerList(s string) (TrackerList, error) {
+	list := TrackerList{}
+	var tier []*url.URL
+	for _, line := range strings.Split(s, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			if len(tier) > 0 {
+				list = append(list, tier)
+				tier = nil
+			}
+			continue
+		}
+		u, err := url.Parse(line)
+		if err != nil {
+			return nil, err
+		}
+		tier = append(tier, u)
+	}
+	if len(tier) > 0 {
+		list = append(list, tier)
+	}
+
+	return list, nil
+}
+
+// String returns tracker list in Transmission format.
+func (l TrackerList) String() string {
+	tiers := make([]string, 0, len(l))
+	for _, tier := range l {
+		if len(tier) == 0 {
+			continue
+		}
+		urls := make([]string, len(tier))
+		for i, u := range tier {
+			urls[i] = u.String()
+		}
+		tiers = append(tiers, strings.Join(urls, "\n"))
+	}
+	return strings.Join(tiers, "\n\n")
+}
+
+// URLs returns announce URLs of all trackers in the list in tier order.
+func (l TrackerList) URLs() []*url.URL {
+	var urls []*url.URL
+	for _, tier := range l {
+		urls = append(urls, tier...)
+	}
+	return urls
+}
+
+// TrackerListFromTrackers builds tracker list from torrent trackers grouping
+// them by tier.
+func TrackerListFromTrackers(trackers []Tracker) TrackerList {
+	sorted := make([]Tracker, len(trackers))
+	copy(sorted, trackers)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Tier < sorted[j].Tier })
+
+	list := TrackerList{}
+	for i, tr := range sorted {
+		if i == 0 || tr.Tier != sorted[i-1].Tier {
+			list = append(list, nil)
+		}
+		list[len(list)-1] = append(list[len(list)-1], tr.AnnounceURL)
+	}
+	return list
+}
+
+func (c *Client) getRPCVersion(ctx context.Context) (int, error) {
+	if v, ok := c.rpcVersion.Load().(int); ok && v > 0 {
+		return v, nil
+	}
+
+	var getSessionReq = struct {
+		Fields []SessionField `json:"fields"`
+	}{[]SessionField{SessionFieldRPCVersion}}
+
+	var resp = struct {
+		RPCVersion int `json:"rpc-version"`
+	}{}
+	if err := c.callRPC(ctx, "session-get", getSessionReq, &resp); err != nil {
+		return 0, err
+	}
+	c.rpcVersion.Store(resp.RPCVersion)
+
+	return resp.RPCVersion, nil
+}
+
+// setTrackerListCompat emulates trackerList on daemons that don't support it
+// by adding missing and removing extra trackers of every torrent. Extra and
+// missing trackers are paired in order and replaced instead, so that a
+// tracker whose URL changed keeps its ID and stats. Such daemons place every
+// added tracker in its own tier, so tiers aren't preserved.
+func (c *Client) setTrackerListCompat(ctx context.Context, ids Identifier, list TrackerList) error {
+	torrents, err := c.GetTorrents(ctx, ids, TorrentFieldID, TorrentFieldTrackers)
+	if err != nil {
+		return err
+	}
+
+	wanted := make(map[string]bool)
+	for _, u := range list.URLs() {
+		wanted[u.String()] = true
+	}
+	for _, t := range torrents {
+		req := new(SetTorrentReq)
+		existing := make(map[string]bool)
+		for _, tr := range t.Trackers {
+			existing[tr.AnnounceURL.String()] = true
+			if !wanted[tr.AnnounceURL.String()] {
+				req.TrackerToRemove = append(req.TrackerToRemove, tr.ID)
+			}
+		}
+		for _, u := range list.URLs() {
+			if !existing[u.String()] {
+				existing[u.String()] = true
+				req.TrackersToAdd = append(req.TrackersToAdd, u)
+			}
+		}
+		for len(req.TrackerToRemove) > 0 && len(req.TrackersToAdd) > 0 {
+			req.TrackersToReplace = append(req.TrackersToReplace, TrackerReplacement{
+				ID:          req.TrackerToRemove[0],
+				AnnounceURL: req.TrackersToAdd[0],
+			})
+			req.TrackerToRemove, req.TrackersToAdd = req.TrackerToRemove[1:], req.TrackersToAdd[1:]
+		}
+		if len(req.TrackerToRemove) == 0 && len(req.TrackersToAdd) == 0 && len(req.TrackersToReplace) == 0 {
+			continue
+		}
+		if err := c.SetTorrents(ctx, t.ID, req); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,171 @@
+package transmission
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestTrackerList(t *testing.T) {
+	const str = "http://a.example.com/announce\nhttp://b.example.com/announce\n\nudp://c.example.com:80"
+
+	list, err := ParseTrackerList("\n  http://a.example.com/announce\nhttp://b.example.com/announce\n\n\n" +
+		"udp://c.example.com:80\n")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := TrackerList{
+		{parseTestURL(t, "http://a.example.com/announce"), parseTestURL(t, "http://b.example.com/announce")},
+		{parseTestURL(t, "udp://c.example.com:80")},
+	}
+	if !cmp.Equal(want, list) {
+		t.Errorf("unexpected tracker list, diff = \n%s", cmp.Diff(want, list))
+	}
+	if got := list.String(); str != got {
+		t.Errorf("unexpected string, want = %q, got = %q", str, got)
+	}
+
+	empty, err := ParseTrackerList("")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(empty) != 0 || empty.String() != "" {
+		t.Errorf("expected empty tracker list, got %v", empty)
+	}
+}
+
+func TestTrackerListFromTrackers(t *testing.T) {
+	got := TrackerListFromTrackers([]Tracker{
+		{ID: 0, Tier: 1, AnnounceURL: parseTestURL(t, "http://b")},
+		{ID: 1, Tier: 0, AnnounceURL: parseTestURL(t, "http://a")},
+		{ID: 2, Tier: 1, AnnounceURL: parseTestURL(t, "http://c")},
+	})
+	want := TrackerList{
+		{parseTestURL(t, "http://a")},
+		{parseTestURL(t, "http://b"), parseTestURL(t, "http://c")},
+	}
+	if !cmp.Equal(want, got) {
+		t.Errorf("unexpected tracker list, diff = \n%s", cmp.Diff(want, got))
+	}
+}
+
+func TestSetTorrents_trackerList(t *testing.T) {
+	var tests = []struct {
+		name       string
+		rpcVersion int
+		want       []string
+	}{
+		{
+			name:       "native",
+			rpcVersion: 17,
+			want: []string{
+				`{"method":"session-get","arguments":{"fields":["rpc-version"]}}`,
+				`{"method":"torrent-set","arguments":{"priority-high":null,"priority-normal":null,"priority-low":null,` +
+					`"files-wanted":null,"files-unwanted":null,"labels":null,"ids":1,` +
+					`"trackerList":"http://a\n\nhttp://b"}}`,
+			},
+		},
+		{
+			name:       "compat",
+			rpcVersion: 16,
+			want: []string{
+				`{"method":"session-get","arguments":{"fields":["rpc-version"]}}`,
+				`{"method":"torrent-set","arguments":{"priority-high":null,"priority-normal":null,"priority-low":null,` +
+					`"files-wanted":null,"files-unwanted":null,"labels":null,"ids":1}}`,
+				`{"method":"torrent-get","arguments":{"ids":1,"fields":["id","trackers"]}}`,
+				`{"method":"torrent-set","arguments":{"priority-high":null,"priority-normal":null,"priority-low":null,` +
+					`"files-wanted":null,"files-unwanted":null,"labels":null,"ids":1,` +
+					`"trackerReplace":[0,"http://b"]}}`,
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			client, handle, teardown := setup(t)
+			defer teardown()
+
+			var got []string
+			handle(func(w http.ResponseWriter, r *http.Request) {
+				body, err := io.ReadAll(r.Body)
+				if err != nil {
+					t.Fatalf("failed to read request body: %v", err)
+				}
+				var req struct {
+					Method string `json:"method"`
+				}
+				if err := json.Unmarshal(body, &req); err != nil {
+					t.Fatalf("failed to decode request: %v", err)
+				}
+				got = append(got, string(body[:len(body)-1]))
+
+				switch req.Method {
+				case "session-get":
+					fmt.Fprintf(w, `{"result":"success","arguments":{"rpc-version":%d}}`, tc.rpcVersion)
+				case "torrent-get":
+					fmt.Fprint(w, `{"result":"success","arguments":{"torrents":[{"id":1,"trackers":[
+						{"id":0,"tier":0,"announce":"http://c","scrape":""},
+						{"id":1,"tier":1,"announce":"http://a","scrape":""}
+					]}]}}`)
+				default:
+					fmt.Fprint(w, `{"result":"success"}`)
+				}
+			})
+
+			err := client.SetTorrents(context.Background(), ID(1), &SetTorrentReq{
+				TrackerList: TrackerList{{parseTestURL(t, "http://a")}, {parseTestURL(t, "http://b")}},
+			})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !cmp.Equal(tc.want, got) {
+				t.Errorf("unexpected requests, diff = \n%s", cmp.Diff(tc.want, got))
+			}
+		})
+	}
+}
+
+func TestGetRPCVersion_newSession(t *testing.T) {
+	client, handle, teardown := setup(t)
+	defer teardown()
+
+	// The daemon is restarted with a newer version after the first call.
+	session, version := "a", 16
+	var calls int
+	handle(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get(headerCSRF) != session {
+			w.Header().Add(headerCSRF, session)
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+		calls++
+		fmt.Fprintf(w, `{"result":"success","arguments":{"rpc-version":%d}}`, version)
+	})
+
+	ctx := context.Background()
+	for i, want := range []int{16, 16, 17} {
+		if i == 2 {
+			// The restart is noticed by the next call.
+			session, version = "b", 17
+			if err := client.callRPC(ctx, "test", nil, nil); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		}
+		got, err := client.getRPCVersion(ctx)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if want != got {
+			t.Errorf("unexpected RPC version, want = %d, got = %d", want, got)
+		}
+	}
+	if want := 3; calls != want {
+		t.Errorf("unexpected number of calls, want = %d, got = %d", want, calls)
+	}
+}
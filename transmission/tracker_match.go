@@ -0,0 +1,37 @@
+package transmission
+
+import (
+	"net/url"
+	"strings"
+)
+
+// MatchTracker reports whether any tracker of the torrent announces to one of
+// hosts or their subdomains. Hosts are matched case-insensitively. The torrent
+// must be requested with TorrentFieldTrackers or TorrentFieldTrackerStats.
+func MatchTracker(t *Torrent, hosts ...string) bool {
+	for i := range t.Trackers {
+		if matchTrackerHost(t.Trackers[i].AnnounceURL, hosts) {
+			return true
+		}
+	}
+	for i := range t.TrackerStats {
+		if matchTrackerHost(t.TrackerStats[i].AnnounceURL, hosts) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchTrackerHost(u *url.URL, hosts []string) bool {
+	if u == nil {
+		return false
+	}
+	host := strings.ToLower(u.Hostname())
+	for _, want := range hosts {
+		want = strings.ToLower(want)
+		if host == want || strings.HasSuffix(host, "."+want) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,33 @@
+package transmission
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestMatchTracker(t *testing.T) {
+	torrent := &Torrent{
+		Trackers:     []Tracker{{AnnounceURL: &url.URL{Scheme: "https", Host: "t.Example.org:443"}}},
+		TrackerStats: []TrackerStat{{AnnounceURL: &url.URL{Scheme: "udp", Host: "other.net:6969"}}},
+	}
+
+	var tests = []struct {
+		hosts []string
+		want  bool
+	}{
+		{hosts: []string{"example.org"}, want: true},
+		{hosts: []string{"T.EXAMPLE.ORG"}, want: true},
+		{hosts: []string{"nope.org", "other.net"}, want: true},
+		{hosts: []string{"ample.org"}, want: false},
+		{hosts: nil, want: false},
+	}
+
+	for _, tc := range tests {
+		if got := MatchTracker(torrent, tc.hosts...); got != tc.want {
+			t.Errorf("unexpected match of %v, want = %v, got = %v", tc.hosts, tc.want, got)
+		}
+	}
+	if MatchTracker(&Torrent{TrackerStats: []TrackerStat{{}}}, "example.org") {
+		t.Errorf("expected tracker without announce URL not to match")
+	}
+}
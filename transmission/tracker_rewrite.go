@@ -0,0 +1,211 @@
+package transmission
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// TrackerChange describes a single planned change of a tracker announce URL.
+type TrackerChange struct {
+	// ID of the tracker within the torrent
+	ID int
+	// Current announce URL
+	From *url.URL
+	// New announce URL. It is nil if the tracker is removed because the
+	// torrent already has a tracker with the new announce URL
+	To *url.URL
+}
+
+// TrackerRewriteResult holds the outcome of rewriting trackers of a single
+// torrent.
+type TrackerRewriteResult struct {
+	// ID of the torrent
+	ID ID
+	// Hash of the torrent
+	Hash Hash
+	// Name of the torrent
+	Name string
+	// Changes planned for the torrent
+	Changes []TrackerChange
+	// Indicates whether the changes were sent to Transmission
+	Applied bool
+	// An error that prevented the changes from being applied
+	Err error
+}
+
+// String returns a human readable description of the planned changes, one line
+// per tracker.
+func (r *TrackerRewriteResult) String() string {
+	var b strings.Builder
+	for _, ch := range r.Changes {
+		if ch.To == nil {
+			fmt.Fprintf(&b, "torrent %d (%s): tracker %d %s -> removed (duplicate)\n", r.ID, r.Name, ch.ID, ch.From)
+		} else {
+			fmt.Fprintf(&b, "torrent %d (%s): tracker %d %s -> %s\n", r.ID, r.Name, ch.ID, ch.From, ch.To)
+		}
+	}
+	if r.Err != nil {
+		fmt.Fprintf(&b, "torrent %d (%s): %v\n", r.ID, r.Name, r.Err)
+	}
+	return b.String()
+}
+
+// request builds the request applying the changes. Transmission rejects a
+// replacement whose URL is still used by another tracker of the torrent, so
+// trackers are replaced in the order that frees their new URLs first. Changes
+// that swap URLs can't be ordered this way and are refused.
+func (r *TrackerRewriteResult) request() (*SetTorrentReq, error) {
+	req := new(SetTorrentReq)
+	var pending []TrackerChange
+	for _, ch := range r.Changes {
+		if ch.To == nil {
+			req.TrackerToRemove = append(req.TrackerToRemove, ch.ID)
+			continue
+		}
+		pending = append(pending, ch)
+	}
+
+	for len(pending) > 0 {
+		used := make(map[string]bool, len(pending))
+		for _, ch := range pending {
+			used[ch.From.String()] = true
+		}
+
+		var rest []TrackerChange
+		for _, ch := range pending {
+			if used[ch.To.String()] {
+				rest = append(rest, ch)
+				continue
+			}
+			req.TrackersToReplace = append(req.TrackersToReplace, TrackerReplacement{
+				ID:          ch.ID,
+				AnnounceURL: ch.To,
+			})
+		}
+		if len(rest) == len(pending) {
+			return nil, errors.New("transmission: tracker rewrite swaps announce URLs")
+		}
+		pending = rest
+	}
+	return req, nil
+}
+
+// rewriteKey returns a string that is equal for requests making the same
+// changes.
+func rewriteKey(req *SetTorrentReq) string {
+	var b strings.Builder
+	fmt.Fprint(&b, req.TrackerToRemove)
+	for _, tr := range req.TrackersToReplace {
+		fmt.Fprintf(&b, " %d=%s", tr.ID, tr.AnnounceURL)
+	}
+	return b.String()
+}
+
+// PlanTrackerRewrite computes tracker changes for the torrents identified by
+// ids without applying them. The rewrite function is called for every
+// tracker announce URL and must return the new URL, or nil to leave the tracker
+// unchanged. The URL passed to rewrite is a copy and may be modified in place.
+//
+// Only torrents with at least one change are returned. Torrents whose changes
+// can't be applied, such as swapping announce URLs of two trackers, have Err
+// set.
+func (c *Client) PlanTrackerRewrite(ctx context.Context, ids Identifier, rewrite func(*url.URL) *url.URL) ([]*TrackerRewriteResult, error) { //nolint:lll
+	torrents, err := c.GetTorrents(ctx, ids, TorrentFieldID, TorrentFieldHash, TorrentFieldName, TorrentFieldTrackers)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]*TrackerRewriteResult, 0, len(torrents))
+	for _, t := range torrents {
+		// Duplicates are detected among the rewritten URLs, so that chained
+		// rewrites (a -> b, b -> c) don't lose trackers. Trackers that are
+		// left unchanged take precedence over rewritten ones.
+		rewritten := make([]*url.URL, len(t.Trackers))
+		seen := make(map[string]bool, len(t.Trackers))
+		for i, tr := range t.Trackers {
+			from := *tr.AnnounceURL
+			if to := rewrite(&from); to != nil && to.String() != tr.AnnounceURL.String() {
+				rewritten[i] = to
+			} else {
+				seen[tr.AnnounceURL.String()] = true
+			}
+		}
+
+		res := &TrackerRewriteResult{ID: t.ID, Hash: t.Hash, Name: t.Name}
+		for i, tr := range t.Trackers {
+			to := rewritten[i]
+			if to == nil {
+				continue
+			}
+
+			ch := TrackerChange{ID: tr.ID, From: tr.AnnounceURL, To: to}
+			if seen[to.String()] {
+				ch.To = nil
+			}
+			seen[to.String()] = true
+			res.Changes = append(res.Changes, ch)
+		}
+		if len(res.Changes) > 0 {
+			if _, err := res.request(); err != nil {
+				res.Err = err
+			}
+			results = append(results, res)
+		}
+	}
+
+	return results, nil
+}
+
+// RewriteTrackers rewrites announce URLs of trackers of the torrents
+// identified by ids using rewrite function (see PlanTrackerRewrite). Torrents
+// with identical changes are updated in one request. A failure to update
+// torrents doesn't stop the process, instead it is reported in the
+// corresponding results.
+func (c *Client) RewriteTrackers(ctx context.Context, ids Identifier, rewrite func(*url.URL) *url.URL) ([]*TrackerRewriteResult, error) { //nolint:lll
+	results, err := c.PlanTrackerRewrite(ctx, ids, rewrite)
+	if err != nil {
+		return nil, err
+	}
+
+	type group struct {
+		req     *SetTorrentReq
+		ids     IDList
+		results []*TrackerRewriteResult
+	}
+	var groups []*group
+	byKey := make(map[string]*group)
+	for _, res := range results {
+		if res.Err != nil {
+			continue
+		}
+		req, err := res.request()
+		if err != nil {
+			res.Err = err
+			continue
+		}
+		key := rewriteKey(req)
+		g, ok := byKey[key]
+		if !ok {
+			g = &group{req: req}
+			byKey[key] = g
+			groups = append(groups, g)
+		}
+		g.ids = append(g.ids, res.ID)
+		g.results = append(g.results, res)
+	}
+
+	for _, g := range groups {
+		err := ctx.Err()
+		if err == nil {
+			err = c.SetTorrents(ctx, g.ids, g.req)
+		}
+		for _, res := range g.results {
+			res.Err, res.Applied = err, err == nil
+		}
+	}
+
+	return results, nil
+}
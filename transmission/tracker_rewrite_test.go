@@ -0,0 +1,224 @@
+package transmission
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+const trackerRewriteTorrents = `{"result":"success","arguments":{"torrents":[
+	{"id":1,"hashString":"aaaa","name":"first","trackers":[
+	  {"id":0,"tier":0,"announce":"http://old.example.com/announce?passkey=1","scrape":""},
+	  {"id":1,"tier":1,"announce":"http://other.example.org/announce","scrape":""}
+	]},
+	{"id":2,"hashString":"bbbb","name":"second","trackers":[
+	  {"id":0,"tier":0,"announce":"http://old.example.com/announce?passkey=1","scrape":""},
+	  {"id":1,"tier":0,"announce":"http://new.example.com/announce?passkey=1","scrape":""}
+	]},
+	{"id":3,"hashString":"cccc","name":"third","trackers":[
+	  {"id":0,"tier":0,"announce":"http://other.example.org/announce","scrape":""}
+	]}
+]}}`
+
+func rewriteTestHost(u *url.URL) *url.URL {
+	if u.Host != "old.example.com" {
+		return nil
+	}
+	u.Host = "new.example.com"
+	return u
+}
+
+func TestPlanTrackerRewrite(t *testing.T) {
+	client, handle, teardown := setup(t)
+	defer teardown()
+
+	handle(func(w http.ResponseWriter, r *http.Request) {
+		testBody(t, r, `{"method":"torrent-get","arguments":{"fields":["id","hashString","name","trackers"]}}`)
+
+		fmt.Fprint(w, trackerRewriteTorrents)
+	})
+
+	results, err := client.PlanTrackerRewrite(context.Background(), All(), rewriteTestHost)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var got string
+	for _, r := range results {
+		got += r.String()
+	}
+	want := "torrent 1 (first): tracker 0 http://old.example.com/announce?passkey=1 -> " +
+		"http://new.example.com/announce?passkey=1\n" +
+		"torrent 2 (second): tracker 0 http://old.example.com/announce?passkey=1 -> removed (duplicate)\n"
+	if want != got {
+		t.Errorf("unexpected plan, diff = \n%s", cmp.Diff(want, got))
+	}
+}
+
+func TestPlanTrackerRewrite_chained(t *testing.T) {
+	client, handle, teardown := setup(t)
+	defer teardown()
+
+	handle(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"result":"success","arguments":{"torrents":[
+			{"id":1,"hashString":"aaaa","name":"first","trackers":[
+			  {"id":0,"tier":0,"announce":"http://x.example.com/announce","scrape":""},
+			  {"id":1,"tier":1,"announce":"http://y.example.com/announce","scrape":""}
+			]}
+		]}}`)
+	})
+
+	chain := map[string]string{"x.example.com": "y.example.com", "y.example.com": "z.example.com"}
+	results, err := client.PlanTrackerRewrite(context.Background(), All(), func(u *url.URL) *url.URL {
+		host, ok := chain[u.Host]
+		if !ok {
+			return nil
+		}
+		u.Host = host
+		return u
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var got string
+	for _, r := range results {
+		got += r.String()
+	}
+	want := "torrent 1 (first): tracker 0 http://x.example.com/announce -> http://y.example.com/announce\n" +
+		"torrent 1 (first): tracker 1 http://y.example.com/announce -> http://z.example.com/announce\n"
+	if want != got {
+		t.Errorf("unexpected plan, diff = \n%s", cmp.Diff(want, got))
+	}
+}
+
+func TestRewriteTrackers(t *testing.T) {
+	client, handle, teardown := setup(t)
+	defer teardown()
+
+	var setBodies []string
+	handle(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Fatalf("failed to read request body: %v", err)
+		}
+		var req struct {
+			Method string `json:"method"`
+		}
+		if err := json.Unmarshal(body, &req); err != nil {
+			t.Fatalf("failed to decode request: %v", err)
+		}
+
+		switch req.Method {
+		case "torrent-get":
+			fmt.Fprint(w, trackerRewriteTorrents)
+		case "torrent-set":
+			setBodies = append(setBodies, string(body))
+			if bytes.Contains(body, []byte(`"ids":[2]`)) {
+				fmt.Fprint(w, `{"result":"no such torrent"}`)
+				return
+			}
+			fmt.Fprint(w, `{"result":"success"}`)
+		default:
+			t.Errorf("unexpected method %q", req.Method)
+		}
+	})
+
+	results, err := client.RewriteTrackers(context.Background(), All(), rewriteTestHost)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want, got := 2, len(setBodies); want != got {
+		t.Fatalf("unexpected number of torrent-set calls, want = %d, got = %d", want, got)
+	}
+	if !bytes.Contains([]byte(setBodies[0]), []byte(`"trackerReplace":[0,"http://new.example.com/announce?passkey=1"]`)) {
+		t.Errorf("unexpected first request: %s", setBodies[0])
+	}
+	if !bytes.Contains([]byte(setBodies[1]), []byte(`"trackerRemove":[0]`)) {
+		t.Errorf("unexpected second request: %s", setBodies[1])
+	}
+
+	if !results[0].Applied || results[0].Err != nil {
+		t.Errorf("expected first torrent to be updated, got %+v", results[0])
+	}
+	if results[1].Applied || results[1].Err == nil {
+		t.Errorf("expected second torrent to fail, got %+v", results[1])
+	}
+}
+
+func TestRewriteTrackers_order(t *testing.T) {
+	client, handle, teardown := setup(t)
+	defer teardown()
+
+	var setBodies []string
+	handle(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Fatalf("failed to read request body: %v", err)
+		}
+		if !bytes.Contains(body, []byte(`"torrent-set"`)) {
+			fmt.Fprint(w, `{"result":"success","arguments":{"torrents":[
+				{"id":1,"hashString":"aaaa","name":"first","trackers":[
+				  {"id":0,"tier":0,"announce":"http://x.example.com/announce","scrape":""},
+				  {"id":1,"tier":1,"announce":"http://y.example.com/announce","scrape":""}
+				]},
+				{"id":2,"hashString":"bbbb","name":"second","trackers":[
+				  {"id":0,"tier":0,"announce":"http://x.example.com/announce","scrape":""},
+				  {"id":1,"tier":1,"announce":"http://y.example.com/announce","scrape":""}
+				]},
+				{"id":3,"hashString":"cccc","name":"third","trackers":[
+				  {"id":0,"tier":0,"announce":"http://z.example.com/announce","scrape":""},
+				  {"id":1,"tier":1,"announce":"http://w.example.com/announce","scrape":""}
+				]}
+			]}}`)
+			return
+		}
+		setBodies = append(setBodies, strings.TrimSpace(string(body)))
+		fmt.Fprint(w, `{"result":"success"}`)
+	})
+
+	// x -> y -> z is a chain, z <-> w is a swap.
+	hosts := map[string]string{
+		"x.example.com": "y.example.com",
+		"y.example.com": "z.example.com",
+		"z.example.com": "w.example.com",
+		"w.example.com": "z.example.com",
+	}
+	results, err := client.RewriteTrackers(context.Background(), All(), func(u *url.URL) *url.URL {
+		host, ok := hosts[u.Host]
+		if !ok {
+			return nil
+		}
+		u.Host = host
+		return u
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []string{
+		`{"method":"torrent-set","arguments":{"priority-high":null,"priority-normal":null,"priority-low":null,` +
+			`"files-wanted":null,"files-unwanted":null,"labels":null,"ids":[1,2],` +
+			`"trackerReplace":[1,"http://z.example.com/announce",0,"http://y.example.com/announce"]}}`,
+	}
+	if !cmp.Equal(want, setBodies) {
+		t.Errorf("unexpected torrent-set requests, diff = \n%s", cmp.Diff(want, setBodies))
+	}
+
+	for _, res := range results[:2] {
+		if !res.Applied || res.Err != nil {
+			t.Errorf("expected torrent to be updated, got %+v", res)
+		}
+	}
+	if results[2].Applied || results[2].Err == nil {
+		t.Errorf("expected swap to be refused, got %+v", results[2])
+	}
+}
@@ -23,12 +23,14 @@ const (
 type Client struct {
 	config
 
-	url string
+	url   string
+	paths *pathMapper
 
 	mu        sync.Mutex
 	sessionID string
 
 	unitConversion atomic.Value
+	rpcVersion     atomic.Value
 }
 
 // New returns new instance of a Client.
@@ -51,6 +53,7 @@ func New(host string, opts ...Option) (*Client, error) {
 	if c.HTTPClient == nil {
 		c.HTTPClient = http.DefaultClient
 	}
+	c.paths = newPathMapper(c.PathMapping)
 
 	return c, nil
 }
@@ -64,8 +67,14 @@ func (c *Client) getSessionID() string {
 
 func (c *Client) setSessionID(id string) {
 	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// A new session means that the daemon was restarted, possibly upgraded,
+	// so the cached RPC version is forgotten.
+	if id != c.sessionID {
+		c.rpcVersion.Store(0)
+	}
 	c.sessionID = id
-	c.mu.Unlock()
 }
 
 type unitConversion struct {
@@ -122,7 +131,7 @@ func (c *Client) callRPC(ctx context.Context, method string, args interface{}, r
 	reqData := new(bytes.Buffer)
 	if err := json.NewEncoder(reqData).Encode(&rpcRequest{
 		Method:    method,
-		Arguments: args,
+		Arguments: c.paths.args(args),
 	}); err != nil {
 		return err
 	}
@@ -162,6 +171,7 @@ func (c *Client) callRPC(ctx context.Context, method string, args interface{}, r
 	if response.Result != "success" {
 		return fmt.Errorf("transmission: RPC call failed (%s)", response.Result)
 	}
+	c.paths.reply(reply)
 
 	return nil
 }
@@ -0,0 +1,76 @@
+//go:build linux
+
+package watchfolder
+
+import (
+	"os"
+	"sync"
+	"syscall"
+)
+
+const notifyMask = syscall.IN_CLOSE_WRITE | syscall.IN_MOVED_TO | syscall.IN_CREATE
+
+// notifier wakes the watcher up when files are added to the watched
+// directories.
+type notifier struct {
+	f    *os.File
+	once sync.Once
+	ch   chan struct{}
+}
+
+func newNotifier() (*notifier, error) {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return nil, os.NewSyscallError("inotify_init1", err)
+	}
+	return &notifier{
+		// Non-blocking descriptor is registered with the runtime poller, so
+		// closing the file interrupts pending reads.
+		f:  os.NewFile(uintptr(fd), "inotify"),
+		ch: make(chan struct{}, 1),
+	}, nil
+}
+
+// add starts watching directory dir. Adding a directory more than once is
+// harmless.
+func (n *notifier) add(dir string) error {
+	sc, err := n.f.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var werr error
+	err = sc.Control(func(fd uintptr) {
+		_, werr = syscall.InotifyAddWatch(int(fd), dir, notifyMask)
+	})
+	if err != nil {
+		return err
+	}
+	if werr != nil {
+		return &os.PathError{Op: "inotify_add_watch", Path: dir, Err: werr}
+	}
+	return nil
+}
+
+// events returns a channel that receives a value after changes in the watched
+// directories. Multiple changes may be coalesced into one notification.
+func (n *notifier) events() <-chan struct{} {
+	n.once.Do(func() {
+		go func() {
+			buf := make([]byte, 64*(syscall.SizeofInotifyEvent+syscall.NAME_MAX+1))
+			for {
+				if _, err := n.f.Read(buf); err != nil {
+					return
+				}
+				select {
+				case n.ch <- struct{}{}:
+				default:
+				}
+			}
+		}()
+	})
+	return n.ch
+}
+
+func (n *notifier) close() error {
+	return n.f.Close()
+}
@@ -0,0 +1,37 @@
+//go:build linux
+
+package watchfolder
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestNotifier(t *testing.T) {
+	dir := t.TempDir()
+
+	n, err := newNotifier()
+	if err != nil {
+		t.Fatalf("failed to create notifier: %v", err)
+	}
+	defer n.close()
+
+	if err := n.add(dir); err != nil {
+		t.Fatalf("failed to watch directory: %v", err)
+	}
+	if err := n.add(filepath.Join(dir, "missing")); err == nil {
+		t.Errorf("expected watching missing directory to fail")
+	}
+
+	events := n.events()
+	if err := os.WriteFile(filepath.Join(dir, "file.torrent"), []byte("data"), 0o600); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+	select {
+	case <-events:
+	case <-time.After(5 * time.Second):
+		t.Errorf("timed out waiting for the event")
+	}
+}
@@ -0,0 +1,26 @@
+//go:build !linux
+
+package watchfolder
+
+import (
+	"errors"
+)
+
+// notifier is not supported on this system, the watcher relies on polling.
+type notifier struct{}
+
+func newNotifier() (*notifier, error) {
+	return nil, errors.ErrUnsupported
+}
+
+func (n *notifier) add(dir string) error {
+	return errors.ErrUnsupported
+}
+
+func (n *notifier) events() <-chan struct{} {
+	return nil
+}
+
+func (n *notifier) close() error {
+	return nil
+}
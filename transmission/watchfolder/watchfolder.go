@@ -0,0 +1,466 @@
+// Package watchfolder implements a drop folder importer that watches a
+// directory tree for .torrent and .magnet files and adds them to
+// Transmission.
+//
+// Subdirectories of the watched directory select settings of the added
+// torrents. Processed files are moved to the done directory, files that
+// couldn't be added are moved to the failed directory along with a sidecar
+// file that holds the error.
+package watchfolder
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pborzenkov/go-transmission/transmission"
+	"github.com/pborzenkov/go-transmission/transmission/internal/atomicfile"
+	"github.com/pborzenkov/go-transmission/transmission/metainfo"
+)
+
+const (
+	defaultPollInterval = 10 * time.Second
+	defaultSettleTime   = 2 * time.Second
+
+	// ErrorSuffix is appended to the name of a failed file to get the name
+	// of the file holding the error
+	ErrorSuffix = ".error"
+)
+
+// Adder adds torrents to Transmission. *transmission.Client implements it.
+type Adder interface {
+	AddTorrent(ctx context.Context, req *transmission.AddTorrentReq) (*transmission.NewTorrent, error)
+}
+
+// Dir holds settings of torrents dropped into a subdirectory.
+type Dir struct {
+	// Labels to attach to the torrents in addition to Config.Settings
+	// labels
+	Labels []string
+	// Download directory of the torrents, if not empty
+	DownloadDirectory string
+}
+
+// Config configures a Watcher.
+type Config struct {
+	// Directory to watch
+	Root string
+	// Directory processed files are moved to. If empty, .done directory
+	// within Root is used
+	DoneDir string
+	// Directory files that couldn't be added are moved to. If empty,
+	// .failed directory within Root is used
+	FailedDir string
+	// Settings of the subdirectories keyed by their path relative to Root
+	// using '/' as a separator. Files in subdirectories that are not listed
+	// get the subdirectory path components as labels
+	Dirs map[string]Dir
+	// Base settings of the added torrents. URL and Meta are ignored
+	Settings *transmission.AddTorrentReq
+	// Interval between directory scans. If 0, 10 seconds is used
+	PollInterval time.Duration
+	// Minimum time since the last modification of a file before it is
+	// processed, so partially written files are skipped. If 0, 2 seconds is
+	// used
+	SettleTime time.Duration
+	// Use inotify to pick up files without waiting for the next scan. It is
+	// ignored on systems other than Linux
+	Notify bool
+	// File that keeps hashes of the added torrents across restarts. If empty,
+	// the hashes are kept in memory only, and a torrent dropped again after
+	// a restart is sent to the daemon, which rejects it as a duplicate
+	StateFile string
+	// Called with the result of every processed file
+	OnResult func(Result)
+}
+
+// Result is the outcome of processing of a single file.
+type Result struct {
+	// Path of the processed file
+	Path string
+	// Info hash of the torrent, if the file was parsed successfully
+	Hash transmission.Hash
+	// Added torrent
+	Torrent *transmission.NewTorrent
+	// Indicates that the torrent was already added by the watcher, so it
+	// wasn't added again
+	Skipped bool
+	// Error that prevented the torrent from being added
+	Err error
+	// Error that prevented the added torrent from being recorded in
+	// Config.StateFile. The file is still treated as added
+	StateErr error
+}
+
+// Watcher watches a directory tree for torrent files.
+type Watcher struct {
+	adder Adder
+	cfg   Config
+
+	mu   sync.Mutex
+	seen map[transmission.Hash]bool
+}
+
+// New returns a new Watcher that adds torrents using adder.
+func New(adder Adder, cfg Config) (*Watcher, error) {
+	if cfg.Root == "" {
+		return nil, errors.New("watchfolder: root directory is not set")
+	}
+	cfg.Root = filepath.Clean(cfg.Root)
+	if cfg.DoneDir == "" {
+		cfg.DoneDir = filepath.Join(cfg.Root, ".done")
+	}
+	if cfg.FailedDir == "" {
+		cfg.FailedDir = filepath.Join(cfg.Root, ".failed")
+	}
+	cfg.DoneDir, cfg.FailedDir = filepath.Clean(cfg.DoneDir), filepath.Clean(cfg.FailedDir)
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = defaultPollInterval
+	}
+	if cfg.SettleTime <= 0 {
+		cfg.SettleTime = defaultSettleTime
+	}
+
+	w := &Watcher{
+		adder: adder,
+		cfg:   cfg,
+		seen:  make(map[transmission.Hash]bool),
+	}
+	if err := w.load(); err != nil {
+		return nil, fmt.Errorf("watchfolder: failed to load state: %w", err)
+	}
+	return w, nil
+}
+
+// load reads hashes of the torrents added before a restart from
+// Config.StateFile.
+func (w *Watcher) load() error {
+	if w.cfg.StateFile == "" {
+		return nil
+	}
+	data, err := os.ReadFile(w.cfg.StateFile)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return nil
+	case err != nil:
+		return err
+	}
+	var hashes []transmission.Hash
+	if err := json.Unmarshal(data, &hashes); err != nil {
+		return err
+	}
+	for _, h := range hashes {
+		w.seen[h] = true
+	}
+	return nil
+}
+
+// markSeen remembers that the torrent identified by hash was added and
+// rewrites Config.StateFile, if it is set.
+func (w *Watcher) markSeen(hash transmission.Hash) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.seen[hash] = true
+	if w.cfg.StateFile == "" {
+		return nil
+	}
+	hashes := make([]transmission.Hash, 0, len(w.seen))
+	for h := range w.seen {
+		hashes = append(hashes, h)
+	}
+	slices.Sort(hashes)
+	data, err := json.Marshal(hashes)
+	if err != nil {
+		return err
+	}
+	return atomicfile.Write(w.cfg.StateFile, data, 0o644)
+}
+
+// Run watches the directory until ctx is done and returns ctx.Err(). Errors
+// of individual files are reported via Config.OnResult, Run only fails if the
+// directory can't be scanned.
+func (w *Watcher) Run(ctx context.Context) error {
+	var (
+		n    *notifier
+		wake <-chan struct{}
+	)
+	if w.cfg.Notify {
+		var err error
+		n, err = newNotifier()
+		if err != nil && !errors.Is(err, errors.ErrUnsupported) {
+			return err
+		}
+		if n != nil {
+			defer n.close()
+			wake = n.events()
+		}
+	}
+
+	for {
+		if n != nil {
+			if err := w.watchDirs(n); err != nil {
+				return err
+			}
+		}
+		_, pending, err := w.scan(ctx)
+		if err != nil && ctx.Err() == nil {
+			return err
+		}
+
+		interval := w.cfg.PollInterval
+		if pending && w.cfg.SettleTime < interval {
+			interval = w.cfg.SettleTime
+		}
+		timer := time.NewTimer(interval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		case <-wake:
+			timer.Stop()
+		}
+	}
+}
+
+// Scan processes files in the directory once.
+func (w *Watcher) Scan(ctx context.Context) ([]Result, error) {
+	results, _, err := w.scan(ctx)
+	return results, err
+}
+
+// skipDir returns true if the directory at path is not scanned.
+func (w *Watcher) skipDir(path string, d fs.DirEntry) bool {
+	if path == w.cfg.Root {
+		return false
+	}
+	return strings.HasPrefix(d.Name(), ".") || path == w.cfg.DoneDir || path == w.cfg.FailedDir
+}
+
+func isTorrentFile(name string) bool {
+	ext := strings.ToLower(filepath.Ext(name))
+	return ext == ".torrent" || ext == ".magnet"
+}
+
+// scan processes files in the directory once. It also reports whether some
+// files were skipped because they were modified too recently.
+func (w *Watcher) scan(ctx context.Context) ([]Result, bool, error) {
+	var (
+		paths   []string
+		pending bool
+	)
+	now := time.Now()
+	err := filepath.WalkDir(w.cfg.Root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if w.skipDir(path, d) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() || !isTorrentFile(d.Name()) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		if now.Sub(info.ModTime()) < w.cfg.SettleTime {
+			pending = true
+			return nil
+		}
+		paths = append(paths, path)
+		return nil
+	})
+	if err != nil {
+		return nil, false, err
+	}
+
+	var (
+		results []Result
+		errs    []error
+	)
+	for _, path := range paths {
+		if err := ctx.Err(); err != nil {
+			return results, false, err
+		}
+		res := w.process(ctx, path)
+		if err := ctx.Err(); err != nil {
+			// Leave the file for the next run.
+			return results, false, err
+		}
+		if err := w.finish(path, res); err != nil {
+			errs = append(errs, err)
+		}
+		results = append(results, res)
+		if w.cfg.OnResult != nil {
+			w.cfg.OnResult(res)
+		}
+	}
+	return results, pending, errors.Join(errs...)
+}
+
+// settings returns settings of the torrents in directory dir relative to
+// root.
+func (w *Watcher) settings(dir string) *transmission.AddTorrentReq {
+	req := new(transmission.AddTorrentReq)
+	if w.cfg.Settings != nil {
+		*req = *w.cfg.Settings
+		req.Labels = append([]string(nil), w.cfg.Settings.Labels...)
+	}
+	req.URL, req.Meta = nil, nil
+	if dir == "." {
+		return req
+	}
+
+	dir = filepath.ToSlash(dir)
+	d, ok := w.cfg.Dirs[dir]
+	if !ok {
+		d.Labels = strings.Split(dir, "/")
+	}
+	for _, l := range d.Labels {
+		if !slices.Contains(req.Labels, l) {
+			req.Labels = append(req.Labels, l)
+		}
+	}
+	if d.DownloadDirectory != "" {
+		req.DownloadDirectory = transmission.OptString(d.DownloadDirectory)
+	}
+	return req
+}
+
+func readFile(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	data, err := io.ReadAll(io.LimitReader(f, transmission.DefaultMaxTorrentSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > transmission.DefaultMaxTorrentSize {
+		return nil, fmt.Errorf("watchfolder: file exceeds %d bytes", transmission.DefaultMaxTorrentSize)
+	}
+	return data, nil
+}
+
+func (w *Watcher) process(ctx context.Context, path string) Result {
+	res := Result{Path: path}
+
+	rel, err := filepath.Rel(w.cfg.Root, filepath.Dir(path))
+	if err != nil {
+		res.Err = err
+		return res
+	}
+	req := w.settings(rel)
+
+	data, err := readFile(path)
+	if err != nil {
+		res.Err = err
+		return res
+	}
+	if strings.EqualFold(filepath.Ext(path), ".magnet") {
+		link := strings.TrimSpace(string(data))
+		m, err := transmission.ParseMagnet(link)
+		if err != nil {
+			res.Err = err
+			return res
+		}
+		res.Hash = m.Hash()
+		req.URL = transmission.OptString(link)
+	} else {
+		m, err := metainfo.ParseBytes(data)
+		if err != nil {
+			res.Err = err
+			return res
+		}
+		res.Hash = m.Hash()
+		req.Meta = bytes.NewReader(data)
+	}
+
+	w.mu.Lock()
+	seen := w.seen[res.Hash]
+	w.mu.Unlock()
+	if seen {
+		res.Skipped = true
+		return res
+	}
+
+	if res.Torrent, res.Err = w.adder.AddTorrent(ctx, req); res.Err != nil {
+		return res
+	}
+	if err := w.markSeen(res.Hash); err != nil {
+		res.StateErr = fmt.Errorf("watchfolder: failed to save state: %w", err)
+	}
+	return res
+}
+
+// finish moves processed file to done or failed directory.
+func (w *Watcher) finish(path string, res Result) error {
+	rel, err := filepath.Rel(w.cfg.Root, path)
+	if err != nil {
+		return err
+	}
+	dir := w.cfg.DoneDir
+	if res.Err != nil {
+		dir = w.cfg.FailedDir
+	}
+
+	dst, err := moveFile(path, filepath.Join(dir, rel))
+	if err != nil {
+		return err
+	}
+	if res.Err != nil {
+		return os.WriteFile(dst+ErrorSuffix, []byte(res.Err.Error()+"\n"), 0o644) //nolint:gosec
+	}
+	return nil
+}
+
+// moveFile moves file src to dst. If dst exists, a numeric suffix is added to
+// the file name. It returns the final destination path.
+func moveFile(src, dst string) (string, error) {
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return "", err
+	}
+
+	ext := filepath.Ext(dst)
+	base := strings.TrimSuffix(dst, ext)
+	for i := 1; ; i++ {
+		if _, err := os.Lstat(dst); errors.Is(err, fs.ErrNotExist) {
+			break
+		}
+		dst = base + "." + strconv.Itoa(i) + ext
+	}
+	return dst, os.Rename(src, dst)
+}
+
+func (w *Watcher) watchDirs(n *notifier) error {
+	return filepath.WalkDir(w.cfg.Root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		if w.skipDir(path, d) {
+			return filepath.SkipDir
+		}
+		return n.add(path)
+	})
+}
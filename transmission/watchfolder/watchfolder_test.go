@@ -0,0 +1,292 @@
+package watchfolder
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pborzenkov/go-transmission/transmission"
+	"github.com/pborzenkov/go-transmission/transmission/metainfo"
+)
+
+const testMagnet = "magnet:?xt=urn:btih:c12fe1c06bba254a9dc9f519b335aa7c1367a88a"
+
+type fakeAdder struct {
+	mu   sync.Mutex
+	reqs []*transmission.AddTorrentReq
+	err  error
+}
+
+func (a *fakeAdder) AddTorrent(ctx context.Context, req *transmission.AddTorrentReq) (*transmission.NewTorrent, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.reqs = append(a.reqs, req)
+	if a.err != nil {
+		return nil, a.err
+	}
+	return &transmission.NewTorrent{ID: transmission.ID(len(a.reqs))}, nil
+}
+
+func (a *fakeAdder) count() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return len(a.reqs)
+}
+
+func testTorrent(t *testing.T) []byte {
+	t.Helper()
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "data"), []byte("data"), 0o600); err != nil {
+		t.Fatalf("failed to write data: %v", err)
+	}
+	m, err := (&metainfo.Builder{Path: filepath.Join(dir, "data")}).Build(context.Background())
+	if err != nil {
+		t.Fatalf("failed to build torrent: %v", err)
+	}
+	return m.Bytes()
+}
+
+// dropFile writes a file with modification time in the past, so it is
+// processed immediately.
+func dropFile(t *testing.T, path string, data []byte) {
+	t.Helper()
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatalf("failed to create directory: %v", err)
+	}
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+	past := time.Now().Add(-time.Minute)
+	if err := os.Chtimes(path, past, past); err != nil {
+		t.Fatalf("failed to change file times: %v", err)
+	}
+}
+
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+func TestScan(t *testing.T) {
+	root := t.TempDir()
+	torrent := testTorrent(t)
+	dropFile(t, filepath.Join(root, "tv", "hd", "show.torrent"), torrent)
+	dropFile(t, filepath.Join(root, "movies", "film.magnet"), []byte(testMagnet+"\n"))
+	dropFile(t, filepath.Join(root, "broken.torrent"), []byte("garbage"))
+	dropFile(t, filepath.Join(root, "readme.txt"), []byte("ignored"))
+
+	adder := new(fakeAdder)
+	w, err := New(adder, Config{
+		Root:     root,
+		Dirs:     map[string]Dir{"movies": {Labels: []string{"film"}, DownloadDirectory: "/movies"}},
+		Settings: &transmission.AddTorrentReq{Labels: []string{"watch"}, Paused: transmission.OptBool(true)},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	results, err := w.Scan(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want, got := 3, len(results); want != got {
+		t.Fatalf("unexpected number of results, want = %d, got = %d", want, got)
+	}
+	if want, got := 2, adder.count(); want != got {
+		t.Fatalf("unexpected number of added torrents, want = %d, got = %d", want, got)
+	}
+
+	// Files are processed in lexical order: broken.torrent, movies, tv.
+	if results[0].Err == nil {
+		t.Errorf("expected broken torrent to fail")
+	}
+
+	movie := adder.reqs[0]
+	if want, got := testMagnet, *movie.URL; want != got {
+		t.Errorf("unexpected URL, want = %q, got = %q", want, got)
+	}
+	if want, got := []string{"watch", "film"}, movie.Labels; !cmp.Equal(want, got) {
+		t.Errorf("unexpected labels, diff = \n%s", cmp.Diff(want, got))
+	}
+	if want, got := "/movies", *movie.DownloadDirectory; want != got {
+		t.Errorf("unexpected download directory, want = %q, got = %q", want, got)
+	}
+
+	show := adder.reqs[1]
+	if show.Meta == nil || show.URL != nil {
+		t.Errorf("expected torrent to be added with meta")
+	}
+	if want, got := []string{"watch", "tv", "hd"}, show.Labels; !cmp.Equal(want, got) {
+		t.Errorf("unexpected labels, diff = \n%s", cmp.Diff(want, got))
+	}
+	if show.DownloadDirectory != nil {
+		t.Errorf("expected default download directory")
+	}
+
+	for _, p := range []string{
+		".done/tv/hd/show.torrent",
+		".done/movies/film.magnet",
+		".failed/broken.torrent",
+		".failed/broken.torrent" + ErrorSuffix,
+		"readme.txt",
+	} {
+		if !exists(filepath.Join(root, p)) {
+			t.Errorf("expected %s to exist", p)
+		}
+	}
+
+	// Dropping the same torrent again doesn't add it twice.
+	dropFile(t, filepath.Join(root, "again.torrent"), torrent)
+	results, err = w.Scan(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(results) != 1 || !results[0].Skipped {
+		t.Errorf("expected duplicate to be skipped, got = %+v", results)
+	}
+	if want, got := 2, adder.count(); want != got {
+		t.Errorf("unexpected number of added torrents, want = %d, got = %d", want, got)
+	}
+	if !exists(filepath.Join(root, ".done", "again.torrent")) {
+		t.Errorf("expected skipped file to be moved to done directory")
+	}
+}
+
+func TestScan_stateFile(t *testing.T) {
+	root := t.TempDir()
+	state := filepath.Join(t.TempDir(), "state.json")
+	dropFile(t, filepath.Join(root, "film.magnet"), []byte(testMagnet))
+
+	adder := new(fakeAdder)
+	w, err := New(adder, Config{Root: root, StateFile: state})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := w.Scan(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// A restarted watcher remembers the torrent added before.
+	dropFile(t, filepath.Join(root, "again.magnet"), []byte(testMagnet))
+	if w, err = New(adder, Config{Root: root, StateFile: state}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	results, err := w.Scan(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(results) != 1 || !results[0].Skipped {
+		t.Errorf("expected duplicate to be skipped, got = %+v", results)
+	}
+	if want, got := 1, adder.count(); want != got {
+		t.Errorf("unexpected number of added torrents, want = %d, got = %d", want, got)
+	}
+
+	// A torrent that can't be recorded is still treated as added.
+	dropFile(t, filepath.Join(root, "show.torrent"), testTorrent(t))
+	if w, err = New(adder, Config{Root: root, StateFile: filepath.Join(root, "missing", "state.json")}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if results, err = w.Scan(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(results) != 1 || results[0].Err != nil || results[0].StateErr == nil {
+		t.Errorf("expected state error only, got = %+v", results)
+	}
+	if !exists(filepath.Join(root, ".done", "show.torrent")) {
+		t.Errorf("expected file to be moved to done directory")
+	}
+
+	if err := os.WriteFile(state, []byte("garbage"), 0o600); err != nil {
+		t.Fatalf("failed to write state: %v", err)
+	}
+	if _, err := New(adder, Config{Root: root, StateFile: state}); err == nil {
+		t.Errorf("expected New to fail on malformed state")
+	}
+}
+
+func TestScan_addError(t *testing.T) {
+	root := t.TempDir()
+	dropFile(t, filepath.Join(root, "film.magnet"), []byte(testMagnet))
+	dropFile(t, filepath.Join(root, ".failed", "film.magnet"), []byte(testMagnet))
+
+	w, err := New(&fakeAdder{err: errors.New("daemon is down")}, Config{Root: root})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := w.Scan(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(root, ".failed", "film.1.magnet"+ErrorSuffix))
+	if err != nil {
+		t.Fatalf("failed to read error file: %v", err)
+	}
+	if want, got := "daemon is down\n", string(data); want != got {
+		t.Errorf("unexpected error file contents, want = %q, got = %q", want, got)
+	}
+}
+
+func TestScan_settle(t *testing.T) {
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "film.magnet"), []byte(testMagnet), 0o600); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+
+	adder := new(fakeAdder)
+	w, err := New(adder, Config{Root: root, SettleTime: time.Hour})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := w.Scan(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if adder.count() != 0 {
+		t.Errorf("expected recently modified file to be skipped")
+	}
+}
+
+func TestRun(t *testing.T) {
+	root := t.TempDir()
+
+	adder := new(fakeAdder)
+	done := make(chan Result, 1)
+	w, err := New(adder, Config{
+		Root:         root,
+		PollInterval: 10 * time.Millisecond,
+		SettleTime:   time.Millisecond,
+		Notify:       true,
+		OnResult:     func(r Result) { done <- r },
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errc := make(chan error, 1)
+	go func() { errc <- w.Run(ctx) }()
+
+	dropFile(t, filepath.Join(root, "sub", "film.magnet"), []byte(testMagnet))
+	select {
+	case r := <-done:
+		if r.Err != nil {
+			t.Errorf("unexpected error: %v", r.Err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Errorf("timed out waiting for the file to be processed")
+	}
+
+	cancel()
+	if err := <-errc; !errors.Is(err, context.Canceled) {
+		t.Errorf("unexpected error, want = %v, got = %v", context.Canceled, err)
+	}
+}